@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"iter"
+	"log/slog"
+
+	"github.com/karitham/cls/index"
+)
+
+// The index types under the names the commands use.
+type (
+	AddStats      = index.Stats
+	Progress      = index.Progress
+	ProgressEvent = index.Event
+)
+
+// AddOptions are the options of index runs: how files are added, and what
+// the command records along them.
+type AddOptions struct {
+	index.Options
+	// RecordEnv stores the build environment in the collection metadata
+	// after indexing.
+	RecordEnv bool
+}
+
+// AddDocuments indexes the files of paths into coll. Files on disk are
+// stored under the root identified by rootID, whose location is remembered
+// so their paths resolve to local files, and the run is timed when
+// profiling.
+func AddDocuments(ctx context.Context, coll Collection, paths iter.Seq[string], opts AddOptions, logger *slog.Logger) (AddStats, error) {
+	if opts.FS == nil && opts.Root != "" {
+		root := index.RootDir(opts.Root)
+		opts.RootID = rootID(root)
+		if err := rememberRoot(opts.RootID, root); err != nil {
+			logger.Warn("Failed to record the indexed root", "error", err)
+		}
+	}
+	if profiler != nil {
+		opts.Profiler = profiler
+	}
+
+	return index.AddDocuments(ctx, coll, paths, opts.Options, logger)
+}
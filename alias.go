@@ -0,0 +1,31 @@
+package main
+
+// aliasesState maps the names set with collections alias to the collections
+// they stand for, on this machine.
+const aliasesState = "aliases.json"
+
+func loadAliases() (map[string]string, error) {
+	aliases := map[string]string{}
+	if err := readState(aliasesState, &aliases); err != nil {
+		return nil, err
+	}
+
+	return aliases, nil
+}
+
+// setAlias makes name stand for collection, or removes it when collection
+// is empty.
+func setAlias(name, collection string) error {
+	aliases, err := loadAliases()
+	if err != nil {
+		return err
+	}
+
+	if collection == "" {
+		delete(aliases, name)
+	} else {
+		aliases[name] = collection
+	}
+
+	return writeState(aliasesState, aliases)
+}
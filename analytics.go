@@ -0,0 +1,150 @@
+package main
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"time"
+)
+
+const analyticsState = "analytics.jsonl"
+
+type AnalyticsEvent struct {
+	Kind       string    `json:"kind"`
+	Collection string    `json:"collection"`
+	Query      string    `json:"query"`
+	Results    int       `json:"results,omitempty"`
+	ID         string    `json:"id,omitempty"`
+	Time       time.Time `json:"time"`
+}
+
+const (
+	eventQuery = "query"
+	eventOpen  = "open"
+)
+
+func recordQueryEvent(collection, query string, results int) error {
+	return appendState(analyticsState, AnalyticsEvent{
+		Kind:       eventQuery,
+		Collection: collection,
+		Query:      query,
+		Results:    results,
+		Time:       time.Now().UTC(),
+	})
+}
+
+// recordOpenEvent records that a result of query was acted upon, which is
+// what click-through is computed from.
+func recordOpenEvent(collection, query, id string) error {
+	return appendState(analyticsState, AnalyticsEvent{
+		Kind:       eventOpen,
+		Collection: collection,
+		Query:      query,
+		ID:         id,
+		Time:       time.Now().UTC(),
+	})
+}
+
+type QueryStats struct {
+	Query       string
+	Count       int
+	ZeroResults int
+	Opens       int
+}
+
+func (s QueryStats) ClickThrough() float64 {
+	if s.Count == 0 {
+		return 0
+	}
+
+	return float64(s.Opens) / float64(s.Count)
+}
+
+type AnalyticsReport struct {
+	Collection  string
+	Queries     int
+	ZeroResults int
+	Opens       int
+	Top         []QueryStats
+	Zero        []QueryStats
+}
+
+func BuildAnalyticsReport(events []AnalyticsEvent, collection string, top int) AnalyticsReport {
+	report := AnalyticsReport{Collection: collection}
+
+	byQuery := map[string]*QueryStats{}
+	stats := func(q string) *QueryStats {
+		key := strings.ToLower(strings.TrimSpace(q))
+		s, ok := byQuery[key]
+		if !ok {
+			s = &QueryStats{Query: q}
+			byQuery[key] = s
+		}
+		return s
+	}
+
+	for _, e := range events {
+		if e.Collection != collection {
+			continue
+		}
+
+		s := stats(e.Query)
+		switch e.Kind {
+		case eventQuery:
+			report.Queries++
+			s.Count++
+			if e.Results == 0 {
+				report.ZeroResults++
+				s.ZeroResults++
+			}
+		case eventOpen:
+			report.Opens++
+			s.Opens++
+		}
+	}
+
+	var all []QueryStats
+	for _, s := range byQuery {
+		all = append(all, *s)
+	}
+	slices.SortFunc(all, func(a, b QueryStats) int {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), cmp.Compare(a.Query, b.Query))
+	})
+
+	for _, s := range all {
+		if s.ZeroResults > 0 {
+			report.Zero = append(report.Zero, s)
+		}
+	}
+
+	report.Top = all[:min(top, len(all))]
+	report.Zero = report.Zero[:min(top, len(report.Zero))]
+
+	return report
+}
+
+func (p *Printer) Analytics(r AnalyticsReport) {
+	p.Message("Collection: %s", r.Collection)
+	p.Message("Queries: %d", r.Queries)
+	p.Message("Zero-result queries: %d", r.ZeroResults)
+	p.Message("Opened results: %d", r.Opens)
+
+	section := func(title string, stats []QueryStats) {
+		if len(stats) == 0 {
+			return
+		}
+
+		p.Message("")
+		p.Message("%s:", title)
+		for _, s := range stats {
+			if p.plain {
+				p.Message("query %q count %d zero %d ctr %.2f", s.Query, s.Count, s.ZeroResults, s.ClickThrough())
+				continue
+			}
+			p.Message("  %5d  %3.0f%%  %s", s.Count, s.ClickThrough()*100, s.Query)
+		}
+	}
+
+	section("Most frequent queries", r.Top)
+	section("Queries with no results", r.Zero)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"testing/fstest"
+
+	"github.com/karitham/cls/dirextractor"
+)
+
+// stdinTarget indexes a tar stream read from stdin.
+const stdinTarget = "-"
+
+// isArchive reports whether target is indexed through openArchive rather
+// than walked on disk.
+func isArchive(target string) bool {
+	if target == stdinTarget {
+		return true
+	}
+
+	name := strings.ToLower(target)
+	for _, ext := range []string{".zip", ".tar", ".tar.gz", ".tgz"} {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// archiveName names the root of the documents indexed from target.
+func archiveName(target string) string {
+	if target == stdinTarget {
+		return "stdin"
+	}
+
+	return path.Base(strings.ReplaceAll(target, `\`, "/"))
+}
+
+// openArchive returns the files of the zip or tar archive target, or of the
+// tar stream on stdin, as an fs.FS, and a function releasing it. Tar archives
+// are read in memory as they cannot be seeked into.
+func openArchive(target string) (fs.FS, func() error, error) {
+	if strings.HasSuffix(strings.ToLower(target), ".zip") {
+		r, err := zip.OpenReader(target)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open zip archive: %w", err)
+		}
+		return r, r.Close, nil
+	}
+
+	var in io.ReadCloser = os.Stdin
+	if target != stdinTarget {
+		f, err := os.Open(target)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open archive: %w", err)
+		}
+		in = f
+	}
+	defer in.Close()
+
+	fsys, err := readTar(in)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return fsys, func() error { return nil }, nil
+}
+
+// newFSWalker is newWalker for the files of an archive or crawled site.
+func newFSWalker(fsys fs.FS, opts WalkOptions) (Walker, error) {
+	return dirextractor.NewFS(fsys, ".", opts.filters()...)
+}
+
+// readTar reads the regular files of a tar stream, gzipped or not.
+func readTar(r io.Reader) (fs.FS, error) {
+	buf := bufio.NewReader(r)
+	if magic, _ := buf.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(buf)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read gzip stream: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	} else {
+		r = buf
+	}
+
+	fsys := fstest.MapFS{}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return fsys, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tar archive: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		name := path.Clean(strings.TrimPrefix(hdr.Name, "/"))
+		if !fs.ValidPath(name) {
+			continue
+		}
+
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s from tar archive: %w", name, err)
+		}
+		fsys[name] = &fstest.MapFile{Data: data, Mode: fs.FileMode(hdr.Mode).Perm(), ModTime: hdr.ModTime}
+	}
+}
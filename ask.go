@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+
+	"github.com/karitham/cls/store"
+)
+
+const askSystemPrompt = `You answer questions about a codebase using only the provided context.
+Each context block is numbered. Cite the blocks you rely on with their number in brackets, like [1].
+If the context does not contain the answer, say so instead of guessing.`
+
+type AskOptions struct {
+	Provider      string
+	LLMURL        string
+	APIKey        string
+	Model         string
+	Temperature   float64
+	ContextBudget int
+	TopK          int
+	PackStrategy  string
+	NoSummarize   bool
+	Force         bool
+}
+
+func askDB(chromaOpts ChromaOptions, collection, question string, opts AskOptions, printer *Printer, logger *slog.Logger) {
+	ctx := context.Background()
+
+	client, err := newStore(chromaOpts, logger)
+	if err != nil {
+		logger.Error("Failed to create ChromaDB client", "error", err)
+		os.Exit(1)
+	}
+	defer client.Close()
+
+	coll, err := client.GetCollection(ctx, collection)
+	if err != nil {
+		logger.Error("Failed to get collection", "error", err)
+		os.Exit(1)
+	}
+
+	verifyEmbedder(coll, opts.Force, logger)
+
+	resp, err := coll.Query(ctx, question, store.WithN(opts.TopK))
+	if err != nil {
+		logger.Error("Failed to query collection", "error", err)
+		os.Exit(1)
+	}
+	results := resp.Results
+
+	if len(results) == 0 {
+		printer.Message("No relevant context found")
+		return
+	}
+
+	chat, err := NewChatClient(opts.Provider, opts.LLMURL, opts.APIKey)
+	if err != nil {
+		logger.Error("Failed to create LLM client", "error", err)
+		os.Exit(1)
+	}
+
+	chatOpts := ChatOptions{Model: opts.Model, Temperature: opts.Temperature}
+	trim, err := trimContext(ctx, chat, chatOpts, question, results, opts.ContextBudget, opts.PackStrategy, !opts.NoSummarize, logger)
+	if err != nil {
+		logger.Error("Failed to pack context", "error", err)
+		os.Exit(1)
+	}
+
+	answer, err := chat.Chat(ctx, []ChatMessage{
+		{Role: "system", Content: askSystemPrompt},
+		{Role: "user", Content: buildAskPrompt(question, trim.Sources())},
+	}, chatOpts)
+	if err != nil {
+		logger.Error("Failed to generate answer", "error", err)
+		os.Exit(1)
+	}
+
+	printer.Answer(answer, trim.Sources())
+	printer.ContextTrim(trim)
+}
+
+// buildAskPrompt numbers the context blocks of sources and appends question.
+func buildAskPrompt(question string, sources []QueryResult) string {
+	return formatContext(sources) + "Question: " + question
+}
+
+// estimateTokens approximates the token count of s, assuming about four
+// bytes per token, which holds well enough for English text and code.
+func estimateTokens(s string) int {
+	return (len(s) + 3) / 4
+}
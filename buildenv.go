@@ -0,0 +1,318 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/karitham/cls/buildinfo"
+	"github.com/karitham/cls/index"
+	"github.com/karitham/cls/store"
+)
+
+const (
+	buildEnvKey = "build_env"
+
+	// chunkerVersion names how files are cut into documents. It changes
+	// whenever the same file would give different documents.
+	chunkerVersion = "whole-file/v1+notebook-cells/v1+markdown-sections/v1"
+)
+
+// BuildEnv is everything an index build depends on besides the files, so
+// shared indexes can be rebuilt and checked elsewhere.
+type BuildEnv struct {
+	Version     string `json:"version"`
+	Commit      string `json:"commit"`
+	Embedder    string `json:"embedder"`
+	Model       string `json:"model"`
+	ModelDigest string `json:"model_digest,omitempty"`
+	Chunker     string `json:"chunker"`
+	Secrets     string `json:"secrets"`
+	SecretRules int    `json:"secret_rules"`
+	// IncludeGenerated and Languages are the file filters of the build.
+	IncludeGenerated bool     `json:"include_generated"`
+	Languages        []string `json:"languages,omitempty"`
+	// Records is set when datasets were split into records.
+	Records *index.RecordOptions `json:"records,omitempty"`
+	// Ignore holds the sha256 of the ignore files at the root of the tree.
+	Ignore map[string]string `json:"ignore,omitempty"`
+}
+
+// captureBuildEnv describes a build of root with opts. The model digest is
+// left out when the embedder cannot be reached.
+func captureBuildEnv(ctx context.Context, root string, opts AddOptions, logger *slog.Logger) BuildEnv {
+	info := buildinfo.Get()
+	env := BuildEnv{
+		Version:          info.Version,
+		Commit:           info.Commit,
+		Embedder:         store.DefaultEmbedder,
+		Model:            store.DefaultEmbedderModel,
+		Chunker:          chunkerVersion,
+		Secrets:          secretsAction(opts.Secrets),
+		IncludeGenerated: opts.IncludeGenerated,
+		Languages:        opts.Languages,
+		Records:          opts.Records,
+	}
+	if opts.Secrets.Scanner != nil {
+		env.SecretRules = len(opts.Secrets.Scanner.Rules())
+	}
+
+	digest, err := ollamaModelDigest(ctx, store.DefaultEmbedderURL, store.DefaultEmbedderModel)
+	if err != nil {
+		logger.Warn("Failed to get the embedding model digest", "error", err)
+	}
+	env.ModelDigest = digest
+
+	if fi, err := os.Stat(root); err == nil && !fi.IsDir() {
+		root = filepath.Dir(root)
+	}
+	for _, name := range []string{".gitignore", ".clsignore", ".cls.toml"} {
+		if data, err := os.ReadFile(filepath.Join(root, name)); err == nil {
+			if env.Ignore == nil {
+				env.Ignore = map[string]string{}
+			}
+			env.Ignore[name] = sha256Hex(data)
+		}
+	}
+
+	return env
+}
+
+func secretsAction(p index.SecretPolicy) string {
+	if p.Action == "" {
+		return index.SecretsOff
+	}
+
+	return p.Action
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// ollamaModelDigest returns the digest of the model pulled on the Ollama
+// server at baseURL.
+func ollamaModelDigest(ctx context.Context, baseURL, model string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"/api/tags", nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return "", fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	var tags struct {
+		Models []struct {
+			Name   string `json:"name"`
+			Digest string `json:"digest"`
+		} `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+		return "", fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	for _, m := range tags.Models {
+		if m.Name == model || m.Name == model+":latest" {
+			return m.Digest, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w: %s", errModelNotPulled, model)
+}
+
+// recordBuildEnv stores env in the metadata of collection.
+func recordBuildEnv(ctx context.Context, client ChromaClient, collection string, env BuildEnv) error {
+	data, err := json.Marshal(env)
+	if err != nil {
+		return fmt.Errorf("failed to encode build environment: %w", err)
+	}
+
+	return client.SetMetadata(ctx, collection, buildEnvKey, string(data))
+}
+
+// collectionBuildEnv returns the build environment recorded in md.
+func collectionBuildEnv(md map[string]any) (BuildEnv, bool, error) {
+	raw, ok := md[buildEnvKey].(string)
+	if !ok {
+		return BuildEnv{}, false, nil
+	}
+
+	var env BuildEnv
+	if err := json.Unmarshal([]byte(raw), &env); err != nil {
+		return BuildEnv{}, false, fmt.Errorf("failed to decode build environment: %w", err)
+	}
+
+	return env, true, nil
+}
+
+// envDiff lists the settings of want that have a different value in got.
+func envDiff(want, got BuildEnv) []string {
+	var diff []string
+	check := func(name, a, b string) {
+		if a != b {
+			diff = append(diff, fmt.Sprintf("%s: recorded %q, now %q", name, a, b))
+		}
+	}
+
+	check("version", want.Version, got.Version)
+	check("embedder", want.Embedder, got.Embedder)
+	check("model", want.Model, got.Model)
+	if want.ModelDigest != "" && got.ModelDigest != "" {
+		check("model digest", want.ModelDigest, got.ModelDigest)
+	}
+	check("chunker", want.Chunker, got.Chunker)
+	for name, sum := range want.Ignore {
+		check(name, sum, got.Ignore[name])
+	}
+
+	return diff
+}
+
+// ReproducibleReport is the outcome of re-chunking a sample of a collection.
+type ReproducibleReport struct {
+	Sampled   int      `json:"sampled"`
+	Identical int      `json:"identical"`
+	Missing   int      `json:"missing"`
+	Differ    []string `json:"differ,omitempty"`
+	EnvDiff   []string `json:"env_diff,omitempty"`
+}
+
+// verifyReproducible chunks again the files of up to sample documents of
+// coll, picked at random, and compares the chunk hashes with the stored
+// ones.
+func verifyReproducible(ctx context.Context, coll Collection, env BuildEnv, sample int) (ReproducibleReport, error) {
+	var records []Record
+	for r, err := range coll.Records(ctx) {
+		if err != nil {
+			return ReproducibleReport{}, err
+		}
+		records = append(records, r)
+	}
+
+	rand.Shuffle(len(records), func(i, j int) { records[i], records[j] = records[j], records[i] })
+	records = records[:min(sample, len(records))]
+
+	policy := index.SecretPolicy{Action: env.Secrets}
+	if env.Secrets == index.SecretsMask {
+		scanner, err := index.NewSecretScanner("")
+		if err != nil {
+			return ReproducibleReport{}, err
+		}
+		policy.Scanner = scanner
+	}
+
+	var report ReproducibleReport
+	for _, r := range records {
+		result := index.Result(r, localPath)
+		if result.SummaryOf != "" {
+			continue // summaries are written by an LLM, not reproducible
+		}
+		report.Sampled++
+
+		content, err := chunkFile(result.Path, chunkIndex(r.ID), policy, env.Records)
+		if err != nil {
+			report.Missing++
+			continue
+		}
+
+		if sha256Hex([]byte(content)) == sha256Hex([]byte(r.Document)) {
+			report.Identical++
+		} else {
+			report.Differ = append(report.Differ, result.Path)
+		}
+	}
+	slices.Sort(report.Differ)
+
+	return report, nil
+}
+
+// chunkFile returns the document indexing the file at path would store as
+// its chunk.
+func chunkFile(path string, chunk int, policy index.SecretPolicy, records *index.RecordOptions) (string, error) {
+	data, err := index.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	if policy.Action == index.SecretsMask && policy.Scanner != nil {
+		if findings := policy.Scanner.Scan(data); len(findings) > 0 {
+			data = policy.Scanner.Mask(data, findings)
+		}
+	}
+
+	chunks, err := index.Options{Records: records}.Chunk(path, data, strings.Count(data, "\n"))
+	if err != nil {
+		return "", err
+	}
+	if chunk >= len(chunks) {
+		return "", fmt.Errorf("%s has no chunk %d", path, chunk)
+	}
+
+	return chunks[chunk].Text, nil
+}
+
+func verifyCommand(chromaOpts ChromaOptions, collection string, sample int, printer *Printer, logger *slog.Logger) {
+	ctx := context.Background()
+
+	client, err := newStore(chromaOpts, logger)
+	if err != nil {
+		logger.Error("Failed to create ChromaDB client", "error", err)
+		os.Exit(1)
+	}
+	defer client.Close()
+
+	coll, err := client.GetCollection(ctx, collection)
+	if err != nil {
+		logger.Error("Failed to get collection", "error", err)
+		os.Exit(1)
+	}
+
+	env, ok, err := collectionBuildEnv(coll.Metadata())
+	if err != nil {
+		logger.Error("Failed to read build environment", "error", err)
+		os.Exit(1)
+	}
+	if !ok {
+		logger.Error("Collection has no recorded build environment, index it with -record-env", "collection", collection)
+		os.Exit(1)
+	}
+	if env.SecretRules > 0 && env.Secrets == index.SecretsMask && env.SecretRules != len(index.DefaultSecretRules) {
+		printer.Warning("The collection was built with custom secret rules, masked files may not match")
+	}
+
+	report, err := verifyReproducible(ctx, coll, env, sample)
+	if err != nil {
+		logger.Error("Failed to verify collection", "error", err)
+		os.Exit(1)
+	}
+	report.EnvDiff = envDiff(env, captureBuildEnv(ctx, projectRoot("."), AddOptions{}, logger))
+
+	for _, d := range report.EnvDiff {
+		printer.Warning("Build environment differs, %s", d)
+	}
+	printer.Message("Sampled %d documents: %d identical, %d differ, %d missing files", report.Sampled, report.Identical, len(report.Differ), report.Missing)
+	for _, p := range report.Differ {
+		printer.Message("  differs: %s", p)
+	}
+	if len(report.Differ) > 0 {
+		logger.Error("Collection is not reproducible from the current tree", "differ", strings.Join(report.Differ, ", "))
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,136 @@
+// Package buildinfo describes the running binary: its version, the commit it
+// was built from and the optional features compiled into it.
+package buildinfo
+
+import (
+	"fmt"
+	"maps"
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"sync"
+)
+
+// These are set at link time, e.g.
+//
+//	go build -ldflags "-X github.com/karitham/cls/buildinfo.Version=v1.2.3"
+//
+// When left empty they are filled from the module and VCS information Go
+// embeds in the binary.
+var (
+	Version = ""
+	Commit  = ""
+	Date    = ""
+)
+
+type Info struct {
+	Version   string          `json:"version"`
+	Commit    string          `json:"commit"`
+	Date      string          `json:"date"`
+	GoVersion string          `json:"go_version"`
+	Platform  string          `json:"platform"`
+	Features  map[string]bool `json:"features"`
+}
+
+var (
+	mu       sync.Mutex
+	features = map[string]bool{}
+)
+
+// RegisterFeature records whether an optional feature is compiled in. It is
+// meant to be called from init functions of files guarded by build tags.
+func RegisterFeature(name string, enabled bool) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	features[name] = features[name] || enabled
+}
+
+// HasFeature reports whether the named feature is compiled in.
+func HasFeature(name string) bool {
+	mu.Lock()
+	defer mu.Unlock()
+
+	return features[name]
+}
+
+// FeatureNames returns the names of all known features, sorted.
+func FeatureNames() []string {
+	mu.Lock()
+	defer mu.Unlock()
+
+	return slices.Sorted(maps.Keys(features))
+}
+
+// Get returns the build information of the running binary.
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		Date:      Date,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		if info.Version == "" && bi.Main.Version != "" {
+			info.Version = bi.Main.Version
+		}
+
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = s.Value
+				}
+			case "vcs.time":
+				if info.Date == "" {
+					info.Date = s.Value
+				}
+			case "vcs.modified":
+				if s.Value == "true" && info.Commit != "" && Commit == "" {
+					info.Commit += "-dirty"
+				}
+			case "CGO_ENABLED":
+				RegisterFeature("cgo", s.Value == "1")
+			}
+		}
+	}
+
+	if info.Version == "" {
+		info.Version = "(devel)"
+	}
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+	if info.Date == "" {
+		info.Date = "unknown"
+	}
+
+	mu.Lock()
+	info.Features = maps.Clone(features)
+	mu.Unlock()
+
+	return info
+}
+
+// MissingFeatureError is returned by Require when an optional feature was
+// not compiled into the binary.
+type MissingFeatureError struct {
+	Feature string
+	Tag     string
+}
+
+func (e *MissingFeatureError) Error() string {
+	return fmt.Sprintf("cls was built without %s support, rebuild with -tags %s", e.Feature, e.Tag)
+}
+
+// Require returns a *MissingFeatureError unless the named feature, enabled by
+// the build tag tag, is compiled in.
+func Require(name, tag string) error {
+	if HasFeature(name) {
+		return nil
+	}
+
+	return &MissingFeatureError{Feature: name, Tag: tag}
+}
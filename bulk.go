@@ -0,0 +1,185 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"github.com/karitham/cls/query"
+)
+
+// bulkCommand applies an action to several results of the last query at once.
+// Results are selected by number or id, or with "all".
+func bulkCommand(chromaOpts ChromaOptions, args []string, printer *Printer, logger *slog.Logger) {
+	if len(args) < 1 {
+		logger.Error("Please provide an action: pack, open, markdown or exclude")
+		os.Exit(1)
+	}
+
+	fs := flag.NewFlagSet("bulk "+args[0], flag.ExitOnError)
+	out := fs.String("out", "", "Write to this file instead of stdout (markdown)")
+	clearExcluded := fs.Bool("clear", false, "Clear the paths excluded from queries (exclude)")
+	fs.Parse(args[1:])
+
+	project := resolveCollection(autoCollection, projectRoot("."))
+	if args[0] == "exclude" && *clearExcluded {
+		if err := updateSession(project, func(s *Session) { s.Excluded = nil }); err != nil {
+			logger.Error("Failed to save session", "error", err)
+			os.Exit(1)
+		}
+		printer.Message("Cleared excluded paths")
+		return
+	}
+
+	last, selected, err := selectResults(fs.Args())
+	if err != nil {
+		logger.Error("Failed to select results", "error", err)
+		os.Exit(1)
+	}
+
+	switch action := args[0]; action {
+	case "exclude":
+		err := updateSession(project, func(s *Session) {
+			for _, r := range selected {
+				if !slices.Contains(s.Excluded, r.Path) {
+					s.Excluded = append(s.Excluded, r.Path)
+				}
+			}
+		})
+		if err != nil {
+			logger.Error("Failed to save session", "error", err)
+			os.Exit(1)
+		}
+		printer.Message("Excluded %d paths from future queries", len(selected))
+	case "open":
+		editor := cmp.Or(os.Getenv("VISUAL"), os.Getenv("EDITOR"), "vi")
+		var paths []string
+		for _, r := range selected {
+			paths = append(paths, r.Path)
+		}
+
+		cmd := exec.Command(editor, paths...)
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+		if err := cmd.Run(); err != nil {
+			logger.Error("Failed to open editor", "editor", editor, "error", err)
+			os.Exit(1)
+		}
+	case "pack", "markdown":
+		docs, err := fetchResults(chromaOpts, last.Collection, selected, logger)
+		if err != nil {
+			logger.Error("Failed to get documents", "error", err)
+			os.Exit(1)
+		}
+
+		if action == "pack" {
+			printer.Message("%s", strings.TrimRight(formatContext(docs), "\n"))
+			return
+		}
+
+		w := io.Writer(os.Stdout)
+		if *out != "" {
+			f, err := os.Create(*out)
+			if err != nil {
+				logger.Error("Failed to create output file", "error", err)
+				os.Exit(1)
+			}
+			defer f.Close()
+			w = f
+		}
+
+		if err := writeMarkdown(w, last.Query, docs); err != nil {
+			logger.Error("Failed to export markdown", "error", err)
+			os.Exit(1)
+		}
+		if *out != "" {
+			printer.Message("Exported %d results to %s", len(docs), *out)
+		}
+	default:
+		logger.Error("Unknown bulk action", "action", action)
+		os.Exit(1)
+	}
+}
+
+// selectResults resolves refs against the last query. "all" selects every
+// result.
+func selectResults(refs []string) (LastQuery, []LastQueryResult, error) {
+	if len(refs) == 0 {
+		return LastQuery{}, nil, fmt.Errorf("no results selected")
+	}
+
+	var (
+		last     LastQuery
+		selected []LastQueryResult
+	)
+	for _, ref := range refs {
+		if ref == "all" {
+			if err := readState(lastQueryState, &last); err != nil {
+				return last, nil, err
+			}
+			return last, last.Results, nil
+		}
+
+		l, r, err := resolveResult(ref)
+		if err != nil {
+			return l, nil, err
+		}
+		last = l
+		selected = append(selected, r)
+	}
+
+	return last, selected, nil
+}
+
+func fetchResults(chromaOpts ChromaOptions, collection string, selected []LastQueryResult, logger *slog.Logger) ([]QueryResult, error) {
+	ctx := context.Background()
+
+	client, err := newStore(chromaOpts, logger)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	coll, err := client.GetCollection(ctx, collection)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, r := range selected {
+		ids = append(ids, r.ID)
+	}
+
+	docs, err := coll.Get(ctx, ids...)
+	if err != nil {
+		return nil, err
+	}
+
+	// keep the selection order
+	slices.SortStableFunc(docs, func(a, b QueryResult) int {
+		return slices.Index(ids, a.ID) - slices.Index(ids, b.ID)
+	})
+
+	return docs, nil
+}
+
+func writeMarkdown(w io.Writer, title string, docs []QueryResult) error {
+	if _, err := fmt.Fprintf(w, "# %s\n", title); err != nil {
+		return err
+	}
+
+	for _, d := range docs {
+		_, err := fmt.Fprintf(w, "\n## %s\n\n%s\n", d.Path, codeFence(d.Content, query.Language(d)))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"log/slog"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/karitham/cls/index"
+	"github.com/karitham/cls/store"
+)
+
+var errReadOnly = errors.New("bundles are read-only")
+
+// bundleCollection is a snapshot loaded in memory and searched by brute
+// force, so shared indexes can be queried without a running ChromaDB.
+type bundleCollection struct {
+	header  SnapshotHeader
+	records []Record
+	ef      store.Embedder
+}
+
+// OpenBundle loads the snapshot at path as a read-only Collection.
+func OpenBundle(path string) (*bundleCollection, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bundle: %w", err)
+	}
+	defer f.Close()
+
+	snap, err := NewSnapshotReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer snap.Close()
+
+	ef, err := newEmbedder()
+	if err != nil {
+		return nil, err
+	}
+
+	b := &bundleCollection{header: snap.Header, ef: ef}
+	for {
+		r, err := snap.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		b.records = append(b.records, r)
+	}
+
+	return b, nil
+}
+
+// Writer is nil, bundles being read-only.
+func (b *bundleCollection) Writer() store.Writer {
+	return nil
+}
+
+// Query ranks every record by squared L2 distance to the query, the default
+// distance of ChromaDB collections, so distances and calibrated cutoffs mean
+// the same for bundles.
+func (b *bundleCollection) Query(ctx context.Context, text string, opts ...QueryOption) (QueryResponse, error) {
+	q := store.NewRequest(text, opts...)
+
+	var (
+		groups    [][]QueryResult
+		first     []float32
+		negatives [][]float32
+	)
+	for _, t := range q.Negatives {
+		emb, err := b.ef.EmbedQuery(ctx, t)
+		if err != nil {
+			return QueryResponse{}, fmt.Errorf("%w: %w", store.ErrEmbed, err)
+		}
+		negatives = append(negatives, emb)
+	}
+	for _, t := range q.Texts() {
+		emb, err := b.ef.EmbedQuery(ctx, t)
+		if err != nil {
+			return QueryResponse{}, fmt.Errorf("%w: %w", store.ErrEmbed, err)
+		}
+		qe := emb
+		if first == nil {
+			first = qe
+		}
+
+		results := b.nearest(qe, q)
+		groups = append(groups, results[:min(q.Fetch(), len(results))])
+	}
+	groups, err := store.ResolveSummaries(ctx, b, q.InTarget(groups))
+	if err != nil {
+		return QueryResponse{}, err
+	}
+
+	results, err := q.Finish(ctx, q.Penalize(store.Fuse(groups), negatives))
+	if err != nil {
+		return QueryResponse{}, err
+	}
+
+	resp := QueryResponse{Results: results}
+	if q.IncludeEmbeddings {
+		resp.Embedding = first
+	}
+
+	return resp, nil
+}
+
+// nearest returns the records matching q sorted by distance to qe.
+func (b *bundleCollection) nearest(qe []float32, q QueryRequest) []QueryResult {
+	var results []QueryResult
+	for _, r := range b.records {
+		if len(r.Embedding) != len(qe) || !q.Matches(r.Metadata) {
+			continue
+		}
+
+		result := index.Result(r, localPath)
+		result.Distance = squaredL2(qe, r.Embedding)
+		if q.Embeddings() {
+			result.Embedding = r.Embedding
+		}
+		results = append(results, result)
+	}
+
+	slices.SortStableFunc(results, func(a, b QueryResult) int {
+		return cmp.Compare(a.Distance, b.Distance)
+	})
+
+	return results
+}
+
+func (b *bundleCollection) KeywordSearch(ctx context.Context, terms []string, n int) ([]QueryResult, error) {
+	var results []QueryResult
+	for _, r := range b.records {
+		score := store.KeywordScore(r.Document, terms)
+		if _, summary := r.Metadata[index.SummaryOfKey]; score == 0 || summary {
+			continue
+		}
+
+		result := index.Result(r, localPath)
+		result.Score = score
+		results = append(results, result)
+	}
+
+	slices.SortStableFunc(results, func(a, b QueryResult) int {
+		return cmp.Compare(b.Score, a.Score)
+	})
+
+	return results[:min(n, len(results))], nil
+}
+
+func (b *bundleCollection) Get(ctx context.Context, ids ...string) ([]QueryResult, error) {
+	var results []QueryResult
+	for _, r := range b.records {
+		if slices.Contains(ids, r.ID) {
+			results = append(results, index.Result(r, localPath))
+		}
+	}
+
+	return results, nil
+}
+
+func (b *bundleCollection) Count(ctx context.Context) (int, error) {
+	return len(b.records), nil
+}
+
+func (b *bundleCollection) DeleteIndexedBefore(ctx context.Context, t time.Time) (int, error) {
+	return 0, errReadOnly
+}
+
+func (b *bundleCollection) DeleteRun(ctx context.Context, id string) (int, error) {
+	return 0, errReadOnly
+}
+
+func (b *bundleCollection) DeletePaths(ctx context.Context, rootID string, paths []string) (int, error) {
+	return 0, errReadOnly
+}
+
+func (b *bundleCollection) Delete(ctx context.Context, ids ...string) error {
+	return errReadOnly
+}
+
+func (b *bundleCollection) Metadata() map[string]any {
+	return b.header.Metadata
+}
+
+func (b *bundleCollection) Records(ctx context.Context) iter.Seq2[Record, error] {
+	return func(yield func(Record, error) bool) {
+		for _, r := range b.records {
+			if !yield(r, nil) {
+				return
+			}
+		}
+	}
+}
+
+func (b *bundleCollection) AddRecords(ctx context.Context, records []Record) error {
+	return errReadOnly
+}
+
+func squaredL2(a, b []float32) float64 {
+	var d float64
+	for i := range a {
+		x := float64(a[i] - b[i])
+		d += x * x
+	}
+
+	return d
+}
+
+// queryBundle searches the bundle at path as query does a collection.
+func queryBundle(path, query string, opts QueryOptions, printer *Printer, logger *slog.Logger) {
+	ctx := context.Background()
+
+	coll, err := OpenBundle(path)
+	if err != nil {
+		logger.Error("Failed to load bundle", "error", err)
+		os.Exit(1)
+	}
+
+	runQuery(ctx, coll, coll.header.Collection, query, opts, printer, logger)
+}
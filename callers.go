@@ -0,0 +1,63 @@
+package main
+
+import (
+	"log/slog"
+	"slices"
+	"strings"
+)
+
+// Callers returns the call sites of the functions defined in the document
+// id, skipping the definitions themselves.
+func (ix *SymbolIndex) Callers(id string) []Symbol {
+	defined := map[string]bool{}
+	definitions := map[Symbol]bool{}
+	for _, symbols := range ix.Files {
+		for _, s := range symbols {
+			if s.ID == id && (s.Kind == "function" || s.Kind == "method") {
+				defined[s.Name] = true
+			}
+		}
+	}
+	if len(defined) == 0 {
+		return nil
+	}
+	for _, symbols := range ix.Files {
+		for _, s := range symbols {
+			if defined[s.Name] {
+				definitions[Symbol{Root: s.Root, Path: s.Path, Line: s.Line}] = true
+			}
+		}
+	}
+
+	var callers []Symbol
+	for _, calls := range ix.Calls {
+		for _, c := range calls {
+			if defined[c.Name] && c.ID != id && !definitions[Symbol{Root: c.Root, Path: c.Path, Line: c.Line}] {
+				callers = append(callers, c)
+			}
+		}
+	}
+	slices.SortFunc(callers, func(a, b Symbol) int {
+		if c := strings.Compare(a.Path, b.Path); c != 0 {
+			return c
+		}
+		return a.Line - b.Line
+	})
+
+	return callers
+}
+
+// addCallers lists up to n call sites of the functions defined in each
+// result.
+func addCallers(collection string, results []QueryResult, n int, logger *slog.Logger) {
+	ix, err := loadSymbols(collection)
+	if err != nil {
+		logger.Warn("Failed to load symbol index, not listing callers", "error", err)
+		return
+	}
+
+	for i := range results {
+		callers := ix.Callers(results[i].ID)
+		results[i].Callers = callers[:min(n, len(callers))]
+	}
+}
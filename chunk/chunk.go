@@ -0,0 +1,56 @@
+// Package chunk splits files into the chunks indexed as documents, by their
+// structure for the formats with one, such as markdown sections or notebook
+// cells, and whole otherwise.
+package chunk
+
+import (
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Chunk is a part of a file indexed as its own document.
+type Chunk struct {
+	Text string
+	// StartLine and EndLine are the lines of the file the chunk spans, zero
+	// when they mean nothing, as for notebook cells.
+	StartLine, EndLine int
+	// Language overrides the language of the file, when set.
+	Language string
+	// Metadata is added to the metadata of the file on the chunk document.
+	Metadata map[string]any
+}
+
+// Func splits the content of a file into chunks.
+type Func func(content string) ([]Chunk, error)
+
+// chunkers split the files of formats with a structure into chunks, keyed by
+// extension. Other files are a single chunk.
+var chunkers = map[string]Func{
+	".md":       Markdown,
+	".markdown": Markdown,
+	".ipynb":    Notebook,
+}
+
+// Register makes fn split the files with extension ext, replacing the
+// chunker of ext if any. It is not safe to call while files are split.
+func Register(ext string, fn Func) {
+	chunkers[strings.ToLower(ext)] = fn
+}
+
+// Extensions returns the extensions with a chunker, sorted.
+func Extensions() []string {
+	return slices.Sorted(maps.Keys(chunkers))
+}
+
+// Split splits content, the text of the file at path spanning lines lines,
+// into the chunks indexed for it.
+func Split(path, content string, lines int) ([]Chunk, error) {
+	fn, ok := chunkers[strings.ToLower(filepath.Ext(path))]
+	if !ok {
+		return []Chunk{{Text: content, StartLine: 1, EndLine: max(lines, 1)}}, nil
+	}
+
+	return fn(content)
+}
@@ -0,0 +1,194 @@
+package chunk
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Metadata keys of markdown documents. Tags are stored comma separated as
+// metadata values cannot be lists.
+const (
+	TitleKey   = "title"
+	TagsKey    = "tags"
+	SectionKey = "section"
+)
+
+// SectionSeparator joins the headings of a section breadcrumb.
+const SectionSeparator = " > "
+
+var (
+	atxHeading = regexp.MustCompile(`^ {0,3}(#{1,6})[ \t]+(.*?)(?:[ \t]+#+)?[ \t]*$`)
+	fenceLine  = regexp.MustCompile("^ {0,3}(```|~~~)")
+)
+
+// Frontmatter is what is kept of the YAML frontmatter of a markdown file.
+type Frontmatter struct {
+	Title string
+	Tags  []string
+}
+
+func (f Frontmatter) metadata() map[string]any {
+	md := map[string]any{}
+	if f.Title != "" {
+		md[TitleKey] = f.Title
+	}
+	if len(f.Tags) > 0 {
+		md[TagsKey] = strings.Join(f.Tags, ",")
+	}
+
+	return md
+}
+
+// parseFrontmatter splits the frontmatter off content and returns it with
+// the number of lines it spans. Only the title and tags keys are read, tags
+// given either as a flow list, a block list or a comma separated string.
+func parseFrontmatter(content string) (Frontmatter, int) {
+	lines := strings.Split(content, "\n")
+	if len(lines) == 0 || strings.TrimRight(lines[0], "\r ") != "---" {
+		return Frontmatter{}, 0
+	}
+
+	end := -1
+	for i := 1; i < len(lines); i++ {
+		if l := strings.TrimRight(lines[i], "\r "); l == "---" || l == "..." {
+			end = i
+			break
+		}
+	}
+	if end < 0 {
+		return Frontmatter{}, 0
+	}
+
+	var fm Frontmatter
+	inTags := false
+	for _, line := range lines[1:end] {
+		line = strings.TrimRight(line, "\r")
+		if inTags {
+			if item, ok := strings.CutPrefix(strings.TrimSpace(line), "- "); ok {
+				fm.Tags = append(fm.Tags, yamlScalar(item))
+				continue
+			}
+			inTags = false
+		}
+		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.TrimSpace(key) {
+		case "title":
+			fm.Title = yamlScalar(value)
+		case "tags", "keywords":
+			switch {
+			case value == "":
+				inTags = true
+			case strings.HasPrefix(value, "["):
+				fm.Tags = append(fm.Tags, splitTags(strings.Trim(value, "[]"))...)
+			default:
+				fm.Tags = append(fm.Tags, splitTags(value)...)
+			}
+		}
+	}
+
+	return fm, end + 1
+}
+
+// yamlScalar returns the value of a plain or quoted YAML scalar.
+func yamlScalar(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) > 1 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	if i := strings.Index(s, " #"); i >= 0 {
+		s = strings.TrimSpace(s[:i])
+	}
+
+	return s
+}
+
+func splitTags(s string) []string {
+	var tags []string
+	for _, t := range strings.Split(s, ",") {
+		if t = yamlScalar(t); t != "" {
+			tags = append(tags, t)
+		}
+	}
+
+	return tags
+}
+
+// Markdown makes a chunk of every section of a markdown file, from a
+// heading to the next one, with the breadcrumb of the headings it is under.
+// The frontmatter is stored on every chunk rather than indexed as text.
+func Markdown(content string) ([]Chunk, error) {
+	fm, skip := parseFrontmatter(content)
+	lines := strings.Split(content, "\n")
+
+	type section struct {
+		crumb string
+		start int
+	}
+	var (
+		chunks   []Chunk
+		headings []string
+		current  = section{start: skip}
+		fenced   string
+	)
+	flush := func(end int) {
+		for end > current.start && strings.TrimSpace(lines[end-1]) == "" {
+			end--
+		}
+		text := strings.Join(lines[current.start:end], "\n")
+		if strings.TrimSpace(text) == "" {
+			return
+		}
+
+		md := fm.metadata()
+		if current.crumb != "" {
+			md[SectionKey] = current.crumb
+		}
+		chunks = append(chunks, Chunk{
+			Text:      text,
+			StartLine: current.start + 1,
+			EndLine:   end,
+			Metadata:  md,
+		})
+	}
+
+	for i := skip; i < len(lines); i++ {
+		if m := fenceLine.FindStringSubmatch(lines[i]); m != nil {
+			switch fenced {
+			case "":
+				fenced = m[1]
+			case m[1]:
+				fenced = ""
+			}
+			continue
+		}
+		if fenced != "" {
+			continue
+		}
+
+		m := atxHeading.FindStringSubmatch(strings.TrimRight(lines[i], "\r"))
+		if m == nil {
+			continue
+		}
+
+		flush(i)
+		level := len(m[1])
+		headings = append(headings[:min(level-1, len(headings))], strings.TrimSpace(m[2]))
+		current = section{crumb: strings.Join(headings, SectionSeparator), start: i}
+	}
+	flush(len(lines))
+
+	if len(chunks) == 0 {
+		// only frontmatter, index it whole so the file can still be found
+		chunks = append(chunks, Chunk{Text: content, StartLine: 1, EndLine: max(len(lines)-1, 1), Metadata: fm.metadata()})
+	}
+
+	return chunks, nil
+}
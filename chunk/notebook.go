@@ -0,0 +1,89 @@
+package chunk
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Metadata keys of notebook cells.
+const (
+	CellIndexKey = "cell_index"
+	CellTypeKey  = "cell_type"
+)
+
+// notebookSource is a cell source, stored either as one string or as a list
+// of lines.
+type notebookSource string
+
+func (s *notebookSource) UnmarshalJSON(data []byte) error {
+	var lines []string
+	if err := json.Unmarshal(data, &lines); err == nil {
+		*s = notebookSource(strings.Join(lines, ""))
+		return nil
+	}
+
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return err
+	}
+	*s = notebookSource(text)
+
+	return nil
+}
+
+// Notebook makes a chunk of every code and markdown cell of a Jupyter
+// notebook. Outputs, often base64 images, are left out.
+func Notebook(content string) ([]Chunk, error) {
+	var nb struct {
+		Metadata struct {
+			LanguageInfo struct {
+				Name string `json:"name"`
+			} `json:"language_info"`
+			KernelSpec struct {
+				Language string `json:"language"`
+			} `json:"kernelspec"`
+		} `json:"metadata"`
+		Cells []struct {
+			Type   string         `json:"cell_type"`
+			Source notebookSource `json:"source"`
+		} `json:"cells"`
+	}
+	if err := json.Unmarshal([]byte(content), &nb); err != nil {
+		return nil, fmt.Errorf("failed to parse notebook: %w", err)
+	}
+
+	codeLanguage := strings.ToLower(nb.Metadata.LanguageInfo.Name)
+	if codeLanguage == "" {
+		codeLanguage = strings.ToLower(nb.Metadata.KernelSpec.Language)
+	}
+
+	var chunks []Chunk
+	for i, cell := range nb.Cells {
+		text := strings.TrimSpace(string(cell.Source))
+		if text == "" {
+			continue
+		}
+
+		var lang string
+		switch cell.Type {
+		case "code":
+			lang = codeLanguage
+		case "markdown":
+			lang = "markdown"
+		default:
+			continue
+		}
+
+		chunks = append(chunks, Chunk{
+			Text:     text,
+			Language: lang,
+			Metadata: map[string]any{
+				CellIndexKey: int64(i),
+				CellTypeKey:  cell.Type,
+			},
+		})
+	}
+
+	return chunks, nil
+}
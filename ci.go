@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// ciMode is set by -ci: prompts are never shown, index and diff-index print
+// their summary as JSON, also written to the GitHub Actions step summary,
+// and runs where only some files failed exit with exitPartial.
+var ciMode bool
+
+// exitPartial is the exit status of index runs in CI mode where some files
+// or documents failed while others were indexed. Runs where nothing could be
+// indexed exit with 1 as other errors.
+const exitPartial = 4
+
+// JSON writes v as indented JSON.
+func (p *Printer) JSON(v any) error {
+	enc := json.NewEncoder(p.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// ciExit exits with the status of a CI run that added documents and saw
+// failed ones, returning when nothing failed.
+func ciExit(added, failed int) {
+	switch {
+	case failed == 0:
+		return
+	case added == 0:
+		os.Exit(1)
+	default:
+		os.Exit(exitPartial)
+	}
+}
+
+// ciSummary prints summary as JSON and appends markdown to the step summary
+// of GitHub Actions, when running in it.
+func ciSummary(printer *Printer, summary any, markdown string) error {
+	if err := printer.JSON(summary); err != nil {
+		return fmt.Errorf("failed to write summary: %w", err)
+	}
+
+	path := os.Getenv("GITHUB_STEP_SUMMARY")
+	if path == "" {
+		return nil
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open step summary: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(markdown); err != nil {
+		return fmt.Errorf("failed to write step summary: %w", err)
+	}
+
+	return nil
+}
+
+func indexMarkdown(reports []IndexReport) string {
+	var b strings.Builder
+	b.WriteString("### cls index\n\n")
+	b.WriteString("| Target | Collection | Documents | Failed | Skipped | Duration |\n")
+	b.WriteString("| --- | --- | ---: | ---: | ---: | ---: |\n")
+	for _, r := range reports {
+		skipped := r.Add.Generated + r.Add.OtherLanguages + r.Add.Copies + r.Duplicates
+		fmt.Fprintf(&b, "| `%s` | %s | %d | %d | %d | %s |\n",
+			r.Target, r.Collection, r.Add.Added, r.Add.Failures(), skipped, r.Duration.Round(time.Millisecond))
+	}
+	b.WriteString("\n")
+
+	return b.String()
+}
+
+func diffIndexMarkdown(r DiffIndexReport) string {
+	var b strings.Builder
+	b.WriteString("### cls diff-index\n\n")
+	fmt.Fprintf(&b, "Since `%s` into %s: %d files changed, %d removed, %d documents deleted, %d added",
+		r.Since, r.Collection, r.Changed, r.Removed, r.Deleted, r.Add.Added)
+	if n := r.Add.Failures(); n > 0 {
+		fmt.Fprintf(&b, ", %d failed", n)
+	}
+	fmt.Fprintf(&b, " in %s.\n\n", r.Duration.Round(time.Millisecond))
+
+	return b.String()
+}
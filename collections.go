@@ -0,0 +1,218 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"flag"
+	"log/slog"
+	"maps"
+	"os"
+	"slices"
+)
+
+func collectionsCommand(chromaOpts ChromaOptions, args []string, printer *Printer, logger *slog.Logger) {
+	if len(args) < 1 {
+		logger.Error("Please provide a subcommand: list, rename, copy, alias, unalias or migrate-ids")
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+
+	client, err := newStore(chromaOpts, logger)
+	if err != nil {
+		logger.Error("Failed to create ChromaDB client", "error", err)
+		os.Exit(1)
+	}
+	defer client.Close()
+
+	switch sub := args[0]; sub {
+	case "list":
+		fs := flag.NewFlagSet("collections list", flag.ExitOnError)
+		all := fs.Bool("all", false, "Include collections not created by cls")
+		fs.Parse(args[1:])
+
+		infos, err := client.ListCollections(ctx)
+		if err != nil {
+			logger.Error("Failed to list collections", "error", err)
+			os.Exit(1)
+		}
+
+		var shown []CollectionInfo
+		for _, info := range infos {
+			if *all || info.Managed {
+				shown = append(shown, info)
+			}
+		}
+		printer.Collections(shown)
+	case "rename":
+		fs := flag.NewFlagSet("collections rename", flag.ExitOnError)
+		unprotect := fs.Bool("unprotect", false, "Allow renaming a protected collection")
+		fs.Parse(args[1:])
+
+		if fs.NArg() < 2 {
+			logger.Error("Usage: collections rename [-unprotect] <name> <new-name>")
+			os.Exit(1)
+		}
+		args = fs.Args()
+
+		if err := checkUnprotected(ctx, client, args[0], *unprotect); err != nil {
+			logger.Error("Refusing to rename collection", "error", err)
+			os.Exit(1)
+		}
+
+		if err := client.RenameCollection(ctx, args[0], args[1]); err != nil {
+			logger.Error("Failed to rename collection", "error", err)
+			os.Exit(1)
+		}
+		printer.Message("Collection '%s' renamed to '%s'", args[0], args[1])
+	case "copy":
+		if len(args) < 3 {
+			logger.Error("Usage: collections copy <name> <new-name>")
+			os.Exit(1)
+		}
+
+		n, err := client.CopyCollection(ctx, args[1], args[2])
+		if err != nil {
+			logger.Error("Failed to copy collection", "error", err, "copied", n)
+			os.Exit(1)
+		}
+		printer.Message("Copied %d documents from '%s' to '%s'", n, args[1], args[2])
+	case "alias":
+		aliases, err := loadAliases()
+		if err != nil {
+			logger.Error("Failed to load aliases", "error", err)
+			os.Exit(1)
+		}
+		if len(args) == 1 {
+			printer.Aliases(aliases)
+			return
+		}
+		if len(args) < 3 {
+			logger.Error("Usage: collections alias [<name> <collection>]")
+			os.Exit(1)
+		}
+		name, target := args[1], args[2]
+		if _, ok := aliases[target]; ok {
+			logger.Error("Cannot alias an alias, name its collection instead", "alias", target, "collection", aliases[target])
+			os.Exit(1)
+		}
+
+		infos, err := client.ListCollections(ctx)
+		if err != nil {
+			logger.Error("Failed to list collections", "error", err)
+			os.Exit(1)
+		}
+		// a collection called name could no longer be reached
+		if slices.ContainsFunc(infos, func(i CollectionInfo) bool { return i.Name == name || i.Alias == name }) {
+			logger.Error("A collection already has this name", "name", name)
+			os.Exit(1)
+		}
+		if !slices.ContainsFunc(infos, func(i CollectionInfo) bool { return i.Name == target || i.Alias == target }) {
+			logger.Error("No such collection", "collection", target)
+			os.Exit(1)
+		}
+
+		if err := setAlias(name, target); err != nil {
+			logger.Error("Failed to save alias", "error", err)
+			os.Exit(1)
+		}
+		printer.Message("'%s' now resolves to collection '%s'", name, target)
+	case "unalias":
+		if len(args) < 2 {
+			logger.Error("Usage: collections unalias <name>")
+			os.Exit(1)
+		}
+		aliases, err := loadAliases()
+		if err != nil {
+			logger.Error("Failed to load aliases", "error", err)
+			os.Exit(1)
+		}
+		if _, ok := aliases[args[1]]; !ok {
+			logger.Error("No such alias", "name", args[1])
+			os.Exit(1)
+		}
+
+		if err := setAlias(args[1], ""); err != nil {
+			logger.Error("Failed to save alias", "error", err)
+			os.Exit(1)
+		}
+		printer.Message("Alias '%s' removed", args[1])
+	case "migrate-ids":
+		fs := flag.NewFlagSet("collections migrate-ids", flag.ExitOnError)
+		root := fs.String("root", ".", "Path the collection was indexed from, for documents stored under legacy IDs without their root")
+		unprotect := fs.Bool("unprotect", false, "Allow rewriting a protected collection")
+		fs.Parse(args[1:])
+
+		if fs.NArg() < 1 {
+			logger.Error("Usage: collections migrate-ids [-root path] [-unprotect] <name>")
+			os.Exit(1)
+		}
+		name := fs.Arg(0)
+
+		if err := checkUnprotected(ctx, client, name, *unprotect); err != nil {
+			logger.Error("Refusing to rewrite collection", "error", err)
+			os.Exit(1)
+		}
+
+		coll, err := client.GetCollection(ctx, name)
+		if err != nil {
+			logger.Error("Failed to get collection", "error", err)
+			os.Exit(1)
+		}
+
+		n, err := migrateIDs(ctx, coll, *root)
+		if err != nil {
+			logger.Error("Failed to migrate document IDs", "error", err, "migrated", n)
+			os.Exit(1)
+		}
+		printer.Message("Migrated %d documents of '%s' to content-addressed IDs", n, name)
+	default:
+		logger.Error("Unknown collections subcommand", "subcommand", sub)
+		os.Exit(1)
+	}
+}
+
+func (p *Printer) Aliases(aliases map[string]string) {
+	if len(aliases) == 0 {
+		p.Message("No aliases defined")
+		return
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(aliases)) {
+		if p.plain {
+			p.Message("alias %s collection %s", name, aliases[name])
+			continue
+		}
+		p.Message("%-30s -> %s", name, aliases[name])
+	}
+}
+
+func (p *Printer) Collections(infos []CollectionInfo) {
+	if p.plain {
+		p.Message("collections: %d", len(infos))
+	}
+	if len(infos) == 0 && !p.plain {
+		p.Message("No collections found")
+		return
+	}
+
+	for _, info := range infos {
+		if p.plain {
+			p.Message("collection %s documents %d managed %t protected %t alias %s", info.Name, info.Count, info.Managed, info.Protected, cmp.Or(info.Alias, "-"))
+			continue
+		}
+
+		marker := " "
+		if !info.Managed {
+			marker = "*"
+		}
+		var notes string
+		if info.Alias != "" {
+			notes += " (as " + info.Alias + ")"
+		}
+		if info.Protected {
+			notes += " (protected)"
+		}
+		p.Message("%s %-40s %8d documents%s", marker, info.Name, info.Count, notes)
+	}
+}
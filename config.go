@@ -0,0 +1,143 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// userConfigFile holds the settings shared by every project, such as the
+// ChromaDB URL written by up, in $XDG_CONFIG_HOME/cls or its platform
+// equivalent. The .cls.toml of a project overrides them:
+//
+//	[chroma]
+//	url = "http://127.0.0.1:8000"
+const userConfigFile = "config.toml"
+
+// userConfigPath returns the path of the user config file.
+func userConfigPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate config directory: %w", err)
+	}
+
+	return filepath.Join(dir, "cls", userConfigFile), nil
+}
+
+// configValue returns the string key of table from the .cls.toml of the
+// project at root, or else from the user config file, and "" when neither
+// sets it.
+func configValue(root, table, key string) string {
+	if v, err := readConfigValue(filepath.Join(root, configFile), table, key); err == nil && v != "" {
+		return v
+	}
+	path, err := userConfigPath()
+	if err != nil {
+		return ""
+	}
+	v, _ := readConfigValue(path, table, key)
+
+	return v
+}
+
+// readConfigValue returns the string key of table in the TOML file at path,
+// "" when the file or the key is missing.
+func readConfigValue(path, table, key string) (string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read config: %w", err)
+	}
+
+	current := ""
+	for n, line := range strings.Split(string(data), "\n") {
+		k, value, ok := configLine(line, &current)
+		if !ok || k != table+"."+key {
+			continue
+		}
+		v, err := strconv.Unquote(value)
+		if err != nil {
+			return "", fmt.Errorf("%s:%d: %s: expected a string", path, n+1, k)
+		}
+		return v, nil
+	}
+
+	return "", nil
+}
+
+// writeConfigValue sets the string key of table in the TOML file at path,
+// or removes it when value is "", leaving the rest of the file as it is.
+func writeConfigValue(path, table, key, value string) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+
+	var (
+		lines   []string
+		current string
+		header  = -1
+	)
+	if len(data) > 0 {
+		for _, line := range strings.Split(strings.TrimRight(string(data), "\n"), "\n") {
+			if k, _, ok := configLine(line, &current); ok && k == table+"."+key {
+				continue
+			}
+			if current == table && header < 0 && strings.HasPrefix(strings.TrimSpace(line), "[") {
+				header = len(lines)
+			}
+			lines = append(lines, line)
+		}
+	}
+	if value != "" {
+		entry := key + " = " + strconv.Quote(value)
+		if header < 0 {
+			if len(lines) > 0 {
+				lines = append(lines, "")
+			}
+			lines = append(lines, "["+table+"]", entry)
+		} else {
+			lines = slices.Insert(lines, header+1, entry)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+
+	return nil
+}
+
+// configLine returns the dotted key and raw value of a key line, tracking
+// the table it is in through current.
+func configLine(line string, current *string) (string, string, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", "", false
+	}
+	if strings.HasPrefix(line, "[") {
+		*current = strings.TrimSpace(strings.Trim(line, "[]"))
+		return "", "", false
+	}
+
+	key, value, ok := strings.Cut(line, "=")
+	if !ok {
+		return "", "", false
+	}
+	key = strings.TrimSpace(key)
+	if *current != "" {
+		key = *current + "." + key
+	}
+
+	return key, strings.TrimSpace(value), true
+}
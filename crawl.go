@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"testing/fstest"
+	"time"
+)
+
+// isURL reports whether target is a web page to crawl rather than a path.
+func isURL(target string) bool {
+	return strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://")
+}
+
+// maxPageSize caps the size of fetched pages.
+const maxPageSize = 10 << 20
+
+// Crawler fetches the pages of a documentation site under a start URL.
+type Crawler struct {
+	Depth    int
+	MaxPages int
+	Client   *http.Client
+	Logger   *slog.Logger
+}
+
+// Crawl fetches start and the pages it links to under it, up to Depth links
+// away, and returns their text as markdown files named after their path
+// relative to start. A sitemap start fetches the pages it lists instead.
+func (c Crawler) Crawl(ctx context.Context, start string) (fstest.MapFS, error) {
+	base, err := url.Parse(start)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %w", err)
+	}
+
+	type page struct {
+		u     *url.URL
+		depth int
+	}
+	var queue []page
+
+	if strings.HasSuffix(base.Path, ".xml") {
+		locs, err := c.sitemap(ctx, start)
+		if err != nil {
+			return nil, err
+		}
+		base.Path = path.Dir(base.Path)
+		for _, loc := range locs {
+			if u, err := url.Parse(loc); err == nil {
+				queue = append(queue, page{u: u})
+			}
+		}
+	} else {
+		first := *base
+		queue = append(queue, page{u: &first})
+		if path.Ext(base.Path) != "" {
+			base.Path = path.Dir(base.Path)
+		}
+	}
+
+	fsys := fstest.MapFS{}
+	seen := map[string]bool{}
+	for len(queue) > 0 && (c.MaxPages <= 0 || len(fsys) < c.MaxPages) {
+		p := queue[0]
+		queue = queue[1:]
+
+		p.u.Fragment = ""
+		if seen[p.u.String()] || !underURL(base, p.u) {
+			continue
+		}
+		seen[p.u.String()] = true
+
+		body, isHTML, err := c.fetch(ctx, p.u.String(), false)
+		if err != nil {
+			c.Logger.Warn("Failed to fetch page", "url", p.u.String(), "error", err)
+			continue
+		}
+
+		text := body
+		if isHTML {
+			text = htmlToText(body)
+			if p.depth < c.Depth {
+				for _, link := range pageLinks(p.u, body) {
+					queue = append(queue, page{u: link, depth: p.depth + 1})
+				}
+			}
+		}
+
+		fsys[pageName(base, p.u)] = &fstest.MapFile{Data: []byte(text), ModTime: time.Now()}
+	}
+
+	return fsys, nil
+}
+
+// fetch returns the text at u and whether it is HTML. Only text pages are
+// fetched, and XML ones too when acceptXML is set, as sitemaps often are
+// served as application/xml.
+func (c Crawler) fetch(ctx context.Context, u string, acceptXML bool) (string, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return "", false, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return "", false, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	switch {
+	case mediaType == "text/html", mediaType == "application/xhtml+xml":
+	case strings.HasPrefix(mediaType, "text/"):
+	case acceptXML && mediaType == "application/xml":
+	default:
+		return "", false, fmt.Errorf("unsupported content type %q", mediaType)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxPageSize))
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read page: %w", err)
+	}
+
+	return string(data), strings.Contains(mediaType, "html"), nil
+}
+
+// sitemap returns the page URLs listed in the sitemap at u.
+func (c Crawler) sitemap(ctx context.Context, u string) ([]string, error) {
+	body, _, err := c.fetch(ctx, u, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch sitemap: %w", err)
+	}
+
+	var sm struct {
+		URLs []struct {
+			Loc string `xml:"loc"`
+		} `xml:"url"`
+	}
+	if err := xml.Unmarshal([]byte(body), &sm); err != nil {
+		return nil, fmt.Errorf("failed to parse sitemap: %w", err)
+	}
+
+	locs := make([]string, 0, len(sm.URLs))
+	for _, u := range sm.URLs {
+		locs = append(locs, strings.TrimSpace(u.Loc))
+	}
+
+	return locs, nil
+}
+
+// underURL reports whether u is on the site of base, under its path.
+func underURL(base, u *url.URL) bool {
+	if u.Host != base.Host || u.Scheme != "http" && u.Scheme != "https" {
+		return false
+	}
+
+	dir := strings.TrimSuffix(base.Path, "/")
+	return u.Path == dir || strings.HasPrefix(u.Path, dir+"/") || dir == ""
+}
+
+// pageName names the file of the page u, relative to base.
+func pageName(base, u *url.URL) string {
+	name := strings.Trim(strings.TrimPrefix(u.Path, strings.TrimSuffix(base.Path, "/")), "/")
+	if name == "" {
+		name = "index"
+	}
+	if u.RawQuery != "" {
+		name += "_" + url.PathEscape(u.RawQuery)
+	}
+
+	return name + ".md"
+}
+
+var (
+	hrefPattern    = regexp.MustCompile(`(?i)<a\s[^>]*href\s*=\s*["']([^"'#]+)`)
+	skippedBlocks  = regexp.MustCompile(`(?is)<(script|style|nav|header|footer|noscript|svg)\b.*?</(?:script|style|nav|header|footer|noscript|svg)>`)
+	headingPattern = regexp.MustCompile(`(?is)<h([1-6])[^>]*>(.*?)</h[1-6]>`)
+	itemPattern    = regexp.MustCompile(`(?i)<li[^>]*>`)
+	preBlocks      = regexp.MustCompile(`(?is)<pre[^>]*>(.*?)</pre>`)
+	blockPattern   = regexp.MustCompile(`(?i)</?(p|div|br|tr|table|section|article|ul|ol|blockquote)[^>]*>`)
+	tagPattern     = regexp.MustCompile(`(?s)<[^>]*>`)
+	blankLines     = regexp.MustCompile(`\n[ \t]*\n(?:[ \t]*\n)+`)
+)
+
+// pageLinks returns the links of the HTML page at u.
+func pageLinks(u *url.URL, body string) []*url.URL {
+	var links []*url.URL
+	for _, m := range hrefPattern.FindAllStringSubmatch(body, -1) {
+		if link, err := u.Parse(html.UnescapeString(m[1])); err == nil {
+			links = append(links, link)
+		}
+	}
+
+	return links
+}
+
+// htmlToText reduces an HTML page to markdown-like text: headings become #
+// lines, list items - lines and code blocks fences, and the rest of the
+// markup and the page chrome are dropped.
+func htmlToText(body string) string {
+	s := skippedBlocks.ReplaceAllString(body, "")
+	s = headingPattern.ReplaceAllStringFunc(s, func(h string) string {
+		m := headingPattern.FindStringSubmatch(h)
+		return "\n\n" + strings.Repeat("#", int(m[1][0]-'0')) + " " + strings.TrimSpace(tagPattern.ReplaceAllString(m[2], "")) + "\n\n"
+	})
+	s = preBlocks.ReplaceAllStringFunc(s, func(pre string) string {
+		code := tagPattern.ReplaceAllString(preBlocks.FindStringSubmatch(pre)[1], "")
+		return "\n\n```\n" + strings.Trim(code, "\n") + "\n```\n\n"
+	})
+	s = itemPattern.ReplaceAllString(s, "\n- ")
+	s = blockPattern.ReplaceAllString(s, "\n")
+	s = tagPattern.ReplaceAllString(s, "")
+	s = html.UnescapeString(s)
+	s = blankLines.ReplaceAllString(s, "\n\n")
+
+	return strings.TrimSpace(s) + "\n"
+}
+
+// crawlRoot is the URL under which the pages crawled from start are stored.
+func crawlRoot(start string) string {
+	u, err := url.Parse(start)
+	if err != nil {
+		return start
+	}
+
+	u.RawQuery, u.Fragment = "", ""
+	if strings.HasSuffix(u.Path, ".xml") || path.Ext(u.Path) != "" {
+		u.Path = path.Dir(u.Path)
+	}
+
+	return strings.TrimSuffix(u.String(), "/")
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCrawlSitemap(t *testing.T) {
+	for _, contentType := range []string{"application/xml", "text/xml; charset=utf-8"} {
+		t.Run(contentType, func(t *testing.T) {
+			var srv *httptest.Server
+			mux := http.NewServeMux()
+			mux.HandleFunc("/docs/sitemap.xml", func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", contentType)
+				fmt.Fprintf(w, `<?xml version="1.0"?><urlset><url><loc>%[1]s/docs/a</loc></url><url><loc>%[1]s/other</loc></url></urlset>`, srv.URL)
+			})
+			mux.HandleFunc("/docs/a", func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/html")
+				io.WriteString(w, "<h1>A</h1><p>page a</p>")
+			})
+			srv = httptest.NewServer(mux)
+			defer srv.Close()
+
+			c := Crawler{Client: srv.Client(), Logger: slog.New(slog.DiscardHandler)}
+			fsys, err := c.Crawl(context.Background(), srv.URL+"/docs/sitemap.xml")
+			if err != nil {
+				t.Fatalf("Crawl: %v", err)
+			}
+
+			if len(fsys) != 1 || fsys["a.md"] == nil {
+				t.Fatalf("got files %v, want only a.md", fsys)
+			}
+			if got, want := string(fsys["a.md"].Data), "# A\n\npage a\n"; got != want {
+				t.Errorf("a.md = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestCrawlRejectsXMLPages(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xml")
+		io.WriteString(w, "<feed/>")
+	}))
+	defer srv.Close()
+
+	c := Crawler{Client: srv.Client(), Logger: slog.New(slog.DiscardHandler)}
+	fsys, err := c.Crawl(context.Background(), srv.URL+"/feed")
+	if err != nil {
+		t.Fatalf("Crawl: %v", err)
+	}
+	if len(fsys) != 0 {
+		t.Errorf("got files %v, want none", fsys)
+	}
+}
@@ -0,0 +1,531 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"log/slog"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/karitham/cls/index"
+	"github.com/karitham/cls/store"
+)
+
+// daemonDialTimeout bounds the wait for the daemon socket, so queries fall
+// back to connecting themselves quickly when the daemon is gone.
+const daemonDialTimeout = 200 * time.Millisecond
+
+// daemonStartTimeout bounds the wait for a daemon started by -auto-daemon
+// to listen.
+const daemonStartTimeout = 15 * time.Second
+
+// DaemonOptions controls how queries use the daemon.
+type DaemonOptions struct {
+	// Disabled queries directly even when a daemon is listening.
+	Disabled bool
+	// Auto starts a daemon when none is listening, stopping after
+	// IdleTimeout without queries.
+	Auto        bool
+	IdleTimeout time.Duration
+}
+
+// daemonRequest is a query proxied to the daemon. The store is sent so a
+// daemon connected elsewhere is not used, and the options hidden from the
+// session JSON are sent alongside them.
+type daemonRequest struct {
+	Store    string
+	URL      string
+	Tenant   string
+	Database string
+	// Credentials fingerprints the credentials of the request, so a
+	// daemon connected with others does not answer it.
+	Credentials string
+	Collection  string
+	Query       string
+	Options     QueryOptions
+	Exclude     []string
+	Force       bool
+}
+
+type daemonResponse struct {
+	Outcome searchOutcome
+	Error   string `json:",omitempty"`
+	// Refused is set when the daemon cannot answer for the store of the
+	// request, which is then run without it.
+	Refused bool `json:",omitempty"`
+}
+
+// daemonSocketPath returns the socket the daemon listens on and queries
+// look for, CLS_DAEMON_SOCKET or daemon.sock in the state directory.
+func daemonSocketPath() (string, error) {
+	if path := os.Getenv("CLS_DAEMON_SOCKET"); path != "" {
+		return path, nil
+	}
+
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "daemon.sock"), nil
+}
+
+// queryDaemon sends the query to the daemon. ok is false when no daemon
+// is listening or it refused the query, which the caller then runs itself.
+func queryDaemon(ctx context.Context, chromaOpts ChromaOptions, collection, query string, opts QueryOptions, logger *slog.Logger) (searchOutcome, bool, error) {
+	path, err := daemonSocketPath()
+	if err != nil {
+		return searchOutcome{}, false, nil
+	}
+	conn, err := (&net.Dialer{Timeout: daemonDialTimeout}).DialContext(ctx, "unix", path)
+	if err != nil {
+		return searchOutcome{}, false, nil
+	}
+	defer conn.Close()
+
+	req := daemonRequest{
+		Store:       cmp.Or(chromaOpts.Store, store.Default),
+		URL:         chromaOpts.URL,
+		Tenant:      chromaOpts.Tenant,
+		Database:    chromaOpts.Database,
+		Credentials: credentialsFingerprint(chromaOpts),
+		Collection:  collection,
+		Query:       query,
+		Options:     opts,
+		Exclude:     opts.Exclude,
+		Force:       opts.Force,
+	}
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		logger.Warn("Failed to send query to the daemon, querying directly", "error", err)
+		return searchOutcome{}, false, nil
+	}
+
+	var resp daemonResponse
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		logger.Warn("Failed to read daemon response, querying directly", "error", err)
+		return searchOutcome{}, false, nil
+	}
+	if resp.Refused {
+		logger.Debug("Daemon refused query, querying directly", "reason", resp.Error)
+		return searchOutcome{}, false, nil
+	}
+	if resp.Error != "" {
+		return searchOutcome{}, true, errors.New(resp.Error)
+	}
+	logger.Debug("Query answered by the daemon", "socket", path)
+
+	return resp.Outcome, true, nil
+}
+
+// ensureDaemon starts a daemon for chromaOpts in the background unless one
+// is listening, and waits for it to listen. The daemon is detached from the
+// terminal, its logs going to daemon.log in the state directory, and stops
+// after idle without queries. Connection secrets are passed in its
+// environment rather than its arguments.
+func ensureDaemon(ctx context.Context, chromaOpts ChromaOptions, idle time.Duration, logger *slog.Logger) error {
+	socket, err := daemonSocketPath()
+	if err != nil {
+		return err
+	}
+	if conn, err := net.DialTimeout("unix", socket, daemonDialTimeout); err == nil {
+		conn.Close()
+		return nil
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate cls executable: %w", err)
+	}
+	dir, err := stateDir()
+	if err != nil {
+		return err
+	}
+	logFile, err := os.OpenFile(filepath.Join(dir, "daemon.log"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open daemon log: %w", err)
+	}
+	defer logFile.Close()
+
+	args := []string{"-store", cmp.Or(chromaOpts.Store, store.Default)}
+	if chromaOpts.Timeout > 0 {
+		args = append(args, "-chroma-timeout", chromaOpts.Timeout.String())
+	}
+	args = append(args, "daemon", "-socket", socket, "-idle-timeout", idle.String())
+	cmd := exec.Command(exe, args...)
+	cmd.Env = append(os.Environ(),
+		"CHROMA_URL="+chromaOpts.URL,
+		"CHROMA_TOKEN="+chromaOpts.Token,
+		"CHROMA_TOKEN_HEADER="+chromaOpts.TokenHeader,
+		"CHROMA_USER="+chromaOpts.Username,
+		"CHROMA_PASSWORD="+chromaOpts.Password,
+		"CHROMA_CA_CERT="+chromaOpts.CACert,
+		"CHROMA_INSECURE="+strconv.FormatBool(chromaOpts.Insecure),
+		"CHROMA_TENANT="+chromaOpts.Tenant,
+		"CHROMA_DATABASE="+chromaOpts.Database,
+	)
+	cmd.Stdout, cmd.Stderr = logFile, logFile
+	// in a session of its own, so it outlives the terminal
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start daemon: %w", err)
+	}
+	logger.Info("Started daemon", "pid", cmd.Process.Pid, "socket", socket, "idle_timeout", idle)
+
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+
+	ctx, cancel := context.WithTimeout(ctx, daemonStartTimeout)
+	defer cancel()
+	tick := time.NewTicker(50 * time.Millisecond)
+	defer tick.Stop()
+	for {
+		select {
+		case err := <-exited:
+			return fmt.Errorf("daemon exited before listening, see %s: %v", logFile.Name(), err)
+		case <-ctx.Done():
+			return fmt.Errorf("daemon did not listen within %s, see %s", daemonStartTimeout, logFile.Name())
+		case <-tick.C:
+			if conn, err := net.DialTimeout("unix", socket, daemonDialTimeout); err == nil {
+				conn.Close()
+				return nil
+			}
+		}
+	}
+}
+
+// daemon answers the queries of cls query on a unix socket, keeping its
+// client, collections and embedder open between them.
+type daemon struct {
+	chromaOpts ChromaOptions
+	client     ChromaClient
+	logger     *slog.Logger
+
+	mu          sync.Mutex
+	collections map[string]Collection
+
+	// idleTimer stops the daemon after idle without queries, and is stopped
+	// while inFlight queries are being answered.
+	idle      time.Duration
+	idleTimer *time.Timer
+	inFlight  int
+}
+
+// begin stops the idle timer while a query is answered.
+func (d *daemon) begin() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.inFlight++
+	if d.idleTimer != nil {
+		d.idleTimer.Stop()
+	}
+}
+
+// end restarts the idle timer once no query is left to answer.
+func (d *daemon) end() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.inFlight--; d.inFlight == 0 && d.idleTimer != nil {
+		d.idleTimer.Reset(d.idle)
+	}
+}
+
+// runDaemon listens on socket until interrupted, or until no query was
+// answered for idle when it is set. When indexPath is set, the collection of
+// its project is also kept up to date every interval.
+func runDaemon(chromaOpts ChromaOptions, collection, socket string, idle time.Duration, indexPath string, interval time.Duration, logger *slog.Logger) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	// acceptCtx is also cancelled once the daemon is idle, which stops
+	// accepting connections but lets the queries being answered finish
+	acceptCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if socket == "" {
+		var err error
+		if socket, err = daemonSocketPath(); err != nil {
+			logger.Error("Failed to locate daemon socket", "error", err)
+			os.Exit(1)
+		}
+	}
+	if conn, err := net.DialTimeout("unix", socket, daemonDialTimeout); err == nil {
+		conn.Close()
+		logger.Error("A daemon is already listening", "socket", socket)
+		os.Exit(1)
+	}
+	// a socket left by a daemon that did not exit cleanly
+	os.Remove(socket)
+
+	client, err := newStore(chromaOpts, logger)
+	if err != nil {
+		logger.Error("Failed to create ChromaDB client", "error", err)
+		os.Exit(1)
+	}
+	defer client.Close()
+
+	// loaded now rather than on the first query
+	if _, err := newEmbedder(); err != nil {
+		logger.Error("Failed to create embedder", "error", err)
+		os.Exit(1)
+	}
+
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		logger.Error("Failed to listen", "socket", socket, "error", err)
+		os.Exit(1)
+	}
+	defer os.Remove(socket)
+	if err := os.Chmod(socket, 0o600); err != nil {
+		logger.Warn("Failed to restrict daemon socket", "error", err)
+	}
+	go func() {
+		<-acceptCtx.Done()
+		l.Close()
+	}()
+
+	d := &daemon{chromaOpts: chromaOpts, client: client, logger: logger, collections: map[string]Collection{}, idle: idle}
+	if idle > 0 {
+		d.idleTimer = time.AfterFunc(idle, func() {
+			logger.Info("Daemon idle, stopping", "idle", idle)
+			cancel()
+		})
+	}
+	if indexPath != "" {
+		go d.backgroundIndex(acceptCtx, collection, indexPath, interval)
+	}
+	logger.Info("Daemon listening", "socket", socket)
+	var wg sync.WaitGroup
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if acceptCtx.Err() != nil {
+				wg.Wait()
+				logger.Info("Daemon stopped")
+				return
+			}
+			logger.Error("Failed to accept connection", "error", err)
+			os.Exit(1)
+		}
+		wg.Go(func() { d.serve(ctx, conn) })
+	}
+}
+
+func (d *daemon) serve(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+
+	var req daemonRequest
+	err := json.NewDecoder(bufio.NewReader(conn)).Decode(&req)
+	// connections closed without a query check the daemon is listening
+	if errors.Is(err, io.EOF) {
+		return
+	}
+	if err != nil {
+		d.logger.Warn("Failed to read query", "error", err)
+		return
+	}
+
+	// only queries keep the daemon up, probes do not
+	d.begin()
+	defer d.end()
+	resp := d.answer(ctx, req)
+	if err := json.NewEncoder(conn).Encode(resp); err != nil {
+		d.logger.Warn("Failed to send results", "error", err)
+	}
+}
+
+func (d *daemon) answer(ctx context.Context, req daemonRequest) daemonResponse {
+	backend := cmp.Or(d.chromaOpts.Store, store.Default)
+	if req.Store != backend || req.URL != d.chromaOpts.URL || req.Tenant != d.chromaOpts.Tenant || req.Database != d.chromaOpts.Database {
+		return daemonResponse{Refused: true, Error: fmt.Sprintf("daemon serves %s at %s, tenant %q and database %q", backend, d.chromaOpts.URL, d.chromaOpts.Tenant, d.chromaOpts.Database)}
+	}
+	if req.Credentials != credentialsFingerprint(d.chromaOpts) {
+		return daemonResponse{Refused: true, Error: "daemon connected with other credentials"}
+	}
+
+	opts := req.Options
+	opts.Exclude, opts.Force = req.Exclude, req.Force
+	start := time.Now()
+	outcome, err := d.search(ctx, req.Collection, req.Query, opts)
+	if err != nil {
+		return daemonResponse{Error: err.Error()}
+	}
+	d.logger.Info("Answered query", "collection", req.Collection, "results", len(outcome.Results), "took", time.Since(start))
+
+	return daemonResponse{Outcome: outcome}
+}
+
+// search runs query against the collection named name. A collection kept
+// from an earlier query may have been deleted since, such as by index
+// -replace swapping in a new version under its alias, so it is got again
+// when the query fails.
+func (d *daemon) search(ctx context.Context, name, query string, opts QueryOptions) (searchOutcome, error) {
+	outcome, cached, err := d.searchOnce(ctx, name, query, opts)
+	if err != nil && cached {
+		d.logger.Debug("Query failed on a kept collection, getting it again", "collection", name, "error", err)
+		d.forget(name)
+		outcome, _, err = d.searchOnce(ctx, name, query, opts)
+	}
+
+	return outcome, err
+}
+
+func (d *daemon) searchOnce(ctx context.Context, name, query string, opts QueryOptions) (searchOutcome, bool, error) {
+	coll, cached, err := d.collection(ctx, name)
+	if err != nil {
+		return searchOutcome{}, false, err
+	}
+	if err := checkEmbedder(coll.Metadata()); err != nil && !opts.Force {
+		return searchOutcome{}, cached, fmt.Errorf("embedder mismatch, reindex the collection or pass -force: %w", err)
+	}
+
+	outcome, err := searchOutcomeOf(ctx, coll, name, query, opts, d.logger)
+	return outcome, cached, err
+}
+
+// collection returns the collection named name, getting it on first use,
+// and whether it was kept from an earlier query.
+func (d *daemon) collection(ctx context.Context, name string) (Collection, bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if coll, ok := d.collections[name]; ok {
+		return coll, true, nil
+	}
+	coll, err := d.client.GetCollection(ctx, name)
+	if err != nil {
+		return nil, false, err
+	}
+	d.collections[name] = coll
+
+	return coll, false, nil
+}
+
+func (d *daemon) forget(name string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.collections, name)
+}
+
+// credentialsFingerprint hashes the credentials of opts, to compare them
+// without sending them over the socket.
+func credentialsFingerprint(opts ChromaOptions) string {
+	h := sha256.New()
+	for _, s := range []string{opts.Token, opts.TokenHeader, opts.Username, opts.Password, opts.CACert, strconv.FormatBool(opts.Insecure)} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// backgroundIndex indexes again, every interval, the files of the project at
+// path modified since the last index run of its collection, every file when
+// it was never indexed. Its batches share the embedder of the queries, which
+// go first.
+func (d *daemon) backgroundIndex(ctx context.Context, collection, path string, interval time.Duration) {
+	root := projectRoot(path)
+	collection = resolveCollection(collection, root)
+	secrets, err := index.NewSecretPolicy(index.SecretsMask, "")
+	if err != nil {
+		d.logger.Error("Invalid secrets options", "error", err)
+		return
+	}
+
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	for {
+		if err := d.indexChanges(ctx, collection, root, secrets); err != nil && ctx.Err() == nil {
+			d.logger.Warn("Failed to index changes", "collection", collection, "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+	}
+}
+
+func (d *daemon) indexChanges(ctx context.Context, collection, root string, secrets index.SecretPolicy) error {
+	var since time.Time
+	runs, err := loadRuns(collection)
+	if err != nil {
+		return err
+	}
+	if len(runs) > 0 {
+		since = runs[len(runs)-1].StartedAt
+	}
+
+	run := Run{StartedAt: time.Now(), Targets: []string{root}}
+	run.ID = newRunID(run.StartedAt)
+	walker, err := newWalker(root, WalkOptions{})
+	if err != nil {
+		return err
+	}
+	// the changed files are added as they are walked, the walk being
+	// started first so nothing is done while none changed
+	var changed int
+	next, stop := iter.Pull(func(yield func(string) bool) {
+		for f := range walkFiles(walker, WalkOptions{}, func(p string) string { return index.RelativePath(root, p) }, d.logger) {
+			if f.ModTime.After(since) && !yield(f.Path) {
+				return
+			}
+		}
+	})
+	defer stop()
+	first, ok := next()
+	if !ok {
+		return nil
+	}
+	paths := func(yield func(string) bool) {
+		for path, ok := first, true; ok; path, ok = next() {
+			changed++
+			if !yield(path) {
+				return
+			}
+		}
+	}
+
+	coll, err := d.client.GetOrCreateCollection(ctx, collection)
+	if err != nil {
+		return err
+	}
+	symbols, err := loadSymbols(collection)
+	if err != nil {
+		d.logger.Warn("Failed to load symbol index, rebuilding it", "error", err)
+		symbols = newSymbolIndex(collection)
+	}
+
+	d.logger.Info("Indexing changed files", "collection", collection)
+	stats, err := AddDocuments(ctx, coll, paths, AddOptions{Options: index.Options{Root: root, Secrets: secrets, Symbols: symbols, RunID: run.ID}}, d.logger)
+	if err != nil {
+		return err
+	}
+	if err := symbols.Save(); err != nil {
+		d.logger.Warn("Failed to save symbol index", "error", err)
+	}
+	run.Added = stats.Added + stats.Summaries
+	run.Duration = time.Since(run.StartedAt)
+	if err := recordRun(collection, run); err != nil {
+		d.logger.Warn("Failed to record index run", "error", err)
+	}
+	d.logger.Info("Indexed changed files", "collection", collection, "files", changed, "added", stats.Added, "took", run.Duration)
+
+	return nil
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/karitham/cls/index"
+)
+
+// GitChanges are the files changed since a git ref, relative to the
+// directory git diff ran in.
+type GitChanges struct {
+	// Changed were added or modified, Removed deleted. A renamed file is
+	// removed under its old path and added under its new one.
+	Changed []string
+	Removed []string
+}
+
+// gitChanges lists the files under dir changed between since and the working
+// tree, which is what gets indexed.
+func gitChanges(dir, since string) (GitChanges, error) {
+	cmd := exec.Command("git", "-C", dir, "diff", "--name-status", "--no-renames", "--relative", "-z", since, "--")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return GitChanges{}, fmt.Errorf("git diff failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	// -z output alternates a status and a path
+	var changes GitChanges
+	fields := strings.Split(strings.TrimSuffix(string(out), "\x00"), "\x00")
+	for i := 0; i+1 < len(fields); i += 2 {
+		status, path := fields[i], filepath.ToSlash(fields[i+1])
+		if strings.HasPrefix(status, "D") {
+			changes.Removed = append(changes.Removed, path)
+		} else {
+			changes.Changed = append(changes.Changed, path)
+		}
+	}
+
+	return changes, nil
+}
+
+// DiffIndexReport is the outcome of diff-index.
+type DiffIndexReport struct {
+	Collection string        `json:"collection"`
+	Run        string        `json:"run"`
+	Since      string        `json:"since"`
+	Changed    int           `json:"changed"`
+	Removed    int           `json:"removed"`
+	Deleted    int           `json:"deleted_documents"`
+	Add        AddStats      `json:"add"`
+	Duration   time.Duration `json:"duration_ns"`
+}
+
+// diffIndex brings the collection of the project at path up to date with the
+// files changed since a git ref: their documents are deleted, and the files
+// still there indexed again. Changed files the index walk would skip are left
+// out, as a full index would.
+func diffIndex(chromaOpts ChromaOptions, collection, path, since, reportPath string, walkOpts WalkOptions, addOpts AddOptions, printer *Printer, logger *slog.Logger) {
+	ctx := context.Background()
+	start := time.Now()
+
+	root := projectRoot(path)
+	changes, err := gitChanges(root, since)
+	if err != nil {
+		logger.Error("Failed to list changed files", "error", err)
+		os.Exit(1)
+	}
+	logger.Info("Changed files", "since", since, "changed", len(changes.Changed), "removed", len(changes.Removed))
+
+	client, err := newStore(chromaOpts, logger)
+	if err != nil {
+		logger.Error("Failed to create ChromaDB client", "error", err)
+		os.Exit(1)
+	}
+	defer client.Close()
+
+	coll, err := client.GetOrCreateCollection(ctx, collection)
+	if err != nil {
+		logger.Error("Failed to get/create collection", "error", err)
+		os.Exit(1)
+	}
+
+	symbols, err := loadSymbols(collection)
+	if err != nil {
+		logger.Warn("Failed to load symbol index, rebuilding it", "error", err)
+		symbols = newSymbolIndex(collection)
+	}
+	addOpts.Symbols = symbols
+	addOpts.Root = root
+	run := Run{StartedAt: start, Targets: []string{root + "@" + since}}
+	run.ID = newRunID(start)
+	addOpts.RunID = run.ID
+
+	// changed files are deleted too, as they may now have fewer chunks
+	id := rootID(root)
+	stale := append(changes.Removed, changes.Changed...)
+	deleted, err := coll.DeletePaths(ctx, id, stale)
+	if err != nil {
+		logger.Error("Failed to delete documents of changed files", "error", err)
+		os.Exit(1)
+	}
+	for _, rel := range stale {
+		symbols.Set(id, rel, nil, nil)
+	}
+
+	changed := map[string]bool{}
+	for _, rel := range changes.Changed {
+		changed[rel] = true
+	}
+	walker, err := newWalker(root, walkOpts)
+	if err != nil {
+		logger.Error("Failed to list files", "error", err)
+		os.Exit(1)
+	}
+	rel := func(p string) string { return index.RelativePath(root, p) }
+	paths := func(yield func(string) bool) {
+		for f := range walkFiles(walker, walkOpts, rel, logger) {
+			if changed[rel(f.Path)] && !yield(f.Path) {
+				return
+			}
+		}
+	}
+
+	added, err := AddDocuments(ctx, coll, paths, addOpts, logger)
+	switch {
+	case err != nil && ciMode && added.Added > 0:
+		logger.Error("Failed to add some documents to collection", "error", err, "failed", added.Failed)
+	case err != nil:
+		logger.Error("Failed to add documents to collection", "error", err)
+		os.Exit(1)
+	}
+
+	if err := symbols.Save(); err != nil {
+		logger.Warn("Failed to save symbol index", "error", err)
+	}
+	run.Added = added.Added + added.Summaries
+	run.Duration = time.Since(start)
+	if err := recordRun(collection, run); err != nil {
+		logger.Warn("Failed to record index run", "error", err)
+	}
+
+	report := DiffIndexReport{
+		Collection: collection,
+		Run:        run.ID,
+		Since:      since,
+		Changed:    len(changes.Changed),
+		Removed:    len(changes.Removed),
+		Deleted:    deleted,
+		Add:        added,
+		Duration:   run.Duration,
+	}
+	if reportPath != "" {
+		if err := writeJSONFile(reportPath, report); err != nil {
+			logger.Error("Failed to write index report", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	if ciMode {
+		if err := ciSummary(printer, report, diffIndexMarkdown(report)); err != nil {
+			logger.Error("Failed to write summary", "error", err)
+			os.Exit(1)
+		}
+		ciExit(added.Added, added.Failures())
+		return
+	}
+
+	printer.DiffIndexSummary(report)
+}
+
+func (p *Printer) DiffIndexSummary(r DiffIndexReport) {
+	if p.plain {
+		p.Message("diff-index collection %s since %s changed %d removed %d deleted %d added %d read-errors %d failed %d duration %s run %s",
+			r.Collection, r.Since, r.Changed, r.Removed, r.Deleted, r.Add.Added, r.Add.ReadErrors, r.Add.Failed, r.Duration.Round(time.Millisecond), r.Run)
+		return
+	}
+	if r.Add.ReadErrors > 0 {
+		p.Message("Failed to read %d files", r.Add.ReadErrors)
+	}
+	p.Message("%d files changed and %d removed since %s: deleted %d documents, added %d to '%s' in %s (run %s)",
+		r.Changed, r.Removed, r.Since, r.Deleted, r.Add.Added, r.Collection, r.Duration.Round(time.Millisecond), r.Run)
+}
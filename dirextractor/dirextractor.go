@@ -2,18 +2,35 @@ package dirextractor
 
 import (
 	"errors"
+	"fmt"
 	"io/fs"
 	"iter"
+	"maps"
 	"os"
 	"path/filepath"
 	"regexp"
 	"slices"
 	"strings"
+	"time"
 )
 
 type extractor struct {
-	root string
-	fns  []func(path string) error
+	root     string
+	fsys     fs.FS
+	fns      []filter
+	dirFns   []filter
+	maxSize  int64
+	symlinks symlinkPolicy
+	confine  bool
+	names    []string
+	stats    *Stats
+}
+
+// filter decides whether to skip a file, given its absolute path, or to prune
+// a directory, given its slash separated path relative to the root.
+type filter struct {
+	reason string
+	fn     func(path string) error
 }
 
 var (
@@ -21,118 +38,451 @@ var (
 	SkipDir = errors.New("skip this directory")
 )
 
-func WithExtensions(ext []string) func(*extractor) {
-	extFilter := func(path string) error {
-		if slices.Contains(ext, filepath.Ext(path)) {
-			return nil
+// Skip reasons reported in Stats.Skipped and Stats.Pruned.
+const (
+	ReasonExtension = "extension"
+	ReasonHidden    = "hidden"
+	ReasonIgnored   = "ignored"
+	ReasonDepth     = "depth"
+	ReasonSize      = "size"
+	ReasonSymlink   = "symlink"
+	ReasonCycle     = "cycle"
+	ReasonOutside   = "outside-root"
+)
+
+type symlinkPolicy int
+
+const (
+	// symlinksDefault yields links to files and does not descend into links
+	// to directories.
+	symlinksDefault symlinkPolicy = iota
+	symlinksSkip
+	symlinksFollow
+)
+
+// Stats counts what happened during a walk.
+type Stats struct {
+	// Seen is the number of files encountered, skipped or not.
+	Seen int `json:"seen"`
+	// Yielded is the number of files returned to the caller.
+	Yielded int `json:"yielded"`
+	// Skipped counts skipped files by the reason they were skipped for.
+	Skipped map[string]int `json:"skipped"`
+	// Pruned counts directories that were not descended into, by reason.
+	Pruned map[string]int `json:"pruned"`
+	// Errors is the number of entries that could not be walked.
+	Errors int `json:"errors"`
+}
+
+// Option configures an extractor. Options report invalid arguments as errors
+// from New.
+type Option func(*extractor) error
+
+func WithExtensions(ext []string) Option {
+	return func(e *extractor) error {
+		e.fns = append(e.fns, filter{ReasonExtension, func(path string) error {
+			if slices.Contains(ext, filepath.Ext(path)) || slices.Contains(e.names, strings.ToLower(filepath.Base(path))) {
+				return nil
+			}
+
+			return Skip
+		}})
+		return nil
+	}
+}
+
+// WithFilenames accepts files with these names, compared case-insensitively,
+// whatever their extension. It is meant for extensionless files such as
+// Makefile.
+func WithFilenames(names ...string) Option {
+	return func(e *extractor) error {
+		for _, name := range names {
+			e.names = append(e.names, strings.ToLower(name))
 		}
+		return nil
+	}
+}
 
-		return Skip
+// WithIgnoreHidden skips dot files and prunes dot directories below the root.
+func WithIgnoreHidden() Option {
+	hidden := func(path string) bool {
+		return strings.HasPrefix(filepath.Base(path), ".") && path != "."
 	}
 
-	return func(e *extractor) {
-		e.fns = append(e.fns, extFilter)
+	return func(e *extractor) error {
+		e.fns = append(e.fns, filter{ReasonHidden, func(path string) error {
+			if hidden(path) {
+				return Skip
+			}
+			return nil
+		}})
+		e.dirFns = append(e.dirFns, filter{ReasonHidden, func(path string) error {
+			if hidden(path) {
+				return SkipDir
+			}
+			return nil
+		}})
+		return nil
 	}
 }
 
-func WithIgnoreHidden() func(*extractor) {
-	f := func(path string) error {
-		components := strings.Split(path, string(os.PathSeparator))
+// WithSkipDirs prunes directories with any of the given names, such as
+// node_modules, without walking their contents.
+func WithSkipDirs(names ...string) Option {
+	return func(e *extractor) error {
+		e.dirFns = append(e.dirFns, filter{ReasonIgnored, func(path string) error {
+			if path != "." && slices.Contains(names, filepath.Base(path)) {
+				return SkipDir
+			}
+			return nil
+		}})
+		return nil
+	}
+}
+
+// WithMaxDepth only descends n directories below the root. With 0, only the
+// files directly in the root are walked.
+func WithMaxDepth(n int) Option {
+	return func(e *extractor) error {
+		if n < 0 {
+			return fmt.Errorf("invalid max depth %d", n)
+		}
 
-		for _, c := range components[:max(0, len(components)-2)] {
-			if strings.HasPrefix(c, ".") {
+		e.dirFns = append(e.dirFns, filter{ReasonDepth, func(path string) error {
+			if path != "." && strings.Count(path, "/")+1 > n {
 				return SkipDir
 			}
+			return nil
+		}})
+		return nil
+	}
+}
+
+func WithIgnoreRegs(regs ...string) Option {
+	return func(e *extractor) error {
+		var regexes []*regexp.Regexp
+		for _, reg := range regs {
+			r, err := regexp.Compile(reg)
+			if err != nil {
+				return fmt.Errorf("invalid ignore pattern %q: %w", reg, err)
+			}
+			regexes = append(regexes, r)
 		}
 
-		if strings.HasPrefix(components[len(components)-1], ".") {
-			return Skip
+		f := func(path string) error {
+			for _, r := range regexes {
+				if r.MatchString(path) {
+					return Skip
+				}
+			}
+
+			return nil
 		}
 
+		e.fns = append(e.fns, filter{ReasonIgnored, f})
 		return nil
 	}
+}
+
+func New(root string, opt ...Option) (extractor, error) {
+	ext := extractor{
+		root:  root,
+		fns:   []filter{},
+		stats: &Stats{Skipped: map[string]int{}, Pruned: map[string]int{}},
+	}
 
-	return func(e *extractor) {
-		e.fns = append(e.fns, f)
+	if root == "" {
+		return ext, errors.New("no root to walk")
 	}
-}
 
-func WithIgnoreRegs(regs ...string) func(*extractor) {
-	var regexes []*regexp.Regexp
-	for _, reg := range regs {
-		r, err := regexp.Compile(reg)
-		if err != nil {
-			panic(err)
+	for _, opt := range opt {
+		if err := opt(&ext); err != nil {
+			return ext, err
 		}
-		regexes = append(regexes, r)
 	}
 
-	f := func(path string) error {
-		for _, r := range regexes {
-			if r.MatchString(path) {
-				return Skip
-			}
+	return ext, nil
+}
+
+// WithMaxSize skips files larger than n bytes, such as huge generated files.
+func WithMaxSize(n int64) Option {
+	return func(e *extractor) error {
+		if n <= 0 {
+			return fmt.Errorf("invalid max size %d", n)
 		}
 
+		e.maxSize = n
 		return nil
 	}
+}
 
-	return func(e *extractor) {
-		e.fns = append(e.fns, f)
+// WithFollowSymlinks sets how symbolic links are handled. When follow is
+// true, links to files and directories are walked as if they were regular
+// entries, and directories reached twice, such as through a link loop, are
+// pruned. When false, links are skipped.
+func WithFollowSymlinks(follow bool) Option {
+	return func(e *extractor) error {
+		e.symlinks = symlinksSkip
+		if follow {
+			e.symlinks = symlinksFollow
+		}
+		return nil
 	}
 }
 
-func New(root string, opt ...func(*extractor)) extractor {
-	ext := extractor{
-		root: root,
-		fns:  []func(path string) error{},
+// WithConfineToRoot skips files and prunes directories that resolve, through
+// symbolic links, to a location outside the root.
+func WithConfineToRoot() Option {
+	return func(e *extractor) error {
+		e.confine = true
+		return nil
 	}
+}
 
-	for _, opt := range opt {
-		opt(&ext)
+// NewFS is like New but walks root inside fsys, such as an embed.FS, a zip
+// archive or an in-memory filesystem. Yielded paths are fsys paths.
+func NewFS(fsys fs.FS, root string, opt ...Option) (extractor, error) {
+	if fsys == nil {
+		return extractor{}, errors.New("no filesystem to walk")
 	}
+	if !fs.ValidPath(root) {
+		return extractor{}, fmt.Errorf("invalid root %q for a filesystem walk", root)
+	}
+
+	ext, err := New(root, opt...)
+	if err != nil {
+		return ext, err
+	}
+	if ext.symlinks == symlinksFollow || ext.confine {
+		return ext, errors.New("symlink options are not supported when walking an fs.FS")
+	}
+	ext.fsys = fsys
 
-	return ext
+	return ext, nil
 }
 
-func (e extractor) filter(path string) error {
-	for _, f := range e.fns {
-		if err := f(path); err != nil {
-			return err
+// Stats returns the counters of the walks done so far.
+func (e extractor) Stats() Stats {
+	s := *e.stats
+	s.Skipped = maps.Clone(e.stats.Skipped)
+	s.Pruned = maps.Clone(e.stats.Pruned)
+	return s
+}
+
+func (e extractor) filter(fns []filter, path string) (string, error) {
+	for _, f := range fns {
+		if err := f.fn(path); err != nil {
+			return f.reason, err
 		}
 	}
 
-	return nil
+	return "", nil
+}
+
+// FileInfo describes a file yielded by Files.
+type FileInfo struct {
+	Path    string
+	Size    int64
+	ModTime time.Time
+
+	fsys fs.FS
+}
+
+// Content reads the file. It is only loaded when called.
+func (f FileInfo) Content() ([]byte, error) {
+	if f.fsys != nil {
+		return fs.ReadFile(f.fsys, f.Path)
+	}
+
+	return os.ReadFile(f.Path)
 }
 
-func (e extractor) Files() iter.Seq[string] {
+// Paths yields the absolute paths, or fsys paths for NewFS, of the files that pass the filters, leaving
+// out entries that could not be walked.
+func (e extractor) Paths() iter.Seq[string] {
 	return func(yield func(string) bool) {
-		err := filepath.WalkDir(e.root, func(path string, d fs.DirEntry, err error) error {
-			if d.IsDir() {
+		for f, err := range e.Files() {
+			if err == nil && !yield(f.Path) {
+				return
+			}
+		}
+	}
+}
+
+// Files yields the files that pass the filters. Entries that cannot be walked
+// or stat'ed, such as directories without read permission, are yielded with
+// their path and the error, and the walk goes on.
+func (e extractor) Files() iter.Seq2[FileInfo, error] {
+	walk := filepath.WalkDir
+	stat := os.Stat
+	if e.fsys != nil {
+		walk = func(root string, fn fs.WalkDirFunc) error {
+			return fs.WalkDir(e.fsys, root, fn)
+		}
+		stat = func(name string) (fs.FileInfo, error) {
+			return fs.Stat(e.fsys, name)
+		}
+	}
+
+	return func(yield func(FileInfo, error) bool) {
+		var (
+			stopped bool
+			visited = map[string]bool{}
+			fn      fs.WalkDirFunc
+		)
+
+		inRoot := func(string) bool { return true }
+		if e.confine {
+			realRoot, err := realPath(e.root)
+			if err != nil {
+				e.stats.Errors++
+				yield(FileInfo{Path: e.root}, err)
+				return
+			}
+
+			inRoot = func(path string) bool {
+				real, err := realPath(path)
+				if err != nil {
+					return false
+				}
+				rel, err := filepath.Rel(realRoot, real)
+				return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+			}
+		}
+
+		// fail reports a path that cannot be walked and tells the walk
+		// whether to go on.
+		fail := func(path string, err error) error {
+			e.stats.Errors++
+			if !yield(FileInfo{Path: path}, err) {
+				stopped = true
+				return filepath.SkipAll
+			}
+			return nil
+		}
+
+		fn = func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return fail(path, err)
+			}
+
+			symlink := d.Type()&fs.ModeSymlink != 0
+			if symlink && e.symlinks == symlinksSkip {
+				e.stats.Seen++
+				e.stats.Skipped[ReasonSymlink]++
 				return nil
 			}
 
-			abs, err := filepath.Abs(path)
+			// links are described by their target, so links to directories
+			// are told apart and files get their size
+			info, err := d.Info()
+			if err == nil && symlink {
+				info, err = stat(path)
+			}
 			if err != nil {
+				if !d.IsDir() {
+					e.stats.Seen++
+				}
+				return fail(path, err)
+			}
+
+			if symlink && !inRoot(path) {
+				if info.IsDir() {
+					e.stats.Pruned[ReasonOutside]++
+				} else {
+					e.stats.Seen++
+					e.stats.Skipped[ReasonOutside]++
+				}
+				return nil
+			}
+
+			if info.IsDir() && symlink && e.symlinks != symlinksFollow {
+				e.stats.Pruned[ReasonSymlink]++
+				return nil
+			}
+			if info.IsDir() && symlink {
+				// WalkDir does not descend into links, so walk the target under
+				// the link's path, where the trailing separator makes WalkDir
+				// resolve the link
+				err := walk(path+string(filepath.Separator), fn)
+				if stopped {
+					return filepath.SkipAll
+				}
+				if err != nil {
+					return fail(path, err)
+				}
+				return nil
+			}
+
+			if info.IsDir() {
+				rel, err := filepath.Rel(e.root, path)
+				if err != nil {
+					return nil
+				}
+				if reason, filter := e.filter(e.dirFns, filepath.ToSlash(rel)); filter != nil {
+					e.stats.Pruned[reason]++
+					return filepath.SkipDir
+				}
+
+				if e.symlinks == symlinksFollow {
+					real, err := filepath.EvalSymlinks(path)
+					if err != nil {
+						return fail(path, err)
+					}
+					if visited[real] {
+						e.stats.Pruned[ReasonCycle]++
+						return filepath.SkipDir
+					}
+					visited[real] = true
+				}
 				return nil
 			}
 
-			switch filter := e.filter(abs); {
-			case errors.Is(filter, Skip):
+			e.stats.Seen++
+
+			abs, err := e.abs(path)
+			if err != nil {
+				return fail(path, err)
+			}
+
+			if reason, filter := e.filter(e.fns, abs); filter != nil {
+				e.stats.Skipped[reason]++
+				return nil
+			}
+			if e.maxSize > 0 && info.Size() > e.maxSize {
+				e.stats.Skipped[ReasonSize]++
 				return nil
-			case errors.Is(filter, SkipDir):
-				return filepath.SkipDir
 			}
 
-			if !yield(abs) {
+			e.stats.Yielded++
+			f := FileInfo{Path: abs, Size: info.Size(), ModTime: info.ModTime(), fsys: e.fsys}
+			if !yield(f, nil) {
+				stopped = true
 				return filepath.SkipAll
 			}
 
 			return nil
-		})
+		}
 
-		if err != nil {
-			panic(err)
+		if err := walk(e.root, fn); err != nil && !stopped {
+			yield(FileInfo{Path: e.root}, err)
 		}
 	}
 }
+
+func (e extractor) abs(path string) (string, error) {
+	if e.fsys != nil {
+		return path, nil
+	}
+
+	return filepath.Abs(path)
+}
+
+func realPath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.EvalSymlinks(abs)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/karitham/cls/index"
+)
+
+var documentIDPattern = regexp.MustCompile(`^[0-9a-f]{64}#chunk[0-9]+(?:#summary)?$`)
+
+// chunkIndex returns the chunk a document ID identifies, 0 for legacy IDs.
+func chunkIndex(id string) int {
+	_, suffix, ok := strings.Cut(id, "#chunk")
+	if !ok {
+		return 0
+	}
+
+	n, _ := strconv.Atoi(suffix)
+	return n
+}
+
+// migrateIDs rewrites the documents of coll whose ID is not the one the
+// current scheme derives from their root and path, and returns how many were
+// rewritten. Documents under legacy IDs get paths taken relative to root, and
+// are stored relative to it like newly indexed documents. Embeddings are
+// carried over.
+func migrateIDs(ctx context.Context, coll Collection, root string) (int, error) {
+	// pages shift as documents are rewritten, so collect them all first
+	var stale []Record
+	oldIDs := map[string]string{}
+	for r, err := range coll.Records(ctx) {
+		if err != nil {
+			return 0, err
+		}
+		if r.Metadata == nil {
+			r.Metadata = map[string]any{}
+		}
+
+		path, _ := r.Metadata["path"].(string)
+		id, _ := r.Metadata[index.RootKey].(string)
+		chunk := chunkIndex(r.ID)
+		if !documentIDPattern.MatchString(r.ID) {
+			if path == "" {
+				path = r.ID
+			}
+			path, id, chunk = index.RelativePath(root, path), rootID(root), 0
+			r.Metadata["path"] = path
+			r.Metadata[index.RootKey] = id
+		}
+
+		want := index.DocumentID(id, path, chunk)
+		if strings.HasSuffix(r.ID, index.SummarySuffix) {
+			r.Metadata[index.SummaryOfKey] = want
+			want = index.SummaryID(want)
+		}
+		if want == r.ID {
+			continue
+		}
+		oldIDs[want] = r.ID
+		r.ID = want
+		stale = append(stale, r)
+	}
+
+	n := 0
+	for batch := range slices.Chunk(stale, copyPageSize) {
+		ids := make([]string, len(batch))
+		for i, r := range batch {
+			ids[i] = oldIDs[r.ID]
+		}
+
+		if err := coll.AddRecords(ctx, batch); err != nil {
+			return n, err
+		}
+		if err := coll.Delete(ctx, ids...); err != nil {
+			return n, err
+		}
+		n += len(batch)
+	}
+
+	return n, nil
+}
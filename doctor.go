@@ -0,0 +1,229 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/karitham/cls/buildinfo"
+	"github.com/karitham/cls/store"
+	"github.com/karitham/cls/store/chromadb"
+)
+
+// Doctor check outcomes.
+const (
+	CheckOK   = "ok"
+	CheckWarn = "warn"
+	CheckFail = "fail"
+)
+
+// Check is the outcome of one doctor check, with how to fix it when it did
+// not pass.
+type Check struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail"`
+	Fix    string `json:"fix,omitempty"`
+}
+
+// errModelNotPulled is returned by ollamaModelDigest for models Ollama does
+// not have.
+var errModelNotPulled = errors.New("model is not pulled")
+
+// doctorTimeout bounds every network check, so an unreachable server is
+// reported rather than waited on.
+const doctorTimeout = 5 * time.Second
+
+// runDoctor checks everything cls depends on for collection.
+func runDoctor(ctx context.Context, chromaOpts ChromaOptions, collection string, logger *slog.Logger) []Check {
+	checks := []Check{checkBuild()}
+
+	client, chromaCheck := checkChroma(ctx, chromaOpts, logger)
+	checks = append(checks, chromaCheck, checkOllama(ctx))
+	if client != nil {
+		defer client.Close()
+		checks = append(checks, checkCollection(ctx, client, collection))
+	}
+
+	return append(checks, checkConfig(), checkState())
+}
+
+// checkBuild reports the version of cls and the optional features built
+// into it, as asked for in bug reports.
+func checkBuild() Check {
+	info := buildinfo.Get()
+	var enabled, disabled []string
+	for _, name := range slices.Sorted(maps.Keys(info.Features)) {
+		if info.Features[name] {
+			enabled = append(enabled, name)
+		} else {
+			disabled = append(disabled, name)
+		}
+	}
+
+	detail := fmt.Sprintf("cls %s, commit %s, %s %s, features: %s", info.Version, info.Commit, info.GoVersion, info.Platform, cmp.Or(strings.Join(enabled, ", "), "none"))
+	if len(disabled) > 0 {
+		detail += ", without: " + strings.Join(disabled, ", ")
+	}
+
+	return Check{Name: "build", Status: CheckOK, Detail: detail}
+}
+
+func checkChroma(ctx context.Context, opts ChromaOptions, logger *slog.Logger) (ChromaClient, Check) {
+	check := Check{Name: "chroma", Status: CheckFail}
+
+	client, err := newStore(opts, logger)
+	if errors.Is(err, chromadb.ErrUnreachable) {
+		check.Detail = err.Error()
+		check.Fix = "start ChromaDB with cls up, or point -url at it"
+		return nil, check
+	}
+	if err != nil {
+		check.Detail = err.Error()
+		check.Fix = "check the -url and -chroma-* flags or the CHROMA_* environment variables"
+		return nil, check
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, doctorTimeout)
+	defer cancel()
+	version, err := client.Version(ctx)
+	if err != nil {
+		client.Close()
+		check.Detail = fmt.Sprintf("%s is unreachable: %v", opts.URL, err)
+		check.Fix = "start ChromaDB with cls up, or point -url at it"
+		return nil, check
+	}
+
+	check.Status, check.Detail = CheckOK, fmt.Sprintf("%s, version %s", opts.URL, version)
+	return client, check
+}
+
+func checkOllama(ctx context.Context) Check {
+	ctx, cancel := context.WithTimeout(ctx, doctorTimeout)
+	defer cancel()
+
+	check := Check{Name: "ollama", Status: CheckFail}
+	digest, err := ollamaModelDigest(ctx, store.DefaultEmbedderURL, store.DefaultEmbedderModel)
+	switch {
+	case errors.Is(err, errModelNotPulled):
+		check.Detail = fmt.Sprintf("%s is not pulled", store.DefaultEmbedderModel)
+		check.Fix = "run ollama pull " + store.DefaultEmbedderModel
+	case err != nil:
+		check.Detail = fmt.Sprintf("%s is unreachable: %v", store.DefaultEmbedderURL, err)
+		check.Fix = "start Ollama with ollama serve"
+	default:
+		check.Status = CheckOK
+		check.Detail = fmt.Sprintf("%s is pulled (%.12s)", store.DefaultEmbedderModel, digest)
+	}
+
+	return check
+}
+
+func checkCollection(ctx context.Context, client ChromaClient, collection string) Check {
+	check := Check{Name: "collection", Status: CheckFail}
+
+	infos, err := client.ListCollections(ctx)
+	if err != nil {
+		check.Detail = err.Error()
+		return check
+	}
+	if !slices.ContainsFunc(infos, func(i CollectionInfo) bool { return i.Name == collection || i.Alias == collection }) {
+		check.Status = CheckWarn
+		check.Detail = fmt.Sprintf("%s does not exist", collection)
+		check.Fix = "run cls index in the project, or pick another collection with -collection"
+		return check
+	}
+
+	coll, err := client.GetCollection(ctx, collection)
+	if err != nil {
+		check.Detail = err.Error()
+		return check
+	}
+	if err := checkEmbedder(coll.Metadata()); err != nil {
+		check.Detail = err.Error()
+		check.Fix = "reindex the collection, or delete it and index again"
+		return check
+	}
+
+	count, err := coll.Count(ctx)
+	switch {
+	case err != nil:
+		check.Detail = err.Error()
+	case count == 0:
+		check.Status = CheckWarn
+		check.Detail = fmt.Sprintf("%s is empty", collection)
+		check.Fix = "run cls index"
+	default:
+		check.Status = CheckOK
+		check.Detail = fmt.Sprintf("%s holds %d documents", collection, count)
+	}
+
+	return check
+}
+
+func checkConfig() Check {
+	root := projectRoot(".")
+	path := filepath.Join(root, configFile)
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		return Check{Name: "config", Status: CheckOK, Detail: "no " + configFile + " in " + root}
+	}
+
+	scopes, err := loadScopes(root)
+	if err != nil {
+		return Check{Name: "config", Status: CheckFail, Detail: err.Error(), Fix: "fix " + path}
+	}
+
+	return Check{Name: "config", Status: CheckOK, Detail: fmt.Sprintf("%s defines %d scopes", path, len(scopes))}
+}
+
+func checkState() Check {
+	check := Check{Name: "state", Status: CheckFail, Fix: "make sure $XDG_STATE_HOME or ~/.local/state is writable"}
+
+	dir, err := stateDir()
+	if err != nil {
+		check.Detail = err.Error()
+		return check
+	}
+
+	probe, err := os.CreateTemp(dir, "doctor-*")
+	if err != nil {
+		check.Detail = fmt.Sprintf("%s is not writable: %v", dir, err)
+		return check
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+
+	var size int64
+	files := 0
+	filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			if fi, err := d.Info(); err == nil {
+				size += fi.Size()
+				files++
+			}
+		}
+		return nil
+	})
+
+	check.Status, check.Fix = CheckOK, ""
+	check.Detail = fmt.Sprintf("%s holds %d files, %s", dir, files, formatBytes(size))
+	return check
+}
+
+func doctorCommand(chromaOpts ChromaOptions, collection string, printer *Printer, logger *slog.Logger) {
+	checks := runDoctor(context.Background(), chromaOpts, collection, logger)
+	printer.Doctor(checks)
+
+	if slices.ContainsFunc(checks, func(c Check) bool { return c.Status == CheckFail }) {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"cmp"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/karitham/cls/dirextractor"
+	"github.com/karitham/cls/index"
+)
+
+type PlannedFile struct {
+	Path   string
+	Size   int64
+	Chunks int
+}
+
+// IndexPlan describes what an index run would send to the embedder.
+type IndexPlan struct {
+	Files      []PlannedFile
+	Chunks     int
+	Bytes      int64
+	EmbedCalls int
+	Walk       dirextractor.Stats
+}
+
+// planIndex walks and filters targetPath exactly like an index run would,
+// and chunks the files found without embedding them.
+func planIndex(targetPath string, walkOpts WalkOptions, addOpts AddOptions, logger *slog.Logger) (IndexPlan, error) {
+	var plan IndexPlan
+	files, walk, err := collectFiles(targetPath, walkOpts, logger)
+	if err != nil {
+		return plan, err
+	}
+	plan.Walk = walk
+	for _, fi := range files {
+		f := PlannedFile{Path: fi.Path, Size: fi.Size, Chunks: plannedChunks(fi.Path, addOpts, logger)}
+		plan.Files = append(plan.Files, f)
+		plan.Chunks += f.Chunks
+		plan.Bytes += f.Size
+	}
+
+	batchSize := cmp.Or(addOpts.BatchSize, index.DefaultBatchSize)
+	plan.EmbedCalls = (plan.Chunks + batchSize - 1) / batchSize
+
+	return plan, nil
+}
+
+// plannedChunks returns how many chunks the file at path is split into,
+// counting files that cannot be read or chunked as one.
+func plannedChunks(path string, addOpts AddOptions, logger *slog.Logger) int {
+	content, err := index.ReadFile(path)
+	if err != nil {
+		logger.Warn("Failed to read file", "path", path, "error", err)
+		return 1
+	}
+	lines := strings.Count(content, "\n")
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		lines++
+	}
+	chunks, err := addOpts.Chunk(path, content, lines)
+	if err != nil {
+		logger.Warn("Failed to chunk file", "path", path, "error", err)
+		return 1
+	}
+
+	return len(chunks)
+}
+
+func dryRunIndex(targetPath string, walkOpts WalkOptions, addOpts AddOptions, printer *Printer, logger *slog.Logger) {
+	plan, err := planIndex(targetPath, walkOpts, addOpts, logger)
+	if err != nil {
+		logger.Error("Failed to list files", "error", err)
+		os.Exit(1)
+	}
+
+	printer.IndexPlan(plan)
+}
+
+func (p *Printer) IndexPlan(plan IndexPlan) {
+	for _, f := range plan.Files {
+		if p.plain {
+			p.Message("file %s bytes %d chunks %d", f.Path, f.Size, f.Chunks)
+			continue
+		}
+		p.Message("%10s  %3d chunks  %s", formatBytes(f.Size), f.Chunks, f.Path)
+	}
+
+	if p.plain {
+		p.Message("files %d chunks %d bytes %d embed-calls %d", len(plan.Files), plan.Chunks, plan.Bytes, plan.EmbedCalls)
+		p.WalkStats(plan.Walk)
+		return
+	}
+
+	p.Message("")
+	p.WalkStats(plan.Walk)
+	p.Message("Would index %d files as %d chunks (%s) using %d embedding calls",
+		len(plan.Files), plan.Chunks, formatBytes(plan.Bytes), plan.EmbedCalls)
+}
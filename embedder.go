@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+
+	"github.com/karitham/cls/store"
+)
+
+// checkEmbedder reports whether the embedder recorded in md matches the one
+// used for queries. Collections indexed before embedders were recorded pass.
+func checkEmbedder(md map[string]any) error {
+	name, _ := md[store.EmbedderKey].(string)
+	model, _ := md[store.EmbedderModelKey].(string)
+	if name == "" && model == "" {
+		return nil
+	}
+
+	if name != store.DefaultEmbedder || model != store.DefaultEmbedderModel {
+		return fmt.Errorf("collection was built with %s/%s%s but queries use %s/%s", name, model, dimensionsSuffix(md), store.DefaultEmbedder, store.DefaultEmbedderModel)
+	}
+
+	return nil
+}
+
+func dimensionsSuffix(md map[string]any) string {
+	if d, ok := md[store.EmbedderDimensionsKey]; ok {
+		return fmt.Sprintf(" (%v dimensions)", d)
+	}
+
+	return ""
+}
+
+// verifyEmbedder exits when coll was built with another embedder, unless
+// force is set, in which case it only warns.
+func verifyEmbedder(coll Collection, force bool, logger *slog.Logger) {
+	err := checkEmbedder(coll.Metadata())
+	if err == nil {
+		return
+	}
+
+	if force {
+		logger.Warn("Embedder mismatch, results may be meaningless", "error", err)
+		return
+	}
+
+	logger.Error("Embedder mismatch, reindex the collection or pass -force", "error", err)
+	os.Exit(1)
+}
+
+// newEmbedder returns the embedder shared by every client of the process, so
+// queries take priority over indexing across them.
+var newEmbedder = sync.OnceValues(func() (store.Embedder, error) {
+	ef, err := store.NewOllamaEmbedder(store.DefaultEmbedderURL, store.DefaultEmbedderModel)
+	if err != nil {
+		return nil, err
+	}
+
+	return newPriorityEmbedder(ef), nil
+})
+
+// priorityEmbedder shares one embedder between interactive queries and
+// background indexing, as done by daemon -index. Queries go first: document
+// batches wait while any query is being embedded, so a running reindex does
+// not make searches time out.
+type priorityEmbedder struct {
+	store.Embedder
+
+	mu          sync.Mutex
+	interactive int
+	// idle is closed while no query is being embedded.
+	idle chan struct{}
+}
+
+func newPriorityEmbedder(ef store.Embedder) *priorityEmbedder {
+	idle := make(chan struct{})
+	close(idle)
+
+	return &priorityEmbedder{Embedder: ef, idle: idle}
+}
+
+func (p *priorityEmbedder) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	p.mu.Lock()
+	if p.interactive == 0 {
+		p.idle = make(chan struct{})
+	}
+	p.interactive++
+	p.mu.Unlock()
+
+	defer func() {
+		p.mu.Lock()
+		if p.interactive--; p.interactive == 0 {
+			close(p.idle)
+		}
+		p.mu.Unlock()
+	}()
+
+	defer profiler.Time(store.StageEmbed)()
+	return p.Embedder.EmbedQuery(ctx, text)
+}
+
+func (p *priorityEmbedder) EmbedDocuments(ctx context.Context, texts []string) ([][]float32, error) {
+	p.mu.Lock()
+	idle := p.idle
+	p.mu.Unlock()
+
+	select {
+	case <-idle:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	defer profiler.Time(store.StageEmbed)()
+	return p.Embedder.EmbedDocuments(ctx, texts)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+const expandPrompt = `Write %d different phrasings of the following code search query, one per line, without numbering or any other text.
+
+Query: %s`
+
+// ExpandOptions selects the model paraphrasing queries.
+type ExpandOptions struct {
+	N     int
+	Model string
+	URL   string
+}
+
+// expandQuery asks an LLM for up to opts.N paraphrases of query.
+func expandQuery(ctx context.Context, query string, opts ExpandOptions) ([]string, error) {
+	chat, err := NewChatClient("ollama", opts.URL, "")
+	if err != nil {
+		return nil, err
+	}
+
+	answer, err := chat.Chat(ctx, []ChatMessage{
+		{Role: "user", Content: fmt.Sprintf(expandPrompt, opts.N, query)},
+	}, ChatOptions{Model: opts.Model, Temperature: 0.7})
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand query: %w", err)
+	}
+
+	var paraphrases []string
+	for _, line := range strings.Split(answer, "\n") {
+		line = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "-*0123456789.) "))
+		if line == "" || strings.EqualFold(line, query) {
+			continue
+		}
+		paraphrases = append(paraphrases, line)
+		if len(paraphrases) == opts.N {
+			break
+		}
+	}
+
+	return paraphrases, nil
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/karitham/cls/buildinfo"
+)
+
+// optionalExtensions maps extensions of formats supported by optional
+// features to the feature and build tag enabling them. PDF is the only one:
+// cls has no tree-sitter chunker, TUI or web UI to leave out of the default
+// binary, and those would register their feature the same way if added.
+var optionalExtensions = map[string][2]string{
+	".pdf": {"pdf", "pdf"},
+}
+
+// checkIndexable reports a helpful error when path is a single file whose
+// format needs a feature missing from this build.
+func checkIndexable(path string) error {
+	feature, ok := optionalExtensions[strings.ToLower(filepath.Ext(path))]
+	if !ok {
+		return nil
+	}
+
+	return buildinfo.Require(feature[0], feature[1])
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"log/slog"
+	"os"
+	"slices"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// federatedConcurrency is how many collections are searched at once.
+const federatedConcurrency = 4
+
+// federatedQuery searches every collection of collections, or every
+// collection made by cls when all is set, and prints the best results of
+// them all labelled with their collection. Collections built with another
+// embedder are skipped, their distances not being comparable.
+func federatedQuery(chromaOpts ChromaOptions, collections []string, all bool, query string, opts QueryOptions, printer *Printer, logger *slog.Logger) {
+	ctx := context.Background()
+
+	client, err := newStore(chromaOpts, logger)
+	if err != nil {
+		logger.Error("Failed to create ChromaDB client", "error", err)
+		os.Exit(1)
+	}
+	defer client.Close()
+
+	if all {
+		infos, err := client.ListCollections(ctx)
+		if err != nil {
+			logger.Error("Failed to list collections", "error", err)
+			os.Exit(1)
+		}
+		collections = nil
+		for _, info := range infos {
+			if info.Managed {
+				collections = append(collections, cmp.Or(info.Alias, info.Name))
+			}
+		}
+	}
+	collections = slices.Compact(slices.Sorted(slices.Values(collections)))
+
+	var (
+		mu      sync.Mutex
+		results []QueryResult
+		failed  int
+	)
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(federatedConcurrency)
+	for _, name := range collections {
+		g.Go(func() error {
+			found, err := searchCollection(ctx, client, name, query, opts, logger)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				logger.Warn("Skipping collection", "collection", name, "error", err)
+				failed++
+				return nil
+			}
+			results = append(results, found...)
+			return nil
+		})
+	}
+	g.Wait()
+	if failed == len(collections) {
+		logger.Error("No collection could be queried", "collections", collections)
+		os.Exit(1)
+	}
+
+	slices.SortStableFunc(results, func(a, b QueryResult) int {
+		if opts.Rerank.Provider != "" {
+			return cmp.Compare(b.Score, a.Score)
+		}
+		return cmp.Compare(a.Distance, b.Distance)
+	})
+	results = results[:min(len(results), opts.N)]
+
+	terms := expandTerms(queryTerms(query))
+	locateMatches(results, terms)
+	printer.SetHighlight(terms)
+	markMissing(results)
+
+	for _, name := range collections {
+		n := 0
+		for _, r := range results {
+			if r.Collection == name {
+				n++
+			}
+		}
+		if err := recordQueryEvent(name, query, n); err != nil {
+			logger.Warn("Failed to record query analytics", "error", err)
+		}
+	}
+
+	printer.Results(results)
+	if len(results) == 0 && opts.MinScore > 0 {
+		os.Exit(exitNoMatch)
+	}
+}
+
+func searchCollection(ctx context.Context, client ChromaClient, name, query string, opts QueryOptions, logger *slog.Logger) ([]QueryResult, error) {
+	coll, err := client.GetCollection(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkEmbedder(coll.Metadata()); err != nil && !opts.Force {
+		return nil, err
+	}
+
+	results, err := Search(ctx, coll, name, query, opts, logger)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Callers > 0 {
+		addCallers(name, results, opts.Callers, logger)
+	}
+	for i := range results {
+		results[i].Collection = name
+	}
+
+	return results, nil
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"time"
+)
+
+const (
+	lastQueryState = "last_query.json"
+	feedbackState  = "feedback.jsonl"
+)
+
+type LastQuery struct {
+	Collection string            `json:"collection"`
+	Query      string            `json:"query"`
+	Results    []LastQueryResult `json:"results"`
+}
+
+type LastQueryResult struct {
+	ID       string  `json:"id"`
+	Path     string  `json:"path"`
+	Distance float64 `json:"distance"`
+}
+
+type Judgment struct {
+	Collection string    `json:"collection"`
+	Query      string    `json:"query"`
+	ID         string    `json:"id"`
+	Path       string    `json:"path"`
+	Distance   float64   `json:"distance"`
+	Relevant   bool      `json:"relevant"`
+	Time       time.Time `json:"time"`
+}
+
+// EvalCase is one query of an evaluation set, as consumed by an eval
+// harness: the ids judged relevant and irrelevant for that query.
+type EvalCase struct {
+	Collection string   `json:"collection"`
+	Query      string   `json:"query"`
+	Relevant   []string `json:"relevant"`
+	Irrelevant []string `json:"irrelevant,omitempty"`
+}
+
+func saveLastQuery(collection, query string, results []QueryResult) error {
+	last := LastQuery{Collection: collection, Query: query}
+	for _, r := range results {
+		last.Results = append(last.Results, LastQueryResult{ID: r.ID, Path: r.Path, Distance: r.Distance})
+	}
+
+	return writeState(lastQueryState, last)
+}
+
+// recordFeedback stores a judgment for a result of the last query. ref is
+// either the 1-based result number or a document id.
+func recordFeedback(ref string, relevant bool) (Judgment, error) {
+	last, r, err := resolveResult(ref)
+	if err != nil {
+		return Judgment{}, err
+	}
+
+	j := Judgment{
+		Collection: last.Collection,
+		Query:      last.Query,
+		ID:         r.ID,
+		Path:       r.Path,
+		Distance:   r.Distance,
+		Relevant:   relevant,
+		Time:       time.Now().UTC(),
+	}
+
+	return j, appendState(feedbackState, j)
+}
+
+// resolveResult finds a result of the last query by its 1-based number or
+// its document id.
+func resolveResult(ref string) (LastQuery, LastQueryResult, error) {
+	var last LastQuery
+	if err := readState(lastQueryState, &last); err != nil {
+		return LastQuery{}, LastQueryResult{}, err
+	}
+	if last.Query == "" {
+		return LastQuery{}, LastQueryResult{}, fmt.Errorf("no previous query")
+	}
+
+	idx := slices.IndexFunc(last.Results, func(r LastQueryResult) bool { return r.ID == ref })
+	if n, err := strconv.Atoi(ref); idx < 0 && err == nil && n >= 1 && n <= len(last.Results) {
+		idx = n - 1
+	}
+	if idx < 0 {
+		return last, LastQueryResult{}, fmt.Errorf("result %q not found in last query %q", ref, last.Query)
+	}
+
+	return last, last.Results[idx], nil
+}
+
+func loadJudgments(collection string) ([]Judgment, error) {
+	all, err := readStateLines[Judgment](feedbackState)
+	if err != nil {
+		return nil, err
+	}
+
+	return slices.DeleteFunc(all, func(j Judgment) bool {
+		return collection != "" && j.Collection != collection
+	}), nil
+}
+
+// Calibrate derives a distance cutoff for a collection from its judgments:
+// the midpoint between the mean distance of relevant and irrelevant results.
+// It reports false until both kinds of judgment exist.
+func Calibrate(judgments []Judgment) (float64, bool) {
+	var (
+		relSum, irrSum float64
+		relN, irrN     int
+	)
+	for _, j := range judgments {
+		if j.Relevant {
+			relSum += j.Distance
+			relN++
+		} else {
+			irrSum += j.Distance
+			irrN++
+		}
+	}
+
+	if relN == 0 || irrN == 0 {
+		return 0, false
+	}
+
+	return (relSum/float64(relN) + irrSum/float64(irrN)) / 2, true
+}
+
+// ExportEvalSet writes judgments grouped by query as JSON lines.
+func ExportEvalSet(w io.Writer, judgments []Judgment) error {
+	cases := map[[2]string]*EvalCase{}
+	var order [][2]string
+	for _, j := range judgments {
+		key := [2]string{j.Collection, j.Query}
+		c, ok := cases[key]
+		if !ok {
+			c = &EvalCase{Collection: j.Collection, Query: j.Query}
+			cases[key] = c
+			order = append(order, key)
+		}
+
+		// later judgments override earlier ones for the same id
+		c.Relevant = slices.DeleteFunc(c.Relevant, func(id string) bool { return id == j.ID })
+		c.Irrelevant = slices.DeleteFunc(c.Irrelevant, func(id string) bool { return id == j.ID })
+		if j.Relevant {
+			c.Relevant = append(c.Relevant, j.ID)
+		} else {
+			c.Irrelevant = append(c.Irrelevant, j.ID)
+		}
+	}
+
+	slices.SortStableFunc(order, func(a, b [2]string) int {
+		return cmp.Or(cmp.Compare(a[0], b[0]), cmp.Compare(a[1], b[1]))
+	})
+
+	enc := json.NewEncoder(w)
+	for _, key := range order {
+		if err := enc.Encode(cases[key]); err != nil {
+			return fmt.Errorf("failed to write eval case: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"slices"
+
+	"github.com/karitham/cls/index"
+)
+
+// findInPath queries path in one step: the path is indexed into its own
+// collection the first time, and the cached index is reused afterwards.
+func findInPath(chromaOpts ChromaOptions, collection, path, query string, reindex bool, secrets index.SecretPolicy, opts QueryOptions, printer *Printer, logger *slog.Logger) {
+	ctx := context.Background()
+
+	client, err := newStore(chromaOpts, logger)
+	if err != nil {
+		logger.Error("Failed to create ChromaDB client", "error", err)
+		os.Exit(1)
+	}
+	defer client.Close()
+
+	coll, err := client.GetOrCreateCollection(ctx, collection)
+	if err != nil {
+		logger.Error("Failed to get/create collection", "error", err)
+		os.Exit(1)
+	}
+
+	count, err := coll.Count(ctx)
+	if err != nil {
+		logger.Error("Failed to count documents", "error", err)
+		os.Exit(1)
+	}
+
+	if count == 0 || reindex {
+		files, _, err := collectFiles(path, WalkOptions{}, logger)
+		if err != nil {
+			logger.Error("Failed to list files", "error", err)
+			os.Exit(1)
+		}
+		logger.Info("Indexing path", "path", path, "files", len(files), "collection", collection)
+
+		if _, err := AddDocuments(ctx, coll, slices.Values(filePaths(files)), AddOptions{Options: index.Options{Root: path, Secrets: secrets}}, logger); err != nil {
+			logger.Error("Failed to add documents to collection", "error", err)
+			os.Exit(1)
+		}
+	} else {
+		logger.Info("Using cached index", "collection", collection, "documents", count)
+	}
+
+	runQuery(ctx, coll, collection, query, opts, printer, logger)
+}
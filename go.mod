@@ -3,12 +3,16 @@ module github.com/karitham/cls
 go 1.25.2
 
 require (
+	github.com/alecthomas/chroma/v2 v2.14.0
 	github.com/amikos-tech/chroma-go v0.2.5
 	github.com/k0kubun/pp/v3 v3.5.0
 	golang.org/x/sync v0.15.0
+	google.golang.org/grpc v1.68.1
+	google.golang.org/protobuf v1.36.11
 )
 
 require (
+	github.com/dlclark/regexp2 v1.11.0 // indirect
 	github.com/go-viper/mapstructure/v2 v2.4.0 // indirect
 	github.com/google/uuid v1.6.0 // indirect
 	github.com/mattn/go-colorable v0.1.14 // indirect
@@ -16,6 +20,8 @@ require (
 	github.com/oklog/ulid v1.3.1 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
 	github.com/yalue/onnxruntime_go v1.22.0 // indirect
+	golang.org/x/net v0.39.0 // indirect
 	golang.org/x/sys v0.32.0 // indirect
 	golang.org/x/text v0.26.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20241021214115-324edc3d5d38 // indirect
 )
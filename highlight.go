@@ -0,0 +1,117 @@
+package main
+
+import (
+	"os"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/formatters"
+	"github.com/alecthomas/chroma/v2/lexers"
+)
+
+// matchToken is the token type given to the words matching a query term,
+// negative as the types chroma styles but never lexes.
+const matchToken chroma.TokenType = -100
+
+// highlightStyle colors tokens with the basic ANSI colors of the terminal's
+// palette, leaving the rest of the code in its default color.
+var highlightStyle = chroma.MustNewStyle("cls", chroma.StyleEntries{
+	chroma.Keyword:       "#7f007f",
+	chroma.LiteralString: "#007f00",
+	chroma.Comment:       "#555555",
+	chroma.LiteralNumber: "#007f7f",
+	matchToken:           "bold #ffff00",
+})
+
+// colorEnabled reports whether output to f should be colored: f must be a
+// terminal and NO_COLOR unset, as https://no-color.org asks.
+func colorEnabled(f *os.File) bool {
+	if _, ok := os.LookupEnv("NO_COLOR"); ok || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// highlightLines colors the tokens of lines, consecutive lines of code in
+// lang, and the words matching one of the lower case terms. The lines are
+// lexed together so strings and comments spanning several are colored
+// whole.
+func highlightLines(lines []string, lang string, terms []string) []string {
+	lexer := lexers.Get(lang)
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	tokens, err := chroma.Tokenise(chroma.Coalesce(lexer), nil, strings.Join(lines, "\n"))
+	if err != nil {
+		return lines
+	}
+
+	out := make([]string, len(lines))
+	for i, line := range chroma.SplitTokensIntoLines(tokens) {
+		if i == len(out) {
+			break
+		}
+		var marked []chroma.Token
+		for _, t := range line {
+			t.Value = strings.TrimSuffix(t.Value, "\n")
+			marked = append(marked, markTerms(t, terms)...)
+		}
+		var sb strings.Builder
+		if err := formatters.TTY16.Format(&sb, highlightStyle, chroma.Literator(marked...)); err != nil {
+			out[i] = lines[i]
+			continue
+		}
+		out[i] = sb.String()
+	}
+
+	return out
+}
+
+// markTerms splits t around the words matching one of the terms, which
+// become matchToken tokens.
+func markTerms(t chroma.Token, terms []string) []chroma.Token {
+	var (
+		out  []chroma.Token
+		from int
+	)
+	for i := 0; i < len(t.Value); {
+		if !isWordByte(t.Value[i]) {
+			i++
+			continue
+		}
+		n := i + 1
+		for n < len(t.Value) && isWordByte(t.Value[n]) {
+			n++
+		}
+		if matchesTerm(t.Value[i:n], terms) {
+			if from < i {
+				out = append(out, chroma.Token{Type: t.Type, Value: t.Value[from:i]})
+			}
+			out = append(out, chroma.Token{Type: matchToken, Value: t.Value[i:n]})
+			from = n
+		}
+		i = n
+	}
+	if from < len(t.Value) {
+		out = append(out, chroma.Token{Type: t.Type, Value: t.Value[from:]})
+	}
+
+	return out
+}
+
+func isWordByte(b byte) bool {
+	return b == '_' || b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= 0x80
+}
+
+func matchesTerm(word string, terms []string) bool {
+	lower := strings.ToLower(word)
+	for _, t := range terms {
+		if lower == t {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,130 @@
+package index
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Metadata keys of the git blame of a chunk.
+const (
+	AuthorKey      = "author"
+	AuthorEmailKey = "author_email"
+	CommitKey      = "commit"
+	CommittedAtKey = "committed_at"
+)
+
+// notCommitted is the commit git blame reports for uncommitted lines.
+const notCommitted = "0000000000000000000000000000000000000000"
+
+// blameLine is the commit a line of a file was last changed in.
+type blameLine struct {
+	Commit string
+	Author string
+	Email  string
+	Time   time.Time
+}
+
+// Blame sums up the git blame of a chunk: the author of most of its lines
+// and the last commit changing it.
+type Blame struct {
+	Author string
+	Email  string
+	Commit string
+	Time   time.Time
+}
+
+// set adds b to the metadata md.
+func (b Blame) set(md map[string]any) {
+	md[AuthorKey] = b.Author
+	md[AuthorEmailKey] = b.Email
+	md[CommitKey] = b.Commit
+	md[CommittedAtKey] = b.Time.Unix()
+}
+
+// blameFile runs git blame on the file at path and returns its lines, from
+// line 1. Files outside a git repository are an error.
+func blameFile(path string) ([]blameLine, error) {
+	cmd := exec.Command("git", "-C", filepath.Dir(path), "blame", "--line-porcelain", "--", filepath.Base(path))
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git blame failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	var (
+		lines   []blameLine
+		current blameLine
+	)
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	scanner.Buffer(nil, 16<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "\t"):
+			// the line content ends the entry of a line
+			lines = append(lines, current)
+			current = blameLine{}
+		case current.Commit == "":
+			current.Commit, _, _ = strings.Cut(line, " ")
+		default:
+			key, value, _ := strings.Cut(line, " ")
+			switch key {
+			case "author":
+				current.Author = value
+			case "author-mail":
+				current.Email = strings.Trim(value, "<>")
+			case "committer-time":
+				if sec, err := strconv.ParseInt(value, 10, 64); err == nil {
+					current.Time = time.Unix(sec, 0)
+				}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read git blame: %w", err)
+	}
+
+	return lines, nil
+}
+
+// summarizeBlame returns the blame of lines start to end of lines, the whole
+// file when start is 0. Uncommitted lines are not counted, so a chunk never
+// committed has no blame.
+func summarizeBlame(lines []blameLine, start, end int) (Blame, bool) {
+	if start > 0 {
+		lines = lines[min(start-1, len(lines)):min(end, len(lines))]
+	}
+
+	var (
+		blame  Blame
+		counts = map[string]int{}
+		emails = map[string]string{}
+		most   int
+	)
+	for _, l := range lines {
+		if l.Commit == notCommitted {
+			continue
+		}
+		if l.Time.After(blame.Time) {
+			blame.Commit, blame.Time = l.Commit, l.Time
+		}
+		counts[l.Author]++
+		emails[l.Author] = l.Email
+		if n := counts[l.Author]; n > most || n == most && l.Author < blame.Author {
+			blame.Author, most = l.Author, n
+		}
+	}
+	if blame.Commit == "" {
+		return Blame{}, false
+	}
+	blame.Email = emails[blame.Author]
+
+	return blame, true
+}
@@ -0,0 +1,199 @@
+package index
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+
+	"github.com/karitham/cls/store"
+)
+
+// DuplicatesKey holds the paths, comma separated, of the other files having
+// the content of a document, which were not indexed themselves.
+const DuplicatesKey = "duplicates"
+
+// MinHash parameters: signatures of minhashRows*minhashBands hashes of the
+// shingles of minhashShingle words, files sharing a band being compared.
+const (
+	minhashShingle = 5
+	minhashBands   = 16
+	minhashRows    = 4
+)
+
+// minhashSeeds are the multipliers and offsets of the hash functions of
+// signatures, fixed so signatures are comparable across runs.
+var minhashSeeds = func() [minhashBands * minhashRows][2]uint64 {
+	var seeds [minhashBands * minhashRows][2]uint64
+	x := uint64(0x9e3779b97f4a7c15)
+	next := func() uint64 {
+		// splitmix64
+		x += 0x9e3779b97f4a7c15
+		z := x
+		z = (z ^ z>>30) * 0xbf58476d1ce4e5b9
+		z = (z ^ z>>27) * 0x94d049bb133111eb
+		return z ^ z>>31
+	}
+	for i := range seeds {
+		seeds[i] = [2]uint64{next() | 1, next()}
+	}
+	return seeds
+}()
+
+// copyOf is a file indexed once for the files found with its content.
+type copyOf struct {
+	root   string
+	rel    string
+	chunks int
+	sig    []uint64
+	copies []string
+}
+
+// dedupe tracks the content of the files added by a run, to index files
+// with the same content once.
+type dedupe struct {
+	// near is the estimated Jaccard similarity above which files are
+	// copies, exact copies only when 0.
+	near    float64
+	exact   map[[sha256.Size]byte]*copyOf
+	buckets []map[uint64][]*copyOf
+	seen    []*copyOf
+}
+
+func newDedupe(near float64) *dedupe {
+	d := &dedupe{near: near, exact: map[[sha256.Size]byte]*copyOf{}}
+	if near > 0 {
+		d.buckets = make([]map[uint64][]*copyOf, minhashBands)
+		for i := range d.buckets {
+			d.buckets[i] = map[uint64][]*copyOf{}
+		}
+	}
+
+	return d
+}
+
+// contentKey identifies content for dedupe.
+type contentKey struct {
+	sum [sha256.Size]byte
+	sig []uint64
+}
+
+func (d *dedupe) key(content string) contentKey {
+	k := contentKey{sum: sha256.Sum256([]byte(content))}
+	if d.near > 0 {
+		k.sig = minhashSignature(content)
+	}
+
+	return k
+}
+
+// match returns the file indexed with the content of k, if any.
+func (d *dedupe) match(k contentKey) *copyOf {
+	if c, ok := d.exact[k.sum]; ok {
+		return c
+	}
+	if k.sig == nil {
+		return nil
+	}
+
+	var (
+		best *copyOf
+		most float64
+	)
+	for band, bucket := range d.buckets {
+		for _, c := range bucket[bandHash(k.sig, band)] {
+			if sim := similarity(k.sig, c.sig); sim >= d.near && sim > most {
+				best, most = c, sim
+			}
+		}
+	}
+
+	return best
+}
+
+// add records rel of root, indexed as chunks, as having the content of k.
+func (d *dedupe) add(k contentKey, root, rel string, chunks int) {
+	c := &copyOf{root: root, rel: rel, chunks: chunks, sig: k.sig}
+	d.exact[k.sum] = c
+	d.seen = append(d.seen, c)
+	if k.sig == nil {
+		return
+	}
+	for band, bucket := range d.buckets {
+		h := bandHash(k.sig, band)
+		bucket[h] = append(bucket[h], c)
+	}
+}
+
+// record stores the paths of the copies found on the chunks of the files
+// they were copies of.
+func (d *dedupe) record(ctx context.Context, w store.Writer) error {
+	for _, c := range d.seen {
+		if len(c.copies) == 0 {
+			continue
+		}
+
+		records := make([]store.Record, c.chunks)
+		for i := range c.chunks {
+			records[i] = store.Record{
+				ID:       DocumentID(c.root, c.rel, i),
+				Metadata: map[string]any{DuplicatesKey: strings.Join(c.copies, ",")},
+			}
+		}
+		if err := w.Update(ctx, records); err != nil {
+			return fmt.Errorf("failed to record the copies of %s: %w", c.rel, err)
+		}
+	}
+
+	return nil
+}
+
+// minhashSignature returns the MinHash signature of the word shingles of
+// content, whose matching share estimates the Jaccard similarity of the
+// shingle sets of two files.
+func minhashSignature(content string) []uint64 {
+	sig := make([]uint64, len(minhashSeeds))
+	for i := range sig {
+		sig[i] = math.MaxUint64
+	}
+
+	words := strings.Fields(content)
+	for i := 0; i+minhashShingle <= max(len(words), minhashShingle); i++ {
+		h := fnv.New64a()
+		for _, w := range words[i:min(i+minhashShingle, len(words))] {
+			h.Write([]byte(w))
+			h.Write([]byte{0})
+		}
+		x := h.Sum64()
+		for j, s := range minhashSeeds {
+			sig[j] = min(sig[j], x*s[0]+s[1])
+		}
+	}
+
+	return sig
+}
+
+func bandHash(sig []uint64, band int) uint64 {
+	h := fnv.New64a()
+	var buf [8]byte
+	for _, v := range sig[band*minhashRows : (band+1)*minhashRows] {
+		binary.LittleEndian.PutUint64(buf[:], v)
+		h.Write(buf[:])
+	}
+
+	return h.Sum64()
+}
+
+func similarity(a, b []uint64) float64 {
+	same := 0
+	for i := range a {
+		if a[i] == b[i] {
+			same++
+		}
+	}
+
+	return float64(same) / float64(len(a))
+}
@@ -0,0 +1,60 @@
+package index
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+	"strconv"
+)
+
+// DocumentID identifies chunk of the file at relPath under the root
+// identified by root. Hashing the relative path keeps IDs stable when the
+// indexed tree moves and, unlike escaping separators, cannot make two paths
+// collide; hashing the root with it keeps files at the same path of two
+// roots indexed together apart.
+func DocumentID(root, relPath string, chunk int) string {
+	sum := sha256.Sum256([]byte(root + "\x00" + relPath))
+	return hex.EncodeToString(sum[:]) + "#chunk" + strconv.Itoa(chunk)
+}
+
+// SummarySuffix ends the IDs of summary documents.
+const SummarySuffix = "#summary"
+
+// SummaryID identifies the summary of the chunk id.
+func SummaryID(id string) string {
+	return id + SummarySuffix
+}
+
+// RelativePath returns path relative to root in slash form, or path itself
+// when it is not under root. A root that is the file itself yields its name.
+// Relative arguments are taken from the working directory, so a subtree
+// indexed from anywhere in a project gets the same paths.
+func RelativePath(root, path string) string {
+	absRoot, err := filepath.Abs(root)
+	if err != nil || root == "" {
+		return filepath.ToSlash(path)
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+
+	rel, err := filepath.Rel(absRoot, absPath)
+	switch {
+	case err != nil || !filepath.IsLocal(rel):
+		return filepath.ToSlash(path)
+	case rel == ".":
+		return filepath.Base(path)
+	}
+
+	return filepath.ToSlash(rel)
+}
+
+// absPath returns the absolute form of path, or path when it has none.
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+
+	return path
+}
@@ -0,0 +1,441 @@
+// Package index reads, chunks and embeds files into store collections: it
+// detects their language, masks secrets, skips copies and generated files,
+// and tags every chunk with the metadata queries filter on.
+package index
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"iter"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/karitham/cls/chunk"
+	"github.com/karitham/cls/indexer"
+	"github.com/karitham/cls/store"
+)
+
+// DefaultBatchSize is the number of documents embedded and sent to the store
+// per request when Options.BatchSize is not set.
+const DefaultBatchSize = 100
+
+// SummaryOfKey holds, on summary documents, the ID of the chunk they
+// summarize, store.VectorKey tagging them as the description space of
+// queries.
+const SummaryOfKey = "summary_of"
+
+// minSummaryLength is the length under which chunks are not summarized, as
+// they say little more than their summary would.
+const minSummaryLength = 200
+
+// deleteBatch is how many files have their previous chunks deleted at once.
+const deleteBatch = 100
+
+// Stages of a run timed through Options.Profiler.
+const (
+	StageRead   = "read"
+	StageSecret = "secrets"
+	StageChunk  = "chunk"
+)
+
+// Profiler times the stages of a run. Time starts timing a call to stage
+// and returns the function ending it.
+type Profiler interface {
+	Time(stage string) func()
+}
+
+// Summarizer writes a one paragraph summary of text, a chunk of the file at
+// path, embedded along the chunk.
+type Summarizer interface {
+	Summarize(ctx context.Context, path, text string) (string, error)
+}
+
+// Options controls how documents are added.
+type Options struct {
+	// Progress receives progress events, it may be nil.
+	Progress *Progress
+	// Profiler, when set, times the stages of the run.
+	Profiler Profiler
+	// EstimatedFiles is how many files paths is expected to yield, the total
+	// of progress events, 0 when unknown.
+	EstimatedFiles int
+	// Root is the indexed path, document IDs are derived from paths relative
+	// to it.
+	Root string
+	// RootID identifies Root in document IDs and in RootKey, so stored paths
+	// can be resolved on other machines. It defaults to a hash of the
+	// absolute Root.
+	RootID  string
+	Secrets SecretPolicy
+	// BatchSize is the number of documents embedded per request, and
+	// Concurrency the number of requests in flight. Zero means the default.
+	BatchSize   int
+	Concurrency int
+	// RateLimit caps embedding requests per second, zero means no limit.
+	RateLimit float64
+	// MaxMemory bounds the bytes of chunks waiting to be embedded, pausing
+	// the walk while it is reached. Zero means no limit.
+	MaxMemory int64
+	// Languages restricts indexing to files of these languages when set.
+	Languages []string
+	// IncludeGenerated indexes generated and vendored files, which are
+	// skipped otherwise.
+	IncludeGenerated bool
+	// FS, when set, holds the files to add, as for archives and crawled
+	// sites, whose paths are stored as is under RootID.
+	FS fs.FS
+	// Records, when set, splits datasets into a document per record.
+	Records *RecordOptions
+	// Blame stores the git blame of every chunk of the files on disk.
+	Blame bool
+	// Symbols, when set, receives the definitions of the indexed files.
+	Symbols SymbolSet
+	// Summarizer, when set, also embeds a summary of every chunk.
+	Summarizer Summarizer
+	// RunID tags the documents with the index run writing them.
+	RunID string
+	// KeepDuplicates indexes every file, where files with the content of
+	// one already added are otherwise recorded on it instead. Files are
+	// also copies when NearDuplicates is set and their estimated
+	// similarity reaches it.
+	KeepDuplicates bool
+	NearDuplicates float64
+}
+
+// Chunk splits content, the text of the file at path spanning lines lines,
+// into the chunks indexed for it.
+func (opts Options) Chunk(path, content string, lines int) ([]chunk.Chunk, error) {
+	if opts.Records != nil && isRecordFile(path) {
+		return opts.Records.chunk(path, content)
+	}
+
+	return chunk.Split(path, content, lines)
+}
+
+// time times a call to stage with the profiler, if any.
+func (opts Options) time(stage string) func() {
+	if opts.Profiler == nil {
+		return func() {}
+	}
+
+	return opts.Profiler.Time(stage)
+}
+
+// Stats reports the outcome of adding documents.
+type Stats struct {
+	Added         int `json:"added"`
+	ReadErrors    int `json:"read_errors"`
+	SecretsMasked int `json:"secrets_masked"`
+	SecretFiles   int `json:"secret_files"`
+	// OtherLanguages counts the files skipped for not being in
+	// Options.Languages.
+	OtherLanguages int `json:"other_languages"`
+	// Generated counts the generated or vendored files skipped.
+	Generated int `json:"generated"`
+	// Summaries counts the chunk summaries added along the chunks.
+	Summaries int `json:"summaries,omitempty"`
+	// Copies counts the files skipped for having the content of another.
+	Copies int `json:"copies,omitempty"`
+	// Failed counts the documents that could not be embedded or stored.
+	Failed int `json:"failed,omitempty"`
+}
+
+// Failures counts the files and documents of the run that failed.
+func (s Stats) Failures() int {
+	return s.ReadErrors + s.Failed
+}
+
+// ErrReadOnly is returned when adding documents to a read-only collection.
+var ErrReadOnly = errors.New("collection is read-only")
+
+// RootDir returns the directory the documents of path are stored relative
+// to: path itself, or the directory of a file.
+func RootDir(path string) string {
+	if fi, err := os.Stat(path); err == nil && !fi.IsDir() {
+		return filepath.Dir(path)
+	}
+
+	return path
+}
+
+// AddDocuments indexes the files of paths into coll, through its writer.
+func AddDocuments(ctx context.Context, coll store.Collection, paths iter.Seq[string], opts Options, logger *slog.Logger) (Stats, error) {
+	w := coll.Writer()
+	if w == nil {
+		return Stats{}, ErrReadOnly
+	}
+
+	return BatchAddDocuments(ctx, w, paths, opts, logger)
+}
+
+// BatchAddDocuments reads, chunks and adds the files of paths as they are
+// yielded, so a tree is indexed while it is walked and only the batches in
+// flight are held in memory.
+func BatchAddDocuments(ctx context.Context, w store.Writer, paths iter.Seq[string], opts Options, logger *slog.Logger) (Stats, error) {
+	progress := opts.Progress
+	var (
+		stats Stats
+		ix    *indexer.Indexer
+		// embedTotal is the number of documents expected, extrapolated
+		// from the chunks of the files read so far
+		embedTotal atomic.Int64
+		// summaries counts the summaries added, told from the chunks by
+		// their ID
+		summaries atomic.Int64
+	)
+
+	ix = indexer.New(w,
+		indexer.WithBatchSize(cmp.Or(opts.BatchSize, DefaultBatchSize)),
+		indexer.WithConcurrency(opts.Concurrency),
+		indexer.WithRateLimit(opts.RateLimit),
+		indexer.WithMaxBytes(opts.MaxMemory),
+		indexer.WithErrorHandler(func(batch []indexer.Document, err error) {
+			logger.Warn("Failed to add batch", "documents", len(batch), "error", err)
+		}),
+		indexer.WithFlushHandler(func(batch []indexer.Document) {
+			for _, doc := range batch {
+				if strings.HasSuffix(doc.ID, SummarySuffix) {
+					summaries.Add(1)
+				}
+			}
+			progress.Report(Event{Phase: PhaseEmbed, Done: ix.Added(), Total: int(embedTotal.Load())})
+		}),
+	)
+
+	// paths are stored relative to the root, which is identified so they
+	// resolve on any machine having it
+	root := RootDir(opts.Root)
+	id := opts.RootID
+	read, stat := ReadFile, os.Stat
+	switch {
+	case opts.FS != nil:
+		// paths are already relative, and never resolve on disk
+		root = ""
+		read = func(p string) (string, error) { return ReadFileFS(opts.FS, p) }
+		stat = func(p string) (fs.FileInfo, error) { return fs.Stat(opts.FS, p) }
+	case root != "" && id == "":
+		sum := sha256.Sum256([]byte(absPath(root)))
+		id = hex.EncodeToString(sum[:])[:16]
+	}
+
+	var dupes *dedupe
+	if !opts.KeepDuplicates {
+		dupes = newDedupe(opts.NearDuplicates)
+	}
+
+	indexedAt := time.Now().Unix()
+	licenses := newLicenseDetector(opts.FS, root)
+	submitted := 0
+	files := 0
+	var addErr error
+
+	// the chunks of files indexed before are deleted ahead of adding the new
+	// ones, as a file split into fewer chunks would otherwise keep its last
+	// ones
+	var (
+		pending      []indexer.Document
+		pendingPaths []string
+	)
+	flush := func() error {
+		if err := deleteStalePaths(ctx, w, id, pendingPaths); err != nil {
+			return fmt.Errorf("failed to delete the previous chunks: %w", err)
+		}
+		for _, doc := range pending {
+			submitted++
+			if err := ix.Add(ctx, doc); err != nil {
+				return err
+			}
+		}
+		total := submitted
+		if files > 0 && files < opts.EstimatedFiles {
+			total = submitted * opts.EstimatedFiles / files
+		}
+		embedTotal.Store(int64(total))
+		pending, pendingPaths = pending[:0], pendingPaths[:0]
+
+		return nil
+	}
+	for p := range paths {
+		progress.Report(Event{Phase: PhaseRead, Done: files, Total: max(opts.EstimatedFiles, files), Current: p})
+		files++
+
+		done := opts.time(StageRead)
+		data, err := read(p)
+		done()
+		if err != nil {
+			logger.Warn("Failed to read file", "path", p, "error", err)
+			stats.ReadErrors++
+			continue
+		}
+
+		if scanner := opts.Secrets.Scanner; scanner != nil {
+			done := opts.time(StageSecret)
+			findings := scanner.Scan(data)
+			done()
+			if len(findings) > 0 {
+				rules := secretRules(findings)
+				stats.SecretFiles++
+				switch opts.Secrets.Action {
+				case SecretsSkip:
+					logger.Warn("Skipping file containing secrets", "path", p, "rules", rules)
+					continue
+				case SecretsMask:
+					logger.Warn("Masking secrets", "path", p, "rules", rules, "count", len(findings))
+					data = scanner.Mask(data, findings)
+					stats.SecretsMasked += len(findings)
+				default:
+					logger.Warn("File contains secrets", "path", p, "rules", rules)
+				}
+			}
+		}
+
+		rel := RelativePath(root, p)
+		md := fileMetadata(p, rel, data, stat)
+		if len(opts.Languages) > 0 && !slices.Contains(opts.Languages, md.Language) {
+			stats.OtherLanguages++
+			continue
+		}
+		if !opts.IncludeGenerated && (md.Generated || md.Vendored) {
+			logger.Debug("Skipping generated or vendored file", "path", p)
+			stats.Generated++
+			continue
+		}
+
+		var key contentKey
+		if dupes != nil {
+			key = dupes.key(data)
+			if c := dupes.match(key); c != nil {
+				logger.Debug("Skipping copy of an indexed file", "path", p, "copy_of", c.rel)
+				c.copies = append(c.copies, rel)
+				pendingPaths = append(pendingPaths, rel)
+				stats.Copies++
+				if opts.Symbols != nil {
+					opts.Symbols.Set(id, rel, nil, nil)
+				}
+				continue
+			}
+		}
+
+		done = opts.time(StageChunk)
+		chunks, err := opts.Chunk(p, data, md.Lines)
+		done()
+		if err != nil {
+			logger.Warn("Failed to chunk file", "path", p, "error", err)
+			stats.ReadErrors++
+			continue
+		}
+		if dupes != nil {
+			dupes.add(key, id, rel, len(chunks))
+		}
+
+		if opts.Symbols != nil {
+			symbols, calls := extractSymbols(md.Language, data), extractCalls(data)
+			chunkSymbols(symbols, chunks, id, rel)
+			chunkSymbols(calls, chunks, id, rel)
+			opts.Symbols.Set(id, rel, symbols, firstCalls(calls))
+		}
+
+		var blame []blameLine
+		if opts.Blame && opts.FS == nil {
+			if blame, err = blameFile(p); err != nil {
+				logger.Debug("Failed to blame file", "path", p, "error", err)
+			}
+		}
+
+		license := licenses.Detect(p, data)
+		for i, chunk := range chunks {
+			cmd := md
+			if chunk.Language != "" {
+				cmd.Language = chunk.Language
+			}
+
+			md := cmd.metadata()
+			md[IndexedAtKey] = indexedAt
+			if chunk.StartLine > 0 {
+				md[StartLineKey] = int64(chunk.StartLine)
+				md[EndLineKey] = int64(chunk.EndLine)
+			}
+			maps.Copy(md, chunk.Metadata)
+			if id != "" {
+				md[RootKey] = id
+			}
+			if opts.RunID != "" {
+				md[RunKey] = opts.RunID
+			}
+			if license != "" {
+				md[LicenseKey] = license
+			}
+			if b, ok := summarizeBlame(blame, chunk.StartLine, chunk.EndLine); ok {
+				b.set(md)
+			}
+
+			pending = append(pending, indexer.Document{
+				ID:       DocumentID(id, rel, i),
+				Content:  chunk.Text,
+				Metadata: md,
+			})
+
+			if opts.Summarizer == nil || len(chunk.Text) < minSummaryLength {
+				continue
+			}
+			summary, err := opts.Summarizer.Summarize(ctx, rel, chunk.Text)
+			if err != nil || summary == "" {
+				logger.Warn("Failed to summarize chunk, indexing it without summary", "path", p, "chunk", i, "error", err)
+				continue
+			}
+			smd := maps.Clone(md)
+			smd[SummaryOfKey] = DocumentID(id, rel, i)
+			smd[store.VectorKey] = store.TargetDesc
+			pending = append(pending, indexer.Document{
+				ID:       SummaryID(DocumentID(id, rel, i)),
+				Content:  summary,
+				Metadata: smd,
+			})
+		}
+		pendingPaths = append(pendingPaths, rel)
+		if len(pendingPaths) >= deleteBatch {
+			if addErr = flush(); addErr != nil {
+				break
+			}
+		}
+	}
+	if addErr == nil {
+		addErr = flush()
+	}
+
+	// closed on errors too, so the batches in flight are waited for
+	err := errors.Join(addErr, ix.Close(ctx))
+	stats.Summaries = int(summaries.Load())
+	stats.Added = ix.Added() - stats.Summaries
+	stats.Failed = submitted - ix.Added()
+	if dupes != nil && err == nil {
+		if err := dupes.record(ctx, w); err != nil {
+			logger.Warn("Failed to record the paths of duplicate files", "error", err)
+		}
+	}
+
+	return stats, err
+}
+
+// deleteStalePaths deletes the documents of paths under rootID through w.
+// Documents indexed without a root are only replaced chunk by chunk, as
+// they cannot be told apart by root.
+func deleteStalePaths(ctx context.Context, w store.Writer, rootID string, paths []string) error {
+	if rootID == "" || len(paths) == 0 {
+		return nil
+	}
+
+	_, err := w.DeletePaths(ctx, rootID, paths)
+	return err
+}
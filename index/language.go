@@ -0,0 +1,178 @@
+package index
+
+import (
+	"maps"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Metadata keys of the language of documents, and of whether they are
+// vendored or generated code.
+const (
+	LanguageKey  = "language"
+	VendoredKey  = "vendored"
+	GeneratedKey = "generated"
+)
+
+// languages maps file extensions to the language names used to tag code
+// fences.
+var languages = map[string]string{
+	".go":         "go",
+	".py":         "python",
+	".js":         "javascript",
+	".ts":         "typescript",
+	".json":       "json",
+	".yaml":       "yaml",
+	".yml":        "yaml",
+	".xml":        "xml",
+	".html":       "html",
+	".css":        "css",
+	".sh":         "bash",
+	".rs":         "rust",
+	".java":       "java",
+	".c":          "c",
+	".cpp":        "cpp",
+	".h":          "c",
+	".hpp":        "cpp",
+	".sql":        "sql",
+	".dockerfile": "dockerfile",
+	".toml":       "toml",
+	".ini":        "ini",
+	".cfg":        "ini",
+	".conf":       "ini",
+	".nix":        "nix",
+	".md":         "markdown",
+	".ipynb":      "jupyter",
+	".txt":        "text",
+	".pdf":        "text",
+}
+
+// filenameLanguages maps the lower case names of files known without their
+// extension to their language.
+var filenameLanguages = map[string]string{
+	"dockerfile":     "dockerfile",
+	"containerfile":  "dockerfile",
+	"makefile":       "makefile",
+	"gnumakefile":    "makefile",
+	"justfile":       "makefile",
+	"cmakelists.txt": "cmake",
+	"jenkinsfile":    "groovy",
+	"gemfile":        "ruby",
+	"rakefile":       "ruby",
+	"vagrantfile":    "ruby",
+	"go.mod":         "go",
+	"go.sum":         "text",
+	"flake.lock":     "json",
+}
+
+// Filenames lists the names of the files whose language is known without
+// an extension.
+func Filenames() []string {
+	return slices.Sorted(maps.Keys(filenameLanguages))
+}
+
+// interpreterLanguages maps shebang interpreters to their language.
+var interpreterLanguages = map[string]string{
+	"sh":     "bash",
+	"bash":   "bash",
+	"zsh":    "bash",
+	"python": "python",
+	"node":   "javascript",
+	"deno":   "typescript",
+	"ruby":   "ruby",
+	"perl":   "perl",
+	"php":    "php",
+	"lua":    "lua",
+}
+
+// DetectLanguage returns the language of the file at path, or "" when it is
+// unknown.
+func DetectLanguage(path string) string {
+	if lang, ok := filenameLanguages[strings.ToLower(filepath.Base(path))]; ok {
+		return lang
+	}
+
+	return languages[strings.ToLower(filepath.Ext(path))]
+}
+
+// classifyLanguage is DetectLanguage falling back to the shebang of content,
+// for extensionless scripts. The tables stand in for go-enry, whose linguist
+// data would add megabytes to the binary to tell apart languages nothing
+// downstream of the tag distinguishes.
+func classifyLanguage(path, content string) string {
+	if lang := DetectLanguage(path); lang != "" {
+		return lang
+	}
+
+	line, _, _ := strings.Cut(content, "\n")
+	if !strings.HasPrefix(line, "#!") {
+		return ""
+	}
+
+	fields := strings.Fields(strings.TrimPrefix(line, "#!"))
+	if len(fields) == 0 {
+		return ""
+	}
+	interpreter := filepath.Base(fields[0])
+	// #!/usr/bin/env [-S] python3
+	if interpreter == "env" {
+		for _, f := range fields[1:] {
+			if !strings.HasPrefix(f, "-") {
+				interpreter = f
+				break
+			}
+		}
+	}
+
+	return interpreterLanguages[strings.TrimRight(interpreter, "0123456789.")]
+}
+
+// VendoredDirs hold third-party or build output checked into trees.
+var VendoredDirs = []string{"vendor", "node_modules", "third_party", "bower_components", "dist"}
+
+// isVendored reports whether the file at the slash separated relPath is
+// third-party code checked into the tree.
+func isVendored(relPath string) bool {
+	for _, dir := range strings.Split(path.Dir(relPath), "/") {
+		if slices.Contains(VendoredDirs, dir) {
+			return true
+		}
+	}
+
+	return false
+}
+
+var (
+	generatedSuffixes = []string{".pb.go", "_generated.go", ".gen.go", "_string.go", ".min.js", ".min.css", ".map"}
+	lockfiles         = []string{
+		"package-lock.json", "yarn.lock", "pnpm-lock.yaml", "go.sum", "cargo.lock", "flake.lock",
+		"poetry.lock", "gemfile.lock", "composer.lock", "pipfile.lock", "uv.lock",
+	}
+	generatedMarker = regexp.MustCompile(`(?m)^(?://|#|/\*|--) Code generated .* DO NOT EDIT\.|@generated\b`)
+)
+
+// isGenerated reports whether the file at path holding content was written
+// by a tool: by its name, a generated code marker in its first lines, or
+// for scripts and styles, lines too long to be written by hand.
+func isGenerated(path, content string) bool {
+	name := strings.ToLower(filepath.Base(path))
+	if slices.Contains(lockfiles, name) || slices.ContainsFunc(generatedSuffixes, func(s string) bool { return strings.HasSuffix(name, s) }) {
+		return true
+	}
+
+	if generatedMarker.MatchString(content[:min(len(content), 1024)]) {
+		return true
+	}
+
+	switch filepath.Ext(name) {
+	case ".js", ".mjs", ".cjs", ".css":
+		// minified: a few huge lines
+		lines := strings.Count(content, "\n") + 1
+		return len(content) > 4096 && len(content)/lines > 500
+	}
+
+	return false
+}
@@ -0,0 +1,116 @@
+package index
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var (
+	licenseFiles = []string{"LICENSE", "LICENSE.md", "LICENSE.txt", "LICENCE", "COPYING", "COPYING.md", "COPYING.txt"}
+	spdxHeader   = regexp.MustCompile(`SPDX-License-Identifier:\s*([A-Za-z0-9.+-]+)`)
+)
+
+// licenseMarkers identifies a license by phrases of its text, checked in
+// order so that more specific licenses win over the ones they extend.
+var licenseMarkers = []struct {
+	id      string
+	phrases []string
+}{
+	{"AGPL-3.0", []string{"GNU AFFERO GENERAL PUBLIC LICENSE"}},
+	{"LGPL-3.0", []string{"GNU LESSER GENERAL PUBLIC LICENSE", "Version 3"}},
+	{"LGPL-2.1", []string{"GNU LESSER GENERAL PUBLIC LICENSE", "Version 2.1"}},
+	{"GPL-3.0", []string{"GNU GENERAL PUBLIC LICENSE", "Version 3"}},
+	{"GPL-2.0", []string{"GNU GENERAL PUBLIC LICENSE", "Version 2"}},
+	{"MPL-2.0", []string{"Mozilla Public License", "2.0"}},
+	{"Apache-2.0", []string{"Apache License", "Version 2.0"}},
+	{"BSD-3-Clause", []string{"Redistribution and use in source and binary forms", "Neither the name"}},
+	{"BSD-2-Clause", []string{"Redistribution and use in source and binary forms"}},
+	{"ISC", []string{"Permission to use, copy, modify, and/or distribute this software for any purpose"}},
+	{"MIT", []string{"Permission is hereby granted, free of charge"}},
+	{"Unlicense", []string{"This is free and unencumbered software released into the public domain"}},
+}
+
+// licenseDetector finds the license of files from their SPDX header or from
+// the nearest license file of an enclosing directory up to the indexed root,
+// so that vendored dependencies and cloned repositories get their own
+// license.
+type licenseDetector struct {
+	fsys fs.FS
+	root string
+	dirs map[string]string
+}
+
+// newLicenseDetector returns a detector for the files under root, or the
+// files of fsys when it is set. Without either, only headers are looked at.
+func newLicenseDetector(fsys fs.FS, root string) *licenseDetector {
+	d := &licenseDetector{fsys: fsys, dirs: map[string]string{}}
+	switch {
+	case fsys != nil:
+		d.root = "."
+	case root != "":
+		d.root = absPath(root)
+	}
+
+	return d
+}
+
+// Detect returns the SPDX identifier of the license of the file at path with
+// the given content, or "" when it is unknown.
+func (d *licenseDetector) Detect(path, content string) string {
+	if m := spdxHeader.FindStringSubmatch(content[:min(len(content), 2048)]); m != nil {
+		return m[1]
+	}
+	if d.root == "" {
+		return ""
+	}
+	if d.fsys == nil {
+		path = absPath(path)
+	}
+
+	return d.dir(filepath.Dir(path))
+}
+
+func (d *licenseDetector) readFile(name string) ([]byte, error) {
+	if d.fsys != nil {
+		return fs.ReadFile(d.fsys, filepath.ToSlash(name))
+	}
+
+	return os.ReadFile(name)
+}
+
+func (d *licenseDetector) dir(dir string) string {
+	if id, ok := d.dirs[dir]; ok {
+		return id
+	}
+
+	var id string
+	for _, name := range licenseFiles {
+		if data, err := d.readFile(filepath.Join(dir, name)); err == nil {
+			id = identifyLicense(string(data))
+			break
+		}
+	}
+
+	// license files above the indexed root are not part of it
+	if parent := filepath.Dir(dir); id == "" && parent != dir && dir != d.root {
+		id = d.dir(parent)
+	}
+
+	d.dirs[dir] = id
+	return id
+}
+
+func identifyLicense(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	for _, m := range licenseMarkers {
+		if !slices.ContainsFunc(m.phrases, func(p string) bool { return !strings.Contains(text, p) }) {
+			return m.id
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,94 @@
+package index
+
+import (
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Metadata keys of the file attributes, stored typed so they can be used in
+// where filters. Documents also have their relative path under "path".
+const (
+	FilenameKey  = "filename"
+	SizeKey      = "size"
+	ModTimeKey   = "modified_at"
+	ExtensionKey = "extension"
+	LinesKey     = "lines"
+
+	// StartLineKey and EndLineKey hold the lines of the file a chunk spans,
+	// from 1 and inclusive.
+	StartLineKey = "start_line"
+	EndLineKey   = "end_line"
+
+	// IndexedAtKey holds the unix time at which a document was indexed.
+	IndexedAtKey = "indexed_at"
+	// RootKey holds the identifier of the root a document was indexed
+	// under, and RunKey the ID of the index run that last wrote it.
+	RootKey = "root_id"
+	RunKey  = "run_id"
+	// LicenseKey holds the SPDX identifier of the license of the file.
+	LicenseKey = "license"
+)
+
+// FileMetadata describes an indexed file, stored on each of its chunks.
+type FileMetadata struct {
+	Filename  string
+	Path      string
+	Size      int64
+	ModTime   time.Time
+	Extension string
+	Language  string
+	Lines     int
+	Vendored  bool
+	Generated bool
+}
+
+// fileMetadata describes the file at path holding content, stored under
+// relPath.
+func fileMetadata(path, relPath, content string, stat func(string) (fs.FileInfo, error)) FileMetadata {
+	md := FileMetadata{
+		Filename:  filepath.Base(path),
+		Path:      relPath,
+		Size:      int64(len(content)),
+		Extension: strings.ToLower(filepath.Ext(path)),
+		Language:  classifyLanguage(path, content),
+		Lines:     strings.Count(content, "\n"),
+		Vendored:  isVendored(relPath),
+		Generated: isGenerated(path, content),
+	}
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		md.Lines++
+	}
+	if fi, err := stat(path); err == nil {
+		md.Size, md.ModTime = fi.Size(), fi.ModTime()
+	}
+
+	return md
+}
+
+func (m FileMetadata) metadata() map[string]any {
+	md := map[string]any{
+		"path":      m.Path,
+		FilenameKey: m.Filename,
+		SizeKey:     m.Size,
+		LinesKey:    int64(m.Lines),
+	}
+	if !m.ModTime.IsZero() {
+		md[ModTimeKey] = m.ModTime.Unix()
+	}
+	if m.Extension != "" {
+		md[ExtensionKey] = m.Extension
+	}
+	if m.Language != "" {
+		md[LanguageKey] = m.Language
+	}
+	if m.Vendored {
+		md[VendoredKey] = true
+	}
+	if m.Generated {
+		md[GeneratedKey] = true
+	}
+
+	return md
+}
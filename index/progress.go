@@ -0,0 +1,95 @@
+package index
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"sync"
+)
+
+// LevelTrace is the level every progress event is logged at, below debug.
+const LevelTrace = slog.LevelDebug - 4
+
+// Progress phases of index and migrate runs.
+const (
+	PhaseWalk    = "walk"
+	PhaseRead    = "read"
+	PhaseEmbed   = "embed"
+	PhaseMigrate = "migrate"
+	PhaseDone    = "done"
+)
+
+// Event is one line of the progress protocol, meant for GUI frontends and
+// editor extensions rendering their own progress bars.
+type Event struct {
+	Phase   string  `json:"phase"`
+	Percent float64 `json:"percent"`
+	Done    int     `json:"done"`
+	// Total is estimated while the tree is still walked, and 0 when it is
+	// not known.
+	Total   int    `json:"total"`
+	Current string `json:"current,omitempty"`
+}
+
+// Progress logs Events, phases at debug level and every event at LevelTrace,
+// and writes them as JSON lines to w when set, or passes them to send. A nil
+// Progress discards events.
+type Progress struct {
+	mu     sync.Mutex
+	enc    *json.Encoder
+	send   func(Event)
+	logger *slog.Logger
+	phase  string
+}
+
+func NewProgress(w io.Writer, logger *slog.Logger) *Progress {
+	p := &Progress{logger: logger}
+	if w != nil {
+		p.enc = json.NewEncoder(w)
+	}
+
+	return p
+}
+
+// NewProgressFunc returns a Progress passing every event to send, such as
+// to stream them to a client.
+func NewProgressFunc(send func(Event), logger *slog.Logger) *Progress {
+	return &Progress{send: send, logger: logger}
+}
+
+// Wanted reports whether events are written out, and worth extra work to
+// compute their totals.
+func (p *Progress) Wanted() bool {
+	return p != nil && (p.enc != nil || p.send != nil)
+}
+
+// Report writes e, computing its percentage from Done and Total.
+func (p *Progress) Report(e Event) {
+	if p == nil {
+		return
+	}
+
+	if e.Total > 0 {
+		e.Percent = float64(e.Done) / float64(e.Total) * 100
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.logger != nil {
+		if e.Phase != p.phase {
+			p.phase = e.Phase
+			p.logger.Debug("Progress", "phase", e.Phase, "done", e.Done, "total", e.Total)
+		}
+		p.logger.Log(context.Background(), LevelTrace, "Progress", "phase", e.Phase, "done", e.Done, "total", e.Total, "current", e.Current)
+	}
+
+	if p.enc != nil {
+		// progress is best effort, a closed reader must not fail the run
+		_ = p.enc.Encode(e)
+	}
+	if p.send != nil {
+		p.send(e)
+	}
+}
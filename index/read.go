@@ -0,0 +1,66 @@
+package index
+
+import (
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Extractor turns the content of a file that is not plain text into
+// indexable text.
+type Extractor func(data []byte) (string, error)
+
+// extractors are keyed by extension. Optional extractors are registered from
+// files guarded by build tags.
+var extractors = map[string]Extractor{}
+
+// RegisterExtractor makes fn extract the text of the files with extension
+// ext. It is not safe to call while files are read.
+func RegisterExtractor(ext string, fn Extractor) {
+	extractors[strings.ToLower(ext)] = fn
+}
+
+// ExtractorExtensions returns the extensions with an extractor, sorted.
+func ExtractorExtensions() []string {
+	return slices.Sorted(maps.Keys(extractors))
+}
+
+// ReadFile returns the indexable text of the file at path.
+func ReadFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	return extractText(path, data)
+}
+
+// ReadFileFS is ReadFile for a file of fsys.
+func ReadFileFS(fsys fs.FS, path string) (string, error) {
+	data, err := fs.ReadFile(fsys, path)
+	if err != nil {
+		return "", err
+	}
+
+	return extractText(path, data)
+}
+
+// extractText returns the indexable text of data, the content of the file
+// at path.
+func extractText(path string, data []byte) (string, error) {
+	extract, ok := extractors[strings.ToLower(filepath.Ext(path))]
+	if !ok {
+		return string(data), nil
+	}
+
+	text, err := extract(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to extract text from %s: %w", path, err)
+	}
+
+	return text, nil
+}
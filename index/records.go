@@ -0,0 +1,162 @@
+package index
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/karitham/cls/chunk"
+)
+
+// RecordKey holds the number of the row, from 1, a record chunk was read
+// from.
+const RecordKey = "record"
+
+// RecordExtensions are the dataset formats split into records.
+var RecordExtensions = []string{".csv", ".jsonl", ".ndjson"}
+
+// RecordOptions indexes every row of .csv files and every line of .jsonl
+// files as its own document.
+type RecordOptions struct {
+	// Columns are the fields making up the text of a record, in order. All
+	// fields are used when empty.
+	Columns []string `json:"columns,omitempty"`
+}
+
+func isRecordFile(path string) bool {
+	return slices.Contains(RecordExtensions, strings.ToLower(filepath.Ext(path)))
+}
+
+// chunk splits content, the text of the dataset at path, into records.
+func (o RecordOptions) chunk(path, content string) ([]chunk.Chunk, error) {
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return o.chunkCSV(content)
+	}
+
+	return o.chunkJSONL(content)
+}
+
+func (o RecordOptions) chunkCSV(content string) ([]chunk.Chunk, error) {
+	r := csv.NewReader(strings.NewReader(content))
+	r.FieldsPerRecord = -1
+	r.ReuseRecord = true
+
+	header, err := r.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read csv header: %w", err)
+	}
+	header = slices.Clone(header)
+
+	columns := make([]int, 0, len(header))
+	if len(o.Columns) == 0 {
+		for i := range header {
+			columns = append(columns, i)
+		}
+	}
+	for _, name := range o.Columns {
+		i := slices.IndexFunc(header, func(h string) bool { return strings.EqualFold(strings.TrimSpace(h), name) })
+		if i < 0 {
+			return nil, fmt.Errorf("no column %q in csv header", name)
+		}
+		columns = append(columns, i)
+	}
+
+	var chunks []chunk.Chunk
+	for row := 1; ; row++ {
+		rec, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return chunks, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read csv row %d: %w", row, err)
+		}
+
+		fields := make([][2]string, 0, len(columns))
+		for _, i := range columns {
+			if i < len(rec) {
+				fields = append(fields, [2]string{header[i], rec[i]})
+			}
+		}
+
+		if chunk, ok := recordChunk(row, fields); ok {
+			chunks = append(chunks, chunk)
+		}
+	}
+}
+
+func (o RecordOptions) chunkJSONL(content string) ([]chunk.Chunk, error) {
+	var chunks []chunk.Chunk
+	row := 0
+	for n, line := range strings.Split(content, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		row++
+
+		var rec map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			return nil, fmt.Errorf("line %d: invalid json record: %w", n+1, err)
+		}
+
+		columns := o.Columns
+		if len(columns) == 0 {
+			columns = slices.Sorted(maps.Keys(rec))
+		}
+
+		fields := make([][2]string, 0, len(columns))
+		for _, name := range columns {
+			raw, ok := rec[name]
+			if !ok {
+				continue
+			}
+			var s string
+			if err := json.Unmarshal(raw, &s); err != nil {
+				s = string(raw) // numbers, lists and objects stay JSON
+			}
+			fields = append(fields, [2]string{name, s})
+		}
+
+		if chunk, ok := recordChunk(row, fields); ok {
+			chunks = append(chunks, chunk)
+		}
+	}
+
+	return chunks, nil
+}
+
+// recordChunk makes the chunk of a record from its name, value fields. A
+// single field is indexed as is, several as "name: value" lines. Records
+// without text are left out. The text does not follow the lines of the
+// file, so records are located by row rather than by line.
+func recordChunk(row int, fields [][2]string) (chunk.Chunk, bool) {
+	var lines []string
+	for _, f := range fields {
+		if strings.TrimSpace(f[1]) == "" || f[1] == "null" {
+			continue
+		}
+		lines = append(lines, f[0]+": "+f[1])
+	}
+	if len(lines) == 0 {
+		return chunk.Chunk{}, false
+	}
+
+	text := strings.Join(lines, "\n")
+	if len(fields) == 1 {
+		text = fields[0][1]
+	}
+
+	return chunk.Chunk{
+		Text:     text,
+		Metadata: map[string]any{RecordKey: int64(row)},
+	}, true
+}
@@ -0,0 +1,97 @@
+package index
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/karitham/cls/chunk"
+	"github.com/karitham/cls/store"
+)
+
+// URLRootPrefix prefixes the root identifiers of crawled documents, whose
+// paths are relative to the crawled URL.
+const URLRootPrefix = "url:"
+
+// PageURL returns the URL of the page crawled from the root id and stored
+// under rel.
+func PageURL(id, rel string) string {
+	rel = strings.TrimSuffix(rel, ".md")
+	if rel == "index" {
+		rel = ""
+	}
+
+	return strings.TrimSuffix(strings.TrimPrefix(id, URLRootPrefix), "/") + "/" + rel
+}
+
+// Result returns the query result of the stored document r, with the fields
+// read from the metadata written at index time. Paths under a root are
+// resolved to local ones with resolve, left relative when it is nil or does
+// not know the root.
+func Result(r store.Record, resolve func(rootID, rel string) (string, bool)) store.Result {
+	result := store.Result{ID: r.ID, Content: r.Document}
+	md := r.Metadata
+
+	result.FileName, _ = md[FilenameKey].(string)
+	if path, ok := md["path"].(string); ok {
+		result.Path, result.RelPath = path, path
+		if id, ok := md[RootKey].(string); ok && strings.HasPrefix(id, URLRootPrefix) {
+			result.Path, result.Unresolved = PageURL(id, path), true
+		} else if ok {
+			local, ok := "", false
+			if resolve != nil {
+				local, ok = resolve(id, path)
+			}
+			if ok {
+				result.Path = local
+			}
+			result.Unresolved = !ok
+		}
+	}
+	result.License, _ = md[LicenseKey].(string)
+	result.Language, _ = md[LanguageKey].(string)
+	if lines, ok := MetadataInt(md[LinesKey]); ok {
+		result.Lines = int(lines)
+	}
+	if modTime, ok := MetadataInt(md[ModTimeKey]); ok {
+		result.ModTime = time.Unix(modTime, 0)
+	}
+	if start, ok := MetadataInt(md[StartLineKey]); ok {
+		result.StartLine = int(start)
+	}
+	if end, ok := MetadataInt(md[EndLineKey]); ok {
+		result.EndLine = int(end)
+	}
+	if dupes, ok := md[DuplicatesKey].(string); ok && dupes != "" {
+		result.Duplicates = strings.Split(dupes, ",")
+	}
+	result.Section, _ = md[chunk.SectionKey].(string)
+	result.Title, _ = md[chunk.TitleKey].(string)
+	if record, ok := MetadataInt(md[RecordKey]); ok {
+		result.Record = int(record)
+	}
+	result.Author, _ = md[AuthorKey].(string)
+	result.AuthorEmail, _ = md[AuthorEmailKey].(string)
+	result.Commit, _ = md[CommitKey].(string)
+	result.SummaryOf, _ = md[SummaryOfKey].(string)
+
+	return result
+}
+
+// MetadataInt reads an integer metadata value, which may have been decoded
+// from JSON as a float or a number.
+func MetadataInt(v any) (int64, bool) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), true
+	case int64:
+		return n, true
+	case float64:
+		return int64(n), true
+	case json.Number:
+		i, err := n.Int64()
+		return i, err == nil
+	}
+
+	return 0, false
+}
@@ -0,0 +1,196 @@
+package index
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// What to do with files containing secrets.
+const (
+	SecretsMask = "mask"
+	SecretsSkip = "skip"
+	SecretsWarn = "warn"
+	SecretsOff  = "off"
+)
+
+// SecretRule detects one kind of secret. When the regex has a capture group,
+// only the group is the secret. Entropy, when set, is the minimum Shannon
+// entropy in bits per byte a match must have, to tell generated tokens from
+// placeholders.
+type SecretRule struct {
+	ID      string  `json:"id"`
+	Regex   string  `json:"regex"`
+	Entropy float64 `json:"entropy,omitempty"`
+
+	re *regexp.Regexp
+}
+
+// DefaultSecretRules are the rules every scanner starts with.
+var DefaultSecretRules = []SecretRule{
+	{ID: "private-key", Regex: `-----BEGIN[ A-Z0-9_-]*PRIVATE KEY( BLOCK)?-----[\s\S]*?-----END[ A-Z0-9_-]*PRIVATE KEY( BLOCK)?-----`},
+	{ID: "aws-access-key-id", Regex: `\b((?:A3T[A-Z0-9]|AKIA|ASIA|ABIA|ACCA)[A-Z2-7]{16})\b`},
+	{ID: "github-token", Regex: `\b((?:ghp|gho|ghu|ghs|ghr)_[A-Za-z0-9]{36}|github_pat_[A-Za-z0-9_]{82})\b`},
+	{ID: "gitlab-token", Regex: `\b(glpat-[A-Za-z0-9_-]{20})\b`},
+	{ID: "slack-token", Regex: `\b(xox[baprs]-[A-Za-z0-9-]{10,})\b`},
+	{ID: "stripe-key", Regex: `\b((?:sk|rk)_live_[A-Za-z0-9]{24,})\b`},
+	{ID: "google-api-key", Regex: `\b(AIza[A-Za-z0-9_-]{35})\b`},
+	{ID: "openai-api-key", Regex: `\b(sk-(?:proj-)?[A-Za-z0-9_-]{32,})\b`},
+	{ID: "jwt", Regex: `\b(eyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,})\b`},
+	{ID: "generic-secret", Regex: `(?i)(?:api[_-]?key|secret|token|passw(?:or)?d|credential)[A-Za-z0-9_-]*["']?\s*[:=]\s*["']?([A-Za-z0-9_\-+/=.]{16,})`, Entropy: 3.5},
+}
+
+// SecretPolicy is what indexing does with files in which Scanner finds
+// secrets: one of SecretsMask, SecretsSkip, SecretsWarn or SecretsOff.
+type SecretPolicy struct {
+	Action  string
+	Scanner *SecretScanner
+}
+
+// NewSecretPolicy validates action and builds the scanner it needs.
+func NewSecretPolicy(action, rulesPath string) (SecretPolicy, error) {
+	switch action {
+	case SecretsOff:
+		return SecretPolicy{Action: action}, nil
+	case SecretsMask, SecretsSkip, SecretsWarn:
+	default:
+		return SecretPolicy{}, fmt.Errorf("unknown secrets action %q, expected mask, skip, warn or off", action)
+	}
+
+	scanner, err := NewSecretScanner(rulesPath)
+	if err != nil {
+		return SecretPolicy{}, err
+	}
+
+	return SecretPolicy{Action: action, Scanner: scanner}, nil
+}
+
+type SecretScanner struct {
+	rules []SecretRule
+}
+
+// NewSecretScanner compiles the default rules followed by the rules of the
+// JSON file rulesPath, if any.
+func NewSecretScanner(rulesPath string) (*SecretScanner, error) {
+	rules := append([]SecretRule(nil), DefaultSecretRules...)
+	if rulesPath != "" {
+		data, err := os.ReadFile(rulesPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read secret rules: %w", err)
+		}
+
+		var extra []SecretRule
+		if err := json.Unmarshal(data, &extra); err != nil {
+			return nil, fmt.Errorf("failed to decode secret rules %s: %w", rulesPath, err)
+		}
+		rules = append(rules, extra...)
+	}
+
+	for i := range rules {
+		re, err := regexp.Compile(rules[i].Regex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid secret rule %q: %w", rules[i].ID, err)
+		}
+		rules[i].re = re
+	}
+
+	return &SecretScanner{rules: rules}, nil
+}
+
+type SecretFinding struct {
+	Rule  string
+	Line  int
+	start int
+	end   int
+}
+
+// Rules returns the IDs of the rules of s, in order.
+func (s *SecretScanner) Rules() []string {
+	ids := make([]string, len(s.rules))
+	for i, r := range s.rules {
+		ids[i] = r.ID
+	}
+
+	return ids
+}
+
+// Scan returns the secrets found in content.
+func (s *SecretScanner) Scan(content string) []SecretFinding {
+	var findings []SecretFinding
+	for _, rule := range s.rules {
+		for _, m := range rule.re.FindAllStringSubmatchIndex(content, -1) {
+			start, end := m[0], m[1]
+			if len(m) >= 4 && m[2] >= 0 {
+				start, end = m[2], m[3]
+			}
+
+			if rule.Entropy > 0 && shannonEntropy(content[start:end]) < rule.Entropy {
+				continue
+			}
+
+			findings = append(findings, SecretFinding{
+				Rule:  rule.ID,
+				Line:  strings.Count(content[:start], "\n") + 1,
+				start: start,
+				end:   end,
+			})
+		}
+	}
+
+	return findings
+}
+
+// Mask replaces the secrets of findings in content by a marker naming the
+// rule that found them.
+func (s *SecretScanner) Mask(content string, findings []SecretFinding) string {
+	var (
+		sb   strings.Builder
+		last int
+	)
+	sorted := slices.SortedFunc(slices.Values(findings), func(a, b SecretFinding) int { return a.start - b.start })
+	for _, f := range sorted {
+		if f.start < last {
+			continue
+		}
+		sb.WriteString(content[last:f.start])
+		sb.WriteString("[REDACTED:" + f.Rule + "]")
+		last = f.end
+	}
+	sb.WriteString(content[last:])
+
+	return sb.String()
+}
+
+func shannonEntropy(s string) float64 {
+	var counts [256]int
+	for i := 0; i < len(s); i++ {
+		counts[s[i]]++
+	}
+
+	var h float64
+	for _, c := range counts {
+		if c == 0 {
+			continue
+		}
+		p := float64(c) / float64(len(s))
+		h -= p * math.Log2(p)
+	}
+
+	return h
+}
+
+// secretRules returns the distinct rules of findings.
+func secretRules(findings []SecretFinding) []string {
+	var rules []string
+	for _, f := range findings {
+		if !slices.Contains(rules, f.Rule) {
+			rules = append(rules, f.Rule)
+		}
+	}
+
+	return rules
+}
@@ -0,0 +1,201 @@
+package index
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/karitham/cls/chunk"
+	"github.com/karitham/cls/store"
+)
+
+// SymbolSet receives the definitions and call sites of the indexed files.
+type SymbolSet interface {
+	// Set replaces the symbols and call sites of the file stored under rel
+	// in root.
+	Set(root, rel string, symbols, calls []store.Symbol)
+}
+
+type symbolPattern struct {
+	kind string
+	re   *regexp.Regexp
+}
+
+// symbolPatterns find the definitions of languages without a parser here,
+// the first group of each being the name.
+var symbolPatterns = map[string][]symbolPattern{
+	"python": {
+		{"function", regexp.MustCompile(`^\s*(?:async\s+)?def\s+(\w+)`)},
+		{"class", regexp.MustCompile(`^\s*class\s+(\w+)`)},
+		{"constant", regexp.MustCompile(`^([A-Z][A-Z0-9_]*)\s*(?::[^=]+)?=`)},
+	},
+	"javascript": jsPatterns,
+	"typescript": append(jsPatterns,
+		symbolPattern{"type", regexp.MustCompile(`^\s*(?:export\s+)?(?:interface|type|enum)\s+(\w+)`)},
+	),
+	"rust": {
+		{"function", regexp.MustCompile(`^\s*(?:pub(?:\([^)]*\))?\s+)?(?:async\s+)?(?:unsafe\s+)?fn\s+(\w+)`)},
+		{"type", regexp.MustCompile(`^\s*(?:pub(?:\([^)]*\))?\s+)?(?:struct|enum|trait|type|union)\s+(\w+)`)},
+		{"constant", regexp.MustCompile(`^\s*(?:pub(?:\([^)]*\))?\s+)?(?:const|static)\s+(\w+)`)},
+		{"module", regexp.MustCompile(`^\s*(?:pub(?:\([^)]*\))?\s+)?mod\s+(\w+)`)},
+	},
+	"java": {
+		{"type", regexp.MustCompile(`^\s*(?:(?:public|private|protected|abstract|final|static)\s+)*(?:class|interface|enum|record)\s+(\w+)`)},
+		{"method", regexp.MustCompile(`^\s+(?:(?:public|private|protected|abstract|final|static|synchronized)\s+)+[\w<>\[\], ]+\s+(\w+)\s*\(`)},
+	},
+	"c": cPatterns,
+	"cpp": append(cPatterns,
+		symbolPattern{"type", regexp.MustCompile(`^\s*(?:class|namespace)\s+(\w+)`)},
+	),
+	"ruby": {
+		{"function", regexp.MustCompile(`^\s*def\s+(?:self\.)?(\w+[?!]?)`)},
+		{"class", regexp.MustCompile(`^\s*(?:class|module)\s+(\w+)`)},
+	},
+	"bash": {
+		{"function", regexp.MustCompile(`^\s*(?:function\s+)?([\w-]+)\s*\(\)\s*\{?`)},
+	},
+}
+
+var (
+	jsPatterns = []symbolPattern{
+		{"function", regexp.MustCompile(`^\s*(?:export\s+)?(?:default\s+)?(?:async\s+)?function\s*\*?\s*(\w+)`)},
+		{"class", regexp.MustCompile(`^\s*(?:export\s+)?(?:default\s+)?(?:abstract\s+)?class\s+(\w+)`)},
+		{"function", regexp.MustCompile(`^\s*(?:export\s+)?(?:const|let|var)\s+(\w+)\s*=\s*(?:async\s+)?(?:function|\([^)]*\)\s*=>|\w+\s*=>)`)},
+		{"constant", regexp.MustCompile(`^\s*(?:export\s+)?const\s+([A-Z][A-Z0-9_]*)\s*=`)},
+	}
+	cPatterns = []symbolPattern{
+		{"function", regexp.MustCompile(`^(?:static\s+|inline\s+|extern\s+)*[\w\*\s]+?\b(\w+)\s*\([^;]*$`)},
+		{"type", regexp.MustCompile(`^\s*(?:typedef\s+)?(?:struct|enum|union)\s+(\w+)`)},
+		{"constant", regexp.MustCompile(`^\s*#define\s+(\w+)`)},
+	}
+)
+
+// extractSymbols returns the definitions of content, a file in lang. Go is
+// parsed, other languages are matched line by line.
+func extractSymbols(lang, content string) []store.Symbol {
+	if lang == "go" {
+		return goSymbols(content)
+	}
+
+	patterns, ok := symbolPatterns[lang]
+	if !ok {
+		return nil
+	}
+
+	var symbols []store.Symbol
+	for n, line := range strings.Split(content, "\n") {
+		for _, p := range patterns {
+			if m := p.re.FindStringSubmatch(line); m != nil && !cKeywords[m[1]] {
+				symbols = append(symbols, store.Symbol{Name: m[1], Kind: p.kind, Line: n + 1})
+				break
+			}
+		}
+	}
+
+	return symbols
+}
+
+// cKeywords are words the loose C function pattern would take for names.
+var cKeywords = map[string]bool{"if": true, "for": true, "while": true, "switch": true, "return": true, "sizeof": true}
+
+func goSymbols(content string) []store.Symbol {
+	fset := token.NewFileSet()
+	// a file with syntax errors still has the declarations before them
+	f, _ := parser.ParseFile(fset, "", content, parser.SkipObjectResolution)
+	if f == nil {
+		return nil
+	}
+
+	var symbols []store.Symbol
+	add := func(name *ast.Ident, kind string) {
+		if name != nil && name.Name != "_" {
+			symbols = append(symbols, store.Symbol{Name: name.Name, Kind: kind, Line: fset.Position(name.Pos()).Line})
+		}
+	}
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			kind := "function"
+			if d.Recv != nil {
+				kind = "method"
+			}
+			add(d.Name, kind)
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					add(s.Name, "type")
+				case *ast.ValueSpec:
+					kind := "variable"
+					if d.Tok == token.CONST {
+						kind = "constant"
+					}
+					for _, name := range s.Names {
+						add(name, kind)
+					}
+				}
+			}
+		}
+	}
+
+	return symbols
+}
+
+// chunkSymbols sets the document of symbols to the chunk holding their line,
+// the first one for chunks without lines.
+func chunkSymbols(symbols []store.Symbol, chunks []chunk.Chunk, root, rel string) {
+	for i := range symbols {
+		s := &symbols[i]
+		s.Path, s.Root = rel, root
+
+		chunk := 0
+		for j, c := range chunks {
+			if c.StartLine <= s.Line && s.Line <= c.EndLine {
+				chunk = j
+				break
+			}
+		}
+		s.ID = DocumentID(root, rel, chunk)
+	}
+}
+
+var callPattern = regexp.MustCompile(`\b([A-Za-z_]\w*)\s*\(`)
+
+// callKeywords are the words followed by a parenthesis that are not calls.
+var callKeywords = map[string]bool{
+	"if": true, "for": true, "while": true, "switch": true, "return": true, "func": true, "function": true,
+	"sizeof": true, "catch": true, "elif": true, "and": true, "or": true, "not": true, "in": true, "def": true,
+}
+
+// extractCalls returns a call site per identifier called in content and
+// line, as symbols of kind "call". Every language is matched alike, by an
+// identifier followed by a parenthesis, which also matches definitions.
+func extractCalls(content string) []store.Symbol {
+	var calls []store.Symbol
+	for n, line := range strings.Split(content, "\n") {
+		for _, m := range callPattern.FindAllStringSubmatch(line, -1) {
+			if name := m[1]; len(name) > 1 && !callKeywords[name] {
+				calls = append(calls, store.Symbol{Name: name, Kind: "call", Line: n + 1})
+			}
+		}
+	}
+
+	return calls
+}
+
+// firstCalls keeps the first call of each identifier in each chunk, calls
+// being placed in chunks by chunkSymbols.
+func firstCalls(calls []store.Symbol) []store.Symbol {
+	seen := map[[2]string]bool{}
+	return slices.DeleteFunc(calls, func(c store.Symbol) bool {
+		key := [2]string{c.Name, c.ID}
+		if seen[key] {
+			return true
+		}
+		seen[key] = true
+		return false
+	})
+}
@@ -0,0 +1,320 @@
+// Package indexer streams documents into a store collection, batching them
+// internally so callers can add documents one at a time.
+package indexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/karitham/cls/store"
+)
+
+const (
+	DefaultBatchSize   = 100
+	DefaultConcurrency = 8
+)
+
+var ErrClosed = errors.New("indexer is closed")
+
+type Document struct {
+	ID       string
+	Content  string
+	Metadata map[string]any
+}
+
+// Indexer upserts documents into a collection in batches. Add blocks once
+// the configured number of batches are in flight, so a fast producer cannot
+// outrun the embedder.
+type Indexer struct {
+	w         store.Writer
+	batchSize int
+	onError   func(batch []Document, err error)
+	onFlush   func(batch []Document)
+	sem       chan struct{}
+	interval  time.Duration
+	next      time.Time
+	wg        sync.WaitGroup
+	mu        sync.Mutex
+	pending   []Document
+	err       error
+	added     int
+	closed    bool
+	// bytes is the content size of the pending and in-flight documents,
+	// bounded by maxBytes, and freed is closed whenever some is released.
+	maxBytes int64
+	bytes    int64
+	freed    chan struct{}
+}
+
+type Option func(*Indexer)
+
+// WithBatchSize sets how many documents are sent per upsert.
+func WithBatchSize(n int) Option {
+	return func(ix *Indexer) {
+		if n > 0 {
+			ix.batchSize = n
+		}
+	}
+}
+
+// WithConcurrency sets how many batches may be in flight at once.
+func WithConcurrency(n int) Option {
+	return func(ix *Indexer) {
+		if n > 0 {
+			ix.sem = make(chan struct{}, n)
+		}
+	}
+}
+
+// WithRateLimit caps how many batches are sent per second, for embedders
+// that cannot keep up. Zero or less means no limit.
+func WithRateLimit(perSecond float64) Option {
+	return func(ix *Indexer) {
+		if perSecond > 0 {
+			ix.interval = time.Duration(float64(time.Second) / perSecond)
+		}
+	}
+}
+
+// WithMaxBytes bounds the content size of the documents pending or in
+// flight. Add blocks until enough of it is upserted, so a producer reading
+// large files waits for the embedder instead of holding them all in memory.
+// A document larger than the bound is still added, alone. Zero or less means
+// no bound.
+func WithMaxBytes(n int64) Option {
+	return func(ix *Indexer) {
+		ix.maxBytes = n
+	}
+}
+
+// WithErrorHandler is called with every batch that failed to upsert. Errors
+// are also returned by the next Flush or Close.
+func WithErrorHandler(fn func(batch []Document, err error)) Option {
+	return func(ix *Indexer) {
+		ix.onError = fn
+	}
+}
+
+// WithFlushHandler is called with every batch that was upserted.
+func WithFlushHandler(fn func(batch []Document)) Option {
+	return func(ix *Indexer) {
+		ix.onFlush = fn
+	}
+}
+
+func New(w store.Writer, opts ...Option) *Indexer {
+	ix := &Indexer{
+		w:         w,
+		batchSize: DefaultBatchSize,
+		sem:       make(chan struct{}, DefaultConcurrency),
+		freed:     make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(ix)
+	}
+
+	return ix
+}
+
+// Add queues doc, sending a batch once enough documents are pending.
+func (ix *Indexer) Add(ctx context.Context, doc Document) error {
+	if err := ix.reserve(ctx, int64(len(doc.Content))); err != nil {
+		return err
+	}
+
+	ix.mu.Lock()
+	if ix.closed {
+		ix.release([]Document{doc})
+		ix.mu.Unlock()
+		return ErrClosed
+	}
+
+	ix.pending = append(ix.pending, doc)
+	var batch []Document
+	if len(ix.pending) >= ix.batchSize {
+		batch, ix.pending = ix.pending, nil
+	}
+	ix.mu.Unlock()
+
+	if batch == nil {
+		return nil
+	}
+
+	return ix.send(ctx, batch)
+}
+
+// Flush sends the pending documents and waits for every batch in flight. It
+// returns the errors of the batches that failed since the last Flush.
+func (ix *Indexer) Flush(ctx context.Context) error {
+	ix.mu.Lock()
+	batch := ix.pending
+	ix.pending = nil
+	ix.mu.Unlock()
+
+	if len(batch) > 0 {
+		if err := ix.send(ctx, batch); err != nil {
+			return err
+		}
+	}
+
+	ix.wg.Wait()
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	err := ix.err
+	ix.err = nil
+
+	return err
+}
+
+// Close flushes the indexer. Adding documents afterwards returns ErrClosed.
+func (ix *Indexer) Close(ctx context.Context) error {
+	ix.mu.Lock()
+	ix.closed = true
+	ix.mu.Unlock()
+
+	return ix.Flush(ctx)
+}
+
+// Added returns the number of documents upserted so far.
+func (ix *Indexer) Added() int {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	return ix.added
+}
+
+// reserve waits until n more bytes of content fit in the bound.
+func (ix *Indexer) reserve(ctx context.Context, n int64) error {
+	if ix.maxBytes <= 0 {
+		return nil
+	}
+
+	for {
+		ix.mu.Lock()
+		if ix.bytes == 0 || ix.bytes+n <= ix.maxBytes {
+			ix.bytes += n
+			ix.mu.Unlock()
+			return nil
+		}
+		// pending documents hold part of the bound and are only sent once a
+		// batch is full, send them now or nothing may ever be released
+		batch, freed := ix.pending, ix.freed
+		ix.pending = nil
+		ix.mu.Unlock()
+
+		if len(batch) > 0 {
+			if err := ix.send(ctx, batch); err != nil {
+				return err
+			}
+		}
+
+		select {
+		case <-freed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// release gives back the bytes of batch, ix.mu held.
+func (ix *Indexer) release(batch []Document) {
+	if ix.maxBytes <= 0 {
+		return
+	}
+
+	for _, doc := range batch {
+		ix.bytes -= int64(len(doc.Content))
+	}
+	close(ix.freed)
+	ix.freed = make(chan struct{})
+}
+
+func (ix *Indexer) send(ctx context.Context, batch []Document) error {
+	err := ix.wait(ctx)
+	if err == nil {
+		select {
+		case ix.sem <- struct{}{}:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	}
+	if err != nil {
+		// the batch is dropped, its bytes given back so nothing waits on them
+		ix.mu.Lock()
+		ix.release(batch)
+		ix.mu.Unlock()
+		if ix.onError != nil {
+			ix.onError(batch, err)
+		}
+		return err
+	}
+
+	ix.wg.Add(1)
+	go func() {
+		defer ix.wg.Done()
+		defer func() { <-ix.sem }()
+
+		err := ix.upsert(ctx, batch)
+
+		ix.mu.Lock()
+		if err != nil {
+			ix.err = errors.Join(ix.err, err)
+		} else {
+			ix.added += len(batch)
+		}
+		ix.release(batch)
+		ix.mu.Unlock()
+
+		if err != nil && ix.onError != nil {
+			ix.onError(batch, err)
+		}
+		if err == nil && ix.onFlush != nil {
+			ix.onFlush(batch)
+		}
+	}()
+
+	return nil
+}
+
+// wait blocks until the rate limit allows sending another batch.
+func (ix *Indexer) wait(ctx context.Context) error {
+	if ix.interval == 0 {
+		return nil
+	}
+
+	ix.mu.Lock()
+	at := ix.next
+	if now := time.Now(); at.Before(now) {
+		at = now
+	}
+	ix.next = at.Add(ix.interval)
+	ix.mu.Unlock()
+
+	t := time.NewTimer(time.Until(at))
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (ix *Indexer) upsert(ctx context.Context, batch []Document) error {
+	records := make([]store.Record, len(batch))
+	for i, d := range batch {
+		records[i] = store.Record{ID: d.ID, Document: d.Content, Metadata: d.Metadata}
+	}
+
+	if err := ix.w.Upsert(ctx, records); err != nil {
+		return fmt.Errorf("failed to add documents to collection: %w", err)
+	}
+
+	return nil
+}
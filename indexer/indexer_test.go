@@ -0,0 +1,200 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/karitham/cls/store"
+)
+
+// fakeWriter records the batches upserted, failing with err when set and
+// blocking on block when it is not nil.
+type fakeWriter struct {
+	mu      sync.Mutex
+	batches [][]store.Record
+	err     error
+	block   chan struct{}
+}
+
+func (w *fakeWriter) Upsert(ctx context.Context, records []store.Record) error {
+	if w.block != nil {
+		<-w.block
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return w.err
+	}
+	w.batches = append(w.batches, records)
+
+	return nil
+}
+
+func (w *fakeWriter) Update(ctx context.Context, records []store.Record) error {
+	return nil
+}
+
+func (w *fakeWriter) DeletePaths(ctx context.Context, rootID string, paths []string) (int, error) {
+	return 0, nil
+}
+
+func doc(i int, content string) Document {
+	return Document{ID: strconv.Itoa(i), Content: content}
+}
+
+func TestAddSendsFullBatches(t *testing.T) {
+	ctx := context.Background()
+	w := &fakeWriter{}
+	ix := New(w, WithBatchSize(2), WithConcurrency(1))
+
+	for i := range 5 {
+		if err := ix.Add(ctx, doc(i, "x")); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+	if err := ix.Flush(ctx); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	if len(w.batches) != 3 {
+		t.Fatalf("got %d batches, want 3", len(w.batches))
+	}
+	for i, want := range []int{2, 2, 1} {
+		if got := len(w.batches[i]); got != want {
+			t.Errorf("batch %d has %d documents, want %d", i, got, want)
+		}
+	}
+	if got := ix.Added(); got != 5 {
+		t.Errorf("Added() = %d, want 5", got)
+	}
+}
+
+func TestFlushReturnsBatchErrors(t *testing.T) {
+	ctx := context.Background()
+	errUpsert := errors.New("upsert failed")
+	w := &fakeWriter{err: errUpsert}
+	var failed atomic.Int64
+	ix := New(w, WithBatchSize(2), WithErrorHandler(func(batch []Document, err error) {
+		failed.Add(int64(len(batch)))
+	}))
+
+	for i := range 3 {
+		if err := ix.Add(ctx, doc(i, "x")); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+	if err := ix.Flush(ctx); !errors.Is(err, errUpsert) {
+		t.Fatalf("Flush = %v, want %v", err, errUpsert)
+	}
+	if got := failed.Load(); got != 3 {
+		t.Errorf("error handler got %d documents, want 3", got)
+	}
+	if got := ix.Added(); got != 0 {
+		t.Errorf("Added() = %d, want 0", got)
+	}
+
+	// errors are returned once
+	if err := ix.Flush(ctx); err != nil {
+		t.Errorf("second Flush = %v, want nil", err)
+	}
+}
+
+func TestCloseFlushesAndRejectsAdds(t *testing.T) {
+	ctx := context.Background()
+	w := &fakeWriter{}
+	ix := New(w)
+
+	if err := ix.Add(ctx, doc(0, "x")); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if err := ix.Close(ctx); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if got := ix.Added(); got != 1 {
+		t.Errorf("Added() = %d, want 1", got)
+	}
+	if err := ix.Add(ctx, doc(1, "x")); !errors.Is(err, ErrClosed) {
+		t.Errorf("Add after Close = %v, want %v", err, ErrClosed)
+	}
+}
+
+func TestMaxBytesBlocksAdd(t *testing.T) {
+	ctx := context.Background()
+	w := &fakeWriter{block: make(chan struct{})}
+	ix := New(w, WithBatchSize(100), WithMaxBytes(10))
+
+	if err := ix.Add(ctx, doc(0, "123456")); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	added := make(chan error, 1)
+	go func() { added <- ix.Add(ctx, doc(1, "123456")) }()
+	select {
+	case err := <-added:
+		t.Fatalf("Add over the bound returned %v before the first document was upserted", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(w.block)
+	if err := <-added; err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if err := ix.Close(ctx); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if got := ix.Added(); got != 2 {
+		t.Errorf("Added() = %d, want 2", got)
+	}
+}
+
+func TestMaxBytesAllowsLargeDocument(t *testing.T) {
+	ctx := context.Background()
+	ix := New(&fakeWriter{}, WithMaxBytes(4))
+
+	if err := ix.Add(ctx, doc(0, "larger than the bound")); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if err := ix.Close(ctx); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+}
+
+func TestCancelledSendReleasesBytes(t *testing.T) {
+	w := &fakeWriter{block: make(chan struct{})}
+	var failed atomic.Int64
+	ix := New(w, WithBatchSize(1), WithConcurrency(1), WithMaxBytes(100), WithErrorHandler(func(batch []Document, err error) {
+		failed.Add(int64(len(batch)))
+	}))
+
+	// the first batch holds the only slot until the writer is unblocked
+	if err := ix.Add(context.Background(), doc(0, "123")); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := ix.Add(ctx, doc(1, "1234")); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Add = %v, want %v", err, context.Canceled)
+	}
+	if got := failed.Load(); got != 1 {
+		t.Errorf("error handler got %d documents, want 1", got)
+	}
+
+	ix.mu.Lock()
+	bytes := ix.bytes
+	ix.mu.Unlock()
+	if bytes != 3 {
+		t.Errorf("%d bytes reserved, want the 3 of the batch in flight", bytes)
+	}
+
+	close(w.block)
+	if err := ix.Close(context.Background()); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+}
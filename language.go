@@ -0,0 +1,14 @@
+package main
+
+import "strings"
+
+// codeFence wraps content in a fence tagged with lang, long enough not to be
+// closed by backticks inside content.
+func codeFence(content, lang string) string {
+	fence := "```"
+	for strings.Contains(content, fence) {
+		fence += "`"
+	}
+
+	return fence + lang + "\n" + strings.TrimRight(content, "\n") + "\n" + fence
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+type ChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type ChatOptions struct {
+	Model       string
+	Temperature float64
+}
+
+type ChatClient interface {
+	Chat(ctx context.Context, messages []ChatMessage, opts ChatOptions) (string, error)
+}
+
+func NewChatClient(provider, baseURL, apiKey string) (ChatClient, error) {
+	switch provider {
+	case "ollama":
+		return &ollamaChat{baseURL: strings.TrimSuffix(baseURL, "/"), http: http.DefaultClient}, nil
+	case "openai":
+		return &openAIChat{baseURL: strings.TrimSuffix(baseURL, "/"), apiKey: apiKey, http: http.DefaultClient}, nil
+	default:
+		return nil, fmt.Errorf("unknown llm provider %q", provider)
+	}
+}
+
+type ollamaChat struct {
+	baseURL string
+	http    *http.Client
+}
+
+func (c *ollamaChat) Chat(ctx context.Context, messages []ChatMessage, opts ChatOptions) (string, error) {
+	req := map[string]any{
+		"model":    opts.Model,
+		"messages": messages,
+		"stream":   false,
+		"options":  map[string]any{"temperature": opts.Temperature},
+	}
+
+	var resp struct {
+		Message ChatMessage `json:"message"`
+	}
+	if err := postJSON(ctx, c.http, c.baseURL+"/api/chat", nil, req, &resp); err != nil {
+		return "", fmt.Errorf("ollama chat: %w", err)
+	}
+
+	return resp.Message.Content, nil
+}
+
+type openAIChat struct {
+	baseURL string
+	apiKey  string
+	http    *http.Client
+}
+
+func (c *openAIChat) Chat(ctx context.Context, messages []ChatMessage, opts ChatOptions) (string, error) {
+	req := map[string]any{
+		"model":       opts.Model,
+		"messages":    messages,
+		"temperature": opts.Temperature,
+	}
+
+	headers := map[string]string{}
+	if c.apiKey != "" {
+		headers["Authorization"] = "Bearer " + c.apiKey
+	}
+
+	var resp struct {
+		Choices []struct {
+			Message ChatMessage `json:"message"`
+		} `json:"choices"`
+	}
+	if err := postJSON(ctx, c.http, c.baseURL+"/chat/completions", headers, req, &resp); err != nil {
+		return "", fmt.Errorf("openai chat: %w", err)
+	}
+
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("openai chat: empty response")
+	}
+
+	return resp.Choices[0].Message.Content, nil
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, headers map[string]string, in, out any) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("unexpected status %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/karitham/cls/index"
+)
+
+// levelTrace logs every progress event, below debug.
+const levelTrace = index.LevelTrace
+
+// Log formats of the -log-format flag.
+const (
+	LogText = "text"
+	LogJSON = "json"
+)
+
+var logFormats = []string{LogText, LogJSON}
+
+// LogOptions selects how much is logged and how.
+type LogOptions struct {
+	// Verbosity is 1 for -v, 2 for -vv, and -1 for -quiet.
+	Verbosity int
+	Format    string
+}
+
+func (o LogOptions) level() slog.Level {
+	switch {
+	case o.Verbosity < 0:
+		return slog.LevelError
+	case o.Verbosity == 1:
+		return slog.LevelDebug
+	case o.Verbosity > 1:
+		return levelTrace
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// newLogger returns the logger writing to w, which is stderr so logs never
+// mix with the results written to stdout.
+func newLogger(w io.Writer, opts LogOptions) (*slog.Logger, error) {
+	handlerOpts := &slog.HandlerOptions{
+		Level: opts.level(),
+		ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.LevelKey && a.Value.Any() == levelTrace {
+				a.Value = slog.StringValue("TRACE")
+			}
+			return a
+		},
+	}
+
+	switch opts.Format {
+	case LogText, "":
+		return slog.New(slog.NewTextHandler(w, handlerOpts)), nil
+	case LogJSON:
+		return slog.New(slog.NewJSONHandler(w, handlerOpts)), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q", opts.Format)
+	}
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/karitham/cls/buildinfo"
+)
+
+// JSON-RPC error codes used by the language server.
+const (
+	lspMethodNotFound = -32601
+	lspInvalidParams  = -32602
+	lspRequestFailed  = -32803
+)
+
+// lspSymbolFile is the SymbolKind of results, which are chunks of files
+// rather than symbols.
+const lspSymbolFile = 1
+
+// lspMessage is a JSON-RPC request, notification or response.
+type lspMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  any             `json:"result,omitempty"`
+	Error   *lspError       `json:"error,omitempty"`
+}
+
+type lspError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspLocation struct {
+	URI   string   `json:"uri"`
+	Range lspRange `json:"range"`
+}
+
+type lspSymbol struct {
+	Name          string      `json:"name"`
+	Kind          int         `json:"kind"`
+	Location      lspLocation `json:"location"`
+	ContainerName string      `json:"containerName,omitempty"`
+}
+
+// lspResult is a result of the cls/search method, for clients wanting more
+// than workspace/symbol gives.
+type lspResult struct {
+	Location lspLocation `json:"location"`
+	Path     string      `json:"path"`
+	Content  string      `json:"content"`
+	Score    float64     `json:"score"`
+	Line     int         `json:"line,omitempty"`
+	Language string      `json:"language,omitempty"`
+	Section  string      `json:"section,omitempty"`
+	Title    string      `json:"title,omitempty"`
+}
+
+// lspServer answers semantic searches over the Language Server Protocol:
+// workspace/symbol lists the chunks matching the query as symbols, so any
+// editor with LSP support can search a collection, and cls/search returns
+// the results with their content. The collection of the workspace is
+// resolved on initialize, and connected to on the first search.
+type lspServer struct {
+	chromaOpts ChromaOptions
+	collection string
+	opts       QueryOptions
+	logger     *slog.Logger
+
+	client ChromaClient
+	coll   Collection
+
+	mu sync.Mutex
+	w  *bufio.Writer
+}
+
+// serveLSP runs a language server on stdin and stdout until the client
+// exits.
+func serveLSP(chromaOpts ChromaOptions, collection string, opts QueryOptions, logger *slog.Logger) {
+	s := &lspServer{chromaOpts: chromaOpts, collection: collection, opts: opts, logger: logger, w: bufio.NewWriter(os.Stdout)}
+	defer func() {
+		if s.client != nil {
+			s.client.Close()
+		}
+	}()
+
+	if err := s.serve(context.Background(), os.Stdin); err != nil {
+		logger.Error("Language server failed", "error", err)
+		os.Exit(1)
+	}
+}
+
+func (s *lspServer) serve(ctx context.Context, r io.Reader) error {
+	tp := textproto.NewReader(bufio.NewReader(r))
+	for {
+		header, err := tp.ReadMIMEHeader()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read message header: %w", err)
+		}
+		length, err := strconv.Atoi(header.Get("Content-Length"))
+		if err != nil {
+			return fmt.Errorf("invalid Content-Length %q", header.Get("Content-Length"))
+		}
+		body := make([]byte, length)
+		if _, err := io.ReadFull(tp.R, body); err != nil {
+			return fmt.Errorf("failed to read message: %w", err)
+		}
+
+		var msg lspMessage
+		if err := json.Unmarshal(body, &msg); err != nil {
+			s.logger.Warn("Ignoring invalid message", "error", err)
+			continue
+		}
+		if msg.Method == "exit" {
+			return nil
+		}
+
+		result, rerr := s.handle(ctx, msg)
+		if msg.ID == nil {
+			// notifications get no response
+			continue
+		}
+		if err := s.send(lspMessage{JSONRPC: "2.0", ID: msg.ID, Result: result, Error: rerr}); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *lspServer) send(msg lspMessage) error {
+	// a null result must still be sent for requests without one
+	if msg.Result == nil && msg.Error == nil {
+		msg.Result = json.RawMessage("null")
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fmt.Fprintf(s.w, "Content-Length: %d\r\n\r\n", len(data))
+	s.w.Write(data)
+	if err := s.w.Flush(); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+
+	return nil
+}
+
+func (s *lspServer) handle(ctx context.Context, msg lspMessage) (any, *lspError) {
+	switch msg.Method {
+	case "initialize":
+		var params struct {
+			RootURI  string `json:"rootUri"`
+			RootPath string `json:"rootPath"`
+		}
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &lspError{Code: lspInvalidParams, Message: err.Error()}
+		}
+		root := params.RootPath
+		if u, err := url.Parse(params.RootURI); err == nil && u.Scheme == "file" {
+			root = u.Path
+		}
+		s.collection = resolveCollection(s.collection, projectRoot(cmp.Or(root, ".")))
+		s.logger.Info("Language server initialized", "collection", s.collection)
+
+		return map[string]any{
+			"capabilities": map[string]any{"workspaceSymbolProvider": true},
+			"serverInfo":   map[string]any{"name": "cls", "version": buildinfo.Get().Version},
+		}, nil
+	case "workspace/symbol":
+		var params struct {
+			Query string `json:"query"`
+		}
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &lspError{Code: lspInvalidParams, Message: err.Error()}
+		}
+		results, err := s.search(ctx, params.Query, s.opts.N)
+		if err != nil {
+			return nil, &lspError{Code: lspRequestFailed, Message: err.Error()}
+		}
+
+		symbols := []lspSymbol{}
+		for _, r := range results {
+			loc, ok := lspLocationOf(r)
+			if !ok {
+				continue
+			}
+			symbols = append(symbols, lspSymbol{Name: lspSymbolName(r), Kind: lspSymbolFile, Location: loc, ContainerName: r.RelPath})
+		}
+		return symbols, nil
+	case "cls/search":
+		var params struct {
+			Query string `json:"query"`
+			N     int    `json:"n"`
+		}
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &lspError{Code: lspInvalidParams, Message: err.Error()}
+		}
+		results, err := s.search(ctx, params.Query, params.N)
+		if err != nil {
+			return nil, &lspError{Code: lspRequestFailed, Message: err.Error()}
+		}
+
+		out := []lspResult{}
+		for _, r := range results {
+			loc, _ := lspLocationOf(r)
+			out = append(out, lspResult{
+				Location: loc,
+				Path:     r.Path,
+				Content:  r.Content,
+				Score:    r.Score,
+				Line:     r.Line,
+				Language: r.Language,
+				Section:  r.Section,
+				Title:    r.Title,
+			})
+		}
+		return out, nil
+	case "shutdown", "initialized", "$/cancelRequest", "$/setTrace":
+		return nil, nil
+	}
+
+	return nil, &lspError{Code: lspMethodNotFound, Message: "method not supported: " + msg.Method}
+}
+
+// search queries the collection, connecting to it first if needed. Empty
+// queries, as sent by editors when the symbol picker opens, have no
+// results.
+func (s *lspServer) search(ctx context.Context, query string, n int) ([]QueryResult, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, nil
+	}
+
+	if s.coll == nil {
+		if s.collection == autoCollection {
+			// searched before initialize
+			s.collection = resolveCollection(s.collection, projectRoot("."))
+		}
+		client, err := newStore(s.chromaOpts, s.logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create ChromaDB client: %w", err)
+		}
+		coll, err := client.GetCollection(ctx, s.collection)
+		if err != nil {
+			client.Close()
+			return nil, fmt.Errorf("failed to get collection %s: %w", s.collection, err)
+		}
+		s.client, s.coll = client, coll
+	}
+
+	opts := s.opts
+	if n > 0 {
+		opts.N = n
+	}
+	results, err := Search(ctx, s.coll, s.collection, query, opts, s.logger)
+	if err != nil {
+		return nil, err
+	}
+	locateMatches(results, expandTerms(queryTerms(query)))
+
+	return results, nil
+}
+
+// lspLocationOf returns the file URI and lines of r, the whole file for
+// documents indexed without lines. Documents whose root is unknown here
+// have no location.
+func lspLocationOf(r QueryResult) (lspLocation, bool) {
+	uri, ok := fileURI(r)
+	if !ok {
+		return lspLocation{}, false
+	}
+
+	var rng lspRange
+	if r.StartLine > 0 {
+		// the end is the start of the line after the chunk, LSP lines
+		// counting from 0
+		rng = lspRange{Start: lspPosition{Line: r.StartLine - 1}, End: lspPosition{Line: r.EndLine}}
+	}
+
+	return lspLocation{URI: uri, Range: rng}, true
+}
+
+// lspSymbolName names r in symbol lists by its section or title, or its
+// first line of text.
+func lspSymbolName(r QueryResult) string {
+	if r.Section != "" {
+		return r.Section
+	}
+	if r.Title != "" {
+		return r.Title
+	}
+	for line := range strings.Lines(r.Content) {
+		if line = strings.TrimSpace(line); line != "" {
+			if runes := []rune(line); len(runes) > 80 {
+				line = string(runes[:80]) + "…"
+			}
+			return line
+		}
+	}
+
+	return filepath.Base(r.Path)
+}
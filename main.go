@@ -1,150 +1,1648 @@
 package main
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io/fs"
+	"iter"
 	"log/slog"
+	"net/http"
 	"os"
 	"slices"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/karitham/cls/chunk"
 	"github.com/karitham/cls/dirextractor"
+	"github.com/karitham/cls/index"
+	"github.com/karitham/cls/indexer"
+	"github.com/karitham/cls/query"
+	"github.com/karitham/cls/store"
 )
 
+// addQueryFlags registers the flags shared by commands that run a search.
+func addQueryFlags(fs *flag.FlagSet, opts *QueryOptions) {
+	fs.IntVar(&opts.N, "n", 5, "Number of results to return")
+	fs.StringVar(&opts.Rerank.Provider, "rerank", "", "Rerank candidates with a cross-encoder (ollama, cohere or jina)")
+	fs.StringVar(&opts.Rerank.Model, "rerank-model", "", "Model used for reranking")
+	fs.StringVar(&opts.Rerank.URL, "rerank-url", "", "Override the rerank endpoint URL")
+	fs.IntVar(&opts.Rerank.Candidates, "rerank-candidates", 50, "Number of candidates fetched before reranking")
+	fs.Float64Var(&opts.Diversity, "diversity", 0, "Diversify results with maximal marginal relevance (0 disables, 1 is most diverse)")
+	fs.BoolVar(&opts.Calibrated, "calibrated", false, "Drop results beyond the distance cutoff learned from feedback")
+	fs.Float64Var(&opts.MinScore, "min-score", 0, "Drop results below this cosine similarity, from 0 to 1, and exit with status 3 when none is left")
+	fs.BoolVar(&opts.NoFallback, "no-fallback", false, "Do not retry with fallback strategies when nothing matches")
+	fs.BoolVar(&opts.Force, "force", false, "Query even if the collection was built with another embedder")
+	fs.BoolVar(&opts.AutoPruneMissing, "auto-prune-missing", false, "Delete results whose file no longer exists from the collection")
+	addLanguagesFlag(fs, &opts.Languages, "Only return results in these comma separated languages, such as go,python")
+	fs.Func("run", "Only return documents last written by this index run, see runs list", func(id string) error {
+		opts.Run = id
+		return checkRunID(id)
+	})
+	fs.Func("not", "Push down results similar to this phrase, such as tests (repeatable)", func(s string) error {
+		opts.Not = append(opts.Not, s)
+		return nil
+	})
+	fs.Float64Var(&opts.NotWeight, "not-weight", 0.5, "How strongly -not pushes similar results down, from 0 to 1")
+	fs.Func("exclude-path", "Drop results whose path matches this glob, such as 'testdata/**' (repeatable)", func(s string) error {
+		if _, err := query.Glob(s); err != nil {
+			return err
+		}
+		opts.ExcludePaths = append(opts.ExcludePaths, s)
+		return nil
+	})
+	opts.Target = store.TargetBoth
+	fs.Func("target", "Search the code of chunks, their descriptions embedded with index -summarize, or both: "+strings.Join(targets, ", "), func(s string) error {
+		if !slices.Contains(targets, s) {
+			return fmt.Errorf("expected one of %s", strings.Join(targets, ", "))
+		}
+		opts.Target = s
+		return nil
+	})
+	fs.IntVar(&opts.Callers, "callers", 0, "List up to this many call sites of the functions defined in each result")
+	fs.Func("author", "Only keep results mostly written by this author, matched against name and email (repeatable, needs index -blame)", func(s string) error {
+		opts.Authors = append(opts.Authors, s)
+		return nil
+	})
+	fs.IntVar(&opts.Expand.N, "expand", 0, "Also search this many LLM paraphrases of the query, merged with rank fusion")
+	fs.StringVar(&opts.Expand.Model, "expand-model", "llama3.2", "Ollama model writing the paraphrases")
+	fs.StringVar(&opts.Expand.URL, "expand-url", "http://127.0.0.1:11434", "Ollama URL of the paraphrasing model")
+	fs.Func("scope", "Only return results in this scope profile of .cls.toml", func(name string) error {
+		patterns, err := loadScope(projectRoot("."), name)
+		opts.Scope = patterns
+		return err
+	})
+	fs.Func("exclude-license", "Drop results under this SPDX license id, such as GPL-3.0 (repeatable)", func(id string) error {
+		opts.ExcludeLicenses = append(opts.ExcludeLicenses, id)
+		return nil
+	})
+}
+
+// addLanguagesFlag registers -lang, a comma separated list of languages.
+func addLanguagesFlag(fs *flag.FlagSet, langs *[]string, usage string) {
+	fs.Func("lang", usage, func(s string) error {
+		for _, lang := range strings.Split(s, ",") {
+			if lang = strings.ToLower(strings.TrimSpace(lang)); lang != "" {
+				*langs = append(*langs, lang)
+			}
+		}
+		return nil
+	})
+}
+
+// addDisplayFlags registers the flags controlling how results are printed.
+// The returned function applies them to printer once fs is parsed.
+func addDisplayFlags(fs *flag.FlagSet, printer *Printer) func() {
+	maxLines := fs.Int("max-lines", 20, "Maximum content lines printed per result")
+	full := fs.Bool("full", false, "Print result content in full")
+	contextLines := fs.Int("context", 3, "Lines of context printed around the matching line of results, 0 to print content from the start")
+	output := OutputText
+	fs.Func("output", "Result format: text, the default, grep for path:line:text lines, or editor for file:// URIs with #Lstart-Lend to open results at their lines", func(s string) error {
+		if s != OutputText && s != OutputGrep && s != OutputEditor {
+			return fmt.Errorf("expected %s, %s or %s", OutputText, OutputGrep, OutputEditor)
+		}
+		output = s
+		return nil
+	})
+	pathStyle := PathRelative
+	fs.Func("path-style", "Display paths relative to the indexed root (relative, the default) or as local paths (absolute)", func(s string) error {
+		if s != PathRelative && s != PathAbsolute {
+			return fmt.Errorf("expected %s or %s", PathRelative, PathAbsolute)
+		}
+		pathStyle = s
+		return nil
+	})
+
+	return func() {
+		if !*full {
+			printer.SetMaxLines(*maxLines)
+			printer.SetContext(*contextLines)
+		}
+		printer.SetPathStyle(pathStyle)
+		printer.SetOutput(output)
+	}
+}
+
+// addChromaFlags registers the flags configuring the connection to ChromaDB.
+// Their defaults come from the CHROMA_* environment variables.
+func addChromaFlags(fs *flag.FlagSet, opts *ChromaOptions) {
+	fs.StringVar(&opts.URL, "url", cmp.Or(os.Getenv("CHROMA_URL"), opts.URL), "ChromaDB server URL, or the URL of the -store used")
+	fs.StringVar(&opts.Token, "chroma-token", os.Getenv("CHROMA_TOKEN"), "Token sent as a bearer token to ChromaDB")
+	fs.StringVar(&opts.TokenHeader, "chroma-token-header", cmp.Or(os.Getenv("CHROMA_TOKEN_HEADER"), "Authorization"), "Header carrying the token: Authorization or X-Chroma-Token")
+	fs.StringVar(&opts.Username, "chroma-user", os.Getenv("CHROMA_USER"), "Username for basic auth to ChromaDB")
+	fs.StringVar(&opts.Password, "chroma-password", os.Getenv("CHROMA_PASSWORD"), "Password for basic auth to ChromaDB")
+	fs.StringVar(&opts.CACert, "chroma-ca-cert", os.Getenv("CHROMA_CA_CERT"), "PEM file of a CA trusted for the ChromaDB server")
+	fs.BoolVar(&opts.Insecure, "chroma-insecure", os.Getenv("CHROMA_INSECURE") == "true", "Skip TLS verification of the ChromaDB server")
+	fs.DurationVar(&opts.Timeout, "chroma-timeout", 0, "Timeout of ChromaDB requests, 0 for the client default")
+	fs.StringVar(&opts.Tenant, "tenant", os.Getenv("CHROMA_TENANT"), "ChromaDB tenant")
+	fs.StringVar(&opts.Database, "database", os.Getenv("CHROMA_DATABASE"), "ChromaDB database")
+	fs.IntVar(&opts.HNSW.M, "hnsw-m", 0, "Maximum edges per node of the HNSW index of collections created in milvus, elasticsearch and opensearch stores, 0 for the store default")
+	fs.IntVar(&opts.HNSW.EfConstruction, "hnsw-ef-construction", 0, "Candidate list size while building the HNSW index of collections created in milvus, elasticsearch and opensearch stores, 0 for the store default")
+	fs.IntVar(&opts.HNSW.Ef, "hnsw-ef", 0, "Candidate list size while searching milvus and elasticsearch collections, at least the results fetched")
+	opts.Store = store.Default
+	fs.Func("store", "Vector store at -url: "+strings.Join(store.Names(), ", ")+" (default chroma)", func(s string) error {
+		if !slices.Contains(store.Names(), s) {
+			return fmt.Errorf("expected one of %s", strings.Join(store.Names(), ", "))
+		}
+		opts.Store = s
+		return nil
+	})
+}
+
+// addSecretFlags registers the flags selecting how secrets are handled when
+// indexing. The returned function builds the policy once fs is parsed.
+func addSecretFlags(fs *flag.FlagSet) func() (index.SecretPolicy, error) {
+	action := fs.String("secrets", index.SecretsMask, "What to do with files containing secrets: mask, skip, warn or off")
+	rules := fs.String("secret-rules", "", "JSON file of additional secret detection rules")
+
+	return func() (index.SecretPolicy, error) {
+		return index.NewSecretPolicy(*action, *rules)
+	}
+}
+
 func main() {
+	// the server of the config files, as written by up, is the default
+	chromaOpts := ChromaOptions{URL: cmp.Or(configValue(projectRoot("."), "chroma", "url"), "http://localhost:8000")}
+	addChromaFlags(flag.CommandLine, &chromaOpts)
+
 	var (
-		chromaURL  = flag.String("url", "http://localhost:8000", "ChromaDB server URL")
-		collection = flag.String("collection", "files", "ChromaDB collection name")
+		collection = flag.String("collection", autoCollection, "ChromaDB collection name, or auto to derive it from the git remote or project path")
+		plain      = flag.Bool("plain", false, "Plain line-oriented output without decorations")
+		noColor    = flag.Bool("no-color", false, "Do not highlight result content, also disabled by setting NO_COLOR")
+		progressFD = flag.Int("progress-fd", 0, "Write JSON lines progress events of index and migrate runs to this file descriptor")
+		verbose    = flag.Bool("v", false, "Log debug messages and progress phases")
+		trace      = flag.Bool("vv", false, "Also log every progress event")
+		quiet      = flag.Bool("quiet", false, "Only log errors, and print no warnings")
+		profile    = flag.Bool("profile", false, "Print the time spent walking, reading, chunking, embedding and in the store to stderr")
+		cpuProfile = flag.String("cpuprofile", "", "Write a pprof CPU profile to this file")
+		memProfile = flag.String("memprofile", "", "Write a pprof heap profile to this file on exit")
+		ci         = flag.Bool("ci", false, "Never prompt, print index summaries as JSON and to GITHUB_STEP_SUMMARY, and exit 4 when only some files failed")
+		logOpts    LogOptions
 	)
+	flag.Func("log-format", "Format of logs written to stderr: "+strings.Join(logFormats, ", "), func(s string) error {
+		if !slices.Contains(logFormats, s) {
+			return fmt.Errorf("expected one of %s", strings.Join(logFormats, ", "))
+		}
+		logOpts.Format = s
+		return nil
+	})
 
 	flag.Parse()
 
-	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	switch {
+	case *quiet:
+		logOpts.Verbosity = -1
+	case *trace:
+		logOpts.Verbosity = 2
+	case *verbose:
+		logOpts.Verbosity = 1
+	}
+	logger, err := newLogger(os.Stderr, logOpts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	printer := NewPrinter(os.Stdout, *plain)
+	if *noColor || *ci {
+		printer.SetColor(false)
+	}
+	ciMode = *ci
+	printer.SetQuiet(*quiet)
+
+	if *profile {
+		profiler = NewProfile()
+	}
+	if *cpuProfile != "" {
+		stop, err := startCPUProfile(*cpuProfile)
+		if err != nil {
+			logger.Error("Failed to start profiling", "error", err)
+			os.Exit(1)
+		}
+		defer stop()
+	}
+	if *memProfile != "" {
+		defer func() {
+			if err := writeHeapProfile(*memProfile); err != nil {
+				logger.Error("Failed to write heap profile", "error", err)
+			}
+		}()
+	}
+	defer profiler.Report(os.Stderr)
+
+	progress, err := openProgressFD(*progressFD, logger)
+	if err != nil {
+		logger.Error("Failed to open progress output", "error", err)
+		os.Exit(1)
+	}
 
 	if len(flag.Args()) < 1 {
 		fmt.Println("Usage: cls [command] [options]")
 		fmt.Println("Commands:")
-		fmt.Println("  index <filepath>  - Index a file or directory")
-		fmt.Println("  query <search>     - Query the indexed content")
+		fmt.Println("  index [path...]    - Index files, directories or zip/tar archives (- for a tar on stdin), the current project by default")
+		fmt.Println("  diff-index -since <ref> [path] - Index only the files changed since a git ref, deleting removed ones")
+		fmt.Println("  query [search]     - Query the indexed content, or resume the last search")
+		fmt.Println("  query -bundle <snapshot> <search> - Search an exported snapshot without ChromaDB")
+		fmt.Println("  query -i           - Read queries from stdin in a loop, keeping clients warm")
+		fmt.Println("  query -auto-daemon <search> - Start a daemon in the background if needed and query through it")
+		fmt.Println("  query -all | -collection a -collection b <search> - Query several collections at once and merge their results")
+		fmt.Println("  query -route docs | -route all <search> - Query the collections of the routes of .cls.toml")
+		fmt.Println("  find <path> <query> - Index a path if needed and query it in one step")
+		fmt.Println("  daemon             - Keep clients and the embedder warm and answer queries on a unix socket, used by query when running")
+		fmt.Println("  lsp                - Serve semantic search to editors as a language server on stdio (workspace/symbol, cls/search)")
+		fmt.Println("  serve -grpc        - Serve the Index, Query, Watch and Admin RPCs of proto/cls/v1 over gRPC")
+		fmt.Println("  grep-ai <path> <query> - Index a path in memory, query it and forget it, without ChromaDB or state")
+		fmt.Println("  similar <file>[:start-end] - Find the indexed code most similar to a file, lines of it or stdin (-)")
+		fmt.Println("  symbols <name>     - Find where a function, type or constant is defined, exactly or fuzzily")
+		fmt.Println("  ask <question>     - Answer a question using the indexed content")
+		fmt.Println("  pack <query>       - Print the context ask would send to the model")
+		fmt.Println("  get <result|id>    - Print the full content of a result or document")
+		fmt.Println("  bulk pack|open|markdown|exclude <result>... - Act on several results of the last query")
+		fmt.Println("  feedback <result>  - Mark a result of the last query as relevant or irrelevant")
+		fmt.Println("  feedback export    - Export recorded feedback as an eval set")
+		fmt.Println("  analytics          - Report query analytics for the collection")
+		fmt.Println("  collections list|rename|copy|alias|unalias|migrate-ids - Manage collections")
+		fmt.Println("  runs list          - List the index runs of the collection, for query -run and delete -run")
+		fmt.Println("  export             - Export the collection to a snapshot file")
+		fmt.Println("  import <snapshot>  - Import a snapshot file into a collection")
+		fmt.Println("  migrate -from <store> -to <store> - Copy documents and embeddings between stores (" + strings.Join(storeBackends(), ", ") + ")")
+		fmt.Println("  verify -reproducible - Check a sample of the collection chunks again to the same hashes")
+		fmt.Println("  protect [name]     - Protect a collection from destructive commands")
+		fmt.Println("  unprotect [name]   - Remove the protection of a collection")
 		fmt.Println("  delete             - Delete the collection")
+		fmt.Println("  up                 - Start a local ChromaDB container and write its URL to the user config file")
+		fmt.Println("  down               - Stop the ChromaDB container started by up")
+		fmt.Println("  doctor             - Check ChromaDB, Ollama, the collection, config and state, and suggest fixes")
+		fmt.Println("  version            - Print build information")
 		fmt.Println("Flags:")
 		flag.PrintDefaults()
 		os.Exit(1)
 	}
 
 	command := flag.Args()[0]
+	collectionName := resolveCollection(*collection, projectRoot("."))
 
 	switch command {
 	case "index":
-		if len(flag.Args()) < 2 {
-			logger.Error("Please provide a filepath to index")
+		fs := flag.NewFlagSet("index", flag.ExitOnError)
+		dryRun := fs.Bool("dry-run", false, "Report what would be indexed without contacting ChromaDB or Ollama")
+		report := fs.String("report", "", "Write a JSON report of the run to this file")
+		replace := fs.Bool("replace", false, "Rebuild the collection in a new version and swap it in once complete, so queries never see a partial index")
+		var (
+			walkOpts WalkOptions
+			urls     []string
+		)
+		fs.BoolVar(&walkOpts.FollowSymlinks, "follow-symlinks", false, "Follow symbolic links that stay inside the indexed path")
+		fs.BoolVar(&walkOpts.IncludeGenerated, "include-generated", false, "Index generated files, lockfiles and vendored directories")
+		secretPolicy := addSecretFlags(fs)
+		addOpts := AddOptions{Options: index.Options{Progress: progress}}
+		fs.IntVar(&addOpts.BatchSize, "batch-size", index.DefaultBatchSize, "Number of documents embedded per request")
+		fs.IntVar(&addOpts.Concurrency, "embed-concurrency", indexer.DefaultConcurrency, "Number of embedding requests in flight")
+		fs.Float64Var(&addOpts.RateLimit, "rate-limit", 0, "Maximum embedding requests per second, 0 for no limit")
+		fs.Func("max-memory", "Pause reading files while this much chunk text, such as 512MB, waits to be embedded (default no limit)", func(s string) error {
+			n, err := parseBytes(s)
+			addOpts.MaxMemory = n
+			return err
+		})
+		addLanguagesFlag(fs, &addOpts.Languages, "Only index files in these comma separated languages, such as go,python")
+		var limits TreeLimits
+		fs.IntVar(&limits.Files, "max-files", 100_000, "Ask for confirmation before indexing more files than this, 0 for no limit")
+		fs.Int64Var(&limits.Bytes, "max-bytes", 2<<30, "Ask for confirmation before indexing more bytes than this, 0 for no limit")
+		force := fs.Bool("force", false, "Do not ask for confirmation before indexing large trees")
+		fs.Func("url", "Also crawl and index the documentation pages under this URL or sitemap (repeatable)", func(s string) error {
+			if !isURL(s) {
+				return fmt.Errorf("expected an http or https URL")
+			}
+			urls = append(urls, s)
+			return nil
+		})
+		fs.IntVar(&walkOpts.CrawlDepth, "crawl-depth", 2, "How many links away from -url pages are fetched")
+		fs.IntVar(&walkOpts.CrawlMax, "crawl-max", 500, "Maximum number of pages fetched per -url")
+		scope := fs.String("scope", "", "Only index the files of this scope profile of .cls.toml")
+		records := fs.Bool("records", false, "Index every row of .csv and line of .jsonl files as its own document")
+		var recordColumns []string
+		fs.Func("record-columns", "Comma separated fields making up the text of records, all fields by default; implies -records", func(s string) error {
+			for _, c := range strings.Split(s, ",") {
+				if c = strings.TrimSpace(c); c != "" {
+					recordColumns = append(recordColumns, c)
+				}
+			}
+			return nil
+		})
+		summarize := fs.Bool("summarize", false, "Also embed an LLM summary of every chunk, matched by queries along the chunk")
+		var summarizeOpts SummarizeOptions
+		fs.StringVar(&summarizeOpts.Model, "summarize-model", "llama3.2", "Ollama model writing the summaries")
+		fs.StringVar(&summarizeOpts.URL, "summarize-url", "http://127.0.0.1:11434", "Ollama URL of the summarizing model")
+		fs.BoolVar(&addOpts.KeepDuplicates, "keep-duplicates", false, "Index every file with the same content as another, rather than recording its path on the first")
+		fs.Float64Var(&addOpts.NearDuplicates, "near-duplicates", 0, "Also treat files as duplicates when their MinHash similarity reaches this, such as 0.9, 0 for exact copies only")
+		fs.BoolVar(&addOpts.Blame, "blame", false, "Store the main author and last commit of every chunk from git blame")
+		fs.BoolVar(&addOpts.RecordEnv, "record-env", false, "Record the tool version, model digest, chunker and ignore files in the collection for verify -reproducible")
+		fs.Parse(flag.Args()[1:])
+
+		secrets, err := secretPolicy()
+		if err != nil {
+			logger.Error("Invalid secrets options", "error", err)
+			os.Exit(1)
+		}
+		addOpts.Secrets = secrets
+		addOpts.IncludeGenerated = walkOpts.IncludeGenerated
+		if *summarize {
+			summarizer, err := newSummarizer(summarizeOpts)
+			if err != nil {
+				logger.Error("Invalid summarize options", "error", err)
+				os.Exit(1)
+			}
+			addOpts.Summarizer = summarizer
+		}
+		if *records || len(recordColumns) > 0 {
+			addOpts.Records = &index.RecordOptions{Columns: recordColumns}
+			walkOpts.Records = true
+		}
+		if addOpts.BatchSize < 1 || addOpts.Concurrency < 1 || addOpts.RateLimit < 0 {
+			logger.Error("Batch size and embed concurrency must be positive, and the rate limit not negative")
+			os.Exit(1)
+		}
+		if addOpts.NearDuplicates < 0 || addOpts.NearDuplicates > 1 {
+			logger.Error("Near duplicate similarity must be between 0 and 1")
+			os.Exit(1)
+		}
+
+		// without a path, index the whole project the working directory is in
+		targets := fs.Args()
+		if len(targets) == 0 && len(urls) == 0 {
+			targets = []string{projectRoot(".")}
+		}
+		targets = append(targets, urls...)
+		for _, target := range targets {
+			if err := checkIndexable(target); err != nil {
+				logger.Error("Cannot index path", "path", target, "error", err)
+				os.Exit(1)
+			}
+		}
+		if *scope != "" {
+			if walkOpts.Scope, err = loadScope(projectRoot(targets[0]), *scope); err != nil {
+				logger.Error("Invalid scope", "error", err)
+				os.Exit(1)
+			}
+		}
+		if *dryRun {
+			for _, target := range targets {
+				if isArchive(target) || isURL(target) {
+					logger.Warn("Skipping archive, dry runs only walk directories", "path", target)
+					continue
+				}
+				dryRunIndex(target, walkOpts, addOpts, printer, logger)
+			}
+			return
+		}
+		for _, target := range targets {
+			if target == stdinTarget || isURL(target) {
+				continue
+			}
+			// CI runs index what they are given, without a prompt
+			if !confirmTreeSize(target, limits, *force || ciMode, logger) {
+				printer.Message("Aborted")
+				return
+			}
+		}
+		if walkOpts.Routes, err = loadRoutes(projectRoot(targets[0])); err != nil {
+			logger.Error("Invalid routes", "error", err)
 			os.Exit(1)
 		}
-		filepath := flag.Args()[1]
-		indexFile(*chromaURL, *collection, filepath, logger)
+		indexFile(chromaOpts, resolveCollection(*collection, projectRoot(targets[0])), targets, *report, *replace, walkOpts, addOpts, printer, logger)
+	case "diff-index":
+		fs := flag.NewFlagSet("diff-index", flag.ExitOnError)
+		since := fs.String("since", "", "Git ref to index the changes since, such as origin/main (required)")
+		report := fs.String("report", "", "Write a JSON report of the run to this file")
+		var walkOpts WalkOptions
+		fs.BoolVar(&walkOpts.IncludeGenerated, "include-generated", false, "Index generated files, lockfiles and vendored directories")
+		secretPolicy := addSecretFlags(fs)
+		addOpts := AddOptions{Options: index.Options{Progress: progress}}
+		fs.IntVar(&addOpts.BatchSize, "batch-size", index.DefaultBatchSize, "Number of documents embedded per request")
+		fs.IntVar(&addOpts.Concurrency, "embed-concurrency", indexer.DefaultConcurrency, "Number of embedding requests in flight")
+		addLanguagesFlag(fs, &addOpts.Languages, "Only index files in these comma separated languages, such as go,python")
+		fs.Parse(flag.Args()[1:])
+
+		if *since == "" {
+			logger.Error("Usage: diff-index -since <ref> [path]")
+			os.Exit(1)
+		}
+		secrets, err := secretPolicy()
+		if err != nil {
+			logger.Error("Invalid secrets options", "error", err)
+			os.Exit(1)
+		}
+		addOpts.Secrets = secrets
+		addOpts.IncludeGenerated = walkOpts.IncludeGenerated
+		if addOpts.BatchSize < 1 || addOpts.Concurrency < 1 {
+			logger.Error("Batch size and embed concurrency must be positive")
+			os.Exit(1)
+		}
+
+		path := cmp.Or(fs.Arg(0), ".")
+		diffIndex(chromaOpts, resolveCollection(*collection, projectRoot(path)), path, *since, *report, walkOpts, addOpts, printer, logger)
 	case "query":
+		fs := flag.NewFlagSet("query", flag.ExitOnError)
+		var opts QueryOptions
+		addQueryFlags(fs, &opts)
+		applyDisplay := addDisplayFlags(fs, printer)
+		bundle := fs.String("bundle", "", "Search this exported snapshot directly instead of ChromaDB")
+		multi := fs.Bool("multi", false, "Treat each argument as a separate query and merge their results with rank fusion")
+		interactive := fs.Bool("i", false, "Read queries from stdin in a loop, keeping clients warm between them; show N prints the whole file of result N")
+		var daemonOpts DaemonOptions
+		fs.BoolVar(&daemonOpts.Disabled, "no-daemon", false, "Query directly even when a daemon is listening")
+		fs.BoolVar(&daemonOpts.Auto, "auto-daemon", os.Getenv("CLS_AUTO_DAEMON") == "true", "Start a daemon in the background when none is listening, to answer this query and the next ones")
+		fs.DurationVar(&daemonOpts.IdleTimeout, "daemon-idle-timeout", 30*time.Minute, "Stop the daemon started by -auto-daemon once it answered no query for this long")
+		var collections []string
+		fs.Func("collection", "Query this collection, overriding the global -collection; repeat it to query several at once", func(s string) error {
+			collections = append(collections, s)
+			return nil
+		})
+		all := fs.Bool("all", false, "Query every collection made by cls at once")
+		var routes []string
+		fs.Func("route", "Query the collection of this route of .cls.toml, or all for every route and the project collection; repeatable", func(s string) error {
+			routes = append(routes, s)
+			return nil
+		})
+		fs.Parse(flag.Args()[1:])
+		applyDisplay()
+
+		if len(routes) > 0 {
+			routed, err := routeCollections(projectRoot("."), routes, collectionName)
+			if err != nil {
+				logger.Error("Invalid route", "error", err)
+				os.Exit(1)
+			}
+			collections = append(collections, routed...)
+		}
+
+		if *all || len(collections) > 1 {
+			if fs.NArg() < 1 {
+				logger.Error("Please provide a search query")
+				os.Exit(1)
+			}
+			for i, c := range collections {
+				collections[i] = resolveCollection(c, projectRoot("."))
+			}
+			federatedQuery(chromaOpts, collections, *all, strings.Join(fs.Args(), " "), opts, printer, logger)
+			return
+		}
+		if len(collections) == 1 {
+			collectionName = resolveCollection(collections[0], projectRoot("."))
+		}
+
+		if *interactive {
+			queryREPL(chromaOpts, collectionName, opts, printer, logger)
+			return
+		}
+
+		if *bundle != "" {
+			if fs.NArg() < 1 {
+				logger.Error("Please provide a search query")
+				os.Exit(1)
+			}
+			queryBundle(*bundle, strings.Join(fs.Args(), " "), opts, printer, logger)
+			return
+		}
+
+		project := resolveCollection(autoCollection, projectRoot("."))
+		last, ok, err := loadSession(project)
+		if err != nil {
+			logger.Error("Failed to load session", "error", err)
+			os.Exit(1)
+		}
+
+		query := strings.Join(fs.Args(), " ")
+		if *multi && fs.NArg() > 1 {
+			query, opts.Alternatives = fs.Arg(0), fs.Args()[1:]
+		}
+		if fs.NArg() < 1 {
+			if !ok {
+				logger.Error("Please provide a search query")
+				os.Exit(1)
+			}
+
+			// resume the last search, with the flags given now on top
+			given := map[string]bool{}
+			fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+			query, opts = last.Query, last.resume(opts, given)
+			if *collection == autoCollection && len(collections) == 0 {
+				collectionName = last.Collection
+			}
+			logger.Info("Resuming last search", "query", query, "collection", collectionName)
+		}
+		session := Session{Collection: collectionName, Query: query, Filters: sessionFilters(opts), N: opts.N, Excluded: last.Excluded}
+		if err := saveSession(project, session); err != nil {
+			logger.Warn("Failed to save session", "error", err)
+		}
+		opts.Exclude = session.Excluded
+		queryDB(chromaOpts, session.Collection, session.Query, opts, daemonOpts, printer, logger)
+	case "find":
+		fs := flag.NewFlagSet("find", flag.ExitOnError)
+		var opts QueryOptions
+		addQueryFlags(fs, &opts)
+		applyDisplay := addDisplayFlags(fs, printer)
+		reindex := fs.Bool("reindex", false, "Index the path again even if a cached index exists")
+		secretPolicy := addSecretFlags(fs)
+		fs.Parse(flag.Args()[1:])
+		applyDisplay()
+
+		secrets, err := secretPolicy()
+		if err != nil {
+			logger.Error("Invalid secrets options", "error", err)
+			os.Exit(1)
+		}
+
+		if fs.NArg() < 2 {
+			logger.Error("Usage: find [flags] <path> <query>")
+			os.Exit(1)
+		}
+		path := fs.Arg(0)
+		findInPath(chromaOpts, resolveCollection(*collection, path), path, strings.Join(fs.Args()[1:], " "), *reindex, secrets, opts, printer, logger)
+	case "grep-ai":
+		fs := flag.NewFlagSet("grep-ai", flag.ExitOnError)
+		var opts QueryOptions
+		addQueryFlags(fs, &opts)
+		applyDisplay := addDisplayFlags(fs, printer)
+		var walkOpts WalkOptions
+		fs.BoolVar(&walkOpts.IncludeGenerated, "include-generated", false, "Index generated files, lockfiles and vendored directories")
+		secretPolicy := addSecretFlags(fs)
+		var addOpts AddOptions
+		fs.IntVar(&addOpts.BatchSize, "batch-size", index.DefaultBatchSize, "Number of documents embedded per request")
+		fs.IntVar(&addOpts.Concurrency, "embed-concurrency", indexer.DefaultConcurrency, "Number of embedding requests in flight")
+		fs.Parse(flag.Args()[1:])
+		applyDisplay()
+
+		secrets, err := secretPolicy()
+		if err != nil {
+			logger.Error("Invalid secrets options", "error", err)
+			os.Exit(1)
+		}
+		addOpts.Secrets = secrets
+		// -lang both limits the files indexed and the results returned
+		addOpts.Languages = opts.Languages
+		addOpts.IncludeGenerated = walkOpts.IncludeGenerated
+		if addOpts.BatchSize < 1 || addOpts.Concurrency < 1 {
+			logger.Error("Batch size and embed concurrency must be positive")
+			os.Exit(1)
+		}
+
+		if fs.NArg() < 2 {
+			logger.Error("Usage: grep-ai [flags] <path> <query>")
+			os.Exit(1)
+		}
+		grepAI(fs.Arg(0), strings.Join(fs.Args()[1:], " "), walkOpts, addOpts, opts, printer, logger)
+	case "lsp":
+		fs := flag.NewFlagSet("lsp", flag.ExitOnError)
+		var opts QueryOptions
+		addQueryFlags(fs, &opts)
+		fs.Parse(flag.Args()[1:])
+
+		// the collection is resolved from the workspace root on initialize
+		serveLSP(chromaOpts, *collection, opts, logger)
+	case "serve":
+		fs := flag.NewFlagSet("serve", flag.ExitOnError)
+		grpcAPI := fs.Bool("grpc", false, "Serve the gRPC API, the only API served for now")
+		addr := fs.String("addr", "localhost:7310", "Address to listen on")
+		var roots []string
+		fs.Func("root", "Directory clients may index, the current one when unset (repeatable)", func(s string) error {
+			roots = append(roots, s)
+			return nil
+		})
+		var opts QueryOptions
+		addQueryFlags(fs, &opts)
+		fs.Parse(flag.Args()[1:])
+
+		if !*grpcAPI {
+			logger.Error("Usage: serve -grpc [-addr host:port] [-root dir]...")
+			os.Exit(1)
+		}
+		if len(roots) == 0 {
+			roots = []string{"."}
+		}
+		serveGRPC(chromaOpts, collectionName, *addr, roots, opts, logger)
+	case "up":
+		fs := flag.NewFlagSet("up", flag.ExitOnError)
+		var opts UpOptions
+		fs.StringVar(&opts.Docker, "docker", cmp.Or(os.Getenv("CLS_DOCKER"), "docker"), "Container CLI, docker or a compatible one such as podman")
+		fs.StringVar(&opts.Image, "image", "chromadb/chroma", "ChromaDB image")
+		fs.StringVar(&opts.Container, "container", "cls-chroma", "Name of the container")
+		fs.StringVar(&opts.Volume, "volume", "cls-chroma-data", "Volume keeping the data of the container")
+		fs.IntVar(&opts.Port, "port", 8000, "Port of 127.0.0.1 ChromaDB is published on")
+		fs.BoolVar(&opts.PullModel, "pull-model", false, "Also pull the embedding model into Ollama")
+		fs.Parse(flag.Args()[1:])
+
+		upCommand(opts, printer, logger)
+	case "down":
+		fs := flag.NewFlagSet("down", flag.ExitOnError)
+		dockerCLI := fs.String("docker", cmp.Or(os.Getenv("CLS_DOCKER"), "docker"), "Container CLI, docker or a compatible one such as podman")
+		remove := fs.Bool("rm", false, "Remove the container once stopped, keeping its data volume")
+		purge := fs.Bool("purge", false, "Remove the container and its data volume")
+		fs.Parse(flag.Args()[1:])
+
+		downCommand(*dockerCLI, *remove, *purge, printer, logger)
+	case "daemon":
+		fs := flag.NewFlagSet("daemon", flag.ExitOnError)
+		socket := fs.String("socket", "", "Unix socket to listen on, CLS_DAEMON_SOCKET or daemon.sock in the state directory by default")
+		idle := fs.Duration("idle-timeout", 0, "Stop once no query was answered for this long, 0 to run until interrupted")
+		indexPath := fs.String("index", "", "Also keep the collection of the project at this path up to date in the background, queries taking priority over its embedding")
+		interval := fs.Duration("index-interval", 5*time.Minute, "How often -index looks for changed files")
+		fs.Parse(flag.Args()[1:])
+
+		if *indexPath != "" {
+			if err := checkIndexable(*indexPath); err != nil {
+				logger.Error("Cannot index path", "path", *indexPath, "error", err)
+				os.Exit(1)
+			}
+			if *interval <= 0 {
+				logger.Error("Index interval must be positive")
+				os.Exit(1)
+			}
+		}
+		runDaemon(chromaOpts, *collection, *socket, *idle, *indexPath, *interval, logger)
+	case "similar":
+		fs := flag.NewFlagSet("similar", flag.ExitOnError)
+		var opts QueryOptions
+		addQueryFlags(fs, &opts)
+		applyDisplay := addDisplayFlags(fs, printer)
+		fs.Parse(flag.Args()[1:])
+		applyDisplay()
+
+		if fs.NArg() != 1 {
+			logger.Error("Usage: similar [flags] <file>[:start-end], or - to read stdin")
+			os.Exit(1)
+		}
+		similarCommand(chromaOpts, collectionName, fs.Arg(0), opts, printer, logger)
+	case "symbols":
+		fs := flag.NewFlagSet("symbols", flag.ExitOnError)
+		exact := fs.Bool("exact", false, "Only match names exactly, ignoring case")
+		kind := fs.String("kind", "", "Only list symbols of this kind, such as function, method, type or constant")
+		n := fs.Int("n", 20, "Maximum number of symbols listed, 0 for all")
+		fs.Parse(flag.Args()[1:])
+
+		if fs.NArg() != 1 {
+			logger.Error("Usage: symbols [flags] <name>")
+			os.Exit(1)
+		}
+		symbolsCommand(collectionName, fs.Arg(0), *kind, *exact, *n, printer, logger)
+	case "ask":
+		fs := flag.NewFlagSet("ask", flag.ExitOnError)
+		var opts AskOptions
+		fs.StringVar(&opts.Provider, "provider", "ollama", "LLM provider (ollama or openai)")
+		fs.StringVar(&opts.LLMURL, "llm-url", "http://127.0.0.1:11434", "LLM server URL (for openai, the base URL including /v1)")
+		fs.StringVar(&opts.APIKey, "api-key", os.Getenv("OPENAI_API_KEY"), "API key for the openai provider")
+		fs.StringVar(&opts.Model, "model", "llama3.2", "LLM model used to generate the answer")
+		fs.Float64Var(&opts.Temperature, "temperature", 0.2, "Sampling temperature")
+		fs.IntVar(&opts.ContextBudget, "context-budget", 4096, "Maximum number of context tokens sent to the model")
+		fs.IntVar(&opts.TopK, "k", 8, "Number of chunks to retrieve")
+		fs.StringVar(&opts.PackStrategy, "pack-strategy", PackGreedy, "How context is packed: "+strings.Join(packStrategies, ", "))
+		fs.BoolVar(&opts.NoSummarize, "no-summarize", false, "Drop results that do not fit the context budget instead of summarizing the better ranked ones")
+		fs.BoolVar(&opts.Force, "force", false, "Ask even if the collection was built with another embedder")
+		fs.Parse(flag.Args()[1:])
+
+		if fs.NArg() < 1 {
+			logger.Error("Please provide a question")
+			os.Exit(1)
+		}
+		askDB(chromaOpts, collectionName, strings.Join(fs.Args(), " "), opts, printer, logger)
+	case "pack":
+		fs := flag.NewFlagSet("pack", flag.ExitOnError)
+		var opts PackOptions
+		fs.IntVar(&opts.Budget, "context-budget", 4096, "Maximum number of context tokens to pack")
+		fs.IntVar(&opts.TopK, "k", 8, "Number of chunks to retrieve")
+		fs.StringVar(&opts.Strategy, "pack-strategy", PackGreedy, "How context is packed: "+strings.Join(packStrategies, ", "))
+		fs.BoolVar(&opts.Force, "force", false, "Pack even if the collection was built with another embedder")
+		fs.Parse(flag.Args()[1:])
+
+		if fs.NArg() < 1 {
+			logger.Error("Please provide a query")
+			os.Exit(1)
+		}
+		packContextCommand(chromaOpts, collectionName, strings.Join(fs.Args(), " "), opts, printer, logger)
+	case "bulk":
+		bulkCommand(chromaOpts, flag.Args()[1:], printer, logger)
+	case "get":
 		if len(flag.Args()) < 2 {
-			logger.Error("Please provide a search query")
+			logger.Error("Please provide a result number or document id")
+			os.Exit(1)
+		}
+		getDocument(chromaOpts, collectionName, flag.Args()[1], printer, logger)
+	case "feedback":
+		fs := flag.NewFlagSet("feedback", flag.ExitOnError)
+		var (
+			relevant   = fs.Bool("relevant", false, "Mark the result as relevant")
+			irrelevant = fs.Bool("irrelevant", false, "Mark the result as irrelevant")
+			out        = fs.String("out", "", "Write the exported eval set to this file instead of stdout")
+		)
+		fs.Parse(flag.Args()[1:])
+
+		if fs.NArg() < 1 {
+			logger.Error("Please provide a result number or id, or export")
 			os.Exit(1)
 		}
-		query := flag.Args()[1]
-		queryDB(*chromaURL, *collection, query, logger)
+		if fs.Arg(0) == "export" {
+			exportFeedback(collectionName, *out, logger)
+			return
+		}
+		if *relevant == *irrelevant {
+			logger.Error("Please pass exactly one of -relevant or -irrelevant")
+			os.Exit(1)
+		}
+		giveFeedback(fs.Arg(0), *relevant, printer, logger)
+	case "analytics":
+		fs := flag.NewFlagSet("analytics", flag.ExitOnError)
+		top := fs.Int("top", 10, "Number of queries listed per section")
+		fs.Parse(flag.Args()[1:])
+
+		showAnalytics(collectionName, *top, printer, logger)
+	case "collections":
+		collectionsCommand(chromaOpts, flag.Args()[1:], printer, logger)
+	case "runs":
+		runsCommand(collectionName, flag.Args()[1:], printer, logger)
+	case "export":
+		fs := flag.NewFlagSet("export", flag.ExitOnError)
+		out := fs.String("out", "snapshot.jsonl.gz", "Snapshot file to write, gzipped when it ends in .gz")
+		since := fs.String("since", "", "Only export documents indexed since this RFC 3339 time or the creation of this earlier snapshot")
+		fs.Parse(flag.Args()[1:])
+
+		exportCollection(chromaOpts, collectionName, *out, *since, printer, logger)
+	case "import":
+		fs := flag.NewFlagSet("import", flag.ExitOnError)
+		into := fs.String("into", "", "Collection to import into (defaults to the collection recorded in the snapshot)")
+		unprotect := fs.Bool("unprotect", false, "Allow importing over a protected collection")
+		fs.Parse(flag.Args()[1:])
+
+		if fs.NArg() < 1 {
+			logger.Error("Please provide a snapshot file to import")
+			os.Exit(1)
+		}
+		importCollection(chromaOpts, *into, fs.Arg(0), *unprotect, printer, logger)
+	case "verify":
+		fs := flag.NewFlagSet("verify", flag.ExitOnError)
+		reproducible := fs.Bool("reproducible", false, "Chunk a sample of files again and check the chunks match the collection")
+		sample := fs.Int("sample", 50, "Number of documents to check")
+		fs.Parse(flag.Args()[1:])
+
+		if !*reproducible {
+			logger.Error("Nothing to verify, pass -reproducible")
+			os.Exit(1)
+		}
+		verifyCommand(chromaOpts, collectionName, *sample, printer, logger)
+	case "migrate":
+		fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+		opts := MigrateOptions{Progress: progress}
+		fs.StringVar(&opts.From, "from", "", "Store to copy from, as <store>[:collection] or snapshot:<path>")
+		fs.StringVar(&opts.To, "to", "", "Store to copy to, as <store>[:collection] or snapshot:<path>")
+		fs.StringVar(&opts.FromURL, "from-url", "", "URL of the -from store, -url when empty")
+		fs.StringVar(&opts.ToURL, "to-url", "", "URL of the -to store, -url when empty")
+		fs.BoolVar(&opts.Resume, "resume", false, "Continue an interrupted migration between the same stores")
+		addMilvusFlags(fs, &opts.Milvus)
+		fs.Parse(flag.Args()[1:])
+
+		migrateCommand(chromaOpts, collectionName, opts, printer, logger)
+	case "protect", "unprotect":
+		fs := flag.NewFlagSet(command, flag.ExitOnError)
+		fs.Parse(flag.Args()[1:])
+
+		name := collectionName
+		if fs.NArg() > 0 {
+			name = fs.Arg(0)
+		}
+		protectCollection(chromaOpts, name, command == "protect", printer, logger)
 	case "delete":
-		deleteCollection(*chromaURL, *collection, logger)
+		fs := flag.NewFlagSet("delete", flag.ExitOnError)
+		var opts DeleteOptions
+		fs.BoolVar(&opts.Unprotect, "unprotect", false, "Allow deleting a protected collection")
+		fs.BoolVar(&opts.Force, "force", false, "Do not ask for confirmation")
+		fs.DurationVar(&opts.OlderThan, "older-than", 0, "Only delete documents indexed longer ago than this (e.g. 720h)")
+		fs.Func("run", "Only delete the documents last written by this index run, see runs list", func(id string) error {
+			opts.Run = id
+			return checkRunID(id)
+		})
+		fs.Parse(flag.Args()[1:])
+
+		if opts.Run != "" && opts.OlderThan > 0 {
+			logger.Error("Pass either -run or -older-than, not both")
+			os.Exit(1)
+		}
+		deleteCollection(chromaOpts, collectionName, opts, printer, logger)
+	case "doctor":
+		fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+		fs.Parse(flag.Args()[1:])
+
+		doctorCommand(chromaOpts, collectionName, printer, logger)
+	case "version":
+		fs := flag.NewFlagSet("version", flag.ExitOnError)
+		asJSON := fs.Bool("json", false, "Print build information as JSON")
+		fs.Parse(flag.Args()[1:])
+
+		showVersion(*asJSON, printer)
 	default:
 		logger.Error("Unknown command", "command", command)
 		os.Exit(1)
 	}
 }
 
-func indexFile(chromaURL, collection, targetPath string, logger *slog.Logger) {
+// indexFile indexes the targets into collection, each file once even when
+// targets overlap, and prints a summary per target. With replace, they are
+// indexed into a new version of the collection, swapped in for the current
+// one at the end.
+func indexFile(chromaOpts ChromaOptions, collection string, targets []string, reportPath string, replace bool, walkOpts WalkOptions, addOpts AddOptions, printer *Printer, logger *slog.Logger) {
 	ctx := context.Background()
 
-	client, err := NewChromaClient(chromaURL, logger)
+	client, err := newStore(chromaOpts, logger)
 	if err != nil {
 		logger.Error("Failed to create ChromaDB client", "error", err)
 		os.Exit(1)
 	}
 	defer client.Close()
 
-	coll, err := client.GetOrCreateCollection(ctx, collection)
+	run := Run{StartedAt: time.Now(), Targets: targets}
+	run.ID = newRunID(run.StartedAt)
+	addOpts.RunID = run.ID
+
+	// routed files go to the collections of their routes, in the same run
+	// and walk, and the rest to collection
+	var sinks []*indexSink
+	for _, r := range walkOpts.Routes {
+		sinks = append(sinks, openSink(ctx, client, r.Collection, resolveCollection(r.Collection, projectRoot(targets[0])), replace, logger))
+	}
+	sinks = append(sinks, openSink(ctx, client, "", collection, replace, logger))
+
+	var (
+		reports []IndexReport
+		seen    = map[string]bool{}
+	)
+	for _, target := range targets {
+		for i, report := range indexTarget(ctx, sinks, target, seen, walkOpts, addOpts, logger) {
+			report.Run = run.ID
+			if addOpts.RecordEnv {
+				env := captureBuildEnv(ctx, report.Root, addOpts, logger)
+				if err := recordBuildEnv(ctx, client, sinks[i].version, env); err != nil {
+					logger.Error("Failed to record build environment", "error", err)
+					os.Exit(1)
+				}
+				report.Env = &env
+			}
+			reports = append(reports, report)
+		}
+	}
+	for _, s := range sinks {
+		s.finish(ctx, client, run, reports, logger)
+	}
+	logger.Info("Index run finished", "run", run.ID)
+
+	if reportPath != "" {
+		var out any = reports
+		if len(reports) == 1 {
+			out = reports[0]
+		}
+		if err := writeJSONFile(reportPath, out); err != nil {
+			logger.Error("Failed to write index report", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	if ciMode {
+		var out any = reports
+		if len(reports) == 1 {
+			out = reports[0]
+		}
+		if err := ciSummary(printer, out, indexMarkdown(reports)); err != nil {
+			logger.Error("Failed to write summary", "error", err)
+			os.Exit(1)
+		}
+		var added, failed int
+		for _, r := range reports {
+			added, failed = added+r.Add.Added, failed+r.Add.Failures()
+		}
+		ciExit(added, failed)
+		return
+	}
+
+	for _, r := range reports {
+		if len(reports) > 1 {
+			printer.Message("%s:", r.Target)
+		}
+		printer.IndexSummary(r)
+	}
+}
+
+// indexSink is a collection an index run writes to, the one of a route or
+// of the project.
+type indexSink struct {
+	// route is the route of the collection, "" for the project.
+	route      string
+	collection string
+	// version is the name of the collection written, a new version of
+	// collection when it is replaced.
+	version string
+	replace bool
+	coll    Collection
+	symbols *SymbolIndex
+}
+
+// openSink gets collection, or a new version of it with replace, for the
+// files of route.
+func openSink(ctx context.Context, client ChromaClient, route, collection string, replace bool, logger *slog.Logger) *indexSink {
+	s := &indexSink{route: route, collection: collection, version: collection, replace: replace}
+	var err error
+	if replace {
+		if protected, err := client.IsProtected(ctx, collection); err == nil && protected {
+			logger.Error("Refusing to replace a protected collection, unprotect it first", "collection", collection)
+			os.Exit(1)
+		}
+		if s.version, s.coll, err = client.NextVersion(ctx, collection); err != nil {
+			logger.Error("Failed to create collection", "error", err)
+			os.Exit(1)
+		}
+		logger.Info("Rebuilding collection", "collection", collection, "version", s.version)
+		s.symbols = newSymbolIndex(collection)
+	} else {
+		if s.coll, err = client.GetOrCreateCollection(ctx, collection); err != nil {
+			logger.Error("Failed to get/create collection", "error", err)
+			os.Exit(1)
+		}
+		if s.symbols, err = loadSymbols(collection); err != nil {
+			logger.Warn("Failed to load symbol index, rebuilding it", "error", err)
+			s.symbols = newSymbolIndex(collection)
+		}
+	}
+
+	return s
+}
+
+// finish swaps in the version written with replace, saves the symbols and
+// records run with the documents added to the collection per reports.
+func (s *indexSink) finish(ctx context.Context, client ChromaClient, run Run, reports []IndexReport, logger *slog.Logger) {
+	var failed int
+	for _, r := range reports {
+		if r.Collection == s.collection {
+			failed += r.Add.Failures()
+			run.Added += r.Add.Added + r.Add.Summaries
+		}
+	}
+	if s.replace {
+		if failed > 0 {
+			// the collection in use is kept rather than a partial rebuild
+			logger.Error("Failed to rebuild collection, keeping the current one", "collection", s.collection, "failed", failed)
+			if err := client.DeleteCollection(ctx, s.version); err != nil {
+				logger.Warn("Failed to delete the partial rebuild", "version", s.version, "error", err)
+			}
+			os.Exit(1)
+		}
+		if err := client.SwapAlias(ctx, s.collection, s.version); err != nil {
+			logger.Error("Failed to swap in the rebuilt collection", "version", s.version, "error", err)
+			os.Exit(1)
+		}
+		logger.Info("Swapped in rebuilt collection", "collection", s.collection, "version", s.version)
+	}
+	if err := s.symbols.Save(); err != nil {
+		logger.Warn("Failed to save symbol index", "error", err)
+	}
+	run.Duration = time.Since(run.StartedAt)
+	if err := recordRun(s.collection, run); err != nil {
+		logger.Warn("Failed to record index run", "error", err)
+	}
+}
+
+// indexTarget indexes the files under targetPath not in seen, each into the
+// sink of its route, and adds them to seen. It returns a report per sink.
+func indexTarget(ctx context.Context, sinks []*indexSink, targetPath string, seen map[string]bool, walkOpts WalkOptions, addOpts AddOptions, logger *slog.Logger) []IndexReport {
+	// a subtree of a project is merged into the project collection, with
+	// paths relative to the project root
+	root := projectRoot(targetPath)
+	var subtree string
+	if absRoot, absTarget := absPath(root), absPath(targetPath); absRoot != absTarget {
+		subtree = index.RelativePath(root, targetPath)
+	}
+
+	start := time.Now()
+	addOpts.Root = root
+	addOpts.Progress.Report(ProgressEvent{Phase: index.PhaseWalk, Current: targetPath})
+
+	var (
+		walker Walker
+		rel    = func(p string) string { return index.RelativePath(root, p) }
+		fsys   fs.FS
+		err    error
+		key    = absPath
+	)
+	switch {
+	case isURL(targetPath):
+		crawler := Crawler{Depth: walkOpts.CrawlDepth, MaxPages: walkOpts.CrawlMax, Client: http.DefaultClient, Logger: logger}
+		fsys, err = crawler.Crawl(ctx, targetPath)
+		if err != nil {
+			logger.Error("Failed to crawl site", "error", err)
+			os.Exit(1)
+		}
+
+		root, subtree, addOpts.FS, addOpts.RootID = targetPath, "", fsys, index.URLRootPrefix+crawlRoot(targetPath)
+		key = func(p string) string { return targetPath + ":" + p }
+		rel = func(p string) string { return p }
+		walker, err = newFSWalker(fsys, walkOpts)
+	case isArchive(targetPath):
+		var closeArchive func() error
+		fsys, closeArchive, err = openArchive(targetPath)
+		if err != nil {
+			logger.Error("Failed to open archive", "error", err)
+			os.Exit(1)
+		}
+		defer closeArchive()
+
+		root, subtree, addOpts.FS, addOpts.RootID = targetPath, "", fsys, "archive:"+archiveName(targetPath)
+		key = func(p string) string { return targetPath + ":" + p }
+		rel = func(p string) string { return p }
+		walker, err = newFSWalker(fsys, walkOpts)
+	default:
+		walker, err = newWalker(targetPath, walkOpts)
+		// the estimate is of the whole tree, so it is only given when every
+		// file goes to the same collection
+		if addOpts.Progress.Wanted() && len(sinks) == 1 {
+			// files are read as they are walked, so the total is estimated
+			// by a walk ahead
+			if est, err := estimateTree(targetPath, TreeLimits{}); err == nil {
+				addOpts.EstimatedFiles = est.Files
+			}
+		}
+	}
 	if err != nil {
-		logger.Error("Failed to get/create collection", "error", err)
+		logger.Error("Failed to list files", "error", err)
 		os.Exit(1)
 	}
 
-	files := slices.Collect(dirextractor.New(
-		targetPath,
-		dirextractor.WithExtensions(dirextractor.DefaultExtractionExtensions),
-		dirextractor.WithIgnoreHidden(),
-		dirextractor.WithIgnoreRegs(".*node_modules.*"),
-	).Files())
+	// files are added as they are walked, each sink indexing its own from
+	// a feed, so indexing starts right away, the tree is walked once and
+	// never held in memory
+	reports := make([]IndexReport, len(sinks))
+	feeds := make([]chan string, len(sinks))
+	sinkOf := map[string]int{}
+	var wg sync.WaitGroup
+	for i, s := range sinks {
+		logger.Info("Indexing into collection", "collection", s.collection, "root", root, "subtree", subtree)
+		reports[i] = IndexReport{Collection: s.collection, Target: targetPath, Root: root, Subtree: subtree}
+		feeds[i] = make(chan string)
+		sinkOf[s.route] = i
+
+		opts := addOpts
+		opts.Symbols = s.symbols
+		wg.Go(func() {
+			paths := func(yield func(string) bool) {
+				for p := range feeds[i] {
+					if !yield(p) {
+						return
+					}
+				}
+			}
+			added, err := AddDocuments(ctx, s.coll, paths, opts, logger)
+			reports[i].Add = added
+			// the files left are dropped so the walk goes on for the
+			// other sinks
+			for range feeds[i] {
+			}
+			switch {
+			case err != nil && ciMode && added.Added > 0:
+				// reported as a partial failure once every target is done
+				logger.Error("Failed to add some documents to collection", "collection", s.collection, "error", err, "failed", added.Failed)
+			case err != nil:
+				logger.Error("Failed to add documents to collection", "collection", s.collection, "error", err)
+				os.Exit(1)
+			}
+		})
+	}
 
-	err = coll.AddDocuments(ctx, files)
+	var files int
+	for f := range walkFiles(walker, walkOpts, rel, logger) {
+		i := sinkOf[routeOf(walkOpts.Routes, rel(f.Path))]
+		abs := key(f.Path)
+		if seen[abs] {
+			reports[i].Duplicates++
+			continue
+		}
+		seen[abs] = true
+		files++
+		feeds[i] <- f.Path
+	}
+	for _, feed := range feeds {
+		close(feed)
+	}
+	wg.Wait()
+
+	addOpts.Progress.Report(ProgressEvent{Phase: index.PhaseDone, Done: files, Total: files})
+
+	// the walk is shared, so its stats go with the project collection
+	for i := range reports {
+		reports[i].Duration = time.Since(start)
+	}
+	reports[len(reports)-1].Walk = walker.Stats()
+
+	return reports
+}
+
+// WalkOptions controls which files under an indexed path are collected.
+type WalkOptions struct {
+	// FollowSymlinks walks symbolic links. Links resolving outside the
+	// indexed path are never followed.
+	FollowSymlinks bool
+	// IncludeGenerated walks vendored directories, which are pruned
+	// otherwise.
+	IncludeGenerated bool
+	// Scope keeps the files whose path relative to the project root matches
+	// one of these globs, when set.
+	Scope []string
+	// CrawlDepth is how many links away from a URL target pages are
+	// fetched, and CrawlMax the most pages fetched per URL.
+	CrawlDepth int
+	CrawlMax   int
+	// Records also walks the datasets split by AddOptions.Records.
+	Records bool
+	// Routes are the routes of the project, which the files walked are
+	// indexed along.
+	Routes []Route
+}
+
+// Walker walks the files of a tree, counting what it skips.
+type Walker interface {
+	Files() iter.Seq2[dirextractor.FileInfo, error]
+	Stats() dirextractor.Stats
+}
+
+// newWalker returns the walker of the files under targetPath that should be
+// indexed.
+func newWalker(targetPath string, opts WalkOptions) (Walker, error) {
+	return dirextractor.New(targetPath, append(opts.filters(),
+		dirextractor.WithFollowSymlinks(opts.FollowSymlinks),
+		dirextractor.WithConfineToRoot(),
+	)...)
+}
+
+// collectFiles lists the files under targetPath that should be indexed, along
+// with the walk statistics. Paths that cannot be walked are logged and skipped.
+func collectFiles(targetPath string, opts WalkOptions, logger *slog.Logger) ([]dirextractor.FileInfo, dirextractor.Stats, error) {
+	w, err := newWalker(targetPath, opts)
 	if err != nil {
-		logger.Error("Failed to add documents to collection", "error", err)
-		os.Exit(1)
+		return nil, dirextractor.Stats{}, err
 	}
 
-	fmt.Printf("Successfully indexed %d files\n", len(files))
+	root := projectRoot(targetPath)
+	files := slices.Collect(walkFiles(w, opts, func(p string) string { return index.RelativePath(root, p) }, logger))
+
+	return files, w.Stats(), nil
 }
 
-func queryDB(chromaURL, collection, query string, logger *slog.Logger) {
+// filters are the walk options selecting files, shared by disk and archive
+// walks.
+func (opts WalkOptions) filters() []dirextractor.Option {
+	skipDirs := []string{"node_modules"}
+	if !opts.IncludeGenerated {
+		skipDirs = index.VendoredDirs
+	}
+
+	exts := slices.Concat(dirextractor.DefaultExtractionExtensions, index.ExtractorExtensions(), chunk.Extensions())
+	if opts.Records {
+		exts = append(exts, index.RecordExtensions...)
+	}
+
+	return []dirextractor.Option{
+		dirextractor.WithExtensions(exts),
+		dirextractor.WithFilenames(index.Filenames()...),
+		dirextractor.WithIgnoreHidden(),
+		dirextractor.WithSkipDirs(skipDirs...),
+	}
+}
+
+// walkFiles yields the files of w in the scope of opts as they are walked,
+// rel giving the path globs are matched against.
+func walkFiles(w Walker, opts WalkOptions, rel func(string) string, logger *slog.Logger) iter.Seq[dirextractor.FileInfo] {
+	return func(yield func(dirextractor.FileInfo) bool) {
+		done := profiler.Time(StageWalk)
+		for f, err := range w.Files() {
+			if err != nil {
+				logger.Warn("Skipping unreadable path", "path", f.Path, "error", err)
+				continue
+			}
+			if !query.InScope(opts.Scope, rel(f.Path)) {
+				continue
+			}
+
+			// time only the walk, not the files being indexed
+			done()
+			if !yield(f) {
+				return
+			}
+			done = profiler.Time(StageWalk)
+		}
+		done()
+	}
+}
+
+func filePaths(files []dirextractor.FileInfo) []string {
+	paths := make([]string, len(files))
+	for i, f := range files {
+		paths[i] = f.Path
+	}
+
+	return paths
+}
+
+func queryDB(chromaOpts ChromaOptions, collection, query string, opts QueryOptions, daemonOpts DaemonOptions, printer *Printer, logger *slog.Logger) {
 	ctx := context.Background()
 
-	client, err := NewChromaClient(chromaURL, logger)
+	// pruning deletes from the collection, which the daemon keeps to itself
+	if !daemonOpts.Disabled && !opts.AutoPruneMissing {
+		if daemonOpts.Auto {
+			if err := ensureDaemon(ctx, chromaOpts, daemonOpts.IdleTimeout, logger); err != nil {
+				logger.Warn("Failed to start daemon, querying directly", "error", err)
+			}
+		}
+		outcome, ok, err := queryDaemon(ctx, chromaOpts, collection, query, opts, logger)
+		if ok {
+			if err == nil {
+				err = printOutcome(ctx, nil, collection, query, opts, outcome, printer, logger)
+			}
+			if errors.Is(err, errNoMatch) {
+				os.Exit(exitNoMatch)
+			}
+			if err != nil {
+				logger.Error("Failed to query collection", "error", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	client, err := newStore(chromaOpts, logger)
 	if err != nil {
 		logger.Error("Failed to create ChromaDB client", "error", err)
 		os.Exit(1)
 	}
 	defer client.Close()
 
-	var coll Collection
-	coll, err = client.GetCollection(ctx, collection)
+	coll, err := client.GetCollection(ctx, collection)
 	if err != nil {
 		logger.Error("Failed to get collection", "error", err)
 		os.Exit(1)
 	}
 
-	var results []QueryResult
-	results, err = coll.Query(ctx, query, 5)
+	runQuery(ctx, coll, collection, query, opts, printer, logger)
+}
+
+// runQuery searches coll, falling back to alternative strategies when nothing
+// matches, records the query for feedback and analytics and prints results.
+func runQuery(ctx context.Context, coll Collection, collection, query string, opts QueryOptions, printer *Printer, logger *slog.Logger) {
+	verifyEmbedder(coll, opts.Force, logger)
+
+	err := searchAndPrint(ctx, coll, collection, query, opts, printer, logger)
+	if errors.Is(err, errNoMatch) {
+		os.Exit(exitNoMatch)
+	}
 	if err != nil {
 		logger.Error("Failed to query collection", "error", err)
 		os.Exit(1)
 	}
+}
+
+// exitNoMatch is the exit status of queries with -min-score when no result
+// clears it, so scripts can tell it from errors.
+const exitNoMatch = 3
+
+var errNoMatch = errors.New("no result above the minimum score")
+
+// searchAndPrint is runQuery without the embedder check, returning query
+// errors instead of exiting so the REPL can carry on. Fallback results are
+// held against -min-score too, and errNoMatch is returned when none is left.
+func searchAndPrint(ctx context.Context, coll Collection, collection, query string, opts QueryOptions, printer *Printer, logger *slog.Logger) error {
+	outcome, err := searchOutcomeOf(ctx, coll, collection, query, opts, logger)
+	if err != nil {
+		return err
+	}
+
+	return printOutcome(ctx, coll, collection, query, opts, outcome, printer, logger)
+}
+
+// searchOutcome holds the results of a query with what the user is told
+// about how they were found.
+type searchOutcome struct {
+	Results []QueryResult
+	Warning string `json:",omitempty"`
+	Message string `json:",omitempty"`
+}
+
+// searchOutcomeOf searches coll, falling back to keyword search when the
+// embedder is down and to alternative strategies when nothing matches.
+func searchOutcomeOf(ctx context.Context, coll Collection, collection, query string, opts QueryOptions, logger *slog.Logger) (searchOutcome, error) {
+	var outcome searchOutcome
+	results, err := Search(ctx, coll, collection, query, opts, logger)
+	fallback := !opts.NoFallback
+	// keyword matches have no similarity to hold against -min-score
+	degraded := errors.Is(err, store.ErrEmbed) && fallback && opts.MinScore == 0
+	if degraded {
+		logger.Warn("Embedder unavailable, falling back to keyword search", "error", err)
+		results, err = coll.KeywordSearch(ctx, queryTerms(query), opts.N)
+		results = opts.exclude(results)
+		if err == nil {
+			outcome.Warning = "The embedder is unreachable, showing keyword matches only"
+		}
+	}
+	if err != nil {
+		return outcome, err
+	}
+
+	if len(results) == 0 && fallback && !degraded {
+		var fallback string
+		results, fallback, err = SearchFallback(ctx, coll, collection, query, opts, logger)
+		if err != nil {
+			logger.Warn("Fallback search failed", "error", err)
+		}
+		if len(results) > 0 {
+			outcome.Message = fmt.Sprintf("No results for %q, showing results from fallback: %s", query, fallback)
+		}
+	}
+	outcome.Results = results
+
+	return outcome, nil
+}
+
+// printOutcome locates the matches of the results of outcome, records the
+// query for feedback and analytics and prints them. coll is only used to
+// prune missing files, and may be nil when opts.AutoPruneMissing is not set.
+func printOutcome(ctx context.Context, coll Collection, collection, query string, opts QueryOptions, outcome searchOutcome, printer *Printer, logger *slog.Logger) error {
+	if outcome.Warning != "" {
+		printer.Warning("%s", outcome.Warning)
+	}
+	if outcome.Message != "" {
+		printer.Message("%s", outcome.Message)
+	}
+
+	results := outcome.Results
+	terms := expandTerms(queryTerms(query))
+	locateMatches(results, terms)
+	printer.SetHighlight(terms)
+	if opts.Callers > 0 {
+		addCallers(collection, results, opts.Callers, logger)
+	}
+
+	if missing := markMissing(results); len(missing) > 0 {
+		results = pruneMissing(ctx, coll, results, missing, opts.AutoPruneMissing, printer, logger)
+	}
+
+	if err := saveLastQuery(collection, query, results); err != nil {
+		logger.Warn("Failed to save query for feedback", "error", err)
+	}
+	if err := recordQueryEvent(collection, query, len(results)); err != nil {
+		logger.Warn("Failed to record query analytics", "error", err)
+	}
+
+	printer.Results(results)
+	if len(results) == 0 && opts.MinScore > 0 {
+		return errNoMatch
+	}
+
+	return nil
+}
+
+// pruneMissing deletes the missing results from coll and drops them when
+// prune is set, and otherwise warns about them.
+func pruneMissing(ctx context.Context, coll Collection, results, missing []QueryResult, prune bool, printer *Printer, logger *slog.Logger) []QueryResult {
+	if !prune {
+		printer.Warning("%d results point to files that no longer exist, pass -auto-prune-missing to remove them", len(missing))
+		return results
+	}
+
+	ids := make([]string, len(missing))
+	for i, r := range missing {
+		ids[i] = r.ID
+	}
+	if err := coll.Delete(ctx, ids...); err != nil {
+		logger.Warn("Failed to prune missing documents", "error", err)
+		return results
+	}
+
+	printer.Message("Pruned %d documents whose file no longer exists", len(ids))
+	return slices.DeleteFunc(results, func(r QueryResult) bool { return r.Missing })
+}
+
+func getDocument(chromaOpts ChromaOptions, collection, ref string, printer *Printer, logger *slog.Logger) {
+	ctx := context.Background()
+
+	id, query := ref, ""
+	if last, r, err := resolveResult(ref); err == nil {
+		id, query, collection = r.ID, last.Query, last.Collection
+	}
+
+	client, err := newStore(chromaOpts, logger)
+	if err != nil {
+		logger.Error("Failed to create ChromaDB client", "error", err)
+		os.Exit(1)
+	}
 	defer client.Close()
 
-	if len(results) == 0 {
-		fmt.Println("No results found")
-		return
+	coll, err := client.GetCollection(ctx, collection)
+	if err != nil {
+		logger.Error("Failed to get collection", "error", err)
+		os.Exit(1)
 	}
 
-	fmt.Printf("Found %d results:\n\n", len(results))
-	for i := len(results) - 1; i >= 0; i-- {
-		result := results[i]
-		fmt.Printf("File: %s\n", result.FileName)
-		fmt.Printf("Path: %s\n", result.Path)
-		fmt.Printf("Content:\n%s\n", result.Content)
-		fmt.Println(strings.Repeat("-", 50))
+	docs, err := coll.Get(ctx, id)
+	if err != nil {
+		logger.Error("Failed to get document", "error", err)
+		os.Exit(1)
+	}
+	if len(docs) == 0 {
+		logger.Error("Document not found", "id", id, "collection", collection)
+		os.Exit(1)
 	}
+
+	if query != "" {
+		if err := recordOpenEvent(collection, query, id); err != nil {
+			logger.Warn("Failed to record query analytics", "error", err)
+		}
+	}
+
+	printer.Document(docs[0])
+}
+
+func giveFeedback(ref string, relevant bool, printer *Printer, logger *slog.Logger) {
+	j, err := recordFeedback(ref, relevant)
+	if err != nil {
+		logger.Error("Failed to record feedback", "error", err)
+		os.Exit(1)
+	}
+
+	verdict := "irrelevant"
+	if j.Relevant {
+		verdict = "relevant"
+		if err := recordOpenEvent(j.Collection, j.Query, j.ID); err != nil {
+			logger.Warn("Failed to record query analytics", "error", err)
+		}
+	}
+	printer.Message("Marked %s as %s for %q", j.Path, verdict, j.Query)
 }
 
-func deleteCollection(chromaURL, collection string, logger *slog.Logger) {
+func showAnalytics(collection string, top int, printer *Printer, logger *slog.Logger) {
+	events, err := readStateLines[AnalyticsEvent](analyticsState)
+	if err != nil {
+		logger.Error("Failed to load analytics", "error", err)
+		os.Exit(1)
+	}
+
+	printer.Analytics(BuildAnalyticsReport(events, collection, top))
+}
+
+func exportFeedback(collection, out string, logger *slog.Logger) {
+	judgments, err := loadJudgments(collection)
+	if err != nil {
+		logger.Error("Failed to load feedback", "error", err)
+		os.Exit(1)
+	}
+
+	w := os.Stdout
+	if out != "" {
+		w, err = os.Create(out)
+		if err != nil {
+			logger.Error("Failed to create output file", "error", err)
+			os.Exit(1)
+		}
+		defer w.Close()
+	}
+
+	if err := ExportEvalSet(w, judgments); err != nil {
+		logger.Error("Failed to export feedback", "error", err)
+		os.Exit(1)
+	}
+}
+
+type DeleteOptions struct {
+	Force     bool
+	Unprotect bool
+	// OlderThan, when set, only deletes documents indexed longer ago than
+	// this instead of the whole collection, and Run those last written by
+	// this index run.
+	OlderThan time.Duration
+	Run       string
+}
+
+func deleteCollection(chromaOpts ChromaOptions, collection string, opts DeleteOptions, printer *Printer, logger *slog.Logger) {
 	ctx := context.Background()
 
-	client, err := NewChromaClient(chromaURL, logger)
+	client, err := newStore(chromaOpts, logger)
 	if err != nil {
 		logger.Error("Failed to create ChromaDB client", "error", err)
 		os.Exit(1)
 	}
 	defer client.Close()
 
+	if err := checkUnprotected(ctx, client, collection, opts.Unprotect); err != nil {
+		logger.Error("Refusing to delete collection", "error", err)
+		os.Exit(1)
+	}
+
+	coll, err := client.GetCollection(ctx, collection)
+	if err != nil {
+		logger.Error("Failed to get collection", "error", err)
+		os.Exit(1)
+	}
+
+	count, err := coll.Count(ctx)
+	if err != nil {
+		logger.Error("Failed to count documents", "error", err)
+		os.Exit(1)
+	}
+
+	if !opts.Force {
+		question := fmt.Sprintf("Delete collection '%s' and its %d documents?", collection, count)
+		switch {
+		case opts.OlderThan > 0:
+			question = fmt.Sprintf("Delete documents indexed more than %s ago from '%s', of its %d documents? Documents indexed without a time are kept.", opts.OlderThan, collection, count)
+		case opts.Run != "":
+			question = fmt.Sprintf("Delete the documents of run %s from '%s', of its %d documents?", opts.Run, collection, count)
+		}
+
+		ok, err := confirm("%s", question)
+		if err != nil {
+			logger.Error("Cannot confirm deletion", "error", err)
+			os.Exit(1)
+		}
+		if !ok {
+			printer.Message("Aborted")
+			return
+		}
+	}
+
+	if opts.Run != "" {
+		n, err := coll.DeleteRun(ctx, opts.Run)
+		if err != nil {
+			logger.Error("Failed to delete documents", "error", err)
+			os.Exit(1)
+		}
+		if err := forgetRun(collection, opts.Run); err != nil {
+			logger.Warn("Failed to update recorded runs", "error", err)
+		}
+
+		printer.Message("Deleted %d documents of run %s from '%s'", n, opts.Run, collection)
+		return
+	}
+	if opts.OlderThan > 0 {
+		n, err := coll.DeleteIndexedBefore(ctx, time.Now().Add(-opts.OlderThan))
+		if err != nil {
+			logger.Error("Failed to delete documents", "error", err)
+			os.Exit(1)
+		}
+
+		printer.Message("Deleted %d documents from '%s'", n, collection)
+		return
+	}
+
 	err = client.DeleteCollection(ctx, collection)
 	if err != nil {
 		logger.Error("Failed to delete collection", "error", err)
 		os.Exit(1)
 	}
+	if err := deleteSymbols(collection); err != nil {
+		logger.Warn("Failed to delete symbol index", "error", err)
+	}
+
+	printer.Message("Collection '%s' deleted successfully", collection)
+}
+
+func protectCollection(chromaOpts ChromaOptions, collection string, protected bool, printer *Printer, logger *slog.Logger) {
+	ctx := context.Background()
+
+	client, err := newStore(chromaOpts, logger)
+	if err != nil {
+		logger.Error("Failed to create ChromaDB client", "error", err)
+		os.Exit(1)
+	}
+	defer client.Close()
+
+	if err := client.SetProtected(ctx, collection, protected); err != nil {
+		logger.Error("Failed to update collection protection", "error", err)
+		os.Exit(1)
+	}
+
+	if protected {
+		printer.Message("Collection '%s' is now protected", collection)
+	} else {
+		printer.Message("Collection '%s' is no longer protected", collection)
+	}
+}
+
+// checkUnprotected returns an error when collection is protected and the
+// caller did not explicitly allow touching it.
+func checkUnprotected(ctx context.Context, client ChromaClient, collection string, unprotect bool) error {
+	if unprotect {
+		return nil
+	}
+
+	protected, err := client.IsProtected(ctx, collection)
+	if err != nil {
+		return err
+	}
+	if protected {
+		return fmt.Errorf("collection %q is protected, pass -unprotect to override", collection)
+	}
 
-	fmt.Printf("Collection '%s' deleted successfully\n", collection)
+	return nil
 }
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/karitham/cls/index"
+	"github.com/karitham/cls/store"
+)
+
+// memoryCollection receives the documents of a one-shot index in place of a
+// ChromaDB collection, embedding them itself.
+type memoryCollection struct {
+	ef store.Embedder
+
+	mu      sync.Mutex
+	records map[string]*Record
+}
+
+func newMemoryCollection(ef store.Embedder) *memoryCollection {
+	return &memoryCollection{ef: ef, records: map[string]*Record{}}
+}
+
+func (m *memoryCollection) Upsert(ctx context.Context, records []Record) error {
+	if err := (store.Options{}).EmbedMissing(ctx, m.ef, records); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, r := range records {
+		m.records[r.ID] = &r
+	}
+
+	return nil
+}
+
+// Update merges metadata into the records added, as dedupe records the paths
+// of duplicates.
+func (m *memoryCollection) Update(ctx context.Context, records []Record) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, u := range records {
+		if r, ok := m.records[u.ID]; ok {
+			r.Metadata = store.MergeMetadata(r.Metadata, u.Metadata)
+		}
+	}
+
+	return nil
+}
+
+// DeletePaths deletes the records of paths under rootID, as indexing does
+// before adding their chunks.
+func (m *memoryCollection) DeletePaths(ctx context.Context, rootID string, paths []string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	deleted := 0
+	for id, r := range m.records {
+		path, _ := r.Metadata["path"].(string)
+		if r.Metadata[index.RootKey] == rootID && slices.Contains(paths, path) {
+			delete(m.records, id)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+// bundle returns the records added as a bundle to search.
+func (m *memoryCollection) bundle(name string) *bundleCollection {
+	b := &bundleCollection{header: SnapshotHeader{Collection: name}, ef: m.ef}
+	for _, r := range m.records {
+		b.records = append(b.records, *r)
+	}
+	slices.SortFunc(b.records, func(a, b Record) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+
+	return b
+}
+
+// grepAI indexes path in memory, prints the results of query and forgets
+// them: neither ChromaDB nor any state is used, only the embedder.
+func grepAI(path, query string, walkOpts WalkOptions, addOpts AddOptions, opts QueryOptions, printer *Printer, logger *slog.Logger) {
+	ctx := context.Background()
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		logger.Error("Cannot index path", "path", path, "error", err)
+		os.Exit(1)
+	}
+	dir, paths := path, slices.Values([]string{filepath.Base(path)})
+	if fi.IsDir() {
+		walker, err := newFSWalker(os.DirFS(path), walkOpts)
+		if err != nil {
+			logger.Error("Failed to list files", "error", err)
+			os.Exit(1)
+		}
+		paths = func(yield func(string) bool) {
+			for f := range walkFiles(walker, walkOpts, func(p string) string { return p }, logger) {
+				if !yield(f.Path) {
+					return
+				}
+			}
+		}
+	} else {
+		dir = filepath.Dir(path)
+	}
+
+	ef, err := newEmbedder()
+	if err != nil {
+		logger.Error("Failed to create embedder", "error", err)
+		os.Exit(1)
+	}
+	mem := newMemoryCollection(ef)
+
+	// the files are read through a file system so paths stay relative and
+	// the root is not remembered
+	addOpts.FS = os.DirFS(dir)
+	added, err := index.BatchAddDocuments(ctx, mem, paths, addOpts.Options, logger)
+	if err != nil {
+		logger.Error("Failed to index path", "error", err)
+		os.Exit(1)
+	}
+	logger.Info("Indexed in memory", "path", path, "documents", added.Added)
+
+	coll := mem.bundle(path)
+	results, err := Search(ctx, coll, "", query, opts, logger)
+	if err != nil {
+		logger.Error("Failed to query", "error", err)
+		os.Exit(1)
+	}
+	for i := range results {
+		results[i].Path = filepath.Join(dir, results[i].RelPath)
+	}
+
+	terms := expandTerms(queryTerms(query))
+	locateMatches(results, terms)
+	printer.SetHighlight(terms)
+	printer.Results(results)
+	if len(results) == 0 && opts.MinScore > 0 {
+		os.Exit(exitNoMatch)
+	}
+}
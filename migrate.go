@@ -0,0 +1,338 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"iter"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/karitham/cls/index"
+	"github.com/karitham/cls/store"
+	"github.com/karitham/cls/store/milvus"
+)
+
+const migrationsState = "migrations.json"
+
+// recordStore is a backend records can be migrated from and to.
+type recordStore interface {
+	Records(ctx context.Context) iter.Seq2[Record, error]
+	AddRecords(ctx context.Context, records []Record) error
+	Count(ctx context.Context) (int, error)
+	Close() error
+}
+
+// storeBackends lists the stores records can be migrated between, the
+// registered stores and snapshot files.
+func storeBackends() []string {
+	return append(store.Names(), "snapshot")
+}
+
+// openStore opens the store of spec, "<backend>[:<name>]". The name of a
+// registered store is its collection, defaulting to collection, and the name
+// of a snapshot store is its file. Registered stores other than milvus are
+// spoken to at url, -url when empty. Sinks are created when missing.
+func openStore(ctx context.Context, spec, url string, sink bool, chromaOpts ChromaOptions, opts MigrateOptions, collection string, logger *slog.Logger) (recordStore, error) {
+	backend, name, _ := strings.Cut(spec, ":")
+	switch {
+	case backend == "snapshot":
+		if name == "" {
+			return nil, fmt.Errorf("snapshot store needs a file, as in snapshot:<path>")
+		}
+		if sink {
+			return createSnapshotStore(name, collection)
+		}
+		return &snapshotStore{path: name}, nil
+	case backend == "milvus":
+		return milvus.Open(ctx, opts.Milvus, cmp.Or(name, collection), sink)
+	case slices.Contains(store.Names(), backend):
+		if url == "" && backend != cmp.Or(chromaOpts.Store, store.Default) {
+			return nil, fmt.Errorf("the %s store needs its URL, -url being the one of the %s store", backend, cmp.Or(chromaOpts.Store, store.Default))
+		}
+		storeOpts := chromaOpts
+		storeOpts.Store, storeOpts.URL = backend, cmp.Or(url, chromaOpts.URL)
+
+		client, err := newStore(storeOpts, logger)
+		if err != nil {
+			return nil, err
+		}
+
+		get := client.GetCollection
+		if sink {
+			get = client.GetOrCreateCollection
+		}
+		coll, err := get(ctx, cmp.Or(name, collection))
+		if err != nil {
+			client.Close()
+			return nil, err
+		}
+
+		return &clientStore{Collection: coll, client: client}, nil
+	default:
+		return nil, fmt.Errorf("unknown store backend %q, expected one of %s", backend, strings.Join(storeBackends(), ", "))
+	}
+}
+
+// clientStore is a collection of a registered store, closing its client
+// with it.
+type clientStore struct {
+	Collection
+	client ChromaClient
+}
+
+func (s *clientStore) Close() error {
+	return s.client.Close()
+}
+
+// snapshotStore reads records from a snapshot file, or writes them to one
+// when w is set.
+type snapshotStore struct {
+	path string
+
+	f       *os.File
+	w       *SnapshotWriter
+	written int
+}
+
+func createSnapshotStore(path, collection string) (*snapshotStore, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create snapshot file: %w", err)
+	}
+
+	w, err := NewSnapshotWriter(f, strings.HasSuffix(path, ".gz"), SnapshotHeader{
+		Collection: collection,
+		Created:    time.Now().UTC(),
+	})
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &snapshotStore{path: path, f: f, w: w}, nil
+}
+
+func (s *snapshotStore) Records(ctx context.Context) iter.Seq2[Record, error] {
+	return func(yield func(Record, error) bool) {
+		f, err := os.Open(s.path)
+		if err != nil {
+			yield(Record{}, fmt.Errorf("failed to open snapshot: %w", err))
+			return
+		}
+		defer f.Close()
+
+		snap, err := NewSnapshotReader(f)
+		if err != nil {
+			yield(Record{}, err)
+			return
+		}
+		defer snap.Close()
+
+		for {
+			r, err := snap.Next()
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			if !yield(r, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (s *snapshotStore) AddRecords(ctx context.Context, records []Record) error {
+	if s.w == nil {
+		return fmt.Errorf("snapshot %s was opened for reading", s.path)
+	}
+
+	for _, r := range records {
+		if err := s.w.Write(r); err != nil {
+			return err
+		}
+		s.written++
+	}
+
+	return nil
+}
+
+func (s *snapshotStore) Count(ctx context.Context) (int, error) {
+	if s.w != nil {
+		return s.written, nil
+	}
+
+	n := 0
+	for _, err := range s.Records(ctx) {
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+
+	return n, nil
+}
+
+func (s *snapshotStore) Close() error {
+	if s.w == nil {
+		return nil
+	}
+
+	if err := s.w.Close(); err != nil {
+		s.f.Close()
+		return err
+	}
+
+	return s.f.Close()
+}
+
+// MigrateOptions configures a migration between stores.
+type MigrateOptions struct {
+	From, To string
+	// Resume skips the records copied by an interrupted migration between the
+	// same stores.
+	Resume   bool
+	Progress *Progress
+	// FromURL and ToURL are the URLs of the stores, -url when empty.
+	FromURL, ToURL string
+	// Milvus connects to the milvus stores.
+	Milvus milvus.Options
+}
+
+func addMilvusFlags(fs *flag.FlagSet, opts *milvus.Options) {
+	fs.StringVar(&opts.URL, "milvus-url", cmp.Or(os.Getenv("MILVUS_URL"), "http://localhost:19530"), "Milvus server URL of milvus stores")
+	fs.StringVar(&opts.Token, "milvus-token", os.Getenv("MILVUS_TOKEN"), "Milvus token, an API key or user:password")
+	fs.StringVar(&opts.Database, "milvus-database", os.Getenv("MILVUS_DATABASE"), "Milvus database, the default one when empty")
+	fs.IntVar(&opts.M, "hnsw-m", milvus.DefaultM, "Maximum edges per node of the HNSW index of created Milvus collections")
+	fs.IntVar(&opts.EfConstruction, "hnsw-ef-construction", milvus.DefaultEfConstruction, "Candidate list size while building the HNSW index of created Milvus collections")
+}
+
+// Migrate copies every record of from into to, in batches of batchSize,
+// calling checkpoint with the number of records copied after each batch. The
+// first skip records of from are assumed to be copied already.
+func Migrate(ctx context.Context, from, to recordStore, batchSize, skip int, checkpoint func(int) error, progress *Progress) (int, error) {
+	total, err := from.Count(ctx)
+	if err != nil {
+		return skip, fmt.Errorf("failed to count source records: %w", err)
+	}
+
+	var batch []Record
+	n := skip
+	flush := func() error {
+		if err := to.AddRecords(ctx, batch); err != nil {
+			return err
+		}
+		n += len(batch)
+		batch = batch[:0]
+
+		progress.Report(ProgressEvent{Phase: index.PhaseMigrate, Done: n, Total: total})
+		return checkpoint(n)
+	}
+
+	seen := 0
+	for r, err := range from.Records(ctx) {
+		if err != nil {
+			return n, err
+		}
+		if seen++; seen <= skip {
+			continue
+		}
+
+		batch = append(batch, r)
+		if len(batch) >= batchSize {
+			if err := flush(); err != nil {
+				return n, err
+			}
+		}
+	}
+
+	if err := flush(); err != nil {
+		return n, err
+	}
+	progress.Report(ProgressEvent{Phase: index.PhaseDone, Done: n, Total: total})
+
+	return n, nil
+}
+
+func migrateCommand(chromaOpts ChromaOptions, collection string, opts MigrateOptions, printer *Printer, logger *slog.Logger) {
+	ctx := context.Background()
+
+	if opts.From == "" || opts.To == "" {
+		logger.Error("Please provide both -from and -to stores", "backends", strings.Join(storeBackends(), ", "))
+		os.Exit(1)
+	}
+	if opts.Resume && strings.HasPrefix(opts.To, "snapshot") {
+		logger.Error("Cannot resume a migration into a snapshot, which is rewritten from the start")
+		os.Exit(1)
+	}
+
+	from, err := openStore(ctx, opts.From, opts.FromURL, false, chromaOpts, opts, collection, logger)
+	if err != nil {
+		logger.Error("Failed to open source store", "error", err)
+		os.Exit(1)
+	}
+	defer from.Close()
+
+	to, err := openStore(ctx, opts.To, opts.ToURL, true, chromaOpts, opts, collection, logger)
+	if err != nil {
+		logger.Error("Failed to open destination store", "error", err)
+		os.Exit(1)
+	}
+
+	key := opts.From + " -> " + opts.To
+	checkpoints := map[string]int{}
+	if err := readState(migrationsState, &checkpoints); err != nil {
+		logger.Error("Failed to read migration checkpoints", "error", err)
+		os.Exit(1)
+	}
+
+	skip := 0
+	if opts.Resume {
+		skip = checkpoints[key]
+		if skip > 0 {
+			printer.Message("Resuming after %d records", skip)
+		}
+	}
+
+	checkpoint := func(n int) error {
+		checkpoints[key] = n
+		return writeState(migrationsState, checkpoints)
+	}
+
+	n, err := Migrate(ctx, from, to, copyPageSize, skip, checkpoint, opts.Progress)
+	if err != nil {
+		logger.Error("Failed to migrate records, rerun with -resume to continue", "error", err, "copied", n)
+		os.Exit(1)
+	}
+
+	want, err := from.Count(ctx)
+	if err != nil {
+		logger.Error("Failed to count source records", "error", err)
+		os.Exit(1)
+	}
+	got, err := to.Count(ctx)
+	if err != nil {
+		logger.Error("Failed to count destination records", "error", err)
+		os.Exit(1)
+	}
+	if got < want {
+		logger.Error("Migration verification failed, destination has fewer records than the source", "source", want, "destination", got)
+		os.Exit(1)
+	}
+	if err := to.Close(); err != nil {
+		logger.Error("Failed to close destination store", "error", err)
+		os.Exit(1)
+	}
+
+	delete(checkpoints, key)
+	if err := writeState(migrationsState, checkpoints); err != nil {
+		logger.Warn("Failed to clear migration checkpoint", "error", err)
+	}
+
+	printer.Message("Migrated %d records from %s to %s", n, opts.From, opts.To)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const autoCollection = "auto"
+
+var invalidCollectionChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// resolveCollection returns name unless it is "auto", in which case a stable
+// name is derived from the git remote of root, or from its absolute path when
+// root is not inside a git repository with a remote. Either is then replaced
+// by the collection it is an alias of, if any.
+func resolveCollection(name, root string) string {
+	if name != autoCollection {
+		return aliasedCollection(name)
+	}
+
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		abs = root
+	}
+
+	if remote := gitRemoteURL(abs); remote != "" {
+		return aliasedCollection(sanitizeCollectionName(normalizeRemote(remote)))
+	}
+
+	sum := sha256.Sum256([]byte(abs))
+	return aliasedCollection(sanitizeCollectionName(filepath.Base(abs) + "-" + hex.EncodeToString(sum[:])[:12]))
+}
+
+// aliasedCollection returns the collection name is an alias of, or name.
+// Aliases that cannot be read are ignored, the name then being used as is.
+func aliasedCollection(name string) string {
+	aliases, err := loadAliases()
+	if err != nil {
+		return name
+	}
+
+	return cmp.Or(aliases[name], name)
+}
+
+func gitRemoteURL(dir string) string {
+	if fi, err := os.Stat(dir); err == nil && !fi.IsDir() {
+		dir = filepath.Dir(dir)
+	}
+
+	out, err := exec.Command("git", "-C", dir, "config", "--get", "remote.origin.url").Output()
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(out))
+}
+
+// normalizeRemote maps the different spellings of a remote, such as
+// git@github.com:a/b.git and https://github.com/a/b, to the same string.
+func normalizeRemote(remote string) string {
+	r := remote
+	if i := strings.Index(r, "://"); i >= 0 {
+		r = r[i+3:]
+	}
+	if i := strings.Index(r, "@"); i >= 0 {
+		r = r[i+1:]
+	}
+	r = strings.Replace(r, ":", "/", 1)
+	r = strings.TrimSuffix(strings.TrimSuffix(r, "/"), ".git")
+
+	return strings.ToLower(r)
+}
+
+// sanitizeCollectionName makes s a valid Chroma collection name: 3 to 63
+// characters from [a-zA-Z0-9._-], starting and ending with an alphanumeric.
+func sanitizeCollectionName(s string) string {
+	s = invalidCollectionChars.ReplaceAllString(s, "-")
+	s = strings.Trim(s, "._-")
+
+	if len(s) > 63 {
+		sum := sha256.Sum256([]byte(s))
+		s = strings.Trim(s[:50], "._-") + "-" + hex.EncodeToString(sum[:])[:8]
+	}
+
+	for len(s) < 3 {
+		s += "0"
+	}
+
+	return s
+}
@@ -0,0 +1,453 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/karitham/cls/query"
+)
+
+// Printer renders command output. In plain mode it avoids decorations and
+// emits a stable, line-oriented format suited to screen readers and dumb
+// terminals.
+type Printer struct {
+	w io.Writer
+	// errw receives warnings, stderr unless quiet.
+	errw      io.Writer
+	plain     bool
+	maxLines  int
+	context   int
+	pathStyle string
+	output    string
+	color     bool
+	// terms are the lower case query terms highlighted in colored output.
+	terms []string
+}
+
+// Output formats of results.
+const (
+	OutputText   = "text"
+	OutputGrep   = "grep"
+	OutputEditor = "editor"
+)
+
+// How result paths are displayed.
+const (
+	PathRelative = "relative"
+	PathAbsolute = "absolute"
+)
+
+func NewPrinter(w io.Writer, plain bool) *Printer {
+	if os.Getenv("TERM") == "dumb" {
+		plain = true
+	}
+
+	p := &Printer{w: w, errw: os.Stderr, plain: plain}
+	if f, ok := w.(*os.File); ok && !plain {
+		p.color = colorEnabled(f)
+	}
+
+	return p
+}
+
+// SetColor enables syntax highlighting of result content.
+func (p *Printer) SetColor(color bool) {
+	p.color = color && !p.plain
+}
+
+// SetHighlight selects the query terms highlighted in result content.
+func (p *Printer) SetHighlight(terms []string) {
+	p.terms = p.terms[:0]
+	for _, t := range terms {
+		p.terms = append(p.terms, strings.ToLower(t))
+	}
+}
+
+// highlight colors the lines of content of r when color is enabled.
+func (p *Printer) highlight(r QueryResult, lines []string) []string {
+	if !p.color {
+		return lines
+	}
+
+	return highlightLines(lines, query.Language(r), p.terms)
+}
+
+// SetMaxLines caps the number of content lines printed per result. Zero or
+// less prints content in full.
+func (p *Printer) SetMaxLines(n int) {
+	p.maxLines = n
+}
+
+// SetContext prints the lines around the matching line of results, instead
+// of their content from the start. Zero or less disables it.
+func (p *Printer) SetContext(n int) {
+	p.context = n
+}
+
+// SetOutput selects the format of results, OutputText, OutputGrep or
+// OutputEditor.
+func (p *Printer) SetOutput(output string) {
+	p.output = output
+}
+
+// SetPathStyle selects how result paths are displayed, PathRelative to the
+// indexed root or PathAbsolute.
+func (p *Printer) SetPathStyle(style string) {
+	p.pathStyle = style
+}
+
+func (p *Printer) path(r QueryResult) string {
+	if p.pathStyle == PathRelative && r.RelPath != "" {
+		return r.RelPath
+	}
+
+	return r.Path
+}
+
+// content returns the printable content of r, truncated to maxLines with a
+// hint on how to get the rest.
+func (p *Printer) content(r QueryResult) string {
+	if p.context > 0 && r.Line > 0 {
+		return p.snippet(r)
+	}
+
+	lines := strings.Split(strings.TrimRight(r.Content, "\n"), "\n")
+	if p.maxLines <= 0 || len(lines) <= p.maxLines {
+		return strings.Join(p.highlight(r, lines), "\n")
+	}
+
+	// plain output sticks to ASCII
+	ellipsis := "…"
+	if p.plain {
+		ellipsis = "..."
+	}
+	hidden := len(lines) - p.maxLines
+	return strings.Join(p.highlight(r, lines[:p.maxLines]), "\n") +
+		fmt.Sprintf("\n%s +%d lines, use -full or cls get %s", ellipsis, hidden, r.ID)
+}
+
+func (p *Printer) Results(results []QueryResult) {
+	switch p.output {
+	case OutputGrep:
+		p.grepResults(results)
+		return
+	case OutputEditor:
+		p.editorResults(results)
+		return
+	}
+
+	if len(results) == 0 {
+		fmt.Fprintln(p.w, "No results found")
+		return
+	}
+
+	if p.plain {
+		// in the order of text output, the best result last
+		fmt.Fprintf(p.w, "results: %d\n", len(results))
+		for i := len(results) - 1; i >= 0; i-- {
+			r := results[i]
+			fmt.Fprintf(p.w, "result %d id: %s\n", i+1, r.ID)
+			if r.Collection != "" {
+				fmt.Fprintf(p.w, "result %d collection: %s\n", i+1, r.Collection)
+			}
+			fmt.Fprintf(p.w, "result %d path: %s\n", i+1, p.path(r))
+			if r.Line > 0 {
+				fmt.Fprintf(p.w, "result %d line: %d\n", i+1, r.Line)
+			}
+			if r.Title != "" {
+				fmt.Fprintf(p.w, "result %d title: %s\n", i+1, r.Title)
+			}
+			if r.Section != "" {
+				fmt.Fprintf(p.w, "result %d section: %s\n", i+1, r.Section)
+			}
+			if r.Record > 0 {
+				fmt.Fprintf(p.w, "result %d record: %d\n", i+1, r.Record)
+			}
+			for _, d := range r.Duplicates {
+				fmt.Fprintf(p.w, "result %d duplicate: %s\n", i+1, d)
+			}
+			if r.Author != "" {
+				fmt.Fprintf(p.w, "result %d author: %s <%s>\n", i+1, r.Author, r.AuthorEmail)
+				fmt.Fprintf(p.w, "result %d commit: %s\n", i+1, r.Commit)
+			}
+			if r.Missing {
+				fmt.Fprintf(p.w, "result %d missing: true\n", i+1)
+			}
+			if r.Language != "" {
+				fmt.Fprintf(p.w, "result %d language: %s\n", i+1, r.Language)
+			}
+			if r.Lines > 0 {
+				fmt.Fprintf(p.w, "result %d lines: %d\n", i+1, r.Lines)
+			}
+			if !r.ModTime.IsZero() {
+				fmt.Fprintf(p.w, "result %d modified: %s\n", i+1, r.ModTime.UTC().Format(time.RFC3339))
+			}
+			fmt.Fprintf(p.w, "result %d content begins\n", i+1)
+			fmt.Fprintln(p.w, p.content(r))
+			fmt.Fprintf(p.w, "result %d content ends\n", i+1)
+			for _, c := range r.Callers {
+				fmt.Fprintf(p.w, "result %d caller: %s %s\n", i+1, symbolLocation(c), c.Name)
+			}
+		}
+		return
+	}
+
+	fmt.Fprintf(p.w, "Found %d results:\n\n", len(results))
+	for i := len(results) - 1; i >= 0; i-- {
+		result := results[i]
+		fmt.Fprintf(p.w, "Result: %d (%s)\n", i+1, result.ID)
+		if result.Collection != "" {
+			fmt.Fprintf(p.w, "Collection: %s\n", result.Collection)
+		}
+		fmt.Fprintf(p.w, "File: %s\n", result.FileName)
+		if result.Missing {
+			fmt.Fprintf(p.w, "Path: %s (missing on disk)\n", p.reference(result))
+		} else {
+			fmt.Fprintf(p.w, "Path: %s\n", p.reference(result))
+		}
+		if len(result.Duplicates) > 0 {
+			fmt.Fprintf(p.w, "Also at: %s\n", strings.Join(result.Duplicates, ", "))
+		}
+		if result.Title != "" {
+			fmt.Fprintf(p.w, "Title: %s\n", result.Title)
+		}
+		if result.Section != "" {
+			fmt.Fprintf(p.w, "Section: %s\n", result.Section)
+		}
+		if details := fileDetails(result); details != "" {
+			fmt.Fprintf(p.w, "Details: %s\n", details)
+		}
+		fmt.Fprintf(p.w, "Content:\n%s\n", p.content(result))
+		if len(result.Callers) > 0 {
+			fmt.Fprintln(p.w, "Called from:")
+			for _, c := range result.Callers {
+				fmt.Fprintf(p.w, "  %s %s\n", symbolLocation(c), c.Name)
+			}
+		}
+		fmt.Fprintln(p.w, strings.Repeat("-", 50))
+	}
+}
+
+// Symbols prints a line per symbol definition, location first.
+func (p *Printer) Symbols(symbols []Symbol) {
+	if p.plain {
+		fmt.Fprintf(p.w, "symbols: %d\n", len(symbols))
+		for i, s := range symbols {
+			fmt.Fprintf(p.w, "symbol %d name: %s\n", i+1, s.Name)
+			fmt.Fprintf(p.w, "symbol %d kind: %s\n", i+1, s.Kind)
+			fmt.Fprintf(p.w, "symbol %d location: %s\n", i+1, symbolLocation(s))
+			fmt.Fprintf(p.w, "symbol %d id: %s\n", i+1, s.ID)
+		}
+		return
+	}
+
+	for _, s := range symbols {
+		fmt.Fprintf(p.w, "%s\t%s %s\n", symbolLocation(s), s.Kind, s.Name)
+	}
+}
+
+// Doctor prints the outcome of the doctor checks, with the fix of the ones
+// that did not pass.
+func (p *Printer) Doctor(checks []Check) {
+	for _, c := range checks {
+		if p.plain {
+			fmt.Fprintf(p.w, "%s %s: %s\n", c.Name, c.Status, c.Detail)
+			if c.Fix != "" {
+				fmt.Fprintf(p.w, "%s fix: %s\n", c.Name, c.Fix)
+			}
+			continue
+		}
+
+		mark := "✓"
+		switch c.Status {
+		case CheckWarn:
+			mark = "!"
+		case CheckFail:
+			mark = "✗"
+		}
+		fmt.Fprintf(p.w, "%s %-10s %s\n", mark, c.Name, c.Detail)
+		if c.Fix != "" {
+			fmt.Fprintf(p.w, "  %-10s fix: %s\n", "", c.Fix)
+		}
+	}
+}
+
+// grepResults prints a path:line:text line per result, as grep -n does, for
+// editors and tools parsing grep output. Nothing is printed without results.
+func (p *Printer) grepResults(results []QueryResult) {
+	for _, r := range results {
+		lines := strings.Split(r.Content, "\n")
+		line, at := max(r.Line, r.StartLine, 1), 0
+		if r.Line > 0 && r.StartLine > 0 {
+			at = r.Line - r.StartLine
+		}
+
+		text := ""
+		if at < len(lines) {
+			text = strings.TrimRight(lines[at], "\r")
+		}
+		fmt.Fprintf(p.w, "%s:%d:%s\n", p.path(r), line, text)
+	}
+}
+
+// editorResults prints a file URI per result, with the lines of its chunk
+// as a #Lstart-Lend fragment, for terminals and editors to open at them.
+func (p *Printer) editorResults(results []QueryResult) {
+	for _, r := range results {
+		uri, ok := fileURI(r)
+		if !ok {
+			continue
+		}
+		if r.StartLine > 0 && !strings.Contains(uri, "#") {
+			uri += fmt.Sprintf("#L%d-L%d", r.StartLine, r.EndLine)
+		}
+		fmt.Fprintln(p.w, uri)
+	}
+}
+
+// fileURI returns the URI of the document of r: a file URI of its absolute
+// path, or its page URL for crawled sites. Documents whose root is unknown
+// on this machine have none.
+func fileURI(r QueryResult) (string, bool) {
+	switch {
+	case r.Unresolved && strings.Contains(r.Path, "://"):
+		return r.Path, true
+	case r.Unresolved:
+		return "", false
+	}
+
+	abs, err := filepath.Abs(r.Path)
+	if err != nil {
+		return "", false
+	}
+
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(abs)}).String(), true
+}
+
+// fileDetails summarizes the file metadata of r, for documents indexed with
+// it.
+func fileDetails(r QueryResult) string {
+	var details []string
+	if r.Record > 0 {
+		details = append(details, fmt.Sprintf("record %d", r.Record))
+	}
+	if r.Language != "" {
+		details = append(details, r.Language)
+	}
+	if r.Lines > 0 {
+		details = append(details, fmt.Sprintf("%d lines", r.Lines))
+	}
+	if !r.ModTime.IsZero() {
+		details = append(details, "modified "+r.ModTime.Format(time.DateOnly))
+	}
+	if r.Author != "" {
+		details = append(details, fmt.Sprintf("by %s in %.7s", r.Author, r.Commit))
+	}
+
+	return strings.Join(details, ", ")
+}
+
+// Document prints a single document in full.
+func (p *Printer) Document(r QueryResult) {
+	if p.plain {
+		fmt.Fprintf(p.w, "id: %s\n", r.ID)
+		fmt.Fprintf(p.w, "path: %s\n", p.path(r))
+		fmt.Fprintln(p.w, "content begins")
+		fmt.Fprintln(p.w, strings.TrimRight(r.Content, "\n"))
+		fmt.Fprintln(p.w, "content ends")
+		return
+	}
+
+	fmt.Fprintf(p.w, "ID: %s\n", r.ID)
+	fmt.Fprintf(p.w, "Path: %s\n", p.path(r))
+	fmt.Fprintf(p.w, "Content:\n%s\n", strings.TrimRight(r.Content, "\n"))
+}
+
+func (p *Printer) Answer(answer string, sources []QueryResult) {
+	if p.plain {
+		fmt.Fprintln(p.w, "answer begins")
+		fmt.Fprintln(p.w, strings.TrimSpace(answer))
+		fmt.Fprintln(p.w, "answer ends")
+		for i, r := range sources {
+			fmt.Fprintf(p.w, "source %d: %s\n", i+1, p.path(r))
+		}
+		return
+	}
+
+	fmt.Fprintln(p.w, strings.TrimSpace(answer))
+	fmt.Fprintln(p.w)
+	fmt.Fprintln(p.w, "Sources:")
+	for i, r := range sources {
+		fmt.Fprintf(p.w, "  [%d] %s\n", i+1, p.path(r))
+	}
+}
+
+// Warning prints a message that must not be missed, such as results being
+// degraded, to stderr so stdout keeps only results.
+func (p *Printer) Warning(format string, args ...any) {
+	prefix := "WARNING: "
+	if p.plain {
+		prefix = "warning: "
+	}
+
+	fmt.Fprintf(p.errw, prefix+format+"\n", args...)
+}
+
+// SetQuiet drops warnings.
+func (p *Printer) SetQuiet(quiet bool) {
+	p.errw = os.Stderr
+	if quiet {
+		p.errw = io.Discard
+	}
+}
+
+func (p *Printer) Message(format string, args ...any) {
+	fmt.Fprintf(p.w, format+"\n", args...)
+}
+
+// parseBytes reads a size such as 512MB, 2GiB or 1048576. Units are powers
+// of 1024 whether written KB or KiB, as formatBytes prints them.
+func parseBytes(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	num := strings.TrimRightFunc(s, unicode.IsLetter)
+	unit := strings.ToUpper(strings.TrimSpace(s[len(num):]))
+	unit = strings.TrimSuffix(strings.TrimSuffix(unit, "B"), "I")
+
+	n, err := strconv.ParseFloat(strings.TrimSpace(num), 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+
+	exp := 0
+	if unit != "" {
+		exp = strings.Index("KMGTPE", unit) + 1
+		if exp == 0 || len(unit) > 1 {
+			return 0, fmt.Errorf("invalid size unit in %q", s)
+		}
+	}
+
+	return int64(n * math.Pow(1024, float64(exp))), nil
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
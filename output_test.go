@@ -0,0 +1,34 @@
+package main
+
+import "testing"
+
+func TestParseBytes(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+	}{
+		{"1048576", 1 << 20},
+		{"512MB", 512 << 20},
+		{"512 MiB", 512 << 20},
+		{"2GiB", 2 << 30},
+		{"2gb", 2 << 30},
+		{"1.5K", 1536},
+		{"0", 0},
+	}
+	for _, tt := range tests {
+		got, err := parseBytes(tt.in)
+		if err != nil {
+			t.Errorf("parseBytes(%q): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseBytes(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+
+	for _, in := range []string{"", "MB", "-1KB", "12XB", "3 KMB"} {
+		if got, err := parseBytes(in); err == nil {
+			t.Errorf("parseBytes(%q) = %d, want an error", in, got)
+		}
+	}
+}
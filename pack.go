@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/karitham/cls/index"
+	"github.com/karitham/cls/query"
+	"github.com/karitham/cls/store"
+)
+
+// Context packing strategies, selecting which results fill a token budget.
+const (
+	// PackGreedy takes results best first, skipping those that do not fit.
+	PackGreedy = "greedy"
+	// PackDiverse takes the best remaining result of each directory in turn,
+	// so one directory cannot crowd out the others.
+	PackDiverse = "diverse"
+	// PackWholeFile replaces the chunks of a file by the whole file when
+	// several of its chunks matched, and otherwise packs greedily.
+	PackWholeFile = "whole-file"
+)
+
+var packStrategies = []string{PackGreedy, PackDiverse, PackWholeFile}
+
+type PackOptions struct {
+	Strategy string
+	Budget   int
+	TopK     int
+	Force    bool
+}
+
+// PackContext selects and orders results to fill budget tokens according to
+// strategy.
+func PackContext(results []QueryResult, budget int, strategy string) ([]QueryResult, error) {
+	switch strategy {
+	case PackGreedy, "":
+		return packGreedy(results, budget), nil
+	case PackDiverse:
+		return packGreedy(interleaveByDir(results), budget), nil
+	case PackWholeFile:
+		return packGreedy(promoteWholeFiles(results), budget), nil
+	default:
+		return nil, fmt.Errorf("unknown pack strategy %q, expected one of %s", strategy, strings.Join(packStrategies, ", "))
+	}
+}
+
+func packGreedy(results []QueryResult, budget int) []QueryResult {
+	var used []QueryResult
+	for _, r := range results {
+		cost := estimateTokens(contextBlock(len(used)+1, r))
+		if cost > budget {
+			continue
+		}
+
+		budget -= cost
+		used = append(used, r)
+	}
+
+	return used
+}
+
+// interleaveByDir orders results round-robin across their directories,
+// visiting directories in the order of their best result.
+func interleaveByDir(results []QueryResult) []QueryResult {
+	var (
+		dirs   []string
+		groups = map[string][]QueryResult{}
+	)
+	for _, r := range results {
+		dir := filepath.Dir(r.Path)
+		if _, ok := groups[dir]; !ok {
+			dirs = append(dirs, dir)
+		}
+		groups[dir] = append(groups[dir], r)
+	}
+
+	out := make([]QueryResult, 0, len(results))
+	for len(out) < len(results) {
+		for _, dir := range dirs {
+			if g := groups[dir]; len(g) > 0 {
+				out = append(out, g[0])
+				groups[dir] = g[1:]
+			}
+		}
+	}
+
+	return out
+}
+
+// promoteWholeFiles replaces the chunks of files matched more than once by a
+// single result holding the whole file, at the rank of its best chunk. Files
+// that cannot be read keep their chunks.
+func promoteWholeFiles(results []QueryResult) []QueryResult {
+	counts := map[string]int{}
+	for _, r := range results {
+		counts[r.Path]++
+	}
+
+	var (
+		out      []QueryResult
+		promoted = map[string]bool{}
+	)
+	for _, r := range results {
+		if counts[r.Path] < 2 {
+			out = append(out, r)
+			continue
+		}
+		if promoted[r.Path] {
+			continue
+		}
+
+		content, err := index.ReadFile(r.Path)
+		if err != nil {
+			out = append(out, r)
+			counts[r.Path] = 0
+			continue
+		}
+
+		promoted[r.Path] = true
+		// the whole file has no lines, as documents indexed without them
+		r.ID, r.Content = r.Path, content
+		r.StartLine, r.EndLine = 0, 0
+		out = append(out, r)
+	}
+
+	return out
+}
+
+func contextBlock(n int, r QueryResult) string {
+	return fmt.Sprintf("[%d] %s\n%s\n\n", n, r.Path, codeFence(r.Content, query.Language(r)))
+}
+
+// formatContext renders results as numbered context blocks.
+func formatContext(results []QueryResult) string {
+	var sb strings.Builder
+	for i, r := range results {
+		sb.WriteString(contextBlock(i+1, r))
+	}
+
+	return sb.String()
+}
+
+// packContextCommand prints the context that ask would send to the model,
+// for use with other tools.
+func packContextCommand(chromaOpts ChromaOptions, collection, query string, opts PackOptions, printer *Printer, logger *slog.Logger) {
+	ctx := context.Background()
+
+	client, err := newStore(chromaOpts, logger)
+	if err != nil {
+		logger.Error("Failed to create ChromaDB client", "error", err)
+		os.Exit(1)
+	}
+	defer client.Close()
+
+	coll, err := client.GetCollection(ctx, collection)
+	if err != nil {
+		logger.Error("Failed to get collection", "error", err)
+		os.Exit(1)
+	}
+
+	verifyEmbedder(coll, opts.Force, logger)
+
+	resp, err := coll.Query(ctx, query, store.WithN(opts.TopK))
+	if err != nil {
+		logger.Error("Failed to query collection", "error", err)
+		os.Exit(1)
+	}
+	results := resp.Results
+
+	used, err := PackContext(results, opts.Budget, opts.Strategy)
+	if err != nil {
+		logger.Error("Failed to pack context", "error", err)
+		os.Exit(1)
+	}
+	if len(used) < len(results) {
+		logger.Warn("Context budget exceeded, dropped results", "kept", len(used), "total", len(results))
+	}
+
+	printer.Message("%s", strings.TrimRight(formatContext(used), "\n"))
+}
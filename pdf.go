@@ -0,0 +1,66 @@
+//go:build pdf
+
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/karitham/cls/buildinfo"
+	"github.com/karitham/cls/index"
+)
+
+func init() {
+	buildinfo.RegisterFeature("pdf", true)
+	index.RegisterExtractor(".pdf", extractPDFText)
+}
+
+var (
+	pdfStream  = regexp.MustCompile(`(?s)stream\r?\n(.*?)\r?\nendstream`)
+	pdfTextOps = regexp.MustCompile(`(?s)\[(.*?)\]\s*TJ|\((.*?[^\\])\)\s*(?:Tj|'|")|(T\*|ET)`)
+	pdfStrings = regexp.MustCompile(`\((.*?[^\\])\)`)
+)
+
+// extractPDFText pulls the text shown by the content streams of a PDF. It
+// handles uncompressed and Flate encoded streams, which covers most PDFs
+// produced from text, and ignores everything it does not understand.
+func extractPDFText(data []byte) (string, error) {
+	var sb strings.Builder
+	for _, m := range pdfStream.FindAllSubmatch(data, -1) {
+		content := m[1]
+		if r, err := zlib.NewReader(bytes.NewReader(content)); err == nil {
+			if inflated, err := io.ReadAll(r); err == nil {
+				content = inflated
+			}
+		}
+
+		for _, op := range pdfTextOps.FindAllSubmatch(content, -1) {
+			switch {
+			case op[1] != nil:
+				for _, s := range pdfStrings.FindAllSubmatch(op[1], -1) {
+					sb.WriteString(unescapePDFString(s[1]))
+				}
+			case op[2] != nil:
+				sb.WriteString(unescapePDFString(op[2]))
+			default:
+				sb.WriteByte('\n')
+			}
+		}
+	}
+
+	text := strings.TrimSpace(sb.String())
+	if text == "" {
+		return "", errors.New("no extractable text")
+	}
+
+	return text, nil
+}
+
+func unescapePDFString(s []byte) string {
+	r := strings.NewReplacer(`\n`, "\n", `\r`, "\r", `\t`, "\t", `\(`, "(", `\)`, ")", `\\`, `\`)
+	return r.Replace(string(s))
+}
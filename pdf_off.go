@@ -0,0 +1,9 @@
+//go:build !pdf
+
+package main
+
+import "github.com/karitham/cls/buildinfo"
+
+func init() {
+	buildinfo.RegisterFeature("pdf", false)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/karitham/cls/index"
+)
+
+// previewCache holds the full contents of result files for previews. Results
+// ahead of the selected one are loaded in the background, so moving through
+// results does not wait on large files or on the store.
+type previewCache struct {
+	coll  Collection
+	size  int
+	mu    sync.Mutex
+	order []string
+	items map[string]*previewItem
+}
+
+type previewItem struct {
+	done    chan struct{}
+	content string
+	err     error
+}
+
+// newPreviewCache returns a cache keeping the contents of up to size files,
+// read from coll when they are no longer on disk.
+func newPreviewCache(coll Collection, size int) *previewCache {
+	return &previewCache{coll: coll, size: max(size, 1), items: map[string]*previewItem{}}
+}
+
+// Prefetch loads the contents of the ahead results following results[i].
+func (c *previewCache) Prefetch(ctx context.Context, results []QueryResult, i, ahead int) {
+	for _, r := range results[min(i+1, len(results)):min(i+1+ahead, len(results))] {
+		c.load(ctx, r)
+	}
+}
+
+// Content returns the full contents of the file of r, waiting for a load in
+// progress.
+func (c *previewCache) Content(ctx context.Context, r QueryResult) (string, error) {
+	item := c.load(ctx, r)
+
+	select {
+	case <-item.done:
+		return item.content, item.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+func (c *previewCache) load(ctx context.Context, r QueryResult) *previewItem {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if item, ok := c.items[r.Path]; ok {
+		return item
+	}
+
+	item := &previewItem{done: make(chan struct{})}
+	c.items[r.Path] = item
+	c.order = append(c.order, r.Path)
+	if len(c.order) > c.size {
+		delete(c.items, c.order[0])
+		c.order = c.order[1:]
+	}
+
+	go func() {
+		defer close(item.done)
+		item.content, item.err = c.fetch(ctx, r)
+	}()
+
+	return item
+}
+
+// fetch reads the file of r from disk, falling back to the chunks stored for
+// files that were moved or deleted since they were indexed.
+func (c *previewCache) fetch(ctx context.Context, r QueryResult) (string, error) {
+	content, err := index.ReadFile(r.Path)
+	if err == nil || c.coll == nil {
+		return content, err
+	}
+
+	stored, gerr := storedFile(ctx, c.coll, r.ID)
+	if gerr != nil || stored == "" {
+		return "", err
+	}
+
+	return stored, nil
+}
+
+// storedPageSize is how many chunks are read at once when a file is rebuilt
+// from the store.
+const storedPageSize = 100
+
+// storedFile rebuilds the file of the chunk id from all its chunks in coll,
+// joined in order, the lines overlapping chunks share being kept once.
+func storedFile(ctx context.Context, coll Collection, id string) (string, error) {
+	file, _, ok := strings.Cut(id, "#chunk")
+	if !ok {
+		return "", nil
+	}
+
+	var (
+		b strings.Builder
+		// next is the first line of the file not written yet
+		next = 1
+	)
+	for start := 0; ; start += storedPageSize {
+		ids := make([]string, storedPageSize)
+		for i := range ids {
+			ids[i] = file + "#chunk" + strconv.Itoa(start+i)
+		}
+		chunks, err := coll.Get(ctx, ids...)
+		if err != nil {
+			return "", err
+		}
+		slices.SortFunc(chunks, func(a, b QueryResult) int { return chunkIndex(a.ID) - chunkIndex(b.ID) })
+
+		for _, chunk := range chunks {
+			text := chunk.Content
+			if chunk.StartLine > 0 && chunk.StartLine < next {
+				lines := strings.SplitAfter(text, "\n")
+				if skip := next - chunk.StartLine; skip < len(lines) {
+					text = strings.Join(lines[skip:], "")
+				} else {
+					text = ""
+				}
+			}
+			if text != "" && b.Len() > 0 && !strings.HasSuffix(b.String(), "\n") {
+				b.WriteByte('\n')
+			}
+			b.WriteString(text)
+			if chunk.EndLine > 0 {
+				next = max(next, chunk.EndLine+1)
+			}
+		}
+		if len(chunks) < storedPageSize {
+			return b.String(), nil
+		}
+	}
+}
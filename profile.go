@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"slices"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// StageWalk is the profiled stage of walking the tree, along the read,
+// secrets and chunk stages of the index package and the embed and store
+// stages of the store package. Stages overlap when run concurrently.
+const StageWalk = "walk"
+
+// Profile sums the time spent in each stage of a run. Nothing is measured
+// here, no data leaves the machine.
+type Profile struct {
+	start  time.Time
+	mu     sync.Mutex
+	stages map[string]*StageTime
+}
+
+// StageTime is the time spent in a stage and the number of calls to it.
+type StageTime struct {
+	Total time.Duration
+	Calls int
+}
+
+// profiler is the profile of the run, nil unless -profile is set. Its
+// methods do nothing on nil so stages can be timed unconditionally.
+var profiler *Profile
+
+func NewProfile() *Profile {
+	return &Profile{start: time.Now(), stages: map[string]*StageTime{}}
+}
+
+// Time starts timing a call to stage, and returns the function ending it.
+func (p *Profile) Time(stage string) func() {
+	if p == nil {
+		return func() {}
+	}
+
+	start := time.Now()
+	return func() {
+		d := time.Since(start)
+
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		s, ok := p.stages[stage]
+		if !ok {
+			s = &StageTime{}
+			p.stages[stage] = s
+		}
+		s.Total += d
+		s.Calls++
+	}
+}
+
+// Report prints the time spent in each stage, longest first, and its share
+// of the run.
+func (p *Profile) Report(w io.Writer) {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	wall := time.Since(p.start)
+	names := make([]string, 0, len(p.stages))
+	for name := range p.stages {
+		names = append(names, name)
+	}
+	slices.SortFunc(names, func(a, b string) int {
+		return int(p.stages[b].Total - p.stages[a].Total)
+	})
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "stage\tcalls\ttotal\taverage\tshare")
+	for _, name := range names {
+		s := p.stages[name]
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%.1f%%\n", name, s.Calls,
+			s.Total.Round(time.Millisecond), (s.Total / time.Duration(s.Calls)).Round(time.Microsecond),
+			float64(s.Total)/float64(wall)*100)
+	}
+	fmt.Fprintf(tw, "wall\t\t%s\n", wall.Round(time.Millisecond))
+	tw.Flush()
+}
+
+// startCPUProfile writes a pprof CPU profile to path until the returned
+// function is called.
+func startCPUProfile(path string) (func() error, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cpu profile: %w", err)
+	}
+	if err := pprof.StartCPUProfile(f); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to start cpu profile: %w", err)
+	}
+
+	return func() error {
+		pprof.StopCPUProfile()
+		return f.Close()
+	}, nil
+}
+
+// writeHeapProfile writes a pprof heap profile of the live memory to path.
+func writeHeapProfile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create heap profile: %w", err)
+	}
+	defer f.Close()
+
+	runtime.GC()
+	if err := pprof.WriteHeapProfile(f); err != nil {
+		return fmt.Errorf("failed to write heap profile: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/karitham/cls/index"
+)
+
+// openProgressFD returns a Progress writing to the file descriptor fd, which
+// the caller is expected to have opened, or only logging when fd is 0.
+func openProgressFD(fd int, logger *slog.Logger) (*Progress, error) {
+	if fd == 0 {
+		return index.NewProgress(nil, logger), nil
+	}
+
+	f := os.NewFile(uintptr(fd), "progress")
+	if f == nil {
+		return nil, fmt.Errorf("invalid progress file descriptor %d", fd)
+	}
+	if _, err := f.Stat(); err != nil {
+		return nil, fmt.Errorf("progress file descriptor %d is not open: %w", fd, err)
+	}
+
+	return index.NewProgress(f, logger), nil
+}
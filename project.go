@@ -0,0 +1,105 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// absPath returns the absolute form of path, or path when it has none.
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+
+	return path
+}
+
+// projectMarkers are the entries marking the root of a project.
+var projectMarkers = []string{".git", ".cls.toml"}
+
+// projectRoot returns the nearest ancestor of path, path included, holding a
+// project marker, so commands run from a subdirectory act on the whole
+// project like git does. Paths outside any project are returned unchanged.
+func projectRoot(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+
+	for dir := abs; ; {
+		for _, marker := range projectMarkers {
+			if _, err := os.Stat(filepath.Join(dir, marker)); err == nil {
+				return dir
+			}
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return path
+		}
+		dir = parent
+	}
+}
+
+const rootsState = "roots.json"
+
+// rootID identifies root across machines: by its git remote when it has one,
+// and otherwise by a hash of its absolute path.
+func rootID(root string) string {
+	abs := absPath(root)
+
+	if remote := gitRemoteURL(abs); remote != "" {
+		return normalizeRemote(remote)
+	}
+
+	sum := sha256.Sum256([]byte(abs))
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// rememberRoot records where the root identified by id lives on this machine,
+// so relative paths indexed under it resolve to local files.
+func rememberRoot(id, root string) error {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+
+	roots := map[string]string{}
+	if err := readState(rootsState, &roots); err != nil {
+		return err
+	}
+	if roots[id] == abs {
+		return nil
+	}
+	roots[id] = abs
+
+	return writeState(rootsState, roots)
+}
+
+// localRoots maps root identifiers to their local directory: the roots
+// indexed on this machine and the project of the working directory.
+var localRoots = sync.OnceValue(func() map[string]string {
+	roots := map[string]string{}
+	// resolution is best effort, unknown roots keep their relative paths
+	_ = readState(rootsState, &roots)
+
+	if root := projectRoot("."); filepath.IsAbs(root) {
+		roots[rootID(root)] = root
+	}
+
+	return roots
+})
+
+// localPath resolves rel, indexed under the root identified by id, to a
+// local path, reporting false when the root is unknown here.
+func localPath(id, rel string) (string, bool) {
+	root, ok := localRoots()[id]
+	if !ok {
+		return "", false
+	}
+
+	return filepath.Join(root, filepath.FromSlash(rel)), true
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+var errNotInteractive = errors.New("stdin is not a terminal, pass -force to skip the confirmation")
+
+// isInteractive reports whether stdin is a terminal a user can answer
+// prompts on, never in CI mode.
+func isInteractive() bool {
+	if ciMode {
+		return false
+	}
+
+	fi, err := os.Stdin.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// confirm asks a yes/no question on stderr and reads the answer from stdin.
+// Anything but an explicit yes is a no.
+func confirm(format string, args ...any) (bool, error) {
+	if !isInteractive() {
+		return false, errNotInteractive
+	}
+
+	fmt.Fprintf(os.Stderr, format+" [y/N] ", args...)
+
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false, fmt.Errorf("failed to read answer: %w", err)
+	}
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	default:
+		return false, nil
+	}
+}
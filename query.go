@@ -0,0 +1,6 @@
+package main
+
+import "github.com/karitham/cls/store"
+
+// targets are the spaces -target searches.
+var targets = []string{store.TargetBoth, store.TargetCode, store.TargetDesc}
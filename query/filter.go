@@ -0,0 +1,83 @@
+package query
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/karitham/cls/store"
+)
+
+// Glob compiles a slash separated glob where * and ? stay within a path
+// segment and ** spans any number of them.
+func Glob(glob string) (*regexp.Regexp, error) {
+	var sb strings.Builder
+	sb.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				i++
+				if i+1 < len(glob) && glob[i+1] == '/' {
+					// **/ also matches no directory at all
+					i++
+					sb.WriteString("(?:.*/)?")
+				} else {
+					sb.WriteString(".*")
+				}
+			} else {
+				sb.WriteString("[^/]*")
+			}
+		case '?':
+			sb.WriteString("[^/]")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	sb.WriteString("$")
+
+	return regexp.Compile(sb.String())
+}
+
+// InScope reports whether the slash separated relPath matches any of
+// patterns. Every path is in the empty scope.
+func InScope(patterns []string, relPath string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+
+	for _, p := range patterns {
+		re, err := Glob(p)
+		if err == nil && re.MatchString(relPath) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// licenseExcluded reports whether license matches one of excluded. An
+// excluded id also matches its variants, so GPL-3.0 matches GPL-3.0-only and
+// GPL-3.0-or-later.
+func licenseExcluded(license string, excluded []string) bool {
+	return license != "" && slices.ContainsFunc(excluded, func(e string) bool {
+		return strings.EqualFold(license, e) || strings.HasPrefix(strings.ToLower(license), strings.ToLower(e)+"-")
+	})
+}
+
+// authoredBy reports whether the author of r matches one of authors, as a
+// case insensitive substring of "name <email>" like git log --author.
+func authoredBy(r store.Result, authors []string) bool {
+	if r.Author == "" {
+		return false
+	}
+
+	who := strings.ToLower(r.Author + " <" + r.AuthorEmail + ">")
+	for _, a := range authors {
+		if strings.Contains(who, strings.ToLower(a)) {
+			return true
+		}
+	}
+
+	return false
+}
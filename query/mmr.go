@@ -0,0 +1,50 @@
+package query
+
+import (
+	"math"
+
+	"github.com/karitham/cls/store"
+)
+
+// MMR re-selects n results using maximal marginal relevance. diversity is in
+// [0, 1]: 0 keeps the pure relevance order, 1 maximises dissimilarity between
+// the selected results.
+func MMR(query []float32, results []store.Result, n int, diversity float64) []store.Result {
+	lambda := 1 - min(max(diversity, 0), 1)
+
+	candidates := make([]int, len(results))
+	for i := range candidates {
+		candidates[i] = i
+	}
+
+	relevance := make([]float64, len(results))
+	for i, r := range results {
+		relevance[i] = store.Cosine(query, r.Embedding)
+	}
+
+	var selected []int
+	for len(selected) < n && len(candidates) > 0 {
+		best, bestScore := 0, math.Inf(-1)
+		for ci, i := range candidates {
+			var redundancy float64
+			for _, j := range selected {
+				redundancy = max(redundancy, store.Cosine(results[i].Embedding, results[j].Embedding))
+			}
+
+			score := lambda*relevance[i] - (1-lambda)*redundancy
+			if score > bestScore {
+				best, bestScore = ci, score
+			}
+		}
+
+		selected = append(selected, candidates[best])
+		candidates = append(candidates[:best], candidates[best+1:]...)
+	}
+
+	out := make([]store.Result, len(selected))
+	for k, i := range selected {
+		out[k] = results[i]
+	}
+
+	return out
+}
@@ -0,0 +1,139 @@
+// Package query searches store collections: it runs a query with its
+// alternatives and negatives, filters the results by path, language, license
+// and author, and diversifies them.
+package query
+
+import (
+	"cmp"
+	"context"
+	"math"
+	"slices"
+
+	"github.com/karitham/cls/index"
+	"github.com/karitham/cls/store"
+)
+
+// Options selects the results of Search.
+type Options struct {
+	N         int
+	Diversity float64
+	// Exclude lists paths dropped from the results.
+	Exclude []string
+	// ExcludeLicenses lists SPDX license ids dropped from the results.
+	ExcludeLicenses []string
+	// Languages keeps only results of these languages when set.
+	Languages []string
+	// Scope keeps only results whose path matches one of these globs when
+	// set, and ExcludePaths drops the ones matching one of its globs.
+	Scope        []string
+	ExcludePaths []string
+	// Authors keeps the results mostly written by one of these authors, for
+	// collections indexed with git blame.
+	Authors []string
+	// Alternatives are other queries run along the query, their results
+	// merged with rank fusion.
+	Alternatives []string
+	// Not lists phrases results are pushed away from, by NotWeight.
+	Not       []string
+	NotWeight float64
+	// MinScore drops the results less similar to the query than it, as a
+	// cosine similarity, and MaxDistance the ones further than it from the
+	// query.
+	MinScore    float64
+	MaxDistance float64
+	// Reranker, when set, reorders the best Candidates results.
+	Reranker   store.Reranker
+	Candidates int
+	// Target is the space searched, one of store.TargetCode, TargetDesc or
+	// TargetBoth, the default.
+	Target string
+	// Run keeps the documents last written by this index run when set.
+	Run string
+}
+
+// filtered reports whether results are filtered after the query.
+func (opts Options) filtered() bool {
+	return len(opts.Exclude) > 0 || len(opts.ExcludeLicenses) > 0 || len(opts.Scope) > 0 || len(opts.ExcludePaths) > 0 || len(opts.Authors) > 0
+}
+
+// Search runs text against coll and applies the optional rerank,
+// diversification and filters selected in opts.
+func Search(ctx context.Context, coll store.Collection, text string, opts Options) ([]store.Result, error) {
+	n := opts.N + len(opts.Exclude)
+	if len(opts.ExcludeLicenses) > 0 || len(opts.Scope) > 0 || len(opts.ExcludePaths) > 0 || len(opts.Authors) > 0 {
+		n = max(n, opts.N*4)
+	}
+	if opts.Diversity > 0 {
+		n = max(n, opts.N*4)
+	}
+
+	queryOpts := []store.Option{store.WithN(n), store.WithAlternatives(opts.Alternatives...), store.WithTarget(cmp.Or(opts.Target, store.TargetBoth))}
+	if len(opts.Not) > 0 {
+		queryOpts = append(queryOpts, store.WithNegatives(opts.NotWeight, opts.Not...))
+	}
+	if len(opts.Languages) > 0 {
+		queryOpts = append(queryOpts, store.WithWhere(index.LanguageKey, opts.Languages...))
+	}
+	if opts.Run != "" {
+		queryOpts = append(queryOpts, store.WithWhere(index.RunKey, opts.Run))
+	}
+	if opts.Diversity > 0 {
+		queryOpts = append(queryOpts, store.WithIncludeEmbeddings())
+	}
+	if opts.MinScore > 0 {
+		queryOpts = append(queryOpts, store.WithMaxDistance(MinScoreDistance(opts.MinScore)))
+	}
+	if opts.MaxDistance > 0 {
+		queryOpts = append(queryOpts, store.WithMaxDistance(opts.MaxDistance))
+	}
+	if opts.Reranker != nil {
+		queryOpts = append(queryOpts, store.WithReranker(opts.Reranker, opts.Candidates))
+	}
+
+	resp, err := coll.Query(ctx, text, queryOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	// results are fetched beyond opts.N to make up for the ones filtered
+	// out here, or to leave MMR a choice
+	results := Exclude(resp.Results, opts)
+
+	if opts.Diversity > 0 {
+		results = MMR(resp.Embedding, results, opts.N, opts.Diversity)
+	}
+
+	if opts.filtered() || opts.Reranker != nil {
+		results = results[:min(len(results), opts.N)]
+	}
+
+	return results, nil
+}
+
+// MinScoreDistance converts a cosine similarity to the squared L2 distance
+// of unit vectors that similar, the distance Chroma collections use.
+func MinScoreDistance(score float64) float64 {
+	return max(2*(1-score), math.SmallestNonzeroFloat64)
+}
+
+// Exclude drops the results whose path, language, license or author opts
+// excludes.
+func Exclude(results []store.Result, opts Options) []store.Result {
+	return slices.DeleteFunc(results, func(r store.Result) bool {
+		return slices.Contains(opts.Exclude, r.Path) || licenseExcluded(r.License, opts.ExcludeLicenses) ||
+			len(opts.Languages) > 0 && !slices.Contains(opts.Languages, Language(r)) ||
+			!InScope(opts.Scope, r.RelPath) ||
+			len(opts.ExcludePaths) > 0 && InScope(opts.ExcludePaths, r.RelPath) ||
+			len(opts.Authors) > 0 && !authoredBy(r, opts.Authors)
+	})
+}
+
+// Language returns the language stored with r, falling back to its path for
+// documents indexed before languages were stored.
+func Language(r store.Result) string {
+	if r.Language != "" {
+		return r.Language
+	}
+
+	return index.DetectLanguage(r.Path)
+}
@@ -0,0 +1,27 @@
+package query
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMinScoreDistance(t *testing.T) {
+	tests := []struct {
+		score, want float64
+	}{
+		{score: 0, want: 2},
+		{score: 0.5, want: 1},
+		{score: -1, want: 4},
+		{score: 0.75, want: 0.5},
+	}
+	for _, tt := range tests {
+		if got := MinScoreDistance(tt.score); math.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("MinScoreDistance(%v) = %v, want %v", tt.score, got, tt.want)
+		}
+	}
+
+	// identical vectors still match a distance bound
+	if got := MinScoreDistance(1); got <= 0 {
+		t.Errorf("MinScoreDistance(1) = %v, want a positive distance", got)
+	}
+}
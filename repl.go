@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// replPreviews is how many files of the results of a query are loaded ahead
+// for show.
+const replPreviews = 5
+
+// queryREPL reads queries from stdin, one per line, and searches collection
+// for each with the same client and embedder, so only the first query pays
+// for connecting and loading the model. A failed query is reported and the
+// loop goes on. "show N" prints the whole file of result N of the last query,
+// the first results being loaded in the background as they are printed, and
+// "show" the file of the result after the last shown. Queries and the result
+// shown are kept in the session of the project.
+func queryREPL(chromaOpts ChromaOptions, collection string, opts QueryOptions, printer *Printer, logger *slog.Logger) {
+	ctx := context.Background()
+
+	client, err := newStore(chromaOpts, logger)
+	if err != nil {
+		logger.Error("Failed to create ChromaDB client", "error", err)
+		os.Exit(1)
+	}
+	defer client.Close()
+
+	coll, err := client.GetCollection(ctx, collection)
+	if err != nil {
+		logger.Error("Failed to get collection", "error", err)
+		os.Exit(1)
+	}
+	verifyEmbedder(coll, opts.Force, logger)
+
+	prompt := func() {
+		if isInteractive() {
+			fmt.Fprint(os.Stderr, "> ")
+		}
+	}
+
+	var (
+		previews = newPreviewCache(coll, replPreviews)
+		results  []QueryResult
+		position int
+		project  = resolveCollection(autoCollection, projectRoot("."))
+	)
+	scanner := bufio.NewScanner(os.Stdin)
+	for prompt(); scanner.Scan(); prompt() {
+		query := strings.TrimSpace(scanner.Text())
+		switch query {
+		case "":
+			continue
+		case "exit", "quit":
+			return
+		}
+		if n, ok := showCommand(query); ok {
+			if n == 0 {
+				n = position + 1
+			}
+			if n < 1 || n > len(results) {
+				logger.Error("Usage: show [result number]", "results", len(results))
+				continue
+			}
+			content, err := previews.Content(ctx, results[n-1])
+			if err != nil {
+				logger.Error("Failed to read file", "path", results[n-1].Path, "error", err)
+				continue
+			}
+			printer.Message("%s", content)
+			position = n
+			if err := updateSession(project, func(s *Session) { s.Position = n }); err != nil {
+				logger.Warn("Failed to save session", "error", err)
+			}
+			continue
+		}
+
+		outcome, err := searchOutcomeOf(ctx, coll, collection, query, opts, logger)
+		if err != nil {
+			logger.Error("Failed to query collection", "error", err)
+			continue
+		}
+		results, position = outcome.Results, 0
+		previews.Prefetch(ctx, results, -1, replPreviews)
+		err = updateSession(project, func(s *Session) {
+			s.Collection, s.Query, s.Filters, s.N, s.Position = collection, query, sessionFilters(opts), opts.N, 0
+		})
+		if err != nil {
+			logger.Warn("Failed to save session", "error", err)
+		}
+		if err := printOutcome(ctx, coll, collection, query, opts, outcome, printer, logger); err != nil && !errors.Is(err, errNoMatch) {
+			logger.Error("Failed to query collection", "error", err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		logger.Error("Failed to read queries", "error", err)
+		os.Exit(1)
+	}
+}
+
+// showCommand reports whether line is the show command, returning the result
+// number it names or 0 for the next one. Lines such as "show me where auth
+// happens" are queries.
+func showCommand(line string) (int, bool) {
+	arg, ok := strings.CutPrefix(line, "show")
+	if !ok || (arg != "" && arg[0] != ' ') {
+		return 0, false
+	}
+	if arg = strings.TrimSpace(arg); arg == "" {
+		return 0, true
+	}
+	n, err := strconv.Atoi(arg)
+	if err != nil {
+		return 0, false
+	}
+	if n < 1 {
+		// reported as out of range rather than taken for the next result
+		return -1, true
+	}
+	return n, true
+}
@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+func TestShowCommand(t *testing.T) {
+	tests := []struct {
+		line string
+		n    int
+		ok   bool
+	}{
+		{"show", 0, true},
+		{"show 3", 3, true},
+		{"show   12 ", 12, true},
+		{"show 0", -1, true},
+		{"show -2", -1, true},
+		{"show me where auth happens", 0, false},
+		{"showcase", 0, false},
+		{"where is show", 0, false},
+	}
+	for _, tt := range tests {
+		n, ok := showCommand(tt.line)
+		if n != tt.n || ok != tt.ok {
+			t.Errorf("showCommand(%q) = %d, %v, want %d, %v", tt.line, n, ok, tt.n, tt.ok)
+		}
+	}
+}
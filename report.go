@@ -0,0 +1,121 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/karitham/cls/dirextractor"
+)
+
+// IndexReport summarises an index run.
+type IndexReport struct {
+	Collection string `json:"collection"`
+	// Run is the ID of the index run, shared by its targets.
+	Run string `json:"run"`
+	// Target is the indexed path as given.
+	Target string `json:"target"`
+	Root   string `json:"root"`
+	// Subtree is the indexed path relative to Root, when only part of the
+	// project was indexed.
+	Subtree string `json:"subtree,omitempty"`
+	// Duplicates counts the files skipped as already indexed from an
+	// earlier target.
+	Duplicates int                `json:"duplicates,omitempty"`
+	Walk       dirextractor.Stats `json:"walk"`
+	Add        AddStats           `json:"add"`
+	Duration   time.Duration      `json:"duration_ns"`
+	// Env is the build environment, recorded with -record-env.
+	Env *BuildEnv `json:"env,omitempty"`
+}
+
+func (p *Printer) IndexSummary(r IndexReport) {
+	p.WalkStats(r.Walk)
+	if p.plain {
+		p.Message("index collection %s subtree %s added %d summaries %d read-errors %d copies %d generated %d duplicates %d other-languages %d secret-files %d secrets-masked %d failed %d duration %s",
+			r.Collection, cmp.Or(r.Subtree, "-"), r.Add.Added, r.Add.Summaries, r.Add.ReadErrors, r.Add.Copies, r.Add.Generated, r.Duplicates,
+			r.Add.OtherLanguages, r.Add.SecretFiles, r.Add.SecretsMasked, r.Add.Failed, r.Duration.Round(time.Millisecond))
+		return
+	}
+	if r.Add.ReadErrors > 0 {
+		p.Message("Failed to read %d files", r.Add.ReadErrors)
+	}
+	if r.Add.Summaries > 0 {
+		p.Message("Embedded %d chunk summaries", r.Add.Summaries)
+	}
+	if r.Add.Copies > 0 {
+		p.Message("Indexed %d files once under another path with the same content, use -keep-duplicates to index them", r.Add.Copies)
+	}
+	if r.Add.Generated > 0 {
+		p.Message("Skipped %d generated or vendored files, use -include-generated to index them", r.Add.Generated)
+	}
+	if r.Duplicates > 0 {
+		p.Message("Skipped %d files already indexed from an earlier path", r.Duplicates)
+	}
+	if r.Add.OtherLanguages > 0 {
+		p.Message("Skipped %d files in other languages", r.Add.OtherLanguages)
+	}
+	if r.Add.SecretFiles > 0 {
+		p.Message("Found secrets in %d files, %d masked", r.Add.SecretFiles, r.Add.SecretsMasked)
+	}
+	if r.Subtree != "" {
+		p.Message("Successfully indexed %d files of %s into '%s' in %s", r.Add.Added, r.Subtree, r.Collection, r.Duration.Round(time.Millisecond))
+		return
+	}
+	p.Message("Successfully indexed %d files into '%s' in %s", r.Add.Added, r.Collection, r.Duration.Round(time.Millisecond))
+}
+
+func (p *Printer) WalkStats(s dirextractor.Stats) {
+	reasons := slices.Sorted(maps.Keys(s.Skipped))
+	pruneReasons := slices.Sorted(maps.Keys(s.Pruned))
+
+	if p.plain {
+		p.Message("walk seen %d yielded %d errors %d", s.Seen, s.Yielded, s.Errors)
+		for _, r := range reasons {
+			p.Message("walk skipped %s %d", r, s.Skipped[r])
+		}
+		for _, r := range pruneReasons {
+			p.Message("walk pruned %s %d", r, s.Pruned[r])
+		}
+		return
+	}
+
+	var skipped []string
+	for _, r := range reasons {
+		skipped = append(skipped, fmt.Sprintf("%d %s", s.Skipped[r], r))
+	}
+
+	line := fmt.Sprintf("Walked %d files, kept %d", s.Seen, s.Yielded)
+	if len(skipped) > 0 {
+		line += ", skipped " + strings.Join(skipped, ", ")
+	}
+	var pruned []string
+	for _, r := range pruneReasons {
+		pruned = append(pruned, fmt.Sprintf("%d %s", s.Pruned[r], r))
+	}
+	if len(pruned) > 0 {
+		line += ", pruned directories " + strings.Join(pruned, ", ")
+	}
+	if s.Errors > 0 {
+		line += fmt.Sprintf(", %d walk errors", s.Errors)
+	}
+	p.Message("%s", line)
+}
+
+func writeJSONFile(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", path, err)
+	}
+
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+
+	"golang.org/x/sync/errgroup"
+)
+
+type RerankOptions struct {
+	Provider   string
+	Model      string
+	URL        string
+	Candidates int
+}
+
+func NewReranker(opts RerankOptions, logger *slog.Logger) (Reranker, error) {
+	switch opts.Provider {
+	case "ollama":
+		chat, err := NewChatClient("ollama", cmp.Or(opts.URL, "http://127.0.0.1:11434"), "")
+		if err != nil {
+			return nil, err
+		}
+		return &llmReranker{chat: chat, model: cmp.Or(opts.Model, "llama3.2"), logger: logger}, nil
+	case "cohere":
+		return &apiReranker{
+			url:    cmp.Or(opts.URL, "https://api.cohere.com/v2/rerank"),
+			apiKey: os.Getenv("COHERE_API_KEY"),
+			model:  cmp.Or(opts.Model, "rerank-v3.5"),
+		}, nil
+	case "jina":
+		return &apiReranker{
+			url:    cmp.Or(opts.URL, "https://api.jina.ai/v1/rerank"),
+			apiKey: os.Getenv("JINA_API_KEY"),
+			model:  cmp.Or(opts.Model, "jina-reranker-v2-base-multilingual"),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown reranker %q", opts.Provider)
+	}
+}
+
+// apiReranker talks to Cohere and Jina style rerank endpoints, which share
+// the same request and response shape.
+type apiReranker struct {
+	url    string
+	apiKey string
+	model  string
+}
+
+func (a *apiReranker) Rerank(ctx context.Context, query string, documents []string) ([]float64, error) {
+	if a.apiKey == "" {
+		return nil, fmt.Errorf("missing API key for %s", a.url)
+	}
+
+	req := map[string]any{
+		"model":     a.model,
+		"query":     query,
+		"documents": documents,
+		"top_n":     len(documents),
+	}
+
+	var resp struct {
+		Results []struct {
+			Index          int     `json:"index"`
+			RelevanceScore float64 `json:"relevance_score"`
+		} `json:"results"`
+	}
+	headers := map[string]string{"Authorization": "Bearer " + a.apiKey}
+	if err := postJSON(ctx, http.DefaultClient, a.url, headers, req, &resp); err != nil {
+		return nil, err
+	}
+
+	scores := make([]float64, len(documents))
+	for _, r := range resp.Results {
+		if r.Index >= 0 && r.Index < len(scores) {
+			scores[r.Index] = r.RelevanceScore
+		}
+	}
+
+	return scores, nil
+}
+
+// llmReranker asks a local chat model to grade each document, acting as a
+// poor man's cross-encoder: Ollama serves no rerank endpoint, so the
+// cross-encoder models it can pull cannot score pairs through it.
+type llmReranker struct {
+	chat   ChatClient
+	model  string
+	logger *slog.Logger
+}
+
+const rerankPrompt = `Rate how relevant the document is to the query on a scale from 0 to 10.
+Answer with the number only.
+
+Query: %s
+
+Document:
+%s`
+
+// scorePattern matches the grade in an answer, which models often wrap in
+// words or markup despite the prompt.
+var scorePattern = regexp.MustCompile(`\d+(?:\.\d+)?`)
+
+func (l *llmReranker) Rerank(ctx context.Context, query string, documents []string) ([]float64, error) {
+	scores := make([]float64, len(documents))
+
+	group, ctx := errgroup.WithContext(ctx)
+	group.SetLimit(4)
+
+	for i, doc := range documents {
+		group.Go(func() error {
+			answer, err := l.chat.Chat(ctx, []ChatMessage{
+				{Role: "user", Content: fmt.Sprintf(rerankPrompt, query, doc)},
+			}, ChatOptions{Model: l.model})
+			if err != nil {
+				return err
+			}
+
+			score, err := strconv.ParseFloat(scorePattern.FindString(answer), 64)
+			if err != nil {
+				// the document keeps the lowest score
+				l.logger.Warn("Reranker answered without a score", "answer", answer)
+				return nil
+			}
+			scores[i] = score
+
+			return nil
+		})
+	}
+
+	if err := group.Wait(); err != nil {
+		return nil, err
+	}
+
+	return scores, nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"iter"
+
+	"github.com/karitham/cls/store"
+)
+
+// QueryResults yields the results of text in coll best first, fetching them
+// page by page as the consumer asks for more. The page starts at the N of
+// opts and doubles, since stores rank from scratch on every query and cannot
+// resume one. Stopping the range stops fetching.
+func QueryResults(ctx context.Context, coll Collection, text string, opts ...QueryOption) iter.Seq2[QueryResult, error] {
+	return func(yield func(QueryResult, error) bool) {
+		n := max(store.NewRequest(text, opts...).N, 1)
+		yielded := 0
+		for {
+			resp, err := coll.Query(ctx, text, append(opts, store.WithN(n))...)
+			if err != nil {
+				yield(QueryResult{}, err)
+				return
+			}
+
+			for _, r := range resp.Results[min(yielded, len(resp.Results)):] {
+				if !yield(r, nil) {
+					return
+				}
+				yielded++
+			}
+
+			if len(resp.Results) < n {
+				return
+			}
+			n *= 2
+		}
+	}
+}
+
+// MergeResults merges result sequences ranked by distance, such as the
+// results of the same query in several collections, into one sequence ranked
+// by distance. Each sequence is only advanced when its head is yielded.
+func MergeResults(seqs ...iter.Seq2[QueryResult, error]) iter.Seq2[QueryResult, error] {
+	return func(yield func(QueryResult, error) bool) {
+		type head struct {
+			next func() (QueryResult, error, bool)
+			r    QueryResult
+		}
+
+		var heads []*head
+		for _, seq := range seqs {
+			next, stop := iter.Pull2(seq)
+			defer stop()
+
+			r, err, ok := next()
+			if err != nil {
+				yield(QueryResult{}, err)
+				return
+			}
+			if ok {
+				heads = append(heads, &head{next: next, r: r})
+			}
+		}
+
+		for len(heads) > 0 {
+			best := 0
+			for i, h := range heads {
+				if h.r.Distance < heads[best].r.Distance {
+					best = i
+				}
+			}
+
+			h := heads[best]
+			if !yield(h.r, nil) {
+				return
+			}
+
+			r, err, ok := h.next()
+			switch {
+			case err != nil:
+				yield(QueryResult{}, err)
+				return
+			case ok:
+				h.r = r
+			default:
+				heads = append(heads[:best], heads[best+1:]...)
+			}
+		}
+	}
+}
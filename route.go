@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/karitham/cls/query"
+)
+
+// Route sends the files matching its globs to a collection of their own, so
+// that content types such as docs and code are kept apart, all filled by a
+// single walk of an index run. Routes are read from the .cls.toml of
+// the project, given either as dotted keys or as a [route] table named after
+// their collection:
+//
+//	route.docs = ["docs/**", "**/*.md"]
+//
+//	[route]
+//	code = ["src/**"]
+//
+// A file goes to the first route it matches, and files no route matches to
+// the collection of the project. Routes only pick the collection: files are
+// chunked and embedded with the same settings whichever route they take.
+type Route struct {
+	Collection string
+	Patterns   []string
+	// globs are the compiled Patterns.
+	globs []*regexp.Regexp
+}
+
+// allRoutes is the -route value querying every route and the project
+// collection at once.
+const allRoutes = "all"
+
+// loadRoutes returns the routes of the project at root, in the order they are
+// given.
+func loadRoutes(root string) ([]Route, error) {
+	globs, err := loadGlobs(root, "route")
+	if err != nil {
+		return nil, err
+	}
+
+	routes := make([]Route, 0, len(globs))
+	for _, g := range globs {
+		r := Route{Collection: g.Name, Patterns: g.Patterns}
+		for _, p := range g.Patterns {
+			re, err := query.Glob(p)
+			if err != nil {
+				return nil, fmt.Errorf("route %s: invalid glob %q: %w", g.Name, p, err)
+			}
+			r.globs = append(r.globs, re)
+		}
+		routes = append(routes, r)
+	}
+
+	return routes, nil
+}
+
+// routeOf returns the collection of the first of routes matching the slash
+// separated relPath, or "" when none does.
+func routeOf(routes []Route, relPath string) string {
+	for _, r := range routes {
+		for _, re := range r.globs {
+			if re.MatchString(relPath) {
+				return r.Collection
+			}
+		}
+	}
+
+	return ""
+}
+
+// routeCollections returns the collections of the routes named by query
+// -route in the project at root, all standing for every route and the
+// project collection.
+func routeCollections(root string, names []string, project string) ([]string, error) {
+	routes, err := loadRoutes(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var collections []string
+	for _, name := range names {
+		if name == allRoutes {
+			collections = append(collections, project)
+			for _, r := range routes {
+				collections = append(collections, r.Collection)
+			}
+			continue
+		}
+
+		found := false
+		for _, r := range routes {
+			if r.Collection == name {
+				collections = append(collections, r.Collection)
+				found = true
+			}
+		}
+		if !found {
+			known := make([]string, len(routes))
+			for i, r := range routes {
+				known[i] = r.Collection
+			}
+			return nil, fmt.Errorf("no route %q in %s, known routes: %s", name, filepath.Join(root, configFile), strings.Join(known, ", "))
+		}
+	}
+
+	return collections, nil
+}
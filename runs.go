@@ -0,0 +1,123 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+// runIDLayout is the start time prefix of run IDs.
+const runIDLayout = "20060102-150405"
+
+// maxRuns is how many runs are remembered per collection.
+const maxRuns = 100
+
+// Run is an index run, as listed by runs list.
+type Run struct {
+	ID        string        `json:"id"`
+	StartedAt time.Time     `json:"started_at"`
+	Duration  time.Duration `json:"duration"`
+	Targets   []string      `json:"targets"`
+	// Added counts the documents the run wrote. A later run writing the
+	// same documents takes them over.
+	Added int `json:"added"`
+}
+
+func runsState(collection string) string {
+	return "runs-" + collection + ".json"
+}
+
+// newRunID returns an ID sorting by start time, with a random suffix telling
+// apart runs started the same second.
+func newRunID(t time.Time) string {
+	suffix := make([]byte, 2)
+	rand.Read(suffix)
+
+	return t.UTC().Format(runIDLayout) + "-" + hex.EncodeToString(suffix)
+}
+
+// loadRuns returns the runs recorded for collection, oldest first.
+func loadRuns(collection string) ([]Run, error) {
+	var runs []Run
+	if err := readState(runsState(collection), &runs); err != nil {
+		return nil, err
+	}
+
+	return runs, nil
+}
+
+// recordRun adds run to the runs of collection, forgetting the oldest past
+// maxRuns.
+func recordRun(collection string, run Run) error {
+	runs, err := loadRuns(collection)
+	if err != nil {
+		return err
+	}
+
+	runs = append(runs, run)
+	return writeState(runsState(collection), runs[max(len(runs)-maxRuns, 0):])
+}
+
+// forgetRun removes the run id from the runs of collection.
+func forgetRun(collection, id string) error {
+	runs, err := loadRuns(collection)
+	if err != nil {
+		return err
+	}
+
+	kept := runs[:0]
+	for _, r := range runs {
+		if r.ID != id {
+			kept = append(kept, r)
+		}
+	}
+
+	return writeState(runsState(collection), kept)
+}
+
+func runsCommand(collection string, args []string, printer *Printer, logger *slog.Logger) {
+	if len(args) < 1 || args[0] != "list" {
+		logger.Error("Please provide a subcommand: list")
+		os.Exit(1)
+	}
+
+	runs, err := loadRuns(collection)
+	if err != nil {
+		logger.Error("Failed to load runs", "error", err)
+		os.Exit(1)
+	}
+
+	printer.Runs(runs)
+}
+
+func (p *Printer) Runs(runs []Run) {
+	if len(runs) == 0 {
+		p.Message("No index runs recorded")
+		return
+	}
+
+	for i := len(runs) - 1; i >= 0; i-- {
+		r := runs[i]
+		if p.plain {
+			p.Message("run %s started %s documents %d targets %s", r.ID, r.StartedAt.UTC().Format(time.RFC3339), r.Added, strings.Join(r.Targets, ","))
+			continue
+		}
+		p.Message("%s  %s  %6d documents  %s", r.ID, r.StartedAt.Local().Format(time.DateTime), r.Added, strings.Join(r.Targets, ", "))
+	}
+}
+
+// checkRunID rejects IDs not made by newRunID.
+func checkRunID(id string) error {
+	if len(id) != len(runIDLayout)+5 {
+		return fmt.Errorf("invalid run id %q, see runs list", id)
+	}
+	if _, err := time.Parse(runIDLayout, id[:len(runIDLayout)]); err != nil {
+		return fmt.Errorf("invalid run id %q, see runs list", id)
+	}
+
+	return nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const configFile = ".cls.toml"
+
+// loadScopes reads the scope profiles of the .cls.toml at root, given either
+// as dotted keys or as a [scope] table:
+//
+//	scope.backend = ["services/api/**", "pkg/**"]
+//
+//	[scope]
+//	frontend = ["web/**"]
+//
+// Only scope keys are read, the rest of the file is left to other tools.
+func loadScopes(root string) (map[string][]string, error) {
+	globs, err := loadGlobs(root, "scope")
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := map[string][]string{}
+	for _, g := range globs {
+		scopes[g.Name] = g.Patterns
+	}
+
+	return scopes, nil
+}
+
+// namedGlobs are the patterns given to a key of a .cls.toml table.
+type namedGlobs struct {
+	Name     string
+	Patterns []string
+}
+
+// loadGlobs reads the keys of table in the .cls.toml at root, in the order
+// they are given. A key given twice keeps its last patterns.
+func loadGlobs(root, table string) ([]namedGlobs, error) {
+	f, err := os.Open(filepath.Join(root, configFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open config: %w", err)
+	}
+	defer f.Close()
+
+	var globs []namedGlobs
+	current := ""
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			current = strings.TrimSpace(strings.Trim(line, "[]"))
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		if current != "" {
+			key = current + "." + key
+		}
+
+		name, ok := strings.CutPrefix(key, table+".")
+		if !ok {
+			continue
+		}
+		name = strings.Trim(name, `"`)
+		patterns, err := parseStringArray(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %s %s: %w", configFile, n, table, name, err)
+		}
+		globs = slices.DeleteFunc(globs, func(g namedGlobs) bool { return g.Name == name })
+		globs = append(globs, namedGlobs{Name: name, Patterns: patterns})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	return globs, nil
+}
+
+// parseStringArray parses a single line TOML array of strings.
+func parseStringArray(s string) ([]string, error) {
+	if i := strings.LastIndex(s, "]"); i >= 0 {
+		s = s[:i+1] // drop trailing comments
+	}
+	inner, ok := strings.CutPrefix(s, "[")
+	if inner, ok = strings.CutSuffix(inner, "]"); !ok {
+		return nil, fmt.Errorf("expected an array of strings on one line")
+	}
+
+	var values []string
+	for _, item := range strings.Split(inner, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if strings.HasPrefix(item, "'") && strings.HasSuffix(item, "'") && len(item) > 1 {
+			values = append(values, item[1:len(item)-1])
+			continue
+		}
+		v, err := strconv.Unquote(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid string %s", item)
+		}
+		values = append(values, v)
+	}
+
+	return values, nil
+}
+
+// loadScope returns the patterns of the scope name of the project at root.
+func loadScope(root, name string) ([]string, error) {
+	scopes, err := loadScopes(root)
+	if err != nil {
+		return nil, err
+	}
+
+	patterns, ok := scopes[name]
+	if !ok {
+		names := make([]string, 0, len(scopes))
+		for n := range scopes {
+			names = append(names, n)
+		}
+		slices.Sort(names)
+		return nil, fmt.Errorf("no scope %q in %s, known scopes: %s", name, filepath.Join(root, configFile), strings.Join(names, ", "))
+	}
+
+	return patterns, nil
+}
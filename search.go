@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/karitham/cls/query"
+)
+
+type QueryOptions struct {
+	N          int
+	Rerank     RerankOptions
+	Diversity  float64
+	Calibrated bool
+	NoFallback bool
+	// Exclude lists paths dropped from the results.
+	Exclude []string `json:"-"`
+	// ExcludeLicenses lists SPDX license ids dropped from the results.
+	ExcludeLicenses []string
+	// Force queries collections built with another embedder.
+	Force bool `json:"-"`
+	// AutoPruneMissing deletes the results whose file no longer exists.
+	AutoPruneMissing bool
+	// Languages keeps only results of these languages when set.
+	Languages []string
+	// Scope keeps only results whose path matches one of these globs when
+	// set.
+	Scope []string
+	// Alternatives are other queries run along the query, and Expand adds
+	// LLM paraphrases of it. Results are merged with rank fusion.
+	Alternatives []string
+	Expand       ExpandOptions
+	// Not lists phrases results are pushed away from, by NotWeight.
+	Not       []string
+	NotWeight float64
+	// MinScore drops the results less similar to the query than it, as a
+	// cosine similarity.
+	MinScore float64
+	// ExcludePaths drops the results whose path matches one of these globs.
+	ExcludePaths []string
+	// Authors keeps the results mostly written by one of these authors, for
+	// collections indexed with git blame.
+	Authors []string
+	// Callers lists up to this many call sites of the functions defined in
+	// each result.
+	Callers int
+	// Target is the space searched, one of targets.
+	Target string
+	// Run keeps the documents last written by this index run when set.
+	Run string
+}
+
+// searchOptions returns the options of query.Search set by opts, to which
+// Search adds the paraphrases, calibrated cutoff and reranker opts selects.
+func (opts QueryOptions) searchOptions() query.Options {
+	return query.Options{
+		N:               opts.N,
+		Diversity:       opts.Diversity,
+		Exclude:         opts.Exclude,
+		ExcludeLicenses: opts.ExcludeLicenses,
+		Languages:       opts.Languages,
+		Scope:           opts.Scope,
+		ExcludePaths:    opts.ExcludePaths,
+		Authors:         opts.Authors,
+		Alternatives:    opts.Alternatives,
+		Not:             opts.Not,
+		NotWeight:       opts.NotWeight,
+		MinScore:        opts.MinScore,
+		Target:          opts.Target,
+		Run:             opts.Run,
+	}
+}
+
+// exclude drops the results whose path, language, license or author opts
+// excludes.
+func (opts QueryOptions) exclude(results []QueryResult) []QueryResult {
+	return query.Exclude(results, opts.searchOptions())
+}
+
+// Search runs text against coll with query.Search, adding the LLM
+// paraphrases, feedback calibration and reranker selected in opts.
+func Search(ctx context.Context, coll Collection, collection, text string, opts QueryOptions, logger *slog.Logger) ([]QueryResult, error) {
+	search := opts.searchOptions()
+	if opts.Expand.N > 0 {
+		paraphrases, err := expandQuery(ctx, text, opts.Expand)
+		if err != nil {
+			logger.Warn("Failed to expand query, searching without paraphrases", "error", err)
+		}
+		logger.Debug("Expanded query", "paraphrases", paraphrases)
+		search.Alternatives = slices.Concat(search.Alternatives, paraphrases)
+	}
+	if opts.Calibrated {
+		judgments, err := loadJudgments(collection)
+		if err != nil {
+			logger.Warn("Failed to load feedback", "error", err)
+		}
+
+		if cutoff, ok := Calibrate(judgments); ok {
+			search.MaxDistance = cutoff
+		} else {
+			logger.Warn("Not enough feedback to calibrate", "collection", collection)
+		}
+	}
+	if opts.Rerank.Provider != "" {
+		reranker, err := NewReranker(opts.Rerank, logger)
+		if err != nil {
+			return nil, err
+		}
+		search.Reranker, search.Candidates = reranker, opts.Rerank.Candidates
+	}
+
+	return query.Search(ctx, coll, text, search)
+}
+
+// SearchFallback is used when Search found nothing. It tries, in order,
+// relaxing filters, an expanded query and a plain keyword search, returning
+// the results of the first strategy that yields any along with its label.
+// The keyword search is left out when opts.MinScore is set, as its matches
+// have no similarity to hold against it.
+func SearchFallback(ctx context.Context, coll Collection, collection, query string, opts QueryOptions, logger *slog.Logger) ([]QueryResult, string, error) {
+	if opts.Calibrated {
+		relaxed := opts
+		relaxed.Calibrated = false
+
+		results, err := Search(ctx, coll, collection, query, relaxed, logger)
+		if err != nil {
+			return nil, "", err
+		}
+		if len(results) > 0 {
+			return results, "relaxed filters", nil
+		}
+	}
+
+	terms := queryTerms(query)
+	if expanded := strings.Join(expandTerms(terms), " "); expanded != "" && expanded != query {
+		results, err := Search(ctx, coll, collection, expanded, opts, logger)
+		if err != nil {
+			return nil, "", err
+		}
+		if len(results) > 0 {
+			return results, "expanded query " + strconv.Quote(expanded), nil
+		}
+	}
+
+	if opts.MinScore > 0 {
+		return nil, "", nil
+	}
+	results, err := coll.KeywordSearch(ctx, terms, opts.N)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return opts.exclude(results), "keyword search", nil
+}
+
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true, "by": true,
+	"do": true, "does": true, "for": true, "from": true, "how": true, "i": true, "in": true, "is": true,
+	"it": true, "of": true, "on": true, "or": true, "the": true, "this": true, "to": true, "what": true,
+	"where": true, "which": true, "who": true, "why": true, "with": true,
+}
+
+// queryTerms splits query into its significant words.
+func queryTerms(query string) []string {
+	var terms []string
+	for _, w := range strings.FieldsFunc(query, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	}) {
+		if !stopWords[strings.ToLower(w)] && !slices.Contains(terms, w) {
+			terms = append(terms, w)
+		}
+	}
+	return terms
+}
+
+// expandTerms adds the identifier spellings of terms commonly found in code:
+// camelCase and snake_case parts are split apart, and multiple words are also
+// joined into both styles.
+func expandTerms(terms []string) []string {
+	out := slices.Clone(terms)
+	add := func(s string) {
+		if s != "" && !slices.Contains(out, s) {
+			out = append(out, s)
+		}
+	}
+
+	var words []string
+	for _, t := range terms {
+		parts := splitIdentifier(t)
+		for _, p := range parts {
+			add(strings.ToLower(p))
+		}
+		words = append(words, parts...)
+	}
+
+	if len(words) > 1 {
+		lower := make([]string, len(words))
+		for i, w := range words {
+			lower[i] = strings.ToLower(w)
+		}
+		add(strings.Join(lower, "_"))
+
+		camel := lower[0]
+		for _, w := range lower[1:] {
+			r, size := utf8.DecodeRuneInString(w)
+			camel += string(unicode.ToUpper(r)) + w[size:]
+		}
+		add(camel)
+	}
+
+	return out
+}
+
+// splitIdentifier splits camelCase and snake_case identifiers into words.
+func splitIdentifier(s string) []string {
+	var (
+		words []string
+		cur   []rune
+	)
+	runes := []rune(s)
+	for i, r := range runes {
+		switch {
+		case r == '_' || r == '-':
+			if len(cur) > 0 {
+				words = append(words, string(cur))
+			}
+			cur = nil
+			continue
+		case unicode.IsUpper(r) && len(cur) > 0 && (unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))):
+			words = append(words, string(cur))
+			cur = nil
+		}
+		cur = append(cur, r)
+	}
+	if len(cur) > 0 {
+		words = append(words, string(cur))
+	}
+
+	return words
+}
+
+// markMissing flags the results whose path no longer exists on disk and
+// returns them.
+func markMissing(results []QueryResult) []QueryResult {
+	var missing []QueryResult
+	for i, r := range results {
+		if r.Path == "" || r.Unresolved {
+			continue
+		}
+		if _, err := os.Stat(r.Path); errors.Is(err, fs.ErrNotExist) {
+			results[i].Missing = true
+			missing = append(missing, results[i])
+		}
+	}
+
+	return missing
+}
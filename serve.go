@@ -0,0 +1,488 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/karitham/cls/buildinfo"
+	"github.com/karitham/cls/index"
+	clsv1 "github.com/karitham/cls/proto/cls/v1"
+)
+
+// grpcServer serves the gRPC API of proto/cls/v1 for one store. Index runs
+// are serialized, each holding the state files of its collection, while
+// queries run concurrently.
+type grpcServer struct {
+	client ChromaClient
+	// collection is queried when a request names none, and opts are the
+	// query options requests start from.
+	collection string
+	opts       QueryOptions
+	// roots are the absolute paths of the trees clients may index, any
+	// other path being refused.
+	roots  []string
+	logger *slog.Logger
+
+	indexMu sync.Mutex
+}
+
+// serveGRPC serves the gRPC API on addr until interrupted, indexing only
+// paths under roots.
+func serveGRPC(chromaOpts ChromaOptions, collection, addr string, roots []string, opts QueryOptions, logger *slog.Logger) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	client, err := newStore(chromaOpts, logger)
+	if err != nil {
+		logger.Error("Failed to create ChromaDB client", "error", err)
+		os.Exit(1)
+	}
+	defer client.Close()
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		logger.Error("Failed to listen", "addr", addr, "error", err)
+		os.Exit(1)
+	}
+
+	s := &grpcServer{client: client, collection: collection, opts: opts, logger: logger}
+	for _, root := range roots {
+		s.roots = append(s.roots, realPath(root))
+	}
+	server := grpc.NewServer()
+	clsv1.RegisterIndexServer(server, indexService{grpcServer: s})
+	clsv1.RegisterQueryServer(server, queryService{grpcServer: s})
+	clsv1.RegisterAdminServer(server, adminService{grpcServer: s})
+	go func() {
+		<-ctx.Done()
+		server.GracefulStop()
+	}()
+
+	logger.Info("Serving gRPC", "addr", l.Addr().String())
+	if err := server.Serve(l); err != nil {
+		logger.Error("Failed to serve gRPC", "error", err)
+		os.Exit(1)
+	}
+	logger.Info("Server stopped")
+}
+
+// allowed reports whether path is one of the roots clients may index or
+// under one, symbolic links resolved.
+func (s *grpcServer) allowed(path string) bool {
+	path = realPath(path)
+	for _, root := range s.roots {
+		if rel, err := filepath.Rel(root, path); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// realPath returns the absolute form of path with its symbolic links
+// resolved, or its absolute form when it cannot be resolved.
+func realPath(path string) string {
+	if real, err := filepath.EvalSymlinks(absPath(path)); err == nil {
+		return real
+	}
+
+	return absPath(path)
+}
+
+// grpcError converts err to a status, keeping the status of errors that
+// already are one.
+func grpcError(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	if errors.Is(err, context.Canceled) {
+		return status.Error(codes.Canceled, err.Error())
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+
+	return status.Error(codes.Internal, err.Error())
+}
+
+type indexService struct {
+	*grpcServer
+	clsv1.UnimplementedIndexServer
+}
+
+func (s indexService) Index(ctx context.Context, req *clsv1.IndexRequest) (*clsv1.IndexResponse, error) {
+	run, stats, err := s.index(ctx, req, nil)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	return &clsv1.IndexResponse{RunId: run, Stats: protoStats(stats)}, nil
+}
+
+func (s indexService) Watch(req *clsv1.IndexRequest, stream grpc.ServerStreamingServer[clsv1.ProgressEvent]) error {
+	// events are sent from the goroutines of the indexer, one at a time
+	var mu sync.Mutex
+	progress := index.NewProgressFunc(func(e ProgressEvent) {
+		if e.Phase == index.PhaseDone {
+			// sent with the stats once every target is indexed
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if err := stream.Send(protoEvent(e)); err != nil {
+			s.logger.Debug("Failed to send progress", "error", err)
+		}
+	}, s.logger)
+
+	_, stats, err := s.index(stream.Context(), req, progress)
+	if err != nil {
+		return grpcError(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	return stream.Send(&clsv1.ProgressEvent{Phase: index.PhaseDone, Percent: 100, Stats: protoStats(stats)})
+}
+
+// index indexes the paths of req as index does, and returns the ID of the
+// run and its stats.
+func (s indexService) index(ctx context.Context, req *clsv1.IndexRequest, progress *Progress) (string, AddStats, error) {
+	if len(req.Paths) == 0 {
+		return "", AddStats{}, status.Error(codes.InvalidArgument, "no path to index")
+	}
+	for _, path := range req.Paths {
+		if !s.allowed(path) {
+			return "", AddStats{}, status.Errorf(codes.PermissionDenied, "%s is not under a root served for indexing", path)
+		}
+	}
+	collection := cmp.Or(req.Collection, resolveCollection(autoCollection, projectRoot(req.Paths[0])))
+
+	s.indexMu.Lock()
+	defer s.indexMu.Unlock()
+
+	var (
+		coll    Collection
+		symbols *SymbolIndex
+		version string
+		swapped bool
+		err     error
+	)
+	defer func() {
+		if version != "" && !swapped {
+			// the collection in use is kept rather than a partial rebuild
+			if err := s.client.DeleteCollection(context.WithoutCancel(ctx), version); err != nil {
+				s.logger.Warn("Failed to delete the partial rebuild", "version", version, "error", err)
+			}
+		}
+	}()
+	if req.Replace {
+		if protected, err := s.client.IsProtected(ctx, collection); err == nil && protected {
+			return "", AddStats{}, status.Errorf(codes.FailedPrecondition, "collection %s is protected", collection)
+		}
+		if version, coll, err = s.client.NextVersion(ctx, collection); err != nil {
+			return "", AddStats{}, err
+		}
+		symbols = newSymbolIndex(collection)
+	} else {
+		if coll, err = s.client.GetOrCreateCollection(ctx, collection); err != nil {
+			return "", AddStats{}, err
+		}
+		if symbols, err = loadSymbols(collection); err != nil {
+			s.logger.Warn("Failed to load symbol index, rebuilding it", "error", err)
+			symbols = newSymbolIndex(collection)
+		}
+	}
+
+	secrets, err := index.NewSecretPolicy(index.SecretsMask, "")
+	if err != nil {
+		return "", AddStats{}, err
+	}
+	run := Run{StartedAt: time.Now(), Targets: req.Paths}
+	run.ID = newRunID(run.StartedAt)
+	walkOpts := WalkOptions{IncludeGenerated: req.IncludeGenerated}
+
+	var total AddStats
+	for _, path := range req.Paths {
+		walker, err := newWalker(path, walkOpts)
+		if err != nil {
+			return "", total, status.Errorf(codes.InvalidArgument, "failed to walk %s: %v", path, err)
+		}
+		root := projectRoot(path)
+		rel := func(p string) string { return index.RelativePath(root, p) }
+		// files are added as they are walked, as index does, so the total
+		// is estimated by a walk ahead
+		var estimated int
+		if progress.Wanted() {
+			if est, err := estimateTree(path, TreeLimits{}); err == nil {
+				estimated = est.Files
+			}
+		}
+		paths := func(yield func(string) bool) {
+			for f := range walkFiles(walker, walkOpts, rel, s.logger) {
+				if !yield(f.Path) {
+					return
+				}
+			}
+		}
+
+		stats, err := AddDocuments(ctx, coll, paths, AddOptions{Options: index.Options{
+			Progress:         progress,
+			EstimatedFiles:   estimated,
+			Root:             root,
+			Secrets:          secrets,
+			Languages:        req.Languages,
+			IncludeGenerated: req.IncludeGenerated,
+			Blame:            req.Blame,
+			KeepDuplicates:   req.KeepDuplicates,
+			Symbols:          symbols,
+			RunID:            run.ID,
+		}}, s.logger)
+		total = addStats(total, stats)
+		if err != nil {
+			return "", total, err
+		}
+	}
+
+	if req.Replace {
+		if total.Failures() > 0 {
+			return "", total, status.Errorf(codes.Aborted, "failed to rebuild collection %s, %d files or documents failed", collection, total.Failures())
+		}
+		if err := s.client.SwapAlias(ctx, collection, version); err != nil {
+			return "", total, err
+		}
+		swapped = true
+	}
+	if err := symbols.Save(); err != nil {
+		s.logger.Warn("Failed to save symbol index", "error", err)
+	}
+	run.Added = total.Added + total.Summaries
+	run.Duration = time.Since(run.StartedAt)
+	if err := recordRun(collection, run); err != nil {
+		s.logger.Warn("Failed to record index run", "error", err)
+	}
+	s.logger.Info("Indexed", "collection", collection, "run", run.ID, "added", total.Added, "took", run.Duration)
+
+	return run.ID, total, nil
+}
+
+// addStats sums the stats of two targets.
+func addStats(a, b AddStats) AddStats {
+	return AddStats{
+		Added:          a.Added + b.Added,
+		ReadErrors:     a.ReadErrors + b.ReadErrors,
+		SecretsMasked:  a.SecretsMasked + b.SecretsMasked,
+		SecretFiles:    a.SecretFiles + b.SecretFiles,
+		OtherLanguages: a.OtherLanguages + b.OtherLanguages,
+		Generated:      a.Generated + b.Generated,
+		Summaries:      a.Summaries + b.Summaries,
+		Copies:         a.Copies + b.Copies,
+		Failed:         a.Failed + b.Failed,
+	}
+}
+
+func protoStats(s AddStats) *clsv1.IndexStats {
+	return &clsv1.IndexStats{
+		Added:          int64(s.Added),
+		ReadErrors:     int64(s.ReadErrors),
+		SecretsMasked:  int64(s.SecretsMasked),
+		SecretFiles:    int64(s.SecretFiles),
+		OtherLanguages: int64(s.OtherLanguages),
+		Generated:      int64(s.Generated),
+		Summaries:      int64(s.Summaries),
+		Copies:         int64(s.Copies),
+		Failed:         int64(s.Failed),
+	}
+}
+
+func protoEvent(e ProgressEvent) *clsv1.ProgressEvent {
+	return &clsv1.ProgressEvent{
+		Phase:   e.Phase,
+		Percent: e.Percent,
+		Done:    int64(e.Done),
+		Total:   int64(e.Total),
+		Current: e.Current,
+	}
+}
+
+type queryService struct {
+	*grpcServer
+	clsv1.UnimplementedQueryServer
+}
+
+// Query searches the collections of req, merging their results as query
+// -collection does.
+func (s queryService) Query(ctx context.Context, req *clsv1.QueryRequest) (*clsv1.QueryResponse, error) {
+	if req.Query == "" {
+		return nil, status.Error(codes.InvalidArgument, "no query")
+	}
+
+	opts := s.opts
+	opts.N = cmp.Or(int(req.N), opts.N)
+	opts.MinScore = cmp.Or(req.MinScore, opts.MinScore)
+	// filters of the request replace those of the server
+	for _, f := range []struct{ dst, src *[]string }{
+		{&opts.Languages, &req.Languages},
+		{&opts.Scope, &req.Scope},
+		{&opts.ExcludePaths, &req.ExcludePaths},
+		{&opts.Not, &req.Not},
+	} {
+		if len(*f.src) > 0 {
+			*f.dst = *f.src
+		}
+	}
+	if req.Run != "" {
+		if err := checkRunID(req.Run); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		opts.Run = req.Run
+	}
+	if req.Rerank && opts.Rerank.Provider == "" {
+		return nil, status.Error(codes.FailedPrecondition, "the server has no -rerank provider")
+	}
+	if !req.Rerank {
+		opts.Rerank.Provider = ""
+	}
+
+	collections := req.Collections
+	if len(collections) == 0 {
+		collections = []string{s.collection}
+	}
+	var results []QueryResult
+	for _, name := range collections {
+		found, err := searchCollection(ctx, s.client, name, req.Query, opts, s.logger)
+		if err != nil {
+			return nil, grpcError(fmt.Errorf("failed to query %s: %w", name, err))
+		}
+		results = append(results, found...)
+	}
+	slices.SortStableFunc(results, func(a, b QueryResult) int {
+		if opts.Rerank.Provider != "" {
+			return cmp.Compare(b.Score, a.Score)
+		}
+		return cmp.Compare(a.Distance, b.Distance)
+	})
+	results = results[:min(len(results), opts.N)]
+	locateMatches(results, expandTerms(queryTerms(req.Query)))
+
+	resp := &clsv1.QueryResponse{Results: make([]*clsv1.QueryResult, len(results))}
+	for i, r := range results {
+		resp.Results[i] = protoResult(r)
+	}
+
+	return resp, nil
+}
+
+func protoResult(r QueryResult) *clsv1.QueryResult {
+	result := &clsv1.QueryResult{
+		Id:         r.ID,
+		Collection: r.Collection,
+		Path:       r.Path,
+		RelPath:    r.RelPath,
+		Content:    r.Content,
+		Distance:   r.Distance,
+		Score:      r.Score,
+		Language:   r.Language,
+		StartLine:  int32(r.StartLine),
+		EndLine:    int32(r.EndLine),
+		Line:       int32(r.Line),
+		Section:    r.Section,
+		Title:      r.Title,
+		Record:     int32(r.Record),
+		License:    r.License,
+		Author:     r.Author,
+		Commit:     r.Commit,
+		Duplicates: r.Duplicates,
+	}
+	if !r.ModTime.IsZero() {
+		result.ModTime = timestamppb.New(r.ModTime)
+	}
+
+	return result
+}
+
+type adminService struct {
+	*grpcServer
+	clsv1.UnimplementedAdminServer
+}
+
+func (s adminService) ListCollections(ctx context.Context, req *clsv1.ListCollectionsRequest) (*clsv1.ListCollectionsResponse, error) {
+	infos, err := s.client.ListCollections(ctx)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	resp := &clsv1.ListCollectionsResponse{Collections: make([]*clsv1.Collection, len(infos))}
+	for i, info := range infos {
+		resp.Collections[i] = &clsv1.Collection{
+			Name:      info.Name,
+			Count:     int64(info.Count),
+			Managed:   info.Managed,
+			Protected: info.Protected,
+			Alias:     info.Alias,
+		}
+	}
+
+	return resp, nil
+}
+
+// DeleteCollection deletes a collection as delete does, refusing protected
+// ones unless forced.
+func (s adminService) DeleteCollection(ctx context.Context, req *clsv1.DeleteCollectionRequest) (*clsv1.DeleteCollectionResponse, error) {
+	if req.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "no collection name")
+	}
+	protected, err := s.client.IsProtected(ctx, req.Name)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	if protected && !req.Force {
+		return nil, status.Errorf(codes.FailedPrecondition, "collection %s is protected", req.Name)
+	}
+
+	if err := s.client.DeleteCollection(ctx, req.Name); err != nil {
+		return nil, grpcError(err)
+	}
+	s.logger.Info("Deleted collection", "collection", req.Name)
+
+	return &clsv1.DeleteCollectionResponse{}, nil
+}
+
+func (s adminService) SetProtected(ctx context.Context, req *clsv1.SetProtectedRequest) (*clsv1.SetProtectedResponse, error) {
+	if err := s.client.SetProtected(ctx, req.Name, req.Protected); err != nil {
+		return nil, grpcError(err)
+	}
+
+	return &clsv1.SetProtectedResponse{}, nil
+}
+
+// Version returns the build information of the server, the features its
+// binary was built with included. It stands in for an HTTP /about endpoint,
+// cls serving no HTTP API.
+func (s adminService) Version(ctx context.Context, req *clsv1.VersionRequest) (*clsv1.VersionResponse, error) {
+	info := buildinfo.Get()
+
+	return &clsv1.VersionResponse{
+		Version:   info.Version,
+		Commit:    info.Commit,
+		Date:      info.Date,
+		GoVersion: info.GoVersion,
+		Features:  info.Features,
+	}, nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import "time"
+
+const sessionsState = "sessions.json"
+
+// Session is the last search of a project, so that returning to a project
+// picks the search up where it was left. Only what locates the search is
+// kept, not how it was run.
+type Session struct {
+	Collection string         `json:"collection"`
+	Query      string         `json:"query"`
+	Filters    SessionFilters `json:"filters"`
+	N          int            `json:"n,omitempty"`
+	// Position is the last result shown by query -i.
+	Position int `json:"position,omitempty"`
+	// Excluded are paths left out of the results of every query.
+	Excluded []string  `json:"excluded,omitempty"`
+	Updated  time.Time `json:"updated"`
+}
+
+// SessionFilters are the filters of the search of a session.
+type SessionFilters struct {
+	Languages       []string `json:"languages,omitempty"`
+	Scope           []string `json:"scope,omitempty"`
+	ExcludePaths    []string `json:"exclude_paths,omitempty"`
+	ExcludeLicenses []string `json:"exclude_licenses,omitempty"`
+	Authors         []string `json:"authors,omitempty"`
+	Run             string   `json:"run,omitempty"`
+	Target          string   `json:"target,omitempty"`
+}
+
+func sessionFilters(opts QueryOptions) SessionFilters {
+	return SessionFilters{
+		Languages:       opts.Languages,
+		Scope:           opts.Scope,
+		ExcludePaths:    opts.ExcludePaths,
+		ExcludeLicenses: opts.ExcludeLicenses,
+		Authors:         opts.Authors,
+		Run:             opts.Run,
+		Target:          opts.Target,
+	}
+}
+
+// resume returns opts with the result count and filters of s, except those
+// of the flags given now, which are named in given.
+func (s Session) resume(opts QueryOptions, given map[string]bool) QueryOptions {
+	if !given["n"] && s.N > 0 {
+		opts.N = s.N
+	}
+	if !given["lang"] {
+		opts.Languages = s.Filters.Languages
+	}
+	if !given["scope"] {
+		opts.Scope = s.Filters.Scope
+	}
+	if !given["exclude-path"] {
+		opts.ExcludePaths = s.Filters.ExcludePaths
+	}
+	if !given["exclude-license"] {
+		opts.ExcludeLicenses = s.Filters.ExcludeLicenses
+	}
+	if !given["author"] {
+		opts.Authors = s.Filters.Authors
+	}
+	if !given["run"] {
+		opts.Run = s.Filters.Run
+	}
+	if !given["target"] && s.Filters.Target != "" {
+		opts.Target = s.Filters.Target
+	}
+
+	return opts
+}
+
+// loadSession returns the last session of project, reporting false when
+// there is none.
+func loadSession(project string) (Session, bool, error) {
+	sessions := map[string]Session{}
+	if err := readState(sessionsState, &sessions); err != nil {
+		return Session{}, false, err
+	}
+
+	s, ok := sessions[project]
+	return s, ok, nil
+}
+
+func saveSession(project string, s Session) error {
+	return updateSession(project, func(old *Session) { *old = s })
+}
+
+// updateSession applies fn to the session of project and saves it.
+func updateSession(project string, fn func(*Session)) error {
+	sessions := map[string]Session{}
+	if err := readState(sessionsState, &sessions); err != nil {
+		return err
+	}
+
+	s := sessions[project]
+	fn(&s)
+	s.Updated = time.Now().UTC()
+	sessions[project] = s
+
+	return writeState(sessionsState, sessions)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/karitham/cls/index"
+)
+
+var selectionPattern = regexp.MustCompile(`^(.+):([0-9]+)-([0-9]+)$`)
+
+// Selection is a file, or lines start to end of it when End is set, used as
+// the example of a similarity search. The path "-" reads stdin.
+type Selection struct {
+	Path       string
+	Start, End int
+}
+
+// ParseSelection parses "<file>[:start-end]".
+func ParseSelection(s string) (Selection, error) {
+	m := selectionPattern.FindStringSubmatch(s)
+	if m == nil {
+		return Selection{Path: s}, nil
+	}
+
+	start, _ := strconv.Atoi(m[2])
+	end, _ := strconv.Atoi(m[3])
+	if start < 1 || end < start {
+		return Selection{}, fmt.Errorf("invalid line range %s-%s", m[2], m[3])
+	}
+
+	return Selection{Path: m[1], Start: start, End: end}, nil
+}
+
+// Read returns the selected text.
+func (s Selection) Read() (string, error) {
+	var content string
+	if s.Path == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return "", fmt.Errorf("failed to read stdin: %w", err)
+		}
+		content = string(data)
+	} else {
+		data, err := index.ReadFile(s.Path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read example: %w", err)
+		}
+		content = data
+	}
+
+	if s.End == 0 {
+		return content, nil
+	}
+
+	lines := strings.Split(content, "\n")
+	if s.Start > len(lines) {
+		return "", fmt.Errorf("%s has only %d lines", s.Path, len(lines))
+	}
+
+	return strings.Join(lines[s.Start-1:min(s.End, len(lines))], "\n"), nil
+}
+
+// similarCommand searches collection for the chunks closest to the selected
+// code. A whole file is left out of its own results.
+func similarCommand(chromaOpts ChromaOptions, collection, arg string, opts QueryOptions, printer *Printer, logger *slog.Logger) {
+	ctx := context.Background()
+
+	sel, err := ParseSelection(arg)
+	if err != nil {
+		logger.Error("Invalid selection", "error", err)
+		os.Exit(1)
+	}
+	example, err := sel.Read()
+	if err != nil {
+		logger.Error("Failed to read example", "error", err)
+		os.Exit(1)
+	}
+	if strings.TrimSpace(example) == "" {
+		logger.Error("The example is empty")
+		os.Exit(1)
+	}
+	if sel.Path != "-" && sel.End == 0 {
+		opts.Exclude = append(opts.Exclude, absPath(sel.Path))
+	}
+
+	client, err := newStore(chromaOpts, logger)
+	if err != nil {
+		logger.Error("Failed to create ChromaDB client", "error", err)
+		os.Exit(1)
+	}
+	defer client.Close()
+
+	coll, err := client.GetCollection(ctx, collection)
+	if err != nil {
+		logger.Error("Failed to get collection", "error", err)
+		os.Exit(1)
+	}
+	verifyEmbedder(coll, opts.Force, logger)
+
+	results, err := Search(ctx, coll, collection, example, opts, logger)
+	if err != nil {
+		logger.Error("Failed to query collection", "error", err)
+		os.Exit(1)
+	}
+
+	if opts.Callers > 0 {
+		addCallers(collection, results, opts.Callers, logger)
+	}
+	if missing := markMissing(results); len(missing) > 0 {
+		results = pruneMissing(ctx, coll, results, missing, opts.AutoPruneMissing, printer, logger)
+	}
+
+	printer.Results(results)
+}
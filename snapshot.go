@@ -0,0 +1,328 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/karitham/cls/index"
+)
+
+const snapshotVersion = 1
+
+// SnapshotHeader is the first line of a snapshot.
+type SnapshotHeader struct {
+	Version    int            `json:"version"`
+	Collection string         `json:"collection"`
+	Metadata   map[string]any `json:"metadata,omitempty"`
+	Created    time.Time      `json:"created"`
+	// Since is set on delta snapshots, which only hold the documents indexed
+	// at or after it and are applied on top of an earlier snapshot.
+	Since time.Time `json:"since,omitzero"`
+}
+
+// SnapshotWriter writes a snapshot record by record.
+type SnapshotWriter struct {
+	bw  *bufio.Writer
+	zw  *gzip.Writer
+	enc *json.Encoder
+}
+
+// NewSnapshotWriter writes the snapshot header to w, gzipping the snapshot
+// when gz is set.
+func NewSnapshotWriter(w io.Writer, gz bool, header SnapshotHeader) (*SnapshotWriter, error) {
+	sw := &SnapshotWriter{}
+	if gz {
+		sw.zw = gzip.NewWriter(w)
+		w = sw.zw
+	}
+	sw.bw = bufio.NewWriter(w)
+	sw.enc = json.NewEncoder(sw.bw)
+
+	header.Version = snapshotVersion
+	if err := sw.enc.Encode(header); err != nil {
+		return nil, fmt.Errorf("failed to write snapshot header: %w", err)
+	}
+
+	return sw, nil
+}
+
+func (s *SnapshotWriter) Write(r Record) error {
+	if err := s.enc.Encode(r); err != nil {
+		return fmt.Errorf("failed to write record %s: %w", r.ID, err)
+	}
+
+	return nil
+}
+
+// Close flushes the snapshot. It does not close the underlying writer.
+func (s *SnapshotWriter) Close() error {
+	if err := s.bw.Flush(); err != nil {
+		return err
+	}
+	if s.zw != nil {
+		return s.zw.Close()
+	}
+
+	return nil
+}
+
+// WriteSnapshot streams every record of coll to w as JSON lines, gzipped when
+// gz is set, and returns the number of records written. When since is not
+// zero, only records indexed at or after since are written.
+func WriteSnapshot(ctx context.Context, w io.Writer, gz bool, name string, coll Collection, since time.Time) (int, error) {
+	sw, err := NewSnapshotWriter(w, gz, SnapshotHeader{
+		Collection: name,
+		Metadata:   coll.Metadata(),
+		Created:    time.Now().UTC(),
+		Since:      since,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for r, err := range coll.Records(ctx) {
+		if err != nil {
+			return n, err
+		}
+		if at, ok := recordIndexedAt(r); !since.IsZero() && (!ok || at.Before(since.Truncate(time.Second))) {
+			continue
+		}
+
+		if err := sw.Write(r); err != nil {
+			return n, err
+		}
+		n++
+	}
+
+	return n, sw.Close()
+}
+
+// recordIndexedAt returns when r was indexed, reporting false for records
+// indexed before that was recorded.
+func recordIndexedAt(r Record) (time.Time, bool) {
+	sec, ok := index.MetadataInt(r.Metadata[index.IndexedAtKey])
+	if !ok {
+		return time.Time{}, false
+	}
+
+	return time.Unix(sec, 0), true
+}
+
+// parseSince reads the cutoff of a delta export: an RFC 3339 timestamp, or
+// the path of an earlier snapshot, whose creation time is used.
+func parseSince(since string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, since); err == nil {
+		return t, nil
+	}
+
+	f, err := os.Open(since)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is neither an RFC 3339 timestamp nor a readable snapshot: %w", since, err)
+	}
+	defer f.Close()
+
+	snap, err := NewSnapshotReader(f)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer snap.Close()
+
+	return snap.Header.Created, nil
+}
+
+// SnapshotReader reads a snapshot written by WriteSnapshot.
+type SnapshotReader struct {
+	Header SnapshotHeader
+	dec    *json.Decoder
+	close  func() error
+}
+
+// NewSnapshotReader reads the snapshot header from r, transparently
+// decompressing gzipped snapshots.
+func NewSnapshotReader(r io.Reader) (*SnapshotReader, error) {
+	br := bufio.NewReader(r)
+	closer := func() error { return nil }
+
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open gzip stream: %w", err)
+		}
+		r, closer = zr, zr.Close
+	} else {
+		r = br
+	}
+
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+
+	sr := &SnapshotReader{dec: dec, close: closer}
+	if err := dec.Decode(&sr.Header); err != nil {
+		return nil, fmt.Errorf("failed to read snapshot header: %w", err)
+	}
+	if sr.Header.Version != snapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %d", sr.Header.Version)
+	}
+
+	return sr, nil
+}
+
+// Next returns the next record, or io.EOF at the end of the snapshot.
+func (s *SnapshotReader) Next() (Record, error) {
+	var r Record
+	if err := s.dec.Decode(&r); err != nil {
+		if errors.Is(err, io.EOF) {
+			return Record{}, io.EOF
+		}
+		return Record{}, fmt.Errorf("failed to read record: %w", err)
+	}
+
+	return r, nil
+}
+
+func (s *SnapshotReader) Close() error {
+	return s.close()
+}
+
+// ImportSnapshot upserts every record of s into coll in batches of
+// batchSize and returns the number imported.
+func ImportSnapshot(ctx context.Context, s *SnapshotReader, coll Collection, batchSize int) (int, error) {
+	var (
+		batch []Record
+		n     int
+	)
+	flush := func() error {
+		if err := coll.AddRecords(ctx, batch); err != nil {
+			return err
+		}
+		n += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+
+	for {
+		r, err := s.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return n, err
+		}
+
+		batch = append(batch, r)
+		if len(batch) >= batchSize {
+			if err := flush(); err != nil {
+				return n, err
+			}
+		}
+	}
+
+	return n, flush()
+}
+
+func exportCollection(chromaOpts ChromaOptions, collection, out, sinceRef string, printer *Printer, logger *slog.Logger) {
+	ctx := context.Background()
+
+	var since time.Time
+	if sinceRef != "" {
+		t, err := parseSince(sinceRef)
+		if err != nil {
+			logger.Error("Failed to read export cutoff", "error", err)
+			os.Exit(1)
+		}
+		since = t
+	}
+
+	client, err := newStore(chromaOpts, logger)
+	if err != nil {
+		logger.Error("Failed to create ChromaDB client", "error", err)
+		os.Exit(1)
+	}
+	defer client.Close()
+
+	coll, err := client.GetCollection(ctx, collection)
+	if err != nil {
+		logger.Error("Failed to get collection", "error", err)
+		os.Exit(1)
+	}
+
+	f, err := os.Create(out)
+	if err != nil {
+		logger.Error("Failed to create snapshot file", "error", err)
+		os.Exit(1)
+	}
+	defer f.Close()
+
+	n, err := WriteSnapshot(ctx, f, strings.HasSuffix(out, ".gz"), collection, coll, since)
+	if err != nil {
+		logger.Error("Failed to export collection", "error", err, "exported", n)
+		os.Exit(1)
+	}
+
+	if !since.IsZero() {
+		printer.Message("Exported %d documents changed since %s from '%s' to %s", n, since.Format(time.RFC3339), collection, out)
+		return
+	}
+	printer.Message("Exported %d documents from '%s' to %s", n, collection, out)
+}
+
+func importCollection(chromaOpts ChromaOptions, into, path string, unprotect bool, printer *Printer, logger *slog.Logger) {
+	ctx := context.Background()
+
+	f, err := os.Open(path)
+	if err != nil {
+		logger.Error("Failed to open snapshot", "error", err)
+		os.Exit(1)
+	}
+	defer f.Close()
+
+	snap, err := NewSnapshotReader(f)
+	if err != nil {
+		logger.Error("Failed to read snapshot", "error", err)
+		os.Exit(1)
+	}
+	defer snap.Close()
+
+	collection := cmp.Or(into, snap.Header.Collection)
+
+	client, err := newStore(chromaOpts, logger)
+	if err != nil {
+		logger.Error("Failed to create ChromaDB client", "error", err)
+		os.Exit(1)
+	}
+	defer client.Close()
+
+	coll, err := client.GetOrCreateCollection(ctx, collection)
+	if err != nil {
+		logger.Error("Failed to get/create collection", "error", err)
+		os.Exit(1)
+	}
+
+	if err := checkUnprotected(ctx, client, collection, unprotect); err != nil {
+		logger.Error("Refusing to import snapshot", "error", err)
+		os.Exit(1)
+	}
+
+	n, err := ImportSnapshot(ctx, snap, coll, copyPageSize)
+	if err != nil {
+		logger.Error("Failed to import snapshot", "error", err, "imported", n)
+		os.Exit(1)
+	}
+
+	if !snap.Header.Since.IsZero() {
+		printer.Message("Applied delta of %d documents since %s to '%s'", n, snap.Header.Since.Format(time.RFC3339), collection)
+		return
+	}
+	printer.Message("Imported %d documents into '%s'", n, collection)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/karitham/cls/store"
+)
+
+// locateMatches sets the Line of results indexed with line numbers to the
+// line of their chunk holding the most query terms, or the first line when
+// none holds any.
+func locateMatches(results []QueryResult, terms []string) {
+	lowered := make([]string, len(terms))
+	for i, t := range terms {
+		lowered[i] = strings.ToLower(t)
+	}
+
+	for i := range results {
+		r := &results[i]
+		if r.StartLine == 0 {
+			continue
+		}
+
+		best, bestScore := 0, 0.0
+		for n, line := range strings.Split(r.Content, "\n") {
+			if score := store.KeywordScore(strings.ToLower(line), lowered); score > bestScore {
+				best, bestScore = n, score
+			}
+		}
+		r.Line = r.StartLine + best
+	}
+}
+
+// reference returns the path:line reference of r, or its path for results
+// without line numbers.
+func (p *Printer) reference(r QueryResult) string {
+	if r.Line == 0 {
+		return p.path(r)
+	}
+
+	return fmt.Sprintf("%s:%d", p.path(r), r.Line)
+}
+
+// snippet returns the lines of r around its matching line, numbered as in
+// the file.
+func (p *Printer) snippet(r QueryResult) string {
+	lines := strings.Split(strings.TrimRight(r.Content, "\n"), "\n")
+	at := r.Line - r.StartLine
+	from, to := max(at-p.context, 0), min(at+p.context+1, len(lines))
+	shown := p.highlight(r, lines[from:to])
+
+	var sb strings.Builder
+	width := len(fmt.Sprint(r.StartLine + to - 1))
+	for i := from; i < to; i++ {
+		marker := " "
+		if i == at {
+			marker = ">"
+		}
+		fmt.Fprintf(&sb, "%s%*d| %s\n", marker, width, r.StartLine+i, shown[i-from])
+	}
+
+	return strings.TrimRight(sb.String(), "\n")
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// stateDir returns the directory holding local cls state, creating it if
+// needed. It honours XDG_STATE_HOME and falls back to ~/.local/state/cls.
+func stateDir() (string, error) {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to locate home directory: %w", err)
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+
+	dir = filepath.Join(dir, "cls")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	return dir, nil
+}
+
+// readState decodes the JSON state file name into v. A missing file leaves v
+// untouched and is not an error.
+func readState(name string, v any) error {
+	dir, err := stateDir()
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", name, err)
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to decode %s: %w", name, err)
+	}
+
+	return nil
+}
+
+func writeState(name string, v any) error {
+	dir, err := stateDir()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", name, err)
+	}
+
+	tmp := filepath.Join(dir, name+".tmp")
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+
+	return os.Rename(tmp, filepath.Join(dir, name))
+}
+
+// appendState appends v as one JSON line to the state file name.
+func appendState(name string, v any) error {
+	dir, err := stateDir()
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(filepath.Join(dir, name), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", name, err)
+	}
+	defer f.Close()
+
+	if err := json.NewEncoder(f).Encode(v); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// readStateLines decodes every JSON line of the state file name.
+func readStateLines[T any](name string) ([]T, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(filepath.Join(dir, name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", name, err)
+	}
+	defer f.Close()
+
+	var out []T
+	dec := json.NewDecoder(f)
+	for dec.More() {
+		var v T
+		if err := dec.Decode(&v); err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", name, err)
+		}
+		out = append(out, v)
+	}
+
+	return out, nil
+}
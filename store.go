@@ -0,0 +1,51 @@
+package main
+
+import (
+	"log/slog"
+
+	"github.com/karitham/cls/store"
+
+	_ "github.com/karitham/cls/store/chromadb"
+	_ "github.com/karitham/cls/store/milvus"
+	_ "github.com/karitham/cls/store/opensearch"
+	_ "github.com/karitham/cls/store/redis"
+)
+
+// The store types under the names the commands use. Backends implement
+// store.Client in the packages under store, registering themselves when
+// imported.
+type (
+	ChromaOptions  = store.Options
+	ChromaClient   = store.Client
+	Collection     = store.Collection
+	CollectionInfo = store.Info
+	Record         = store.Record
+	QueryRequest   = store.Request
+	QueryOption    = store.Option
+	QueryResponse  = store.Response
+	QueryResult    = store.Result
+	MetadataFilter = store.Filter
+	Symbol         = store.Symbol
+	Reranker       = store.Reranker
+)
+
+// copyPageSize is how many documents are read per request when going
+// through a whole collection.
+const copyPageSize = 500
+
+// newStore connects to the store of opts, sharing the embedder and the
+// profile of the process and resolving the paths of results to the local
+// roots.
+func newStore(opts ChromaOptions, logger *slog.Logger) (ChromaClient, error) {
+	ef, err := newEmbedder()
+	if err != nil {
+		return nil, err
+	}
+	opts.Embedder = ef
+	if profiler != nil {
+		opts.Profiler = profiler
+	}
+	opts.ResolvePath = localPath
+
+	return newStore(opts, logger)
+}
@@ -0,0 +1,132 @@
+package chromadb
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	chroma "github.com/amikos-tech/chroma-go/pkg/api/v2"
+
+	"github.com/karitham/cls/index"
+	"github.com/karitham/cls/store"
+)
+
+// aliasVersionKey holds, on collections built by index -replace, their
+// version of the name in store.AliasKey they are queried under. Chroma has no
+// aliases, so a name resolves to the collection called so when there is one,
+// and to the highest version aliased to it otherwise. Setting the alias of a
+// new version swaps it in for every client at once.
+const aliasVersionKey = "alias_version"
+
+// collection gets the collection called name, or the one aliased to it.
+func (c *chromaClient) collection(ctx context.Context, name string) (chroma.Collection, error) {
+	coll, err := c.client.GetCollection(ctx, name, chroma.WithEmbeddingFunctionGet(c.ef))
+	if err == nil {
+		return coll, nil
+	}
+
+	target, _, aerr := c.resolveAlias(ctx, name)
+	if aerr != nil || target == "" || target == name {
+		return nil, err
+	}
+
+	return c.client.GetCollection(ctx, target, chroma.WithEmbeddingFunctionGet(c.ef))
+}
+
+// resolveAlias returns the collection name resolves to and its version, a
+// collection called name being the first. It returns "" and 0 when there is
+// no such collection.
+func (c *chromaClient) resolveAlias(ctx context.Context, name string) (string, int, error) {
+	colls, err := c.client.ListCollections(ctx)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to list collections: %w", err)
+	}
+
+	var (
+		target  string
+		version int
+	)
+	for _, coll := range colls {
+		if coll.Name() == name {
+			return name, 1, nil
+		}
+		md := metadataMap(coll.Metadata())
+		if md[store.AliasKey] != name {
+			continue
+		}
+		if v, _ := index.MetadataInt(md[aliasVersionKey]); int(v) > version {
+			target, version = coll.Name(), int(v)
+		}
+	}
+
+	return target, version, nil
+}
+
+// NextVersion creates an empty collection to rebuild name in, named after
+// its next version. A version left over by an interrupted rebuild is
+// dropped first.
+func (c *chromaClient) NextVersion(ctx context.Context, name string) (string, store.Collection, error) {
+	_, version, err := c.resolveAlias(ctx, name)
+	if err != nil {
+		return "", nil, err
+	}
+
+	next := fmt.Sprintf("%s_v%d", name, version+1)
+	if err := c.client.DeleteCollection(ctx, next); err == nil {
+		c.logger.Info("Dropped collection left over by an interrupted rebuild", "collection", next)
+	}
+
+	coll, err := c.GetOrCreateCollection(ctx, next)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return next, coll, nil
+}
+
+// SwapAlias points name at the collection target, a version made by
+// NextVersion, and deletes the collection it resolved to before.
+func (c *chromaClient) SwapAlias(ctx context.Context, name, target string) error {
+	old, _, err := c.resolveAlias(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	coll, err := c.client.GetCollection(ctx, target, chroma.WithEmbeddingFunctionGet(c.ef))
+	if err != nil {
+		return fmt.Errorf("failed to get collection: %w", err)
+	}
+	version, err := strconv.Atoi(target[len(name)+len("_v"):])
+	if err != nil {
+		return fmt.Errorf("%s is not a version of %s", target, name)
+	}
+
+	md := chroma.NewMetadataFromMap(metadataMap(coll.Metadata()))
+	md.SetString(store.AliasKey, name)
+	md.SetInt(aliasVersionKey, int64(version))
+	if err := coll.ModifyMetadata(ctx, md); err != nil {
+		return fmt.Errorf("failed to update collection metadata: %w", err)
+	}
+
+	// queries keep reaching a collection called name until it is gone
+	if old == "" || old == target {
+		return nil
+	}
+	if err := c.client.DeleteCollection(ctx, old); err != nil {
+		return fmt.Errorf("failed to delete collection %s: %w", old, err)
+	}
+
+	return nil
+}
+
+// withoutAlias returns md without the keys that alias its collection to a
+// name, and whether it had any. They stay with the collection they were set
+// on: a copy or a renamed version must not shadow the name.
+func withoutAlias(md map[string]any) (map[string]any, bool) {
+	_, aliased := md[store.AliasKey]
+	_, versioned := md[aliasVersionKey]
+	delete(md, store.AliasKey)
+	delete(md, aliasVersionKey)
+
+	return md, aliased || versioned
+}
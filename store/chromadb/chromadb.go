@@ -0,0 +1,872 @@
+// Package chromadb is the default store, a ChromaDB server spoken to with
+// API v2. Importing it registers the store as store.Default.
+package chromadb
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	chroma "github.com/amikos-tech/chroma-go/pkg/api/v2"
+	"github.com/amikos-tech/chroma-go/pkg/embeddings"
+
+	"github.com/karitham/cls/index"
+	"github.com/karitham/cls/store"
+)
+
+// pageSize is how many documents are read per request when going through
+// a whole collection.
+const pageSize = 500
+
+type chromaClient struct {
+	client chroma.Client
+	ef     embeddings.EmbeddingFunction
+	opts   store.Options
+	logger *slog.Logger
+}
+
+// chromaClientOptions returns the options of a ChromaDB client for o.
+func chromaClientOptions(o store.Options) ([]chroma.ClientOption, error) {
+	opts := []chroma.ClientOption{chroma.WithBaseURL(o.URL)}
+
+	switch {
+	case o.Token != "" && o.Username != "":
+		return nil, errors.New("use either a token or basic auth for ChromaDB, not both")
+	case o.Token != "":
+		header := chroma.TokenTransportHeader(cmp.Or(o.TokenHeader, string(chroma.AuthorizationTokenHeader)))
+		if header != chroma.AuthorizationTokenHeader && header != chroma.XChromaTokenHeader {
+			return nil, fmt.Errorf("unsupported token header %q", header)
+		}
+		opts = append(opts, chroma.WithAuth(chroma.NewTokenAuthCredentialsProvider(o.Token, header)))
+	case o.Username != "":
+		opts = append(opts, chroma.WithAuth(chroma.NewBasicAuthCredentialsProvider(o.Username, o.Password)))
+	}
+
+	if o.CACert != "" {
+		opts = append(opts, chroma.WithSSLCert(o.CACert))
+	}
+	if o.Insecure {
+		opts = append(opts, chroma.WithInsecure())
+	}
+	if o.Timeout > 0 {
+		opts = append(opts, chroma.WithTimeout(o.Timeout))
+	}
+
+	switch {
+	case o.Database != "":
+		opts = append(opts, chroma.WithDatabaseAndTenant(o.Database, cmp.Or(o.Tenant, chroma.DefaultTenant)))
+	case o.Tenant != "":
+		opts = append(opts, chroma.WithTenant(o.Tenant))
+	}
+
+	return opts, nil
+}
+
+func init() {
+	store.Register(store.Default, New)
+}
+
+// New connects to the ChromaDB server at opts.URL, after checking it is up
+// and serves API v2.
+func New(opts store.Options, logger *slog.Logger) (store.Client, error) {
+	clientOpts, err := chromaClientOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := chroma.NewHTTPClient(clientOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ChromaDB client: %w", err)
+	}
+	if err := checkChromaHealth(client, opts, logger); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	ef, err := opts.NewEmbedder()
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	return &chromaClient{
+		client: client,
+		ef:     embeddingFunction{ef},
+		opts:   opts,
+		logger: logger,
+	}, nil
+}
+
+// chromaHealthTimeout bounds the health check of new clients when
+// Options.Timeout is not set.
+const chromaHealthTimeout = 5 * time.Second
+
+// ErrUnreachable is returned when creating a client for a ChromaDB server
+// that does not answer.
+var ErrUnreachable = errors.New("ChromaDB not reachable")
+
+// checkChromaHealth pings the server of client before anything is sent to
+// it, so a server that is down or too old is reported as such rather than
+// by the first request failing midway through a command.
+func checkChromaHealth(client chroma.Client, opts store.Options, logger *slog.Logger) error {
+	ctx, cancel := context.WithTimeout(context.Background(), cmp.Or(opts.Timeout, chromaHealthTimeout))
+	defer cancel()
+
+	if err := client.Heartbeat(ctx); err != nil {
+		if chromaServesV1(ctx, opts.URL) {
+			return fmt.Errorf("ChromaDB at %s only serves API v1, cls needs a server with API v2: upgrade ChromaDB", opts.URL)
+		}
+		return fmt.Errorf("%w at %s, is it running? %v", ErrUnreachable, opts.URL, err)
+	}
+
+	version, err := client.GetVersion(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get ChromaDB version: %w", err)
+	}
+	logger.Debug("Connected to ChromaDB", "url", opts.URL, "version", version)
+
+	return nil
+}
+
+// chromaServesV1 reports whether the server at baseURL answers the
+// heartbeat of API v1, telling servers too old for the v2 client from
+// unreachable ones.
+func chromaServesV1(ctx context.Context, baseURL string) bool {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(baseURL, "/")+"/api/v1/heartbeat", nil)
+	if err != nil {
+		return false
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+
+	return resp.StatusCode == http.StatusOK
+}
+
+// embeddingFunction makes an embedder the embedding function of ChromaDB
+// collections, which embed the documents upserted without embeddings.
+type embeddingFunction struct {
+	ef store.Embedder
+}
+
+func (f embeddingFunction) EmbedDocuments(ctx context.Context, texts []string) ([]embeddings.Embedding, error) {
+	vectors, err := f.ef.EmbedDocuments(ctx, texts)
+	if err != nil {
+		return nil, err
+	}
+
+	embs := make([]embeddings.Embedding, len(vectors))
+	for i, v := range vectors {
+		embs[i] = embeddings.NewEmbeddingFromFloat32(v)
+	}
+
+	return embs, nil
+}
+
+func (f embeddingFunction) EmbedQuery(ctx context.Context, text string) (embeddings.Embedding, error) {
+	v, err := f.ef.EmbedQuery(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+
+	return embeddings.NewEmbeddingFromFloat32(v), nil
+}
+
+// wrap returns the store collection of coll, its calls being timed as the
+// store stage when profiling.
+func (c *chromaClient) wrap(coll chroma.Collection) *chromaCollection {
+	if c.opts.Profiler != nil {
+		coll = &timedCollection{Collection: coll, opts: c.opts}
+	}
+
+	return &chromaCollection{coll: coll, ef: c.ef, resolve: c.opts.ResolvePath, logger: c.logger}
+}
+
+func (c *chromaClient) GetOrCreateCollection(ctx context.Context, name string) (store.Collection, error) {
+	if target, _, err := c.resolveAlias(ctx, name); err == nil && target != "" {
+		name = target
+	}
+
+	coll, err := c.client.GetOrCreateCollection(ctx, name,
+		chroma.WithEmbeddingFunctionCreate(c.ef),
+		chroma.WithCollectionMetadataCreate(chroma.NewMetadata(
+			chroma.NewStringAttribute(store.ManagedByKey, "cls"),
+			chroma.NewStringAttribute(store.EmbedderKey, store.DefaultEmbedder),
+			chroma.NewStringAttribute(store.EmbedderModelKey, store.DefaultEmbedderModel),
+		)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get/create collection: %w", err)
+	}
+
+	c.recordDimensions(ctx, coll)
+
+	return c.wrap(coll), nil
+}
+
+// recordDimensions adds the embedding dimensions to the metadata of
+// collections recording the current embedder but not its dimensions yet.
+// It is best effort, as the embedder may not be reachable.
+func (c *chromaClient) recordDimensions(ctx context.Context, coll chroma.Collection) {
+	md := metadataMap(coll.Metadata())
+	if _, ok := md[store.EmbedderDimensionsKey]; ok || md[store.EmbedderModelKey] != store.DefaultEmbedderModel {
+		return
+	}
+
+	emb, err := c.ef.EmbedQuery(ctx, "dimensions")
+	if err != nil {
+		c.logger.Debug("Failed to probe embedding dimensions", "error", err)
+		return
+	}
+
+	meta := chroma.NewMetadataFromMap(md)
+	meta.SetInt(store.EmbedderDimensionsKey, int64(emb.Len()))
+	if err := coll.ModifyMetadata(ctx, meta); err != nil {
+		c.logger.Debug("Failed to record embedding dimensions", "error", err)
+	}
+}
+
+func (c *chromaClient) GetCollection(ctx context.Context, name string) (store.Collection, error) {
+	coll, err := c.collection(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get collection: %w", err)
+	}
+	return c.wrap(coll), nil
+}
+
+func (c *chromaClient) DeleteCollection(ctx context.Context, name string) error {
+	if target, _, err := c.resolveAlias(ctx, name); err == nil && target != "" {
+		name = target
+	}
+
+	err := c.client.DeleteCollection(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to delete collection: %w", err)
+	}
+	return nil
+}
+
+func (c *chromaClient) ListCollections(ctx context.Context) ([]store.Info, error) {
+	colls, err := c.client.ListCollections(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list collections: %w", err)
+	}
+
+	infos := make([]store.Info, 0, len(colls))
+	for _, coll := range colls {
+		info := store.Info{Name: coll.Name(), Metadata: metadataMap(coll.Metadata())}
+		info.Managed = info.Metadata[store.ManagedByKey] == "cls"
+		info.Protected, _ = info.Metadata[store.ProtectedKey].(bool)
+		info.Alias, _ = info.Metadata[store.AliasKey].(string)
+
+		info.Count, err = coll.Count(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count collection %s: %w", info.Name, err)
+		}
+
+		infos = append(infos, info)
+	}
+
+	return infos, nil
+}
+
+func (c *chromaClient) RenameCollection(ctx context.Context, name, newName string) error {
+	coll, err := c.collection(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to get collection: %w", err)
+	}
+
+	if err := coll.ModifyName(ctx, newName); err != nil {
+		return fmt.Errorf("failed to rename collection: %w", err)
+	}
+
+	// a renamed version no longer answers to the name it was aliased to
+	if md, aliased := withoutAlias(metadataMap(coll.Metadata())); aliased {
+		if err := coll.ModifyMetadata(ctx, chroma.NewMetadataFromMap(md)); err != nil {
+			return fmt.Errorf("failed to update collection metadata: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (c *chromaClient) CopyCollection(ctx context.Context, name, newName string) (int, error) {
+	src, err := c.collection(ctx, name)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get collection: %w", err)
+	}
+
+	opts := []chroma.CreateCollectionOption{chroma.WithEmbeddingFunctionCreate(c.ef)}
+	if md, _ := withoutAlias(metadataMap(src.Metadata())); len(md) > 0 {
+		opts = append(opts, chroma.WithCollectionMetadataCreate(chroma.NewMetadataFromMap(md)))
+	}
+
+	dst, err := c.client.CreateCollection(ctx, newName, opts...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create collection: %w", err)
+	}
+
+	copied := 0
+	for {
+		page, err := src.Get(ctx,
+			chroma.WithIncludeGet(chroma.IncludeDocuments, chroma.IncludeMetadatas, chroma.IncludeEmbeddings),
+			chroma.WithLimitGet(pageSize),
+			chroma.WithOffsetGet(copied),
+		)
+		if err != nil {
+			return copied, fmt.Errorf("failed to read documents: %w", err)
+		}
+		if page.Count() == 0 {
+			return copied, nil
+		}
+
+		texts := make([]string, 0, page.Count())
+		for _, d := range page.GetDocuments() {
+			texts = append(texts, d.ContentString())
+		}
+
+		err = dst.Add(ctx,
+			chroma.WithIDs(page.GetIDs()...),
+			chroma.WithTexts(texts...),
+			chroma.WithMetadatas(page.GetMetadatas()...),
+			chroma.WithEmbeddings(page.GetEmbeddings()...),
+		)
+		if err != nil {
+			return copied, fmt.Errorf("failed to write documents: %w", err)
+		}
+
+		copied += page.Count()
+	}
+}
+
+func (c *chromaClient) SetProtected(ctx context.Context, name string, protected bool) error {
+	coll, err := c.collection(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to get collection: %w", err)
+	}
+
+	// metadata updates replace the whole map, so carry the other keys over
+	md := chroma.NewMetadataFromMap(metadataMap(coll.Metadata()))
+	md.SetBool(store.ProtectedKey, protected)
+
+	if err := coll.ModifyMetadata(ctx, md); err != nil {
+		return fmt.Errorf("failed to update collection metadata: %w", err)
+	}
+
+	return nil
+}
+
+func (c *chromaClient) SetMetadata(ctx context.Context, name, key, value string) error {
+	coll, err := c.collection(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to get collection: %w", err)
+	}
+
+	md := chroma.NewMetadataFromMap(metadataMap(coll.Metadata()))
+	md.SetString(key, value)
+
+	if err := coll.ModifyMetadata(ctx, md); err != nil {
+		return fmt.Errorf("failed to update collection metadata: %w", err)
+	}
+
+	return nil
+}
+
+func (c *chromaClient) IsProtected(ctx context.Context, name string) (bool, error) {
+	coll, err := c.collection(ctx, name)
+	if err != nil {
+		return false, fmt.Errorf("failed to get collection: %w", err)
+	}
+
+	protected, _ := metadataMap(coll.Metadata())[store.ProtectedKey].(bool)
+	return protected, nil
+}
+
+func (c *chromaClient) Version(ctx context.Context) (string, error) {
+	version, err := c.client.GetVersion(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get ChromaDB version: %w", err)
+	}
+	return version, nil
+}
+
+func (c *chromaClient) Close() error {
+	return c.client.Close()
+}
+
+// includeDistances is accepted by the Chroma API but not exported by the
+// client library.
+const includeDistances chroma.Include = "distances"
+
+type chromaCollection struct {
+	coll    chroma.Collection
+	ef      embeddings.EmbeddingFunction
+	resolve func(rootID, rel string) (string, bool)
+	logger  *slog.Logger
+}
+
+func (c *chromaCollection) Writer() store.Writer {
+	return c
+}
+
+// Upsert adds records, ChromaDB embedding them with the embedder of the
+// collection unless they all have an embedding.
+func (c *chromaCollection) Upsert(ctx context.Context, records []store.Record) error {
+	return c.AddRecords(ctx, records)
+}
+
+func (c *chromaCollection) Update(ctx context.Context, records []store.Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	ids := make([]chroma.DocumentID, len(records))
+	metas := make([]chroma.DocumentMetadata, len(records))
+	for i, r := range records {
+		md, err := chroma.NewDocumentMetadataFromMap(r.Metadata)
+		if err != nil {
+			return fmt.Errorf("invalid metadata for %s: %w", r.ID, err)
+		}
+		ids[i], metas[i] = chroma.DocumentID(r.ID), md
+	}
+
+	if err := c.coll.Update(ctx, chroma.WithIDsUpdate(ids...), chroma.WithMetadatasUpdate(metas...)); err != nil {
+		return fmt.Errorf("failed to update documents: %w", err)
+	}
+
+	return nil
+}
+
+func (c *chromaCollection) Query(ctx context.Context, text string, opts ...store.Option) (store.Response, error) {
+	q := store.NewRequest(text, opts...)
+
+	var embs []embeddings.Embedding
+	for _, t := range q.Texts() {
+		emb, err := c.ef.EmbedQuery(ctx, t)
+		if err != nil {
+			return store.Response{}, fmt.Errorf("%w: %w", store.ErrEmbed, err)
+		}
+		embs = append(embs, emb)
+	}
+
+	var negatives [][]float32
+	for _, t := range q.Negatives {
+		emb, err := c.ef.EmbedQuery(ctx, t)
+		if err != nil {
+			return store.Response{}, fmt.Errorf("%w: %w", store.ErrEmbed, err)
+		}
+		negatives = append(negatives, emb.ContentAsFloat32())
+	}
+
+	include := []chroma.Include{chroma.IncludeDocuments, chroma.IncludeMetadatas, includeDistances}
+	if q.Embeddings() {
+		include = append(include, chroma.IncludeEmbeddings)
+	}
+	queryOpts := []chroma.CollectionQueryOption{
+		chroma.WithQueryEmbeddings(embs...),
+		chroma.WithIncludeQuery(include...),
+		chroma.WithNResults(q.Fetch()),
+	}
+	if where := whereFilter(q); where != nil {
+		queryOpts = append(queryOpts, chroma.WithWhereQuery(where))
+	}
+
+	groups, err := c.query(ctx, queryOpts...)
+	if err != nil {
+		return store.Response{}, err
+	}
+	if groups, err = store.ResolveSummaries(ctx, c, q.InTarget(groups)); err != nil {
+		return store.Response{}, err
+	}
+
+	results, err := q.Finish(ctx, q.Penalize(store.Fuse(groups), negatives))
+	if err != nil {
+		return store.Response{}, err
+	}
+
+	resp := store.Response{Results: results}
+	if q.IncludeEmbeddings {
+		resp.Embedding = embs[0].ContentAsFloat32()
+	}
+
+	return resp, nil
+}
+
+// query returns a group of results per query embedding.
+func (c *chromaCollection) query(ctx context.Context, opts ...chroma.CollectionQueryOption) ([][]store.Result, error) {
+	results, err := c.coll.Query(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query collection: %w", err)
+	}
+
+	ids := results.GetIDGroups()
+	documents := results.GetDocumentsGroups()
+	metadatas := results.GetMetadatasGroups()
+	distances := results.GetDistancesGroups()
+	embeds := results.GetEmbeddingsGroups()
+
+	if len(documents) == 0 {
+		return [][]store.Result{{}}, nil
+	}
+
+	groups := make([][]store.Result, len(documents))
+	for g, docs := range documents {
+		groups[g] = []store.Result{}
+		for i, doc := range docs {
+			r := store.Record{Document: fmt.Sprintf("%v", doc)}
+			if g < len(ids) && i < len(ids[g]) {
+				r.ID = string(ids[g][i])
+			}
+			if g < len(metadatas) && i < len(metadatas[g]) {
+				r.Metadata = metadataMap(metadatas[g][i])
+			}
+			result := index.Result(r, c.resolve)
+			if g < len(distances) && i < len(distances[g]) {
+				result.Distance = float64(distances[g][i])
+			}
+			if g < len(embeds) && i < len(embeds[g]) && embeds[g][i] != nil {
+				result.Embedding = embeds[g][i].ContentAsFloat32()
+			}
+			groups[g] = append(groups[g], result)
+		}
+	}
+
+	return groups, nil
+}
+
+func (c *chromaCollection) KeywordSearch(ctx context.Context, terms []string, n int) ([]store.Result, error) {
+	if len(terms) == 0 {
+		return []store.Result{}, nil
+	}
+
+	filters := make([]chroma.WhereDocumentFilter, len(terms))
+	for i, t := range terms {
+		filters[i] = chroma.Contains(t)
+	}
+	filter := filters[0]
+	if len(filters) > 1 {
+		filter = chroma.OrDocument(filters...)
+	}
+
+	res, err := c.coll.Get(ctx,
+		chroma.WithWhereDocumentGet(filter),
+		chroma.WithIncludeGet(chroma.IncludeDocuments, chroma.IncludeMetadatas),
+		chroma.WithLimitGet(max(n*10, 100)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search collection: %w", err)
+	}
+
+	results := slices.DeleteFunc(c.results(res), func(r store.Result) bool { return r.SummaryOf != "" })
+	for i := range results {
+		results[i].Score = store.KeywordScore(results[i].Content, terms)
+	}
+
+	slices.SortStableFunc(results, func(a, b store.Result) int {
+		return cmp.Compare(b.Score, a.Score)
+	})
+
+	return results[:min(n, len(results))], nil
+}
+
+func (c *chromaCollection) Get(ctx context.Context, ids ...string) ([]store.Result, error) {
+	docIDs := make([]chroma.DocumentID, len(ids))
+	for i, id := range ids {
+		docIDs[i] = chroma.DocumentID(id)
+	}
+
+	res, err := c.coll.Get(ctx,
+		chroma.WithIDsGet(docIDs...),
+		chroma.WithIncludeGet(chroma.IncludeDocuments, chroma.IncludeMetadatas),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get documents: %w", err)
+	}
+
+	return c.results(res), nil
+}
+
+func (c *chromaCollection) Count(ctx context.Context) (int, error) {
+	n, err := c.coll.Count(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count documents: %w", err)
+	}
+	return n, nil
+}
+
+func (c *chromaCollection) DeleteIndexedBefore(ctx context.Context, t time.Time) (int, error) {
+	before, err := c.Count(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	err = c.coll.Delete(ctx, chroma.WithWhereDelete(chroma.LtInt(index.IndexedAtKey, int(t.Unix()))))
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete documents: %w", err)
+	}
+
+	after, err := c.Count(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return before - after, nil
+}
+
+func (c *chromaCollection) DeleteRun(ctx context.Context, id string) (int, error) {
+	before, err := c.Count(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := c.coll.Delete(ctx, chroma.WithWhereDelete(chroma.EqString(index.RunKey, id))); err != nil {
+		return 0, fmt.Errorf("failed to delete documents: %w", err)
+	}
+
+	after, err := c.Count(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return before - after, nil
+}
+
+func (c *chromaCollection) DeletePaths(ctx context.Context, rootID string, paths []string) (int, error) {
+	if len(paths) == 0 {
+		return 0, nil
+	}
+
+	before, err := c.Count(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	for batch := range slices.Chunk(paths, deletePathsBatch) {
+		where := chroma.And(chroma.EqString(index.RootKey, rootID), chroma.InString("path", batch...))
+		if err := c.coll.Delete(ctx, chroma.WithWhereDelete(where)); err != nil {
+			return 0, fmt.Errorf("failed to delete documents: %w", err)
+		}
+	}
+
+	after, err := c.Count(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return before - after, nil
+}
+
+// deletePathsBatch is how many paths a delete filters on at once.
+const deletePathsBatch = 100
+
+func (c *chromaCollection) Delete(ctx context.Context, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	docIDs := make([]chroma.DocumentID, len(ids))
+	for i, id := range ids {
+		docIDs[i] = chroma.DocumentID(id)
+	}
+
+	if err := c.coll.Delete(ctx, chroma.WithIDsDelete(docIDs...)); err != nil {
+		return fmt.Errorf("failed to delete documents: %w", err)
+	}
+
+	return nil
+}
+
+func (c *chromaCollection) Metadata() map[string]any {
+	return metadataMap(c.coll.Metadata())
+}
+
+func (c *chromaCollection) Records(ctx context.Context) iter.Seq2[store.Record, error] {
+	return func(yield func(store.Record, error) bool) {
+		for offset := 0; ; {
+			page, err := c.coll.Get(ctx,
+				chroma.WithIncludeGet(chroma.IncludeDocuments, chroma.IncludeMetadatas, chroma.IncludeEmbeddings),
+				chroma.WithLimitGet(pageSize),
+				chroma.WithOffsetGet(offset),
+			)
+			if err != nil {
+				yield(store.Record{}, fmt.Errorf("failed to read documents: %w", err))
+				return
+			}
+			if page.Count() == 0 {
+				return
+			}
+
+			ids := page.GetIDs()
+			metadatas := page.GetMetadatas()
+			embeds := page.GetEmbeddings()
+			for i, doc := range page.GetDocuments() {
+				r := store.Record{ID: string(ids[i]), Document: doc.ContentString()}
+				if i < len(metadatas) && metadatas[i] != nil {
+					r.Metadata = metadataMap(metadatas[i])
+				}
+				if i < len(embeds) && embeds[i] != nil {
+					r.Embedding = embeds[i].ContentAsFloat32()
+				}
+
+				if !yield(r, nil) {
+					return
+				}
+			}
+
+			offset += page.Count()
+		}
+	}
+}
+
+func (c *chromaCollection) AddRecords(ctx context.Context, records []store.Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	var (
+		ids    = make([]chroma.DocumentID, len(records))
+		texts  = make([]string, len(records))
+		metas  = make([]chroma.DocumentMetadata, len(records))
+		embeds = make([]embeddings.Embedding, 0, len(records))
+	)
+	for i, r := range records {
+		ids[i] = chroma.DocumentID(r.ID)
+		texts[i] = r.Document
+
+		md, err := chroma.NewDocumentMetadataFromMap(r.Metadata)
+		if err != nil {
+			return fmt.Errorf("invalid metadata for %s: %w", r.ID, err)
+		}
+		metas[i] = md
+
+		if len(r.Embedding) > 0 {
+			embeds = append(embeds, embeddings.NewEmbeddingFromFloat32(r.Embedding))
+		}
+	}
+
+	opts := []chroma.CollectionAddOption{
+		chroma.WithIDs(ids...),
+		chroma.WithTexts(texts...),
+		chroma.WithMetadatas(metas...),
+	}
+	// embeddings are only reused when every record has one, otherwise the
+	// whole batch is embedded again
+	if len(embeds) == len(records) {
+		opts = append(opts, chroma.WithEmbeddings(embeds...))
+	}
+
+	if err := c.coll.Upsert(ctx, opts...); err != nil {
+		return fmt.Errorf("failed to add records: %w", err)
+	}
+
+	return nil
+}
+
+// metadataMap converts Chroma metadata into plain Go values. Document
+// metadata does not expose its keys through the interface, hence the
+// assertion.
+func metadataMap(md any) map[string]any {
+	m := map[string]any{}
+
+	keyed, ok := md.(interface {
+		Keys() []string
+		GetRaw(key string) (any, bool)
+	})
+	if !ok || keyed == nil {
+		return m
+	}
+
+	for _, k := range keyed.Keys() {
+		v, _ := keyed.GetRaw(k)
+		if mv, ok := v.(chroma.MetadataValue); ok {
+			v, _ = mv.GetRaw()
+		}
+		m[k] = v
+	}
+
+	return m
+}
+
+// results returns the results of the documents of res.
+func (c *chromaCollection) results(res chroma.GetResult) []store.Result {
+	ids := res.GetIDs()
+	metadatas := res.GetMetadatas()
+	results := make([]store.Result, 0, len(res.GetDocuments()))
+	for i, doc := range res.GetDocuments() {
+		r := store.Record{ID: string(ids[i]), Document: doc.ContentString()}
+		if i < len(metadatas) && metadatas[i] != nil {
+			r.Metadata = metadataMap(metadatas[i])
+		}
+		results = append(results, index.Result(r, c.resolve))
+	}
+
+	return results
+}
+
+// whereFilter converts the filters of q to a Chroma where clause, nil
+// without filters.
+func whereFilter(q store.Request) chroma.WhereFilter {
+	var clauses []chroma.WhereClause
+	for _, f := range q.Where {
+		clauses = append(clauses, chroma.InString(f.Key, f.Values...))
+	}
+
+	switch len(clauses) {
+	case 0:
+		return nil
+	case 1:
+		return clauses[0]
+	default:
+		return chroma.And(clauses...)
+	}
+}
+
+// timedCollection times the calls to a collection as the store stage.
+type timedCollection struct {
+	chroma.Collection
+	opts store.Options
+}
+
+func (c *timedCollection) Add(ctx context.Context, opts ...chroma.CollectionAddOption) error {
+	defer c.opts.Time(store.StageStore)()
+	return c.Collection.Add(ctx, opts...)
+}
+
+func (c *timedCollection) Upsert(ctx context.Context, opts ...chroma.CollectionAddOption) error {
+	defer c.opts.Time(store.StageStore)()
+	return c.Collection.Upsert(ctx, opts...)
+}
+
+func (c *timedCollection) Update(ctx context.Context, opts ...chroma.CollectionUpdateOption) error {
+	defer c.opts.Time(store.StageStore)()
+	return c.Collection.Update(ctx, opts...)
+}
+
+func (c *timedCollection) Delete(ctx context.Context, opts ...chroma.CollectionDeleteOption) error {
+	defer c.opts.Time(store.StageStore)()
+	return c.Collection.Delete(ctx, opts...)
+}
+
+func (c *timedCollection) Count(ctx context.Context) (int, error) {
+	defer c.opts.Time(store.StageStore)()
+	return c.Collection.Count(ctx)
+}
+
+func (c *timedCollection) Get(ctx context.Context, opts ...chroma.CollectionGetOption) (chroma.GetResult, error) {
+	defer c.opts.Time(store.StageStore)()
+	return c.Collection.Get(ctx, opts...)
+}
+
+func (c *timedCollection) Query(ctx context.Context, opts ...chroma.CollectionQueryOption) (chroma.QueryResult, error) {
+	defer c.opts.Time(store.StageStore)()
+	return c.Collection.Query(ctx, opts...)
+}
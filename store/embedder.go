@@ -0,0 +1,110 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/amikos-tech/chroma-go/pkg/embeddings"
+	ollama "github.com/amikos-tech/chroma-go/pkg/embeddings/ollama"
+)
+
+// The embedder a collection was built with is recorded in its metadata,
+// since querying with another model silently returns garbage.
+const (
+	EmbedderKey           = "embedder"
+	EmbedderModelKey      = "embedder_model"
+	EmbedderDimensionsKey = "embedder_dimensions"
+
+	DefaultEmbedder      = "ollama"
+	DefaultEmbedderModel = "nomic-embed-text"
+	DefaultEmbedderURL   = "http://127.0.0.1:11434"
+)
+
+// ErrEmbed wraps failures of the embedder, such as Ollama being unreachable,
+// so callers can tell them apart from failures of the store.
+var ErrEmbed = errors.New("failed to embed")
+
+// Embedder embeds the documents and queries of collections.
+type Embedder interface {
+	EmbedDocuments(ctx context.Context, texts []string) ([][]float32, error)
+	EmbedQuery(ctx context.Context, text string) ([]float32, error)
+}
+
+// NewOllamaEmbedder returns the embedder of model served by Ollama at url.
+func NewOllamaEmbedder(url, model string) (Embedder, error) {
+	ef, err := ollama.NewOllamaEmbeddingFunction(ollama.WithBaseURL(url), ollama.WithModel(embeddings.EmbeddingModel(model)))
+	if err != nil {
+		return nil, fmt.Errorf("error creating Ollama embedding function: %w", err)
+	}
+
+	return ollamaEmbedder{ef}, nil
+}
+
+type ollamaEmbedder struct {
+	ef embeddings.EmbeddingFunction
+}
+
+func (e ollamaEmbedder) EmbedDocuments(ctx context.Context, texts []string) ([][]float32, error) {
+	embs, err := e.ef.EmbedDocuments(ctx, texts)
+	if err != nil {
+		return nil, err
+	}
+
+	vectors := make([][]float32, len(embs))
+	for i, emb := range embs {
+		vectors[i] = emb.ContentAsFloat32()
+	}
+
+	return vectors, nil
+}
+
+func (e ollamaEmbedder) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	emb, err := e.ef.EmbedQuery(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+
+	return emb.ContentAsFloat32(), nil
+}
+
+// NewEmbedder returns the embedder of the collections of the store,
+// o.Embedder or the default Ollama one.
+func (o Options) NewEmbedder() (Embedder, error) {
+	if o.Embedder != nil {
+		return o.Embedder, nil
+	}
+
+	return NewOllamaEmbedder(DefaultEmbedderURL, DefaultEmbedderModel)
+}
+
+// EmbedMissing embeds the documents of the records without an embedding,
+// for stores that do not embed documents themselves.
+func (o Options) EmbedMissing(ctx context.Context, ef Embedder, records []Record) error {
+	var texts []string
+	for _, r := range records {
+		if len(r.Embedding) == 0 {
+			texts = append(texts, r.Document)
+		}
+	}
+	if len(texts) == 0 {
+		return nil
+	}
+
+	done := o.Time(StageEmbed)
+	embs, err := ef.EmbedDocuments(ctx, texts)
+	done()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrEmbed, err)
+	}
+	if len(embs) != len(texts) {
+		return fmt.Errorf("embedder returned %d embeddings for %d documents", len(embs), len(texts))
+	}
+	for i := range records {
+		if len(records[i].Embedding) == 0 {
+			records[i].Embedding, embs = embs[0], embs[1:]
+		}
+	}
+
+	return nil
+}
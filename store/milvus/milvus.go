@@ -0,0 +1,885 @@
+// Package milvus is the milvus store, a Milvus server spoken to through its
+// RESTful API. Importing it registers the store, and Open opens a collection
+// directly, as migrate does.
+package milvus
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/karitham/cls/index"
+	"github.com/karitham/cls/store"
+)
+
+// Fields of the collections cls creates in Milvus. Documents keep their
+// Chroma metadata as a JSON field, and their vectors are compared by L2 as
+// in ChromaDB, so migrated collections rank results the same.
+const (
+	milvusIDField        = "id"
+	milvusDocumentField  = "document"
+	milvusEmbeddingField = "embedding"
+	milvusMetadataField  = "metadata"
+	// milvusMaxID and milvusMaxDocument are the VarChar lengths of the ID and
+	// document fields, in bytes.
+	milvusMaxID       = 512
+	milvusMaxDocument = 65535
+)
+
+// Options configures the connection to a Milvus server and the HNSW
+// index of the collections created in it.
+type Options struct {
+	URL      string
+	Token    string
+	Database string
+	// M is the maximum number of edges per node of the HNSW graph, and
+	// EfConstruction the size of the candidate list while building it. Ef
+	// is the size of the candidate list while searching.
+	M              int
+	EfConstruction int
+	Ef             int
+}
+
+// pageSize is how many documents are read per request when going through
+// a whole collection, and deleteBatch how many paths a delete filters on at
+// once.
+const (
+	pageSize    = 500
+	deleteBatch = 100
+)
+
+// Defaults of the HNSW index of created Milvus collections.
+const (
+	DefaultM              = 16
+	DefaultEfConstruction = 200
+)
+
+// Store is a Milvus collection, spoken to through the RESTful API so
+// no SDK is needed. A missing collection is created on the first records
+// added, once their dimension is known.
+type Store struct {
+	opts       Options
+	collection string
+	client     *http.Client
+	exists     bool
+}
+
+// Open opens the Milvus collection, which must exist unless sink is set.
+func Open(ctx context.Context, opts Options, collection string, sink bool) (*Store, error) {
+	if opts.M < 2 || opts.EfConstruction < 1 {
+		return nil, fmt.Errorf("HNSW M must be at least 2 and ef construction positive")
+	}
+
+	s := &Store{opts: opts, collection: collection, client: http.DefaultClient}
+	var has struct {
+		Has bool `json:"has"`
+	}
+	if err := s.call(ctx, "collections/has", map[string]any{}, &has); err != nil {
+		return nil, err
+	}
+	if !has.Has && !sink {
+		return nil, fmt.Errorf("no Milvus collection %q", collection)
+	}
+	s.exists = has.Has
+
+	return s, nil
+}
+
+// call posts req to the endpoint of the RESTful API v2 at path, decoding the
+// data of the response into resp when set, numbers as json.Number. The
+// collection, when set, and database are added to req.
+func (s *Store) call(ctx context.Context, path string, req map[string]any, resp any) error {
+	if s.collection != "" {
+		req["collectionName"] = s.collection
+	}
+	if s.opts.Database != "" {
+		req["dbName"] = s.opts.Database
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("failed to encode Milvus request: %w", err)
+	}
+
+	url := strings.TrimSuffix(s.opts.URL, "/") + "/v2/vectordb/" + path
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create Milvus request: %w", err)
+	}
+	r.Header.Set("Content-Type", "application/json")
+	if s.opts.Token != "" {
+		r.Header.Set("Authorization", "Bearer "+s.opts.Token)
+	}
+
+	res, err := s.client.Do(r)
+	if err != nil {
+		return fmt.Errorf("failed to reach Milvus: %w", err)
+	}
+	defer res.Body.Close()
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read Milvus response: %w", err)
+	}
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("milvus %s: %s: %s", path, res.Status, bytes.TrimSpace(data))
+	}
+
+	// errors come back with a 200 status and a non zero code
+	var envelope struct {
+		Code    int             `json:"code"`
+		Message string          `json:"message"`
+		Data    json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return fmt.Errorf("failed to decode Milvus response: %w", err)
+	}
+	if envelope.Code != 0 {
+		return fmt.Errorf("milvus %s: %s (code %d)", path, envelope.Message, envelope.Code)
+	}
+	if resp == nil || len(envelope.Data) == 0 {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(envelope.Data))
+	dec.UseNumber()
+	if err := dec.Decode(resp); err != nil {
+		return fmt.Errorf("failed to decode Milvus %s response: %w", path, err)
+	}
+
+	return nil
+}
+
+// create creates the collection with vectors of dim dimensions and its HNSW
+// index, and loads it.
+func (s *Store) create(ctx context.Context, dim int) error {
+	schema := map[string]any{
+		"autoID": false,
+		"fields": []map[string]any{
+			{"fieldName": milvusIDField, "dataType": "VarChar", "isPrimary": true, "elementTypeParams": map[string]any{"max_length": milvusMaxID}},
+			{"fieldName": milvusDocumentField, "dataType": "VarChar", "elementTypeParams": map[string]any{"max_length": milvusMaxDocument}},
+			{"fieldName": milvusEmbeddingField, "dataType": "FloatVector", "elementTypeParams": map[string]any{"dim": strconv.Itoa(dim)}},
+			{"fieldName": milvusMetadataField, "dataType": "JSON"},
+		},
+	}
+	index := []map[string]any{{
+		"fieldName":  milvusEmbeddingField,
+		"indexName":  milvusEmbeddingField,
+		"metricType": "L2",
+		"params": map[string]any{
+			"index_type":     "HNSW",
+			"M":              s.opts.M,
+			"efConstruction": s.opts.EfConstruction,
+		},
+	}}
+	// strong consistency lets migrate count the records it just wrote
+	params := map[string]any{"consistencyLevel": "Strong"}
+	if err := s.call(ctx, "collections/create", map[string]any{"schema": schema, "indexParams": index, "params": params}, nil); err != nil {
+		return fmt.Errorf("failed to create Milvus collection: %w", err)
+	}
+	if err := s.call(ctx, "collections/load", map[string]any{}, nil); err != nil {
+		return fmt.Errorf("failed to load Milvus collection: %w", err)
+	}
+	s.exists = true
+
+	return nil
+}
+
+// AddRecords upserts records, which must all have embeddings as Milvus does
+// not embed documents itself.
+func (s *Store) AddRecords(ctx context.Context, records []store.Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+	for _, r := range records {
+		if len(r.Embedding) == 0 {
+			return fmt.Errorf("record %s has no embedding, which Milvus stores need", r.ID)
+		}
+		if len(r.ID) > milvusMaxID || len(r.Document) > milvusMaxDocument {
+			return fmt.Errorf("record %s is longer than the Milvus collection fields", r.ID)
+		}
+	}
+	if !s.exists {
+		if err := s.create(ctx, len(records[0].Embedding)); err != nil {
+			return err
+		}
+	}
+
+	data := make([]map[string]any, len(records))
+	for i, r := range records {
+		md := r.Metadata
+		if md == nil {
+			md = map[string]any{}
+		}
+		data[i] = map[string]any{
+			milvusIDField:        r.ID,
+			milvusDocumentField:  r.Document,
+			milvusEmbeddingField: r.Embedding,
+			milvusMetadataField:  md,
+		}
+	}
+	if err := s.call(ctx, "entities/upsert", map[string]any{"data": data}, nil); err != nil {
+		return fmt.Errorf("failed to upsert records: %w", err)
+	}
+
+	return nil
+}
+
+// milvusFields are the fields of the records read back.
+var milvusFields = []string{milvusIDField, milvusDocumentField, milvusEmbeddingField, milvusMetadataField}
+
+// milvusRow is an entity returned by queries and searches, whose metadata
+// is an object or, depending on the server version, its JSON as a string.
+type milvusRow struct {
+	ID        string          `json:"id"`
+	Document  string          `json:"document"`
+	Embedding []float32       `json:"embedding"`
+	Metadata  json.RawMessage `json:"metadata"`
+	Distance  float64         `json:"distance"`
+}
+
+func (row milvusRow) record() store.Record {
+	r := store.Record{ID: row.ID, Document: row.Document, Embedding: row.Embedding}
+	md := []byte(row.Metadata)
+	var s string
+	if json.Unmarshal(md, &s) == nil {
+		md = []byte(s)
+	}
+	// numbers are kept as in snapshots, so integers stay integers
+	dec := json.NewDecoder(bytes.NewReader(md))
+	dec.UseNumber()
+	dec.Decode(&r.Metadata)
+
+	return r
+}
+
+// Records pages through the collection by primary key, which query results
+// with a limit are sorted by.
+func (s *Store) Records(ctx context.Context) iter.Seq2[store.Record, error] {
+	return func(yield func(store.Record, error) bool) {
+		last := ""
+		for {
+			var page []milvusRow
+			err := s.call(ctx, "entities/query", map[string]any{
+				"filter":       milvusIDField + " > " + strconv.Quote(last),
+				"outputFields": milvusFields,
+				"limit":        pageSize,
+			}, &page)
+			if err != nil {
+				yield(store.Record{}, fmt.Errorf("failed to query records: %w", err))
+				return
+			}
+
+			slices.SortFunc(page, func(a, b milvusRow) int {
+				return strings.Compare(a.ID, b.ID)
+			})
+			for _, row := range page {
+				if !yield(row.record(), nil) {
+					return
+				}
+				last = row.ID
+			}
+			if len(page) < pageSize {
+				return
+			}
+		}
+	}
+}
+
+func (s *Store) Count(ctx context.Context) (int, error) {
+	return s.count(ctx, "")
+}
+
+// count returns the number of entities matching filter, every entity when
+// it is empty.
+func (s *Store) count(ctx context.Context, filter string) (int, error) {
+	if !s.exists {
+		return 0, nil
+	}
+
+	var rows []struct {
+		Count int `json:"count(*)"`
+	}
+	if err := s.call(ctx, "entities/query", map[string]any{"filter": filter, "outputFields": []string{"count(*)"}}, &rows); err != nil {
+		return 0, fmt.Errorf("failed to count records: %w", err)
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+
+	return rows[0].Count, nil
+}
+
+func (s *Store) Close() error {
+	return nil
+}
+
+func init() {
+	store.Register("milvus", newMilvusClient)
+}
+
+// milvusMetadataKey is the collection property holding the metadata of the
+// collections cls creates in Milvus, as JSON.
+const milvusMetadataKey = "cls.metadata"
+
+var errMilvusUnsupported = errors.New("not supported by the milvus store")
+
+// milvusClient is the milvus store. Documents are embedded by cls, and the
+// HNSW index of the collections it creates is configured by Options.HNSW.
+type milvusClient struct {
+	opts      Options
+	storeOpts store.Options
+	client    *http.Client
+	ef        store.Embedder
+	logger    *slog.Logger
+}
+
+func newMilvusClient(opts store.Options, logger *slog.Logger) (store.Client, error) {
+	token := opts.Token
+	if opts.Username != "" {
+		// Milvus takes user:password as token
+		token = opts.Username + ":" + opts.Password
+	}
+	mopts := Options{
+		URL:            opts.URL,
+		Token:          token,
+		Database:       opts.Database,
+		M:              cmp.Or(opts.HNSW.M, DefaultM),
+		EfConstruction: cmp.Or(opts.HNSW.EfConstruction, DefaultEfConstruction),
+		Ef:             opts.HNSW.Ef,
+	}
+	if mopts.M < 2 || mopts.EfConstruction < 1 || mopts.Ef < 0 {
+		return nil, fmt.Errorf("HNSW M must be at least 2, ef construction positive and ef not negative")
+	}
+
+	ef, err := opts.NewEmbedder()
+	if err != nil {
+		return nil, err
+	}
+
+	return &milvusClient{opts: mopts, storeOpts: opts, client: &http.Client{Timeout: opts.Timeout}, ef: ef, logger: logger}, nil
+}
+
+func (c *milvusClient) store(name string) *Store {
+	return &Store{opts: c.opts, collection: name, client: c.client}
+}
+
+// metadata returns the metadata of the collection name, and whether it
+// exists.
+func (c *milvusClient) metadata(ctx context.Context, name string) (map[string]any, bool, error) {
+	var has struct {
+		Has bool `json:"has"`
+	}
+	if err := c.store(name).call(ctx, "collections/has", map[string]any{}, &has); err != nil {
+		return nil, false, fmt.Errorf("failed to get collection: %w", err)
+	}
+	if !has.Has {
+		return nil, false, nil
+	}
+
+	var desc struct {
+		Properties []struct {
+			Key   string `json:"key"`
+			Value string `json:"value"`
+		} `json:"properties"`
+	}
+	if err := c.store(name).call(ctx, "collections/describe", map[string]any{}, &desc); err != nil {
+		return nil, false, fmt.Errorf("failed to get collection: %w", err)
+	}
+
+	md := map[string]any{}
+	for _, p := range desc.Properties {
+		if p.Key != milvusMetadataKey {
+			continue
+		}
+		dec := json.NewDecoder(strings.NewReader(p.Value))
+		dec.UseNumber()
+		if err := dec.Decode(&md); err != nil {
+			return nil, false, fmt.Errorf("invalid metadata of collection %s: %w", name, err)
+		}
+	}
+
+	return md, true, nil
+}
+
+func (c *milvusClient) setMetadata(ctx context.Context, name string, md map[string]any) error {
+	data, err := json.Marshal(md)
+	if err != nil {
+		return fmt.Errorf("failed to encode collection metadata: %w", err)
+	}
+	props := map[string]any{"properties": map[string]any{milvusMetadataKey: string(data)}}
+	if err := c.store(name).call(ctx, "collections/alter_properties", props, nil); err != nil {
+		return fmt.Errorf("failed to update collection metadata: %w", err)
+	}
+
+	return nil
+}
+
+// create creates the collection of s for the vectors of the embedder, whose
+// dimension is found by embedding a probe.
+func (c *milvusClient) create(ctx context.Context, s *Store) error {
+	emb, err := c.ef.EmbedQuery(ctx, s.collection)
+	if err != nil {
+		return fmt.Errorf("%w: %w", store.ErrEmbed, err)
+	}
+
+	return s.create(ctx, len(emb))
+}
+
+func (c *milvusClient) collection(name string, md map[string]any) *milvusCollection {
+	s := c.store(name)
+	s.exists = true
+
+	return &milvusCollection{Store: s, storeOpts: c.storeOpts, ef: c.ef, metadata: md, logger: c.logger}
+}
+
+func (c *milvusClient) GetOrCreateCollection(ctx context.Context, name string) (store.Collection, error) {
+	md, ok, err := c.metadata(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		md = map[string]any{
+			store.ManagedByKey:     "cls",
+			store.EmbedderKey:      store.DefaultEmbedder,
+			store.EmbedderModelKey: store.DefaultEmbedderModel,
+		}
+		if err := c.create(ctx, c.store(name)); err != nil {
+			return nil, fmt.Errorf("failed to get/create collection: %w", err)
+		}
+		if err := c.setMetadata(ctx, name, md); err != nil {
+			return nil, fmt.Errorf("failed to get/create collection: %w", err)
+		}
+	}
+
+	return c.collection(name, md), nil
+}
+
+func (c *milvusClient) GetCollection(ctx context.Context, name string) (store.Collection, error) {
+	md, ok, err := c.metadata(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("failed to get collection: no collection %q", name)
+	}
+
+	return c.collection(name, md), nil
+}
+
+func (c *milvusClient) DeleteCollection(ctx context.Context, name string) error {
+	if _, ok, err := c.metadata(ctx, name); err != nil || !ok {
+		return fmt.Errorf("failed to delete collection: no collection %q", name)
+	}
+	if err := c.store(name).call(ctx, "collections/drop", map[string]any{}, nil); err != nil {
+		return fmt.Errorf("failed to delete collection: %w", err)
+	}
+
+	return nil
+}
+
+func (c *milvusClient) ListCollections(ctx context.Context) ([]store.Info, error) {
+	var names []string
+	if err := c.store("").call(ctx, "collections/list", map[string]any{}, &names); err != nil {
+		return nil, fmt.Errorf("failed to list collections: %w", err)
+	}
+
+	infos := make([]store.Info, 0, len(names))
+	for _, name := range names {
+		md, _, err := c.metadata(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+
+		info := store.Info{Name: name, Metadata: md}
+		info.Managed = md[store.ManagedByKey] == "cls"
+		info.Protected, _ = md[store.ProtectedKey].(bool)
+		if info.Count, err = c.collection(name, md).Count(ctx); err != nil {
+			return nil, fmt.Errorf("failed to count collection %s: %w", name, err)
+		}
+		infos = append(infos, info)
+	}
+	slices.SortFunc(infos, func(a, b store.Info) int { return strings.Compare(a.Name, b.Name) })
+
+	return infos, nil
+}
+
+func (c *milvusClient) RenameCollection(ctx context.Context, name, newName string) error {
+	if err := c.store(name).call(ctx, "collections/rename", map[string]any{"newCollectionName": newName}, nil); err != nil {
+		return fmt.Errorf("failed to rename collection: %w", err)
+	}
+
+	return nil
+}
+
+// CopyCollection copies the records of name page by page, the new
+// collection being created with the dimension of the first ones.
+func (c *milvusClient) CopyCollection(ctx context.Context, name, newName string) (int, error) {
+	md, ok, err := c.metadata(ctx, name)
+	if err != nil || !ok {
+		return 0, fmt.Errorf("failed to get collection: no collection %q", name)
+	}
+	if _, exists, _ := c.metadata(ctx, newName); exists {
+		return 0, fmt.Errorf("failed to create collection: %s already exists", newName)
+	}
+
+	src, dst := c.collection(name, md), c.store(newName)
+	var (
+		batch  []store.Record
+		copied int
+	)
+	for r, err := range src.Records(ctx) {
+		if err != nil {
+			return copied, fmt.Errorf("failed to read documents: %w", err)
+		}
+		if batch = append(batch, r); len(batch) == pageSize {
+			if err := dst.AddRecords(ctx, batch); err != nil {
+				return copied, fmt.Errorf("failed to write documents: %w", err)
+			}
+			copied, batch = copied+len(batch), batch[:0]
+		}
+	}
+	if err := dst.AddRecords(ctx, batch); err != nil {
+		return copied, fmt.Errorf("failed to write documents: %w", err)
+	}
+	if !dst.exists {
+		if err := c.create(ctx, dst); err != nil {
+			return 0, fmt.Errorf("failed to create collection: %w", err)
+		}
+	}
+	if err := c.setMetadata(ctx, newName, md); err != nil {
+		return copied, fmt.Errorf("failed to create collection: %w", err)
+	}
+
+	return copied + len(batch), nil
+}
+
+func (c *milvusClient) SetProtected(ctx context.Context, name string, protected bool) error {
+	md, ok, err := c.metadata(ctx, name)
+	if err != nil || !ok {
+		return fmt.Errorf("failed to get collection: no collection %q", name)
+	}
+	md[store.ProtectedKey] = protected
+
+	return c.setMetadata(ctx, name, md)
+}
+
+func (c *milvusClient) SetMetadata(ctx context.Context, name, key, value string) error {
+	md, ok, err := c.metadata(ctx, name)
+	if err != nil || !ok {
+		return fmt.Errorf("failed to get collection: no collection %q", name)
+	}
+	md[key] = value
+
+	return c.setMetadata(ctx, name, md)
+}
+
+func (c *milvusClient) IsProtected(ctx context.Context, name string) (bool, error) {
+	md, ok, err := c.metadata(ctx, name)
+	if err != nil || !ok {
+		return false, fmt.Errorf("failed to get collection: no collection %q", name)
+	}
+
+	protected, _ := md[store.ProtectedKey].(bool)
+	return protected, nil
+}
+
+func (c *milvusClient) NextVersion(ctx context.Context, name string) (string, store.Collection, error) {
+	return "", nil, fmt.Errorf("index -replace is %w", errMilvusUnsupported)
+}
+
+func (c *milvusClient) SwapAlias(ctx context.Context, name, target string) error {
+	return fmt.Errorf("index -replace is %w", errMilvusUnsupported)
+}
+
+// Version only checks that Milvus answers, its RESTful API not telling its
+// version.
+func (c *milvusClient) Version(ctx context.Context) (string, error) {
+	var names []string
+	if err := c.store("").call(ctx, "collections/list", map[string]any{}, &names); err != nil {
+		return "", err
+	}
+
+	return "unknown", nil
+}
+
+func (c *milvusClient) Close() error {
+	return nil
+}
+
+// milvusCollection is a collection of the milvus store. Metadata filters
+// are expressions on the metadata JSON field, evaluated by Milvus.
+type milvusCollection struct {
+	*Store
+	storeOpts store.Options
+	ef        store.Embedder
+	metadata  map[string]any
+	logger    *slog.Logger
+}
+
+func (c *milvusCollection) Writer() store.Writer {
+	return store.NewRecordWriter(c)
+}
+
+// result returns the query result of r.
+func (c *milvusCollection) result(r store.Record) store.Result {
+	return index.Result(r, c.storeOpts.ResolvePath)
+}
+
+// AddRecords upserts records, embedding those without an embedding.
+func (c *milvusCollection) AddRecords(ctx context.Context, records []store.Record) error {
+	if err := c.storeOpts.EmbedMissing(ctx, c.ef, records); err != nil {
+		return err
+	}
+
+	done := c.storeOpts.Time(store.StageStore)
+	defer done()
+	return c.Store.AddRecords(ctx, records)
+}
+
+func (c *milvusCollection) GetRecords(ctx context.Context, ids ...string) ([]store.Record, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	var rows []milvusRow
+	if err := c.call(ctx, "entities/get", map[string]any{"id": ids, "outputFields": milvusFields}, &rows); err != nil {
+		return nil, fmt.Errorf("failed to get documents: %w", err)
+	}
+
+	// in the order of ids, as the other stores
+	byID := make(map[string]store.Record, len(rows))
+	for _, row := range rows {
+		byID[row.ID] = row.record()
+	}
+	var records []store.Record
+	for _, id := range ids {
+		if r, ok := byID[id]; ok {
+			records = append(records, r)
+		}
+	}
+
+	return records, nil
+}
+
+// Query runs a search per query text, with the filters as a Milvus
+// expression.
+func (c *milvusCollection) Query(ctx context.Context, text string, opts ...store.Option) (store.Response, error) {
+	q := store.NewRequest(text, opts...)
+
+	var negatives [][]float32
+	for _, t := range q.Negatives {
+		emb, err := c.ef.EmbedQuery(ctx, t)
+		if err != nil {
+			return store.Response{}, fmt.Errorf("%w: %w", store.ErrEmbed, err)
+		}
+		negatives = append(negatives, emb)
+	}
+
+	fields := []string{milvusIDField, milvusDocumentField, milvusMetadataField}
+	if q.Embeddings() {
+		fields = append(fields, milvusEmbeddingField)
+	}
+
+	var (
+		groups [][]store.Result
+		first  []float32
+	)
+	for _, t := range q.Texts() {
+		emb, err := c.ef.EmbedQuery(ctx, t)
+		if err != nil {
+			return store.Response{}, fmt.Errorf("%w: %w", store.ErrEmbed, err)
+		}
+		qe := emb
+		if first == nil {
+			first = qe
+		}
+
+		var rows []milvusRow
+		done := c.storeOpts.Time(store.StageStore)
+		err = c.call(ctx, "entities/search", map[string]any{
+			"data":         [][]float32{qe},
+			"annsField":    milvusEmbeddingField,
+			"limit":        q.Fetch(),
+			"filter":       milvusFilter(q.Where),
+			"outputFields": fields,
+			"searchParams": map[string]any{
+				"metricType": "L2",
+				"params":     map[string]any{"ef": max(q.Fetch(), c.opts.Ef)},
+			},
+		}, &rows)
+		done()
+		if err != nil {
+			return store.Response{}, fmt.Errorf("failed to query collection: %w", err)
+		}
+
+		results := make([]store.Result, len(rows))
+		for i, row := range rows {
+			r := row.record()
+			results[i] = c.result(r)
+			// L2 distances are squared, as in ChromaDB
+			results[i].Distance = row.Distance
+			if q.Embeddings() {
+				results[i].Embedding = r.Embedding
+			}
+		}
+		groups = append(groups, results)
+	}
+
+	groups, err := store.ResolveSummaries(ctx, c, q.InTarget(groups))
+	if err != nil {
+		return store.Response{}, err
+	}
+	results, err := q.Finish(ctx, q.Penalize(store.Fuse(groups), negatives))
+	if err != nil {
+		return store.Response{}, err
+	}
+
+	resp := store.Response{Results: results}
+	if q.IncludeEmbeddings {
+		resp.Embedding = first
+	}
+
+	return resp, nil
+}
+
+// milvusKey is the expression of the metadata key.
+func milvusKey(key string) string {
+	return milvusMetadataField + "[" + strconv.Quote(key) + "]"
+}
+
+// milvusStrings is the expression of a list of strings.
+func milvusStrings(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = strconv.Quote(v)
+	}
+
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+// milvusFilter converts the filters to a Milvus expression, "" without
+// filters.
+func milvusFilter(where []store.Filter) string {
+	clauses := make([]string, len(where))
+	for i, f := range where {
+		clauses[i] = milvusKey(f.Key) + " in " + milvusStrings(f.Values)
+	}
+
+	return strings.Join(clauses, " and ")
+}
+
+// KeywordSearch matches the documents with like patterns, the document
+// field having no full text index.
+func (c *milvusCollection) KeywordSearch(ctx context.Context, terms []string, n int) ([]store.Result, error) {
+	var clauses []string
+	for _, t := range terms {
+		if t == "" {
+			continue
+		}
+		// % and _ are wildcards of like patterns
+		t = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(t)
+		clauses = append(clauses, milvusDocumentField+" like "+strconv.Quote("%"+t+"%"))
+	}
+	if len(clauses) == 0 {
+		return []store.Result{}, nil
+	}
+
+	var rows []milvusRow
+	err := c.call(ctx, "entities/query", map[string]any{
+		"filter":       strings.Join(clauses, " or "),
+		"outputFields": []string{milvusIDField, milvusDocumentField, milvusMetadataField},
+		"limit":        max(n*10, 100),
+	}, &rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search collection: %w", err)
+	}
+
+	var results []store.Result
+	for _, row := range rows {
+		result := c.result(row.record())
+		if result.SummaryOf != "" {
+			continue
+		}
+		result.Score = store.KeywordScore(result.Content, terms)
+		results = append(results, result)
+	}
+	slices.SortStableFunc(results, func(a, b store.Result) int {
+		return cmp.Compare(b.Score, a.Score)
+	})
+
+	return results[:min(n, len(results))], nil
+}
+
+func (c *milvusCollection) Get(ctx context.Context, ids ...string) ([]store.Result, error) {
+	records, err := c.GetRecords(ctx, ids...)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]store.Result, len(records))
+	for i, r := range records {
+		results[i] = c.result(r)
+	}
+
+	return results, nil
+}
+
+// deleteMatching deletes the documents matching filter and returns how many
+// were deleted, counted beforehand as Milvus does not tell.
+func (c *milvusCollection) deleteMatching(ctx context.Context, filter string) (int, error) {
+	n, err := c.count(ctx, filter)
+	if err != nil || n == 0 {
+		return 0, err
+	}
+	if err := c.call(ctx, "entities/delete", map[string]any{"filter": filter}, nil); err != nil {
+		return 0, fmt.Errorf("failed to delete documents: %w", err)
+	}
+
+	return n, nil
+}
+
+func (c *milvusCollection) DeleteIndexedBefore(ctx context.Context, t time.Time) (int, error) {
+	return c.deleteMatching(ctx, fmt.Sprintf("%s < %d", milvusKey(index.IndexedAtKey), t.Unix()))
+}
+
+func (c *milvusCollection) DeleteRun(ctx context.Context, id string) (int, error) {
+	return c.deleteMatching(ctx, milvusKey(index.RunKey)+" == "+strconv.Quote(id))
+}
+
+func (c *milvusCollection) DeletePaths(ctx context.Context, rootID string, paths []string) (int, error) {
+	deleted := 0
+	for batch := range slices.Chunk(paths, deleteBatch) {
+		n, err := c.deleteMatching(ctx, milvusKey(index.RootKey)+" == "+strconv.Quote(rootID)+" and "+milvusKey("path")+" in "+milvusStrings(batch))
+		deleted += n
+		if err != nil {
+			return deleted, err
+		}
+	}
+
+	return deleted, nil
+}
+
+func (c *milvusCollection) Delete(ctx context.Context, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := c.call(ctx, "entities/delete", map[string]any{"filter": milvusIDField + " in " + milvusStrings(ids)}, nil); err != nil {
+		return fmt.Errorf("failed to delete documents: %w", err)
+	}
+
+	return nil
+}
+
+func (c *milvusCollection) Metadata() map[string]any {
+	return c.metadata
+}
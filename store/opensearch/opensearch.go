@@ -0,0 +1,859 @@
+// Package opensearch holds the elasticsearch and opensearch stores, whose
+// collections are indexes of dense vectors. Importing it registers both.
+package opensearch
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/karitham/cls/index"
+	"github.com/karitham/cls/store"
+)
+
+func init() {
+	store.Register("elasticsearch", newSearchClient(false))
+	store.Register("opensearch", newSearchClient(true))
+}
+
+var errSearchUnsupported = errors.New("not supported by the elasticsearch and opensearch stores")
+
+// pageSize is how many documents are read per search when going through a
+// whole collection, and deleteBatch how many paths a delete matches at once.
+const (
+	pageSize    = 500
+	deleteBatch = 100
+)
+
+// searchClient is the elasticsearch or opensearch store. Collections are indexes of
+// dense vectors whose index template is managed by cls, documents being
+// embedded by cls. The metadata of a collection is the _meta of its mapping.
+type searchClient struct {
+	url string
+	// apiKey is sent as an Elasticsearch API key, and takes precedence over
+	// username and password.
+	apiKey   string
+	username string
+	password string
+	// opensearch selects the knn_vector mapping and knn query of OpenSearch
+	// over the dense_vector ones of Elasticsearch.
+	opensearch bool
+	hnsw       store.HNSWOptions
+	opts       store.Options
+	client     *http.Client
+	ef         store.Embedder
+	logger     *slog.Logger
+}
+
+func newSearchClient(opensearch bool) store.Factory {
+	return func(opts store.Options, logger *slog.Logger) (store.Client, error) {
+		if opts.HNSW.M < 0 || opts.HNSW.EfConstruction < 0 || opts.HNSW.Ef < 0 {
+			return nil, errors.New("HNSW M, ef construction and ef must not be negative")
+		}
+
+		client, err := opts.HTTPClient()
+		if err != nil {
+			return nil, err
+		}
+
+		ef, err := opts.NewEmbedder()
+		if err != nil {
+			return nil, err
+		}
+
+		return &searchClient{
+			url:        opts.URL,
+			apiKey:     opts.Token,
+			username:   opts.Username,
+			password:   opts.Password,
+			opensearch: opensearch,
+			hnsw:       opts.HNSW,
+			opts:       opts,
+			client:     client,
+			ef:         ef,
+			logger:     logger,
+		}, nil
+	}
+}
+
+// do sends body, encoded as JSON unless it is already bytes, to path and
+// decodes the response into resp when set, numbers as json.Number. It
+// returns the response status, with an error for statuses other than 2xx.
+func (c *searchClient) do(ctx context.Context, method, path string, body, resp any) (int, error) {
+	var (
+		r           io.Reader
+		contentType = "application/json"
+	)
+	switch b := body.(type) {
+	case nil:
+	case []byte:
+		r, contentType = bytes.NewReader(b), "application/x-ndjson"
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			return 0, fmt.Errorf("failed to encode search request: %w", err)
+		}
+		r = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(c.url, "/")+"/"+path, r)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create search request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	switch {
+	case c.apiKey != "":
+		req.Header.Set("Authorization", "ApiKey "+c.apiKey)
+	case c.username != "":
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to reach search cluster: %w", err)
+	}
+	defer res.Body.Close()
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return res.StatusCode, fmt.Errorf("failed to read search response: %w", err)
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("%s %s: %s: %s", method, path, res.Status, bytes.TrimSpace(data))
+	}
+	if resp != nil {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		if err := dec.Decode(resp); err != nil {
+			return res.StatusCode, fmt.Errorf("failed to decode search response: %w", err)
+		}
+	}
+
+	return res.StatusCode, nil
+}
+
+// vectorMapping returns the mapping of the embedding field for vectors of
+// dim dimensions, compared by L2 as in ChromaDB.
+func (c *searchClient) vectorMapping(dim int) map[string]any {
+	hnsw := map[string]any{}
+	if c.hnsw.M > 0 {
+		hnsw["m"] = c.hnsw.M
+	}
+	if c.hnsw.EfConstruction > 0 {
+		hnsw["ef_construction"] = c.hnsw.EfConstruction
+	}
+
+	if c.opensearch {
+		return map[string]any{
+			"type":      "knn_vector",
+			"dimension": dim,
+			"method":    map[string]any{"name": "hnsw", "space_type": "l2", "engine": "lucene", "parameters": hnsw},
+		}
+	}
+
+	vector := map[string]any{"type": "dense_vector", "dims": dim, "index": true, "similarity": "l2_norm"}
+	if len(hnsw) > 0 {
+		hnsw["type"] = "hnsw"
+		vector["index_options"] = hnsw
+	}
+	return vector
+}
+
+// template returns the index template of the index name. Metadata keys are
+// mapped as they come, strings as keywords so queries and deletes can filter
+// on them. The embedding field is only mapped once the dimension is known,
+// dim being 0 until then.
+func (c *searchClient) template(name string, dim int) map[string]any {
+	properties := map[string]any{
+		"id":       map[string]any{"type": "keyword"},
+		"document": map[string]any{"type": "text"},
+		"metadata": map[string]any{"type": "object", "dynamic": true},
+	}
+	if dim > 0 {
+		properties["embedding"] = c.vectorMapping(dim)
+	}
+	settings := map[string]any{}
+	if c.opensearch {
+		settings["index.knn"] = true
+	}
+
+	return map[string]any{
+		"index_patterns": []string{name},
+		"template": map[string]any{
+			"settings": settings,
+			"mappings": map[string]any{
+				"dynamic":        false,
+				"date_detection": false,
+				"dynamic_templates": []any{map[string]any{
+					"metadata_strings": map[string]any{
+						"path_match":         "metadata.*",
+						"match_mapping_type": "string",
+						// longer values are stored but not indexed
+						"mapping": map[string]any{"type": "keyword", "ignore_above": 1024},
+					},
+				}},
+				"properties": properties,
+			},
+		},
+		"_meta": map[string]any{store.ManagedByKey: "cls"},
+	}
+}
+
+func searchTemplate(name string) string {
+	return "_index_template/cls-" + url.PathEscape(name)
+}
+
+// create puts the index template of name and creates the index from it,
+// with the collection metadata md.
+func (c *searchClient) create(ctx context.Context, name string, md map[string]any, dim int) error {
+	if _, err := c.do(ctx, http.MethodPut, searchTemplate(name), c.template(name, dim), nil); err != nil {
+		return fmt.Errorf("failed to put index template: %w", err)
+	}
+	body := map[string]any{"mappings": map[string]any{"_meta": md}}
+	if _, err := c.do(ctx, http.MethodPut, url.PathEscape(name), body, nil); err != nil {
+		return fmt.Errorf("failed to create index: %w", err)
+	}
+
+	return nil
+}
+
+// metadata returns the metadata of the collection name, the dimension of
+// its vectors, 0 before any was added, and whether it exists.
+func (c *searchClient) metadata(ctx context.Context, name string) (map[string]any, int, bool, error) {
+	var resp map[string]searchMapping
+	status, err := c.do(ctx, http.MethodGet, url.PathEscape(name)+"/_mapping", nil, &resp)
+	if status == http.StatusNotFound {
+		return nil, 0, false, nil
+	}
+	if err != nil {
+		return nil, 0, false, fmt.Errorf("failed to get collection: %w", err)
+	}
+
+	for _, m := range resp {
+		md := m.Mappings.Meta
+		if md == nil {
+			md = map[string]any{}
+		}
+		return md, m.dim(), true, nil
+	}
+
+	return nil, 0, false, nil
+}
+
+// searchMapping is the mapping of an index, as returned by _mapping.
+type searchMapping struct {
+	Mappings struct {
+		Meta       map[string]any `json:"_meta"`
+		Properties map[string]struct {
+			Dims      json.Number `json:"dims"`
+			Dimension json.Number `json:"dimension"`
+		} `json:"properties"`
+	} `json:"mappings"`
+}
+
+// dim returns the dimension of the embedding field, 0 when unmapped.
+func (m searchMapping) dim() int {
+	vector := m.Mappings.Properties["embedding"]
+	n, _ := cmp.Or(vector.Dims, vector.Dimension).Int64()
+
+	return int(n)
+}
+
+// isCollection reports whether the index has the fields of a cls index.
+func (m searchMapping) isCollection() bool {
+	_, ok := m.Mappings.Properties["document"]
+	return ok
+}
+
+func (c *searchClient) setMetadata(ctx context.Context, name string, md map[string]any) error {
+	if _, err := c.do(ctx, http.MethodPut, url.PathEscape(name)+"/_mapping", map[string]any{"_meta": md}, nil); err != nil {
+		return fmt.Errorf("failed to update collection metadata: %w", err)
+	}
+
+	return nil
+}
+
+func (c *searchClient) collection(name string, md map[string]any, dim int) *searchIndex {
+	return &searchIndex{client: c, index: name, dim: dim, storeOpts: c.opts, ef: c.ef, metadata: md, logger: c.logger}
+}
+
+func (c *searchClient) GetOrCreateCollection(ctx context.Context, name string) (store.Collection, error) {
+	md, dim, ok, err := c.metadata(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		md = map[string]any{
+			store.ManagedByKey:     "cls",
+			store.EmbedderKey:      store.DefaultEmbedder,
+			store.EmbedderModelKey: store.DefaultEmbedderModel,
+		}
+		if err := c.create(ctx, name, md, 0); err != nil {
+			return nil, fmt.Errorf("failed to get/create collection: %w", err)
+		}
+	}
+
+	return c.collection(name, md, dim), nil
+}
+
+func (c *searchClient) GetCollection(ctx context.Context, name string) (store.Collection, error) {
+	md, dim, ok, err := c.metadata(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("failed to get collection: no collection %q", name)
+	}
+
+	return c.collection(name, md, dim), nil
+}
+
+func (c *searchClient) DeleteCollection(ctx context.Context, name string) error {
+	if _, err := c.do(ctx, http.MethodDelete, url.PathEscape(name), nil, nil); err != nil {
+		return fmt.Errorf("failed to delete collection: %w", err)
+	}
+	// the template would recreate the index on the next write to it
+	if status, err := c.do(ctx, http.MethodDelete, searchTemplate(name), nil, nil); err != nil && status != http.StatusNotFound {
+		return fmt.Errorf("failed to delete index template: %w", err)
+	}
+
+	return nil
+}
+
+// ListCollections lists the indexes laid out as cls collections, skipping
+// the other indexes of the cluster.
+func (c *searchClient) ListCollections(ctx context.Context) ([]store.Info, error) {
+	var mappings map[string]searchMapping
+	if _, err := c.do(ctx, http.MethodGet, "_mapping", nil, &mappings); err != nil {
+		return nil, fmt.Errorf("failed to list collections: %w", err)
+	}
+
+	var infos []store.Info
+	for name, m := range mappings {
+		if strings.HasPrefix(name, ".") || !m.isCollection() {
+			continue
+		}
+		md := m.Mappings.Meta
+		if md == nil {
+			md = map[string]any{}
+		}
+
+		info := store.Info{Name: name, Metadata: md}
+		info.Managed = md[store.ManagedByKey] == "cls"
+		info.Protected, _ = md[store.ProtectedKey].(bool)
+		var err error
+		if info.Count, err = c.collection(name, md, m.dim()).Count(ctx); err != nil {
+			return nil, fmt.Errorf("failed to count collection %s: %w", name, err)
+		}
+		infos = append(infos, info)
+	}
+	slices.SortFunc(infos, func(a, b store.Info) int { return strings.Compare(a.Name, b.Name) })
+
+	return infos, nil
+}
+
+// RenameCollection is not supported, indexes not being renamable.
+func (c *searchClient) RenameCollection(ctx context.Context, name, newName string) error {
+	return fmt.Errorf("failed to rename collection: %w, copy it instead", errSearchUnsupported)
+}
+
+// CopyCollection creates newName with the mapping and metadata of name and
+// reindexes the documents of name into it on the cluster.
+func (c *searchClient) CopyCollection(ctx context.Context, name, newName string) (int, error) {
+	md, dim, ok, err := c.metadata(ctx, name)
+	if err != nil || !ok {
+		return 0, fmt.Errorf("failed to get collection: no collection %q", name)
+	}
+	if _, _, exists, _ := c.metadata(ctx, newName); exists {
+		return 0, fmt.Errorf("failed to create collection: %s already exists", newName)
+	}
+	if err := c.create(ctx, newName, md, dim); err != nil {
+		return 0, fmt.Errorf("failed to create collection: %w", err)
+	}
+
+	var resp struct {
+		Created  int               `json:"created"`
+		Failures []json.RawMessage `json:"failures"`
+	}
+	body := map[string]any{"source": map[string]any{"index": name}, "dest": map[string]any{"index": newName}}
+	if _, err := c.do(ctx, http.MethodPost, "_reindex?refresh=true", body, &resp); err != nil {
+		return 0, fmt.Errorf("failed to copy documents: %w", err)
+	}
+	if len(resp.Failures) > 0 {
+		return resp.Created, fmt.Errorf("failed to copy documents: %s", resp.Failures[0])
+	}
+
+	return resp.Created, nil
+}
+
+func (c *searchClient) SetProtected(ctx context.Context, name string, protected bool) error {
+	md, _, ok, err := c.metadata(ctx, name)
+	if err != nil || !ok {
+		return fmt.Errorf("failed to get collection: no collection %q", name)
+	}
+	md[store.ProtectedKey] = protected
+
+	return c.setMetadata(ctx, name, md)
+}
+
+func (c *searchClient) SetMetadata(ctx context.Context, name, key, value string) error {
+	md, _, ok, err := c.metadata(ctx, name)
+	if err != nil || !ok {
+		return fmt.Errorf("failed to get collection: no collection %q", name)
+	}
+	md[key] = value
+
+	return c.setMetadata(ctx, name, md)
+}
+
+func (c *searchClient) IsProtected(ctx context.Context, name string) (bool, error) {
+	md, _, ok, err := c.metadata(ctx, name)
+	if err != nil || !ok {
+		return false, fmt.Errorf("failed to get collection: no collection %q", name)
+	}
+
+	protected, _ := md[store.ProtectedKey].(bool)
+	return protected, nil
+}
+
+func (c *searchClient) NextVersion(ctx context.Context, name string) (string, store.Collection, error) {
+	return "", nil, fmt.Errorf("index -replace is %w", errSearchUnsupported)
+}
+
+func (c *searchClient) SwapAlias(ctx context.Context, name, target string) error {
+	return fmt.Errorf("index -replace is %w", errSearchUnsupported)
+}
+
+// Version returns the distribution and version of the cluster.
+func (c *searchClient) Version(ctx context.Context) (string, error) {
+	var resp struct {
+		Version struct {
+			Number       string `json:"number"`
+			Distribution string `json:"distribution"`
+		} `json:"version"`
+	}
+	if _, err := c.do(ctx, http.MethodGet, "", nil, &resp); err != nil {
+		return "", fmt.Errorf("failed to get search cluster version: %w", err)
+	}
+
+	return strings.TrimSpace(resp.Version.Distribution + " " + resp.Version.Number), nil
+}
+
+func (c *searchClient) Close() error {
+	return nil
+}
+
+// searchIndex is a collection of the elasticsearch or opensearch store.
+// Metadata filters are term queries on the metadata keyword fields,
+// evaluated by the cluster.
+type searchIndex struct {
+	client *searchClient
+	index  string
+	// dim is the dimension of the mapped embedding field, 0 until the
+	// first documents are added.
+	dim       int
+	storeOpts store.Options
+	ef        store.Embedder
+	metadata  map[string]any
+	logger    *slog.Logger
+}
+
+func (c *searchIndex) Writer() store.Writer {
+	return store.NewRecordWriter(c)
+}
+
+// result returns the query result of r.
+func (c *searchIndex) result(r store.Record) store.Result {
+	return index.Result(r, c.storeOpts.ResolvePath)
+}
+
+// path returns the path of the endpoint of the index.
+func (c *searchIndex) path(endpoint string) string {
+	return url.PathEscape(c.index) + "/" + endpoint
+}
+
+// mapVectors maps the embedding field for vectors of dim dimensions in the
+// index and its template, when it is not mapped yet.
+func (c *searchIndex) mapVectors(ctx context.Context, dim int) error {
+	if c.dim != 0 {
+		return nil
+	}
+
+	mapping := map[string]any{"properties": map[string]any{"embedding": c.client.vectorMapping(dim)}}
+	if _, err := c.client.do(ctx, http.MethodPut, c.path("_mapping"), mapping, nil); err != nil {
+		return fmt.Errorf("failed to map embeddings: %w", err)
+	}
+	if _, err := c.client.do(ctx, http.MethodPut, searchTemplate(c.index), c.client.template(c.index, dim), nil); err != nil {
+		return fmt.Errorf("failed to put index template: %w", err)
+	}
+	c.dim = dim
+
+	return nil
+}
+
+// AddRecords indexes records in one bulk request, replacing the documents
+// with the same IDs and embedding those without an embedding.
+func (c *searchIndex) AddRecords(ctx context.Context, records []store.Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	if err := c.storeOpts.EmbedMissing(ctx, c.ef, records); err != nil {
+		return err
+	}
+	if err := c.mapVectors(ctx, len(records[0].Embedding)); err != nil {
+		return err
+	}
+
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	for _, r := range records {
+		if err := enc.Encode(map[string]any{"index": map[string]any{"_index": c.index, "_id": r.ID}}); err != nil {
+			return fmt.Errorf("failed to encode record %s: %w", r.ID, err)
+		}
+		if err := enc.Encode(r); err != nil {
+			return fmt.Errorf("failed to encode record %s: %w", r.ID, err)
+		}
+	}
+
+	var resp struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			ID    string          `json:"_id"`
+			Error json.RawMessage `json:"error"`
+		} `json:"items"`
+	}
+	done := c.storeOpts.Time(store.StageStore)
+	// refreshed so what was just written is counted, queried and deleted
+	_, err := c.client.do(ctx, http.MethodPost, "_bulk?refresh=wait_for", body.Bytes(), &resp)
+	done()
+	if err != nil {
+		return fmt.Errorf("failed to index records: %w", err)
+	}
+	if resp.Errors {
+		for _, item := range resp.Items {
+			for _, op := range item {
+				if len(op.Error) > 0 {
+					return fmt.Errorf("failed to index record %s: %s", op.ID, op.Error)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// searchHit is a document returned by a search, its score being the
+// similarity of kNN queries.
+type searchHit struct {
+	ID     string       `json:"_id"`
+	Score  json.Number  `json:"_score"`
+	Source store.Record `json:"_source"`
+	Sort   []any        `json:"sort"`
+}
+
+type searchHits struct {
+	Hits struct {
+		Hits []searchHit `json:"hits"`
+	} `json:"hits"`
+}
+
+// search runs a search request on the index and returns its hits.
+func (c *searchIndex) search(ctx context.Context, req map[string]any) ([]searchHit, error) {
+	var resp searchHits
+	if _, err := c.client.do(ctx, http.MethodPost, c.path("_search"), req, &resp); err != nil {
+		return nil, err
+	}
+
+	return resp.Hits.Hits, nil
+}
+
+// GetRecords reads the documents of ids, skipping the missing ones.
+func (c *searchIndex) GetRecords(ctx context.Context, ids ...string) ([]store.Record, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	var resp struct {
+		Docs []struct {
+			Found  bool         `json:"found"`
+			Source store.Record `json:"_source"`
+		} `json:"docs"`
+	}
+	if _, err := c.client.do(ctx, http.MethodPost, c.path("_mget"), map[string]any{"ids": ids}, &resp); err != nil {
+		return nil, fmt.Errorf("failed to get documents: %w", err)
+	}
+
+	var records []store.Record
+	for _, d := range resp.Docs {
+		if d.Found {
+			records = append(records, d.Source)
+		}
+	}
+
+	return records, nil
+}
+
+// searchFilter converts the filters to a bool query, nil without filters.
+func searchFilter(where []store.Filter) map[string]any {
+	if len(where) == 0 {
+		return nil
+	}
+
+	clauses := make([]any, len(where))
+	for i, f := range where {
+		clauses[i] = map[string]any{"terms": map[string]any{"metadata." + f.Key: f.Values}}
+	}
+
+	return map[string]any{"bool": map[string]any{"filter": clauses}}
+}
+
+// knnRequest returns the kNN search of the k documents nearest to vector
+// matching filter.
+func (c *searchIndex) knnRequest(vector []float32, k int, filter map[string]any, embeddings bool) map[string]any {
+	req := map[string]any{"size": k}
+	if !embeddings {
+		req["_source"] = map[string]any{"excludes": []string{"embedding"}}
+	}
+
+	if c.client.opensearch {
+		knn := map[string]any{"vector": vector, "k": k}
+		if filter != nil {
+			knn["filter"] = filter
+		}
+		req["query"] = map[string]any{"knn": map[string]any{"embedding": knn}}
+		return req
+	}
+
+	// the candidates of each shard, at most 10000
+	candidates := min(max(k, c.client.hnsw.Ef, 100), 10000)
+	knn := map[string]any{"field": "embedding", "query_vector": vector, "k": k, "num_candidates": candidates}
+	if filter != nil {
+		knn["filter"] = filter
+	}
+	req["knn"] = knn
+	return req
+}
+
+// Query runs a kNN search per query text, with the filters applied by the
+// cluster while searching.
+func (c *searchIndex) Query(ctx context.Context, text string, opts ...store.Option) (store.Response, error) {
+	q := store.NewRequest(text, opts...)
+	if c.dim == 0 {
+		// nothing was added yet
+		return store.Response{Results: []store.Result{}}, nil
+	}
+
+	var negatives [][]float32
+	for _, t := range q.Negatives {
+		emb, err := c.ef.EmbedQuery(ctx, t)
+		if err != nil {
+			return store.Response{}, fmt.Errorf("%w: %w", store.ErrEmbed, err)
+		}
+		negatives = append(negatives, emb)
+	}
+
+	var (
+		groups [][]store.Result
+		first  []float32
+		filter = searchFilter(q.Where)
+	)
+	for _, t := range q.Texts() {
+		emb, err := c.ef.EmbedQuery(ctx, t)
+		if err != nil {
+			return store.Response{}, fmt.Errorf("%w: %w", store.ErrEmbed, err)
+		}
+		qe := emb
+		if first == nil {
+			first = qe
+		}
+
+		done := c.storeOpts.Time(store.StageStore)
+		hits, err := c.search(ctx, c.knnRequest(qe, q.Fetch(), filter, q.Embeddings()))
+		done()
+		if err != nil {
+			return store.Response{}, fmt.Errorf("failed to query collection: %w", err)
+		}
+
+		results := make([]store.Result, len(hits))
+		for i, h := range hits {
+			results[i] = c.result(h.Source)
+			// scores of L2 vectors are 1 / (1 + d²), d² being the distance
+			// of ChromaDB
+			if score, err := h.Score.Float64(); err == nil && score > 0 {
+				results[i].Distance = 1/score - 1
+			}
+			if q.Embeddings() {
+				results[i].Embedding = h.Source.Embedding
+			}
+		}
+		groups = append(groups, results)
+	}
+
+	groups, err := store.ResolveSummaries(ctx, c, q.InTarget(groups))
+	if err != nil {
+		return store.Response{}, err
+	}
+	results, err := q.Finish(ctx, q.Penalize(store.Fuse(groups), negatives))
+	if err != nil {
+		return store.Response{}, err
+	}
+
+	resp := store.Response{Results: results}
+	if q.IncludeEmbeddings {
+		resp.Embedding = first
+	}
+
+	return resp, nil
+}
+
+// KeywordSearch matches the terms against the analyzed document field,
+// ranking the matches as the other stores do.
+func (c *searchIndex) KeywordSearch(ctx context.Context, terms []string, n int) ([]store.Result, error) {
+	terms = slices.DeleteFunc(slices.Clone(terms), func(t string) bool { return t == "" })
+	if len(terms) == 0 {
+		return []store.Result{}, nil
+	}
+
+	hits, err := c.search(ctx, map[string]any{
+		"size":    max(n*10, 100),
+		"_source": map[string]any{"excludes": []string{"embedding"}},
+		"query":   map[string]any{"match": map[string]any{"document": strings.Join(terms, " ")}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search collection: %w", err)
+	}
+
+	var results []store.Result
+	for _, h := range hits {
+		result := c.result(h.Source)
+		if result.SummaryOf != "" {
+			continue
+		}
+		result.Score = store.KeywordScore(result.Content, terms)
+		results = append(results, result)
+	}
+	slices.SortStableFunc(results, func(a, b store.Result) int {
+		return cmp.Compare(b.Score, a.Score)
+	})
+
+	return results[:min(n, len(results))], nil
+}
+
+func (c *searchIndex) Get(ctx context.Context, ids ...string) ([]store.Result, error) {
+	records, err := c.GetRecords(ctx, ids...)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]store.Result, len(records))
+	for i, r := range records {
+		results[i] = c.result(r)
+	}
+
+	return results, nil
+}
+
+func (c *searchIndex) Count(ctx context.Context) (int, error) {
+	var resp struct {
+		Count int `json:"count"`
+	}
+	if _, err := c.client.do(ctx, http.MethodGet, c.path("_count"), nil, &resp); err != nil {
+		return 0, fmt.Errorf("failed to count documents: %w", err)
+	}
+
+	return resp.Count, nil
+}
+
+// deleteMatching deletes the documents matching query and returns how many
+// were deleted.
+func (c *searchIndex) deleteMatching(ctx context.Context, query map[string]any) (int, error) {
+	var resp struct {
+		Deleted int `json:"deleted"`
+	}
+	if _, err := c.client.do(ctx, http.MethodPost, c.path("_delete_by_query?refresh=true&conflicts=proceed"), map[string]any{"query": query}, &resp); err != nil {
+		return 0, fmt.Errorf("failed to delete documents: %w", err)
+	}
+
+	return resp.Deleted, nil
+}
+
+func (c *searchIndex) DeleteIndexedBefore(ctx context.Context, t time.Time) (int, error) {
+	return c.deleteMatching(ctx, map[string]any{"range": map[string]any{"metadata." + index.IndexedAtKey: map[string]any{"lt": t.Unix()}}})
+}
+
+func (c *searchIndex) DeleteRun(ctx context.Context, id string) (int, error) {
+	return c.deleteMatching(ctx, searchFilter([]store.Filter{{Key: index.RunKey, Values: []string{id}}}))
+}
+
+func (c *searchIndex) DeletePaths(ctx context.Context, rootID string, paths []string) (int, error) {
+	deleted := 0
+	for batch := range slices.Chunk(paths, deleteBatch) {
+		n, err := c.deleteMatching(ctx, searchFilter([]store.Filter{
+			{Key: index.RootKey, Values: []string{rootID}},
+			{Key: "path", Values: batch},
+		}))
+		deleted += n
+		if err != nil {
+			return deleted, err
+		}
+	}
+
+	return deleted, nil
+}
+
+func (c *searchIndex) Delete(ctx context.Context, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	_, err := c.deleteMatching(ctx, map[string]any{"ids": map[string]any{"values": ids}})
+	return err
+}
+
+func (c *searchIndex) Metadata() map[string]any {
+	return c.metadata
+}
+
+// Records pages through the index sorted by ID with search_after.
+func (c *searchIndex) Records(ctx context.Context) iter.Seq2[store.Record, error] {
+	return func(yield func(store.Record, error) bool) {
+		var after []any
+		for {
+			req := map[string]any{
+				"size":  pageSize,
+				"sort":  []any{map[string]any{"id": "asc"}},
+				"query": map[string]any{"match_all": map[string]any{}},
+			}
+			if after != nil {
+				req["search_after"] = after
+			}
+
+			hits, err := c.search(ctx, req)
+			if err != nil {
+				yield(store.Record{}, fmt.Errorf("failed to read documents: %w", err))
+				return
+			}
+			for _, h := range hits {
+				if !yield(h.Source, nil) {
+					return
+				}
+				after = h.Sort
+			}
+			if len(hits) < pageSize {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,450 @@
+package store
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Request describes a query to a Collection. Query features are added here
+// as options rather than as new Collection methods.
+type Request struct {
+	Text string
+	// Alternatives are other phrasings of Text, run in the same query and
+	// merged with rank fusion.
+	Alternatives []string
+	N            int
+	// Where keeps the documents matching every filter.
+	Where []Filter
+	// Negatives are phrases results should not be about. Results similar to
+	// one are pushed down by up to NegativeWeight times the distance between
+	// opposite embeddings.
+	Negatives      []string
+	NegativeWeight float64
+	// IncludeEmbeddings returns the embedding of the query and of every
+	// result.
+	IncludeEmbeddings bool
+	// MaxDistance drops the results further than it, zero keeps them all.
+	MaxDistance float64
+	// Reranker, when set, reorders Candidates results and keeps the best N.
+	Reranker   Reranker
+	Candidates int
+	// Target is the space searched: chunks, their descriptions or both.
+	Target string
+}
+
+// Search targets, the code of chunks or their LLM descriptions, embedded
+// with index -summarize.
+const (
+	TargetBoth = "both"
+	TargetCode = "code"
+	TargetDesc = "desc"
+)
+
+// VectorKey tags the description documents as the TargetDesc space.
+const VectorKey = "vector"
+
+// Filter matches documents whose metadata Key holds one of Values.
+type Filter struct {
+	Key    string
+	Values []string
+}
+
+// Response holds the results of a query, best first.
+type Response struct {
+	Results []Result
+	// Embedding is the query embedding, set with IncludeEmbeddings.
+	Embedding []float32
+}
+
+type Result struct {
+	ID string
+	// Collection is the collection of the result, set by queries over
+	// several collections.
+	Collection string
+	FileName   string
+	// Path is the local path of the document, and RelPath the path it was
+	// stored under, relative to the indexed root.
+	Path     string
+	RelPath  string
+	Content  string
+	Distance float64
+	Score    float64
+	// License is the SPDX id of the license detected at index time.
+	License string
+	// Language tags code fences, it is empty for unknown languages.
+	Language string
+	Lines    int
+	ModTime  time.Time
+	// StartLine and EndLine are the lines of the file the chunk spans, zero
+	// for documents indexed without them. Line is the line best matching
+	// the query.
+	StartLine, EndLine int
+	Line               int
+	// Section is the heading breadcrumb of markdown chunks, and Title the
+	// title of their frontmatter.
+	Section string
+	Title   string
+	// Record is the row of dataset records, from 1.
+	Record int
+	// Duplicates are the paths of the files with the same content, which
+	// were not indexed themselves.
+	Duplicates []string
+	// Author wrote most of the chunk and Commit last changed it, for
+	// collections indexed with git blame.
+	Author      string
+	AuthorEmail string
+	Commit      string
+	// Callers are call sites of the functions the chunk defines, listed
+	// with QueryOptions.Callers.
+	Callers []Symbol
+	// SummaryOf is the chunk a summary document summarizes.
+	SummaryOf string
+	// Embedding is only populated with WithIncludeEmbeddings.
+	Embedding []float32
+	// Missing is set when Path no longer exists on disk.
+	Missing bool
+	// Unresolved is set when the root of the document is unknown on this
+	// machine, Path is then relative to it.
+	Unresolved bool
+}
+
+// Symbol is a definition found in an indexed file.
+type Symbol struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+	// Path is the path of the file relative to the root Root identifies,
+	// and ID the document holding the definition.
+	Path string `json:"path"`
+	Root string `json:"root,omitempty"`
+	Line int    `json:"line"`
+	ID   string `json:"id"`
+}
+
+type Option func(*Request)
+
+// WithN sets how many results to return.
+func WithN(n int) Option {
+	return func(q *Request) {
+		q.N = n
+	}
+}
+
+// WithAlternatives adds other phrasings of the query.
+func WithAlternatives(texts ...string) Option {
+	return func(q *Request) {
+		q.Alternatives = append(q.Alternatives, texts...)
+	}
+}
+
+// WithNegatives pushes down the results similar to any of phrases.
+func WithNegatives(weight float64, phrases ...string) Option {
+	return func(q *Request) {
+		q.Negatives = append(q.Negatives, phrases...)
+		q.NegativeWeight = weight
+	}
+}
+
+// WithWhere keeps the documents whose metadata key holds one of values.
+func WithWhere(key string, values ...string) Option {
+	return func(q *Request) {
+		q.Where = append(q.Where, Filter{Key: key, Values: values})
+	}
+}
+
+// WithIncludeEmbeddings returns the query and result embeddings.
+func WithIncludeEmbeddings() Option {
+	return func(q *Request) {
+		q.IncludeEmbeddings = true
+	}
+}
+
+// WithMaxDistance drops the results further than d from the query. The
+// tightest of several cutoffs applies.
+func WithMaxDistance(d float64) Option {
+	return func(q *Request) {
+		if q.MaxDistance == 0 || d < q.MaxDistance {
+			q.MaxDistance = d
+		}
+	}
+}
+
+// WithReranker reorders the best candidates results with r.
+func WithReranker(r Reranker, candidates int) Option {
+	return func(q *Request) {
+		q.Reranker, q.Candidates = r, candidates
+	}
+}
+
+// WithTarget searches the chunks, their descriptions or both.
+func WithTarget(target string) Option {
+	return func(q *Request) {
+		q.Target = target
+		if target == TargetDesc {
+			q.Where = append(q.Where, Filter{Key: VectorKey, Values: []string{TargetDesc}})
+		}
+	}
+}
+
+func NewRequest(text string, opts ...Option) Request {
+	q := Request{Text: text, N: 5, Target: TargetBoth}
+	for _, opt := range opts {
+		opt(&q)
+	}
+
+	return q
+}
+
+// Texts returns the query and its alternatives.
+func (q Request) Texts() []string {
+	return append([]string{q.Text}, q.Alternatives...)
+}
+
+// Fetch is the number of results to get from the store.
+func (q Request) Fetch() int {
+	n := q.N
+	if len(q.Negatives) > 0 {
+		// leave room for the results pushed down to be replaced
+		n *= 3
+	}
+	if q.Target == TargetCode {
+		// descriptions are dropped after the query
+		n *= 2
+	}
+	if q.Reranker != nil {
+		return max(n, q.Candidates)
+	}
+
+	return n
+}
+
+// InTarget drops the descriptions from groups when only code is searched.
+func (q Request) InTarget(groups [][]Result) [][]Result {
+	if q.Target != TargetCode {
+		return groups
+	}
+
+	for i, g := range groups {
+		groups[i] = slices.DeleteFunc(g, func(r Result) bool { return r.SummaryOf != "" })
+	}
+
+	return groups
+}
+
+// Embeddings reports whether the store must return result embeddings.
+func (q Request) Embeddings() bool {
+	return q.IncludeEmbeddings || len(q.Negatives) > 0
+}
+
+// Penalize adds to the distance of results their similarity to the closest
+// negative embedding and sorts them again. Squared L2 distances between unit
+// vectors range from 0 to 4, hence the scale.
+func (q Request) Penalize(results []Result, negatives [][]float32) []Result {
+	if len(negatives) == 0 {
+		return results
+	}
+
+	for i, r := range results {
+		closest := 0.0
+		for _, neg := range negatives {
+			if len(neg) == len(r.Embedding) {
+				closest = max(closest, Cosine(neg, r.Embedding))
+			}
+		}
+		results[i].Distance += q.NegativeWeight * 4 * closest
+		if !q.IncludeEmbeddings {
+			results[i].Embedding = nil
+		}
+	}
+
+	slices.SortStableFunc(results, func(a, b Result) int {
+		return cmp.Compare(a.Distance, b.Distance)
+	})
+
+	return results
+}
+
+// Matches reports whether the metadata md passes the filters, for stores
+// that cannot filter themselves.
+func (q Request) Matches(md map[string]any) bool {
+	for _, f := range q.Where {
+		v, ok := md[f.Key].(string)
+		if !ok || !slices.Contains(f.Values, v) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Finish applies the stages common to every store to the results fetched
+// for q.
+func (q Request) Finish(ctx context.Context, results []Result) ([]Result, error) {
+	if q.MaxDistance > 0 {
+		results = slices.DeleteFunc(results, func(r Result) bool { return r.Distance > q.MaxDistance })
+	}
+
+	if q.Reranker != nil {
+		reranked, err := Rerank(ctx, q.Reranker, q.Text, results, q.N)
+		if err != nil {
+			return nil, err
+		}
+		results = reranked
+	}
+
+	return results[:min(q.N, len(results))], nil
+}
+
+// rrfK dampens the weight of the first ranks in reciprocal rank fusion, 60
+// being the value of the original paper.
+const rrfK = 60
+
+// Fuse merges the result groups of several phrasings of a query with
+// reciprocal rank fusion, so documents ranked well by many phrasings come
+// first. Merged results keep their smallest distance.
+func Fuse(groups [][]Result) []Result {
+	if len(groups) == 1 {
+		return groups[0]
+	}
+
+	var (
+		fused  []Result
+		scores = map[string]float64{}
+		index  = map[string]int{}
+	)
+	for _, group := range groups {
+		for rank, r := range group {
+			scores[r.ID] += 1 / float64(rrfK+rank+1)
+
+			i, ok := index[r.ID]
+			if !ok {
+				index[r.ID] = len(fused)
+				fused = append(fused, r)
+				continue
+			}
+			if r.Distance < fused[i].Distance {
+				fused[i].Distance = r.Distance
+			}
+		}
+	}
+
+	slices.SortStableFunc(fused, func(a, b Result) int {
+		return cmp.Compare(scores[b.ID], scores[a.ID])
+	})
+
+	return fused
+}
+
+// ResolveSummaries replaces the summaries matched in each group by the chunk
+// of coll they summarize, keeping the chunk once at the best rank and
+// distance of it and its summary.
+func ResolveSummaries(ctx context.Context, coll Collection, groups [][]Result) ([][]Result, error) {
+	var ids []string
+	for _, g := range groups {
+		for _, r := range g {
+			if r.SummaryOf != "" {
+				ids = append(ids, r.SummaryOf)
+			}
+		}
+	}
+	if len(ids) == 0 {
+		return groups, nil
+	}
+
+	chunks, err := coll.Get(ctx, ids...)
+	if err != nil {
+		return nil, err
+	}
+	byID := map[string]Result{}
+	for _, r := range chunks {
+		byID[r.ID] = r
+	}
+
+	for g, results := range groups {
+		resolved := make([]Result, 0, len(results))
+		at := map[string]int{}
+		for _, r := range results {
+			if r.SummaryOf != "" {
+				chunk, ok := byID[r.SummaryOf]
+				if !ok {
+					continue // the chunk was deleted without its summary
+				}
+				chunk.Distance, chunk.Embedding = r.Distance, r.Embedding
+				r = chunk
+			}
+
+			if i, ok := at[r.ID]; ok {
+				resolved[i].Distance = min(resolved[i].Distance, r.Distance)
+				continue
+			}
+			at[r.ID] = len(resolved)
+			resolved = append(resolved, r)
+		}
+		groups[g] = resolved
+	}
+
+	return groups, nil
+}
+
+// KeywordScore counts occurrences of terms in content.
+func KeywordScore(content string, terms []string) float64 {
+	var score float64
+	for _, t := range terms {
+		score += float64(strings.Count(content, t))
+	}
+	return score
+}
+
+type Reranker interface {
+	// Rerank scores each document against the query. Higher is more relevant.
+	Rerank(ctx context.Context, query string, documents []string) ([]float64, error)
+}
+
+// Rerank reorders results by reranker score and keeps the best n.
+func Rerank(ctx context.Context, r Reranker, query string, results []Result, n int) ([]Result, error) {
+	docs := make([]string, len(results))
+	for i, res := range results {
+		docs[i] = res.Content
+	}
+
+	scores, err := r.Rerank(ctx, query, docs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to rerank results: %w", err)
+	}
+
+	reranked := slices.Clone(results)
+	for i := range reranked {
+		reranked[i].Score = scores[i]
+	}
+
+	slices.SortStableFunc(reranked, func(a, b Result) int {
+		return cmp.Compare(b.Score, a.Score)
+	})
+
+	return reranked[:min(n, len(reranked))], nil
+}
+
+// Cosine returns the cosine similarity of a and b, 0 when their lengths
+// differ.
+func Cosine(a, b []float32) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+
+	if na == 0 || nb == 0 {
+		return 0
+	}
+
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
@@ -0,0 +1,41 @@
+package store
+
+import (
+	"slices"
+	"testing"
+)
+
+func ids(results []Result) []string {
+	out := make([]string, len(results))
+	for i, r := range results {
+		out[i] = r.ID
+	}
+
+	return out
+}
+
+func TestFuse(t *testing.T) {
+	groups := [][]Result{
+		{{ID: "a", Distance: 0.5}, {ID: "b", Distance: 0.6}, {ID: "c", Distance: 0.7}},
+		{{ID: "b", Distance: 0.4}, {ID: "d", Distance: 0.5}},
+		{{ID: "b", Distance: 0.9}, {ID: "a", Distance: 0.3}},
+	}
+
+	fused := Fuse(groups)
+	if got, want := ids(fused), []string{"b", "a", "d", "c"}; !slices.Equal(got, want) {
+		t.Fatalf("Fuse order = %v, want %v", got, want)
+	}
+	for _, r := range fused {
+		if want := map[string]float64{"a": 0.3, "b": 0.4, "c": 0.7, "d": 0.5}[r.ID]; r.Distance != want {
+			t.Errorf("distance of %s = %v, want the smallest %v", r.ID, r.Distance, want)
+		}
+	}
+}
+
+func TestFuseSingleGroup(t *testing.T) {
+	group := []Result{{ID: "b"}, {ID: "a"}}
+
+	if got := ids(Fuse([][]Result{group})); !slices.Equal(got, []string{"b", "a"}) {
+		t.Errorf("Fuse of one group = %v, want it unchanged", got)
+	}
+}
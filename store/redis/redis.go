@@ -0,0 +1,773 @@
+// Package redis is the redis store, collections being RediSearch indexes
+// over hashes. Importing it registers the store.
+package redis
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"log/slog"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/karitham/cls/index"
+	"github.com/karitham/cls/store"
+)
+
+func init() {
+	store.Register("redis", newRedisClient)
+}
+
+var errUnsupported = errors.New("not supported by the redis store")
+
+// Keys of the redis store. Collections are a set of names, each with a hash
+// holding its metadata as JSON. Documents are hashes under the prefix of
+// their collection, holding their text, embedding and metadata as JSON, and
+// the metadata filtered on by queries and deletes as fields of their own,
+// indexed by a RediSearch index with an HNSW vector field. The index is
+// created with the first documents, once their dimension is known.
+const (
+	redisCollectionsKey = "cls:collections"
+	redisPageSize       = 1000
+)
+
+// copyPageSize is how many documents are copied per batch by
+// CopyCollection, and deleteBatch how many paths a delete matches at once.
+const (
+	copyPageSize = 500
+	deleteBatch  = 100
+)
+
+// redisFields are the metadata keys stored as hash fields of their own,
+// and the RediSearch types they are indexed as. Query filters on TAG fields
+// run in the KNN search.
+var redisFields = [][2]string{
+	{index.IndexedAtKey, "NUMERIC"},
+	{index.RunKey, "TAG"},
+	{index.RootKey, "TAG"},
+	{"path", "TAG"},
+	{index.LanguageKey, "TAG"},
+	{index.ExtensionKey, "TAG"},
+	{store.VectorKey, "TAG"},
+}
+
+func redisCollectionKey(name string) string { return "cls:collection:" + name }
+func redisDocPrefix(name string) string     { return "cls:doc:{" + name + "}:" }
+func redisIndex(name string) string         { return "cls:idx:" + name }
+
+// checkName rejects the names of collections whose documents' keys could
+// start with the prefix of another collection.
+func checkName(name string) error {
+	if strings.Contains(name, ":") {
+		return fmt.Errorf("redis collection names cannot contain ':', got %q", name)
+	}
+
+	return nil
+}
+
+type redisClient struct {
+	conn   *redisConn
+	opts   store.Options
+	ef     store.Embedder
+	logger *slog.Logger
+}
+
+func newRedisClient(opts store.Options, logger *slog.Logger) (store.Client, error) {
+	conn, err := dialRedis(context.Background(), opts.URL, opts.Timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	ef, err := opts.NewEmbedder()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &redisClient{conn: conn, opts: opts, ef: ef, logger: logger}, nil
+}
+
+func (c *redisClient) metadata(ctx context.Context, name string) (map[string]any, bool, error) {
+	reply, err := c.conn.do(ctx, "HGET", redisCollectionKey(name), "metadata")
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get collection: %w", err)
+	}
+	if reply == nil {
+		return nil, false, nil
+	}
+
+	md := map[string]any{}
+	if err := json.Unmarshal(reply.([]byte), &md); err != nil {
+		return nil, false, fmt.Errorf("invalid metadata of collection %s: %w", name, err)
+	}
+
+	return md, true, nil
+}
+
+func (c *redisClient) setMetadata(ctx context.Context, name string, md map[string]any) error {
+	data, err := json.Marshal(md)
+	if err != nil {
+		return fmt.Errorf("failed to encode collection metadata: %w", err)
+	}
+	if _, err := c.conn.do(ctx, "HSET", redisCollectionKey(name), "metadata", data); err != nil {
+		return fmt.Errorf("failed to update collection metadata: %w", err)
+	}
+	if _, err := c.conn.do(ctx, "SADD", redisCollectionsKey, name); err != nil {
+		return fmt.Errorf("failed to update collection metadata: %w", err)
+	}
+
+	return nil
+}
+
+func (c *redisClient) collection(name string, md map[string]any) *redisCollection {
+	return &redisCollection{conn: c.conn, storeOpts: c.opts, ef: c.ef, name: name, metadata: md, logger: c.logger}
+}
+
+func (c *redisClient) GetOrCreateCollection(ctx context.Context, name string) (store.Collection, error) {
+	md, ok, err := c.metadata(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		if err := checkName(name); err != nil {
+			return nil, fmt.Errorf("failed to create collection: %w", err)
+		}
+		md = map[string]any{
+			store.ManagedByKey:     "cls",
+			store.EmbedderKey:      store.DefaultEmbedder,
+			store.EmbedderModelKey: store.DefaultEmbedderModel,
+		}
+		if err := c.setMetadata(ctx, name, md); err != nil {
+			return nil, fmt.Errorf("failed to get/create collection: %w", err)
+		}
+	}
+
+	return c.collection(name, md), nil
+}
+
+func (c *redisClient) GetCollection(ctx context.Context, name string) (store.Collection, error) {
+	md, ok, err := c.metadata(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("failed to get collection: no collection %q", name)
+	}
+
+	return c.collection(name, md), nil
+}
+
+func (c *redisClient) DeleteCollection(ctx context.Context, name string) error {
+	if _, ok, err := c.metadata(ctx, name); err != nil || !ok {
+		return fmt.Errorf("failed to delete collection: no collection %q", name)
+	}
+
+	// dropping the index with DD deletes the documents it indexed
+	if _, err := c.conn.do(ctx, "FT.DROPINDEX", redisIndex(name), "DD"); err != nil && !isUnknownIndex(err) {
+		return fmt.Errorf("failed to delete collection: %w", err)
+	}
+	if _, err := c.conn.do(ctx, "DEL", redisCollectionKey(name)); err != nil {
+		return fmt.Errorf("failed to delete collection: %w", err)
+	}
+	if _, err := c.conn.do(ctx, "SREM", redisCollectionsKey, name); err != nil {
+		return fmt.Errorf("failed to delete collection: %w", err)
+	}
+
+	return nil
+}
+
+func (c *redisClient) ListCollections(ctx context.Context) ([]store.Info, error) {
+	reply, err := c.conn.do(ctx, "SMEMBERS", redisCollectionsKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list collections: %w", err)
+	}
+
+	names, _ := reply.([]any)
+	infos := make([]store.Info, 0, len(names))
+	for _, n := range names {
+		name := replyString(n)
+		md, _, err := c.metadata(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+
+		info := store.Info{Name: name, Metadata: md}
+		info.Managed = md[store.ManagedByKey] == "cls"
+		info.Protected, _ = md[store.ProtectedKey].(bool)
+		if info.Count, err = c.collection(name, md).Count(ctx); err != nil {
+			return nil, fmt.Errorf("failed to count collection %s: %w", name, err)
+		}
+		infos = append(infos, info)
+	}
+	slices.SortFunc(infos, func(a, b store.Info) int { return strings.Compare(a.Name, b.Name) })
+
+	return infos, nil
+}
+
+// RenameCollection is not supported, the documents of a collection being
+// found by the prefix of their keys.
+func (c *redisClient) RenameCollection(ctx context.Context, name, newName string) error {
+	return fmt.Errorf("failed to rename collection: %w, copy it instead", errUnsupported)
+}
+
+func (c *redisClient) CopyCollection(ctx context.Context, name, newName string) (int, error) {
+	md, ok, err := c.metadata(ctx, name)
+	if err != nil || !ok {
+		return 0, fmt.Errorf("failed to get collection: no collection %q", name)
+	}
+	if err := checkName(newName); err != nil {
+		return 0, fmt.Errorf("failed to create collection: %w", err)
+	}
+	if _, exists, _ := c.metadata(ctx, newName); exists {
+		return 0, fmt.Errorf("failed to create collection: %s already exists", newName)
+	}
+	if err := c.setMetadata(ctx, newName, md); err != nil {
+		return 0, fmt.Errorf("failed to create collection: %w", err)
+	}
+
+	src, dst := c.collection(name, md), c.collection(newName, md)
+	var (
+		batch  []store.Record
+		copied int
+	)
+	for r, err := range src.Records(ctx) {
+		if err != nil {
+			return copied, fmt.Errorf("failed to read documents: %w", err)
+		}
+		if batch = append(batch, r); len(batch) == copyPageSize {
+			if err := dst.AddRecords(ctx, batch); err != nil {
+				return copied, fmt.Errorf("failed to write documents: %w", err)
+			}
+			copied, batch = copied+len(batch), batch[:0]
+		}
+	}
+	if err := dst.AddRecords(ctx, batch); err != nil {
+		return copied, fmt.Errorf("failed to write documents: %w", err)
+	}
+
+	return copied + len(batch), nil
+}
+
+func (c *redisClient) SetProtected(ctx context.Context, name string, protected bool) error {
+	md, ok, err := c.metadata(ctx, name)
+	if err != nil || !ok {
+		return fmt.Errorf("failed to get collection: no collection %q", name)
+	}
+	md[store.ProtectedKey] = protected
+
+	return c.setMetadata(ctx, name, md)
+}
+
+func (c *redisClient) SetMetadata(ctx context.Context, name, key, value string) error {
+	md, ok, err := c.metadata(ctx, name)
+	if err != nil || !ok {
+		return fmt.Errorf("failed to get collection: no collection %q", name)
+	}
+	md[key] = value
+
+	return c.setMetadata(ctx, name, md)
+}
+
+func (c *redisClient) IsProtected(ctx context.Context, name string) (bool, error) {
+	md, ok, err := c.metadata(ctx, name)
+	if err != nil || !ok {
+		return false, fmt.Errorf("failed to get collection: no collection %q", name)
+	}
+
+	protected, _ := md[store.ProtectedKey].(bool)
+	return protected, nil
+}
+
+func (c *redisClient) NextVersion(ctx context.Context, name string) (string, store.Collection, error) {
+	return "", nil, fmt.Errorf("index -replace is %w", errUnsupported)
+}
+
+func (c *redisClient) SwapAlias(ctx context.Context, name, target string) error {
+	return fmt.Errorf("index -replace is %w", errUnsupported)
+}
+
+// Version returns the version of the Redis server.
+func (c *redisClient) Version(ctx context.Context) (string, error) {
+	reply, err := c.conn.do(ctx, "INFO", "server")
+	if err != nil {
+		return "", fmt.Errorf("failed to get Redis version: %w", err)
+	}
+
+	for line := range strings.Lines(replyString(reply)) {
+		if v, ok := strings.CutPrefix(strings.TrimSpace(line), "redis_version:"); ok {
+			return v, nil
+		}
+	}
+
+	return "", errors.New("failed to get Redis version: not in INFO")
+}
+
+func (c *redisClient) Close() error {
+	return c.conn.Close()
+}
+
+func isUnknownIndex(err error) bool {
+	var rerr redisError
+	return errors.As(err, &rerr) && strings.Contains(strings.ToLower(string(rerr)), "unknown index")
+}
+
+type redisCollection struct {
+	conn      *redisConn
+	storeOpts store.Options
+	ef        store.Embedder
+	name      string
+	metadata  map[string]any
+	logger    *slog.Logger
+}
+
+func (c *redisCollection) Writer() store.Writer {
+	return store.NewRecordWriter(c)
+}
+
+// result returns the query result of r.
+func (c *redisCollection) result(r store.Record) store.Result {
+	return index.Result(r, c.storeOpts.ResolvePath)
+}
+
+// ensureIndex creates the RediSearch index of the collection for vectors of
+// dim dimensions, compared by L2 as in ChromaDB, when it is missing.
+func (c *redisCollection) ensureIndex(ctx context.Context, dim int) error {
+	if _, err := c.conn.do(ctx, "FT.INFO", redisIndex(c.name)); err == nil || !isUnknownIndex(err) {
+		return err
+	}
+
+	args := []any{"FT.CREATE", redisIndex(c.name), "ON", "HASH", "PREFIX", 1, redisDocPrefix(c.name), "SCHEMA",
+		"document", "TEXT",
+		"embedding", "VECTOR", "HNSW", 6, "TYPE", "FLOAT32", "DIM", dim, "DISTANCE_METRIC", "L2",
+	}
+	for _, f := range redisFields {
+		args = append(args, f[0], f[1])
+		if f[1] == "TAG" {
+			args = append(args, "SEPARATOR", "|", "CASESENSITIVE")
+		}
+	}
+	if _, err := c.conn.do(ctx, args...); err != nil {
+		return fmt.Errorf("failed to create search index: %w", err)
+	}
+
+	return nil
+}
+
+// AddRecords writes records, embedding those without an embedding.
+func (c *redisCollection) AddRecords(ctx context.Context, records []store.Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	if err := c.storeOpts.EmbedMissing(ctx, c.ef, records); err != nil {
+		return err
+	}
+	if err := c.ensureIndex(ctx, len(records[0].Embedding)); err != nil {
+		return err
+	}
+
+	done := c.storeOpts.Time(store.StageStore)
+	defer done()
+	for _, r := range records {
+		md, err := json.Marshal(r.Metadata)
+		if err != nil {
+			return fmt.Errorf("invalid metadata for %s: %w", r.ID, err)
+		}
+		args := []any{"HSET", redisDocPrefix(c.name) + r.ID,
+			"id", r.ID,
+			"document", r.Document,
+			"embedding", encodeVector(r.Embedding),
+			"metadata", md,
+		}
+		for _, f := range redisFields {
+			if v, ok := r.Metadata[f[0]]; ok {
+				args = append(args, f[0], fmt.Sprint(v))
+			}
+		}
+		if _, err := c.conn.do(ctx, args...); err != nil {
+			return fmt.Errorf("failed to add records: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func encodeVector(v []float32) []byte {
+	b := make([]byte, 4*len(v))
+	for i, f := range v {
+		binary.LittleEndian.PutUint32(b[4*i:], math.Float32bits(f))
+	}
+
+	return b
+}
+
+func decodeVector(b []byte) []float32 {
+	v := make([]float32, len(b)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[4*i:]))
+	}
+
+	return v
+}
+
+// hashRecord reads a document hash.
+func hashRecord(h map[string][]byte) (store.Record, error) {
+	r := store.Record{ID: string(h["id"]), Document: string(h["document"])}
+	if b := h["embedding"]; len(b) > 0 {
+		r.Embedding = decodeVector(b)
+	}
+	if b := h["metadata"]; len(b) > 0 {
+		// numbers are kept as in snapshots, so integers stay integers
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.UseNumber()
+		if err := dec.Decode(&r.Metadata); err != nil {
+			return r, fmt.Errorf("invalid metadata for %s: %w", r.ID, err)
+		}
+	}
+
+	return r, nil
+}
+
+// GetRecords reads the documents of ids, skipping the missing ones.
+func (c *redisCollection) GetRecords(ctx context.Context, ids ...string) ([]store.Record, error) {
+	var records []store.Record
+	for _, id := range ids {
+		reply, err := c.conn.do(ctx, "HGETALL", redisDocPrefix(c.name)+id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get documents: %w", err)
+		}
+		if h := replyMap(reply); len(h) > 0 {
+			r, err := hashRecord(h)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get documents: %w", err)
+			}
+			records = append(records, r)
+		}
+	}
+
+	return records, nil
+}
+
+// search runs a RediSearch query, returning the document hashes of the
+// results in their order and the total number of matches.
+func (c *redisCollection) search(ctx context.Context, query string, args ...any) ([]map[string][]byte, int, error) {
+	reply, err := c.conn.do(ctx, append([]any{"FT.SEARCH", redisIndex(c.name), query}, args...)...)
+	if isUnknownIndex(err) {
+		// nothing was added yet
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// the total, then the key and fields of every result
+	items, _ := reply.([]any)
+	if len(items) == 0 {
+		return nil, 0, nil
+	}
+	total, _ := items[0].(int64)
+	var hashes []map[string][]byte
+	for i := 2; i < len(items); i += 2 {
+		hashes = append(hashes, replyMap(items[i]))
+	}
+
+	return hashes, int(total), nil
+}
+
+// Query runs a KNN search per query text, over the documents passing the
+// filters on indexed keys. Filters on other keys are applied to a larger
+// set of nearest documents.
+func (c *redisCollection) Query(ctx context.Context, text string, opts ...store.Option) (store.Response, error) {
+	q := store.NewRequest(text, opts...)
+
+	var negatives [][]float32
+	for _, t := range q.Negatives {
+		emb, err := c.ef.EmbedQuery(ctx, t)
+		if err != nil {
+			return store.Response{}, fmt.Errorf("%w: %w", store.ErrEmbed, err)
+		}
+		negatives = append(negatives, emb)
+	}
+
+	filter, exact := redisFilter(q.Where)
+	k := q.Fetch()
+	if !exact {
+		k *= 10
+	}
+
+	var (
+		groups [][]store.Result
+		first  []float32
+	)
+	for _, t := range q.Texts() {
+		emb, err := c.ef.EmbedQuery(ctx, t)
+		if err != nil {
+			return store.Response{}, fmt.Errorf("%w: %w", store.ErrEmbed, err)
+		}
+		qe := emb
+		if first == nil {
+			first = qe
+		}
+
+		done := c.storeOpts.Time(store.StageStore)
+		hashes, _, err := c.search(ctx, fmt.Sprintf("%s=>[KNN %d @embedding $vec AS distance]", filter, k),
+			"PARAMS", 2, "vec", encodeVector(qe),
+			"SORTBY", "distance", "LIMIT", 0, k, "DIALECT", 2)
+		done()
+		if err != nil {
+			return store.Response{}, fmt.Errorf("failed to query collection: %w", err)
+		}
+
+		results := []store.Result{}
+		for _, h := range hashes {
+			r, err := hashRecord(h)
+			if err != nil {
+				return store.Response{}, fmt.Errorf("failed to query collection: %w", err)
+			}
+			if !q.Matches(r.Metadata) {
+				continue
+			}
+			result := c.result(r)
+			result.Distance, _ = strconv.ParseFloat(string(h["distance"]), 64)
+			if q.Embeddings() {
+				result.Embedding = r.Embedding
+			}
+			results = append(results, result)
+		}
+		groups = append(groups, results[:min(q.Fetch(), len(results))])
+	}
+
+	groups, err := store.ResolveSummaries(ctx, c, q.InTarget(groups))
+	if err != nil {
+		return store.Response{}, err
+	}
+	results, err := q.Finish(ctx, q.Penalize(store.Fuse(groups), negatives))
+	if err != nil {
+		return store.Response{}, err
+	}
+
+	resp := store.Response{Results: results}
+	if q.IncludeEmbeddings {
+		resp.Embedding = first
+	}
+
+	return resp, nil
+}
+
+// redisFilter converts the filters on TAG fields to a RediSearch query, "*"
+// without any, and reports whether every filter was converted.
+func redisFilter(where []store.Filter) (string, bool) {
+	var clauses []string
+	exact := true
+	for _, f := range where {
+		tag := slices.Contains(redisFields, [2]string{f.Key, "TAG"})
+		if !tag || len(f.Values) == 0 {
+			exact = false
+			continue
+		}
+		escaped := make([]string, len(f.Values))
+		for i, v := range f.Values {
+			escaped[i] = redisEscape(v)
+		}
+		clauses = append(clauses, fmt.Sprintf("@%s:{%s}", f.Key, strings.Join(escaped, "|")))
+	}
+	if len(clauses) == 0 {
+		return "*", exact
+	}
+
+	return "(" + strings.Join(clauses, " ") + ")", exact
+}
+
+func (c *redisCollection) KeywordSearch(ctx context.Context, terms []string, n int) ([]store.Result, error) {
+	var words []string
+	for _, t := range terms {
+		if t = redisEscape(t); t != "" {
+			words = append(words, t)
+		}
+	}
+	if len(words) == 0 {
+		return []store.Result{}, nil
+	}
+
+	hashes, _, err := c.search(ctx, "@document:("+strings.Join(words, "|")+")", "LIMIT", 0, max(n*10, 100))
+	if err != nil {
+		return nil, fmt.Errorf("failed to search collection: %w", err)
+	}
+
+	var results []store.Result
+	for _, h := range hashes {
+		r, err := hashRecord(h)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search collection: %w", err)
+		}
+		result := c.result(r)
+		if result.SummaryOf != "" {
+			continue
+		}
+		result.Score = store.KeywordScore(result.Content, terms)
+		results = append(results, result)
+	}
+	slices.SortStableFunc(results, func(a, b store.Result) int {
+		return cmp.Compare(b.Score, a.Score)
+	})
+
+	return results[:min(n, len(results))], nil
+}
+
+// redisEscape escapes the punctuation RediSearch queries give a meaning to.
+func redisEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' || r == '_' || r > 127) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+// globEscape escapes the characters SCAN MATCH patterns give a meaning to.
+var globEscape = strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`, "]", `\]`)
+
+func (c *redisCollection) Get(ctx context.Context, ids ...string) ([]store.Result, error) {
+	records, err := c.GetRecords(ctx, ids...)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]store.Result, len(records))
+	for i, r := range records {
+		results[i] = c.result(r)
+	}
+
+	return results, nil
+}
+
+func (c *redisCollection) Count(ctx context.Context) (int, error) {
+	_, total, err := c.search(ctx, "*", "LIMIT", 0, 0)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count documents: %w", err)
+	}
+
+	return total, nil
+}
+
+// deleteMatching deletes the documents matching a RediSearch query, page by
+// page, and returns how many were deleted.
+func (c *redisCollection) deleteMatching(ctx context.Context, query string) (int, error) {
+	deleted := 0
+	for {
+		reply, err := c.conn.do(ctx, "FT.SEARCH", redisIndex(c.name), query, "NOCONTENT", "LIMIT", 0, redisPageSize, "DIALECT", 2)
+		if isUnknownIndex(err) {
+			return deleted, nil
+		}
+		if err != nil {
+			return deleted, fmt.Errorf("failed to delete documents: %w", err)
+		}
+
+		items, _ := reply.([]any)
+		if len(items) < 2 {
+			return deleted, nil
+		}
+		keys := append([]any{"DEL"}, items[1:]...)
+		if _, err := c.conn.do(ctx, keys...); err != nil {
+			return deleted, fmt.Errorf("failed to delete documents: %w", err)
+		}
+		deleted += len(items) - 1
+	}
+}
+
+func (c *redisCollection) DeleteIndexedBefore(ctx context.Context, t time.Time) (int, error) {
+	return c.deleteMatching(ctx, fmt.Sprintf("@%s:[-inf (%d]", index.IndexedAtKey, t.Unix()))
+}
+
+func (c *redisCollection) DeleteRun(ctx context.Context, id string) (int, error) {
+	return c.deleteMatching(ctx, fmt.Sprintf("@%s:{%s}", index.RunKey, redisEscape(id)))
+}
+
+func (c *redisCollection) DeletePaths(ctx context.Context, rootID string, paths []string) (int, error) {
+	deleted := 0
+	for batch := range slices.Chunk(paths, deleteBatch) {
+		escaped := make([]string, len(batch))
+		for i, p := range batch {
+			escaped[i] = redisEscape(p)
+		}
+		n, err := c.deleteMatching(ctx, fmt.Sprintf("@%s:{%s} @path:{%s}", index.RootKey, redisEscape(rootID), strings.Join(escaped, "|")))
+		deleted += n
+		if err != nil {
+			return deleted, err
+		}
+	}
+
+	return deleted, nil
+}
+
+func (c *redisCollection) Delete(ctx context.Context, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	keys := []any{"DEL"}
+	for _, id := range ids {
+		keys = append(keys, redisDocPrefix(c.name)+id)
+	}
+	if _, err := c.conn.do(ctx, keys...); err != nil {
+		return fmt.Errorf("failed to delete documents: %w", err)
+	}
+
+	return nil
+}
+
+func (c *redisCollection) Metadata() map[string]any {
+	return c.metadata
+}
+
+// Records scans the keys of the collection, which unlike searches is not
+// capped in how far it pages.
+func (c *redisCollection) Records(ctx context.Context) iter.Seq2[store.Record, error] {
+	return func(yield func(store.Record, error) bool) {
+		cursor := "0"
+		for {
+			reply, err := c.conn.do(ctx, "SCAN", cursor, "MATCH", globEscape.Replace(redisDocPrefix(c.name))+"*", "COUNT", redisPageSize)
+			if err != nil {
+				yield(store.Record{}, fmt.Errorf("failed to read documents: %w", err))
+				return
+			}
+			items, _ := reply.([]any)
+			if len(items) != 2 {
+				yield(store.Record{}, errors.New("failed to read documents: unexpected SCAN reply"))
+				return
+			}
+
+			keys, _ := items[1].([]any)
+			for _, key := range keys {
+				h, err := c.conn.do(ctx, "HGETALL", replyString(key))
+				if err != nil {
+					yield(store.Record{}, fmt.Errorf("failed to read documents: %w", err))
+					return
+				}
+				r, err := hashRecord(replyMap(h))
+				if err != nil {
+					yield(store.Record{}, fmt.Errorf("failed to read documents: %w", err))
+					return
+				}
+				if !yield(r, nil) {
+					return
+				}
+			}
+
+			if cursor = replyString(items[0]); cursor == "0" {
+				return
+			}
+		}
+	}
+}
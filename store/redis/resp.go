@@ -0,0 +1,263 @@
+package redis
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/karitham/cls/store"
+)
+
+// redisError is an error reply of the Redis server.
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// redisConn is a connection speaking enough of the RESP2 protocol for the
+// redis store, so no client library is needed. Commands are sent one at a
+// time. A connection left mid-reply by a failure is closed, and dialed again
+// by the next command.
+type redisConn struct {
+	url     *url.URL
+	timeout time.Duration
+
+	mu   sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+	w    *bufio.Writer
+}
+
+// dialRedis connects to the server of a redis:// or rediss:// URL,
+// authenticating with its user and password and selecting its database.
+// Commands whose context has no deadline time out after timeout, or
+// store.DefaultTimeout when it is not set.
+func dialRedis(ctx context.Context, rawURL string, timeout time.Duration) (*redisConn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "redis" && u.Scheme != "rediss") {
+		return nil, fmt.Errorf("redis store needs a redis:// or rediss:// URL, got %q", rawURL)
+	}
+
+	c := &redisConn{url: u, timeout: timeout}
+	if err := c.connect(ctx); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// connect dials the server and sets the connection up. It is called with
+// mu held, or before the connection is shared.
+func (c *redisConn) connect(ctx context.Context) error {
+	u := c.url
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	dialer := &net.Dialer{Timeout: cmp.Or(c.timeout, 10*time.Second)}
+	var (
+		conn net.Conn
+		err  error
+	)
+	if u.Scheme == "rediss" {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: u.Hostname()}}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+
+	c.conn, c.r, c.w = conn, bufio.NewReader(conn), bufio.NewWriter(conn)
+	if password, ok := u.User.Password(); ok {
+		args := []any{"AUTH", password}
+		if user := u.User.Username(); user != "" {
+			args = []any{"AUTH", user, password}
+		}
+		if _, err := c.roundTrip(ctx, args...); err != nil {
+			c.drop()
+			return fmt.Errorf("failed to authenticate to Redis: %w", err)
+		}
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		if _, err := c.roundTrip(ctx, "SELECT", db); err != nil {
+			c.drop()
+			return fmt.Errorf("failed to select Redis database %s: %w", db, err)
+		}
+	}
+
+	return nil
+}
+
+// drop closes the connection, for the next command to dial a new one.
+func (c *redisConn) drop() {
+	c.conn.Close()
+	c.conn, c.r, c.w = nil, nil, nil
+}
+
+// do sends a command and returns its reply: a string for simple strings, an
+// int64, a []byte or nil for bulk strings, or a []any for arrays.
+func (c *redisConn) do(ctx context.Context, args ...any) (any, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		if err := c.connect(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	reply, err := c.roundTrip(ctx, args...)
+	var rerr redisError
+	if err != nil && !errors.As(err, &rerr) {
+		// a partial write or read leaves the stream out of sync
+		c.drop()
+	}
+
+	return reply, err
+}
+
+// roundTrip writes a command and reads its reply.
+func (c *redisConn) roundTrip(ctx context.Context, args ...any) (any, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(cmp.Or(c.timeout, store.DefaultTimeout))
+	}
+	c.conn.SetDeadline(deadline)
+
+	fmt.Fprintf(c.w, "*%d\r\n", len(args))
+	for _, a := range args {
+		var b []byte
+		switch v := a.(type) {
+		case string:
+			b = []byte(v)
+		case []byte:
+			b = v
+		case int:
+			b = strconv.AppendInt(nil, int64(v), 10)
+		case int64:
+			b = strconv.AppendInt(nil, v, 10)
+		case float64:
+			b = strconv.AppendFloat(nil, v, 'g', -1, 64)
+		default:
+			b = []byte(fmt.Sprint(v))
+		}
+		fmt.Fprintf(c.w, "$%d\r\n", len(b))
+		c.w.Write(b)
+		c.w.WriteString("\r\n")
+	}
+	if err := c.w.Flush(); err != nil {
+		return nil, fmt.Errorf("failed to send Redis command: %w", err)
+	}
+
+	reply, err := c.read()
+	if err != nil {
+		var rerr redisError
+		if errors.As(err, &rerr) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to read Redis reply: %w", err)
+	}
+
+	return reply, nil
+}
+
+func (c *redisConn) read() (any, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("empty reply")
+	}
+
+	switch kind, rest := line[0], line[1:]; kind {
+	case '+':
+		return rest, nil
+	case '-':
+		return nil, redisError(rest)
+	case ':':
+		return strconv.ParseInt(rest, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(rest)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		b := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, b); err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	case '*':
+		n, err := strconv.Atoi(rest)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		items := make([]any, n)
+		// errors of nested replies are returned once the array is read, to
+		// keep the stream in sync
+		var first error
+		for i := range items {
+			items[i], err = c.read()
+			var rerr redisError
+			if err != nil && !errors.As(err, &rerr) {
+				return nil, err
+			}
+			if err != nil && first == nil {
+				first = err
+			}
+		}
+		return items, first
+	default:
+		return nil, fmt.Errorf("unexpected reply %q", line)
+	}
+}
+
+func (c *redisConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		return nil
+	}
+	return c.conn.Close()
+}
+
+// replyString returns the text of a string reply.
+func replyString(v any) string {
+	switch s := v.(type) {
+	case []byte:
+		return string(s)
+	case string:
+		return s
+	case int64:
+		return strconv.FormatInt(s, 10)
+	}
+
+	return ""
+}
+
+// replyMap returns the field value pairs of a flat array reply, as returned
+// by HGETALL.
+func replyMap(v any) map[string][]byte {
+	items, _ := v.([]any)
+	m := make(map[string][]byte, len(items)/2)
+	for i := 0; i+1 < len(items); i += 2 {
+		b, _ := items[i+1].([]byte)
+		m[replyString(items[i])] = b
+	}
+
+	return m
+}
@@ -0,0 +1,318 @@
+// Package store defines the vector stores collections are kept in, and the
+// registry backends add themselves to so they can be selected by name.
+// Backends register when their package is imported, as in
+//
+//	import _ "github.com/karitham/cls/store/chromadb"
+//
+// for the default store.
+package store
+
+import (
+	"cmp"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"iter"
+	"log/slog"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Default is the store used when Options.Store is empty, a ChromaDB server.
+const Default = "chroma"
+
+// Options configures the connection to a store.
+type Options struct {
+	URL string
+	// Token is sent in TokenHeader, as a bearer token for Authorization.
+	Token       string
+	TokenHeader string
+	// Username and Password enable basic auth.
+	Username string
+	Password string
+	// CACert is a PEM file of an additional trusted CA.
+	CACert   string
+	Insecure bool
+	Timeout  time.Duration
+	// Tenant and Database default to the server defaults when empty.
+	Tenant   string
+	Database string
+	// Store is the name of the backend spoken to at URL, Default when empty.
+	Store string
+	// HNSW configures the vector index of the collections created by the
+	// stores that build one, zero fields meaning the store default.
+	HNSW HNSWOptions
+	// Embedder embeds documents and queries, the default Ollama embedder
+	// when nil.
+	Embedder Embedder
+	// Profiler, when set, times the calls to the store and the embedder.
+	Profiler Profiler
+	// ResolvePath returns the local path of rel, stored relative to the root
+	// identified by rootID, and false when the root is unknown here. Paths of
+	// results are left relative when nil.
+	ResolvePath func(rootID, rel string) (string, bool)
+}
+
+// Metadata keys of the collections cls creates: the mark of collections it
+// manages, the protection from destructive commands, and the name a version
+// built by index -replace is queried under.
+const (
+	ManagedByKey = "created_by"
+	ProtectedKey = "protected"
+	AliasKey     = "alias"
+)
+
+// Stages timed through Options.Profiler: the embedding of documents and
+// queries, and the calls to the store, which include the embedding of
+// documents for stores embedding them themselves.
+const (
+	StageEmbed = "embed"
+	StageStore = "store"
+)
+
+// Profiler times the stages of a run. Time starts timing a call to stage
+// and returns the function ending it.
+type Profiler interface {
+	Time(stage string) func()
+}
+
+// Time times a call to stage with the profiler, if any.
+func (o Options) Time(stage string) func() {
+	if o.Profiler == nil {
+		return func() {}
+	}
+
+	return o.Profiler.Time(stage)
+}
+
+// DefaultTimeout bounds the requests to the stores spoken to over HTTP, and
+// the commands of the redis store, when Options.Timeout is not set, so a
+// hung server fails the command rather than blocking it.
+const DefaultTimeout = 2 * time.Minute
+
+// HTTPClient returns the client of the stores spoken to over HTTP, trusting
+// the CA and bounded by the timeout of o.
+func (o Options) HTTPClient() (*http.Client, error) {
+	client := &http.Client{Timeout: cmp.Or(o.Timeout, DefaultTimeout)}
+	if o.CACert == "" && !o.Insecure {
+		return client, nil
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: o.Insecure}
+	if o.CACert != "" {
+		pem, err := os.ReadFile(o.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no certificate found in the CA certificate file")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	client.Transport = transport
+
+	return client, nil
+}
+
+// HNSWOptions are the parameters of an HNSW vector index.
+type HNSWOptions struct {
+	// M is the maximum number of edges per node of the graph, and
+	// EfConstruction the size of the candidate list while building it.
+	M              int
+	EfConstruction int
+	// Ef is the size of the candidate list while searching, raised to the
+	// number of results fetched.
+	Ef int
+}
+
+// Factory connects to a store with the connection options, the store being
+// at opts.URL.
+type Factory func(opts Options, logger *slog.Logger) (Client, error)
+
+var factories = map[string]Factory{}
+
+// Register makes the store built by factory selectable by name. It is meant
+// to be called from init functions, so a backend is added by a file of its
+// own, and panics when name is already registered.
+func Register(name string, factory Factory) {
+	if name == "" || factory == nil {
+		panic("store: Register needs a name and a factory")
+	}
+	if _, ok := factories[name]; ok {
+		panic("store: " + name + " registered twice")
+	}
+
+	factories[name] = factory
+}
+
+// Names lists the registered stores, in order.
+func Names() []string {
+	return slices.Sorted(maps.Keys(factories))
+}
+
+// New connects to the store selected by opts.Store.
+func New(opts Options, logger *slog.Logger) (Client, error) {
+	name := cmp.Or(opts.Store, Default)
+	factory, ok := factories[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown store %q, expected one of %s", name, strings.Join(Names(), ", "))
+	}
+
+	return factory(opts, logger)
+}
+
+// Info describes a collection of a store.
+type Info struct {
+	Name      string
+	Count     int
+	Managed   bool
+	Protected bool
+	// Alias is the name the collection is queried under, for versions built
+	// by index -replace.
+	Alias    string
+	Metadata map[string]any
+}
+
+// Record is a single document with its embedding and metadata, the unit of
+// snapshots.
+type Record struct {
+	ID        string         `json:"id"`
+	Document  string         `json:"document"`
+	Embedding []float32      `json:"embedding,omitempty"`
+	Metadata  map[string]any `json:"metadata,omitempty"`
+}
+
+type Client interface {
+	GetOrCreateCollection(ctx context.Context, name string) (Collection, error)
+	GetCollection(ctx context.Context, name string) (Collection, error)
+	DeleteCollection(ctx context.Context, name string) error
+	ListCollections(ctx context.Context) ([]Info, error)
+	RenameCollection(ctx context.Context, name, newName string) error
+	// CopyCollection duplicates every document of name, embeddings included,
+	// into a new collection newName and returns the number copied.
+	CopyCollection(ctx context.Context, name, newName string) (int, error)
+	// SetProtected marks a collection as protected from destructive commands.
+	SetProtected(ctx context.Context, name string, protected bool) error
+	IsProtected(ctx context.Context, name string) (bool, error)
+	// SetMetadata sets a string key of the collection metadata, keeping the
+	// other keys.
+	SetMetadata(ctx context.Context, name, key, value string) error
+	// NextVersion creates an empty collection to rebuild name in, and
+	// SwapAlias makes name resolve to it, deleting the collection it
+	// replaces.
+	NextVersion(ctx context.Context, name string) (string, Collection, error)
+	SwapAlias(ctx context.Context, name, target string) error
+	// Version returns the version of the server.
+	Version(ctx context.Context) (string, error)
+	Close() error
+}
+
+type Collection interface {
+	// Writer returns what documents are indexed through. It is nil for
+	// read-only collections.
+	Writer() Writer
+	Query(ctx context.Context, text string, opts ...Option) (Response, error)
+	// KeywordSearch returns up to n documents containing any of terms,
+	// ranked by how often the terms occur. It does not need the embedder.
+	KeywordSearch(ctx context.Context, terms []string, n int) ([]Result, error)
+	Get(ctx context.Context, ids ...string) ([]Result, error)
+	Count(ctx context.Context) (int, error)
+	// DeleteIndexedBefore removes the documents indexed before t and returns
+	// how many were removed, keeping those indexed without a time, and
+	// DeleteRun those last written by the index run id.
+	DeleteIndexedBefore(ctx context.Context, t time.Time) (int, error)
+	DeleteRun(ctx context.Context, id string) (int, error)
+	// DeletePaths removes the documents of the files at paths, relative to
+	// the root identified by rootID, and returns how many were removed.
+	DeletePaths(ctx context.Context, rootID string, paths []string) (int, error)
+	Metadata() map[string]any
+	// Records iterates over every document of the collection, embeddings
+	// included, fetching them page by page.
+	Records(ctx context.Context) iter.Seq2[Record, error]
+	// AddRecords upserts records, reusing their embeddings when present.
+	AddRecords(ctx context.Context, records []Record) error
+	Delete(ctx context.Context, ids ...string) error
+}
+
+// Writer is what the indexer writes documents through, on plain records so
+// backends need not know about each other.
+type Writer interface {
+	// Upsert adds records, replacing the documents with the same IDs, and
+	// embeds the records without an embedding.
+	Upsert(ctx context.Context, records []Record) error
+	// Update merges the metadata of records into that of the documents with
+	// their IDs, skipping the missing ones. Documents and embeddings are
+	// left as they are.
+	Update(ctx context.Context, records []Record) error
+	// DeletePaths removes the documents of the files at paths, relative to
+	// the root identified by rootID, and returns how many were removed.
+	DeletePaths(ctx context.Context, rootID string, paths []string) (int, error)
+}
+
+// RecordStore is a collection keeping records as they are given, such as a
+// store without server-side embedding.
+type RecordStore interface {
+	// AddRecords upserts records, embedding the ones without an embedding.
+	AddRecords(ctx context.Context, records []Record) error
+	// GetRecords reads the documents of ids, embeddings included, skipping
+	// the missing ones.
+	GetRecords(ctx context.Context, ids ...string) ([]Record, error)
+	DeletePaths(ctx context.Context, rootID string, paths []string) (int, error)
+}
+
+// NewRecordWriter returns the writer of s, updates being read, merged and
+// written back record by record.
+func NewRecordWriter(s RecordStore) Writer {
+	return recordWriter{s}
+}
+
+type recordWriter struct {
+	RecordStore
+}
+
+func (w recordWriter) Upsert(ctx context.Context, records []Record) error {
+	return w.AddRecords(ctx, records)
+}
+
+func (w recordWriter) Update(ctx context.Context, records []Record) error {
+	for _, u := range records {
+		found, err := w.GetRecords(ctx, u.ID)
+		if err != nil {
+			return err
+		}
+		if len(found) == 0 {
+			continue
+		}
+
+		r := found[0]
+		r.Metadata = MergeMetadata(r.Metadata, u.Metadata)
+		if err := w.AddRecords(ctx, []Record{r}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// MergeMetadata returns md with the keys of update set, md being updated in
+// place unless nil.
+func MergeMetadata(md, update map[string]any) map[string]any {
+	if md == nil {
+		md = make(map[string]any, len(update))
+	}
+	maps.Copy(md, update)
+
+	return md
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/karitham/cls/index"
+)
+
+const chunkSummaryPrompt = `Summarize in one short paragraph what the following excerpt of %s does or is about, naming its main functions, types or topics. Answer with the paragraph only.
+
+%s`
+
+// SummarizeOptions selects the model summarizing chunks at index time.
+type SummarizeOptions struct {
+	Model string
+	URL   string
+}
+
+// chatSummarizer summarizes chunks with an LLM.
+type chatSummarizer struct {
+	chat  ChatClient
+	model string
+}
+
+// newSummarizer returns the summarizer of opts, the model being served by
+// Ollama.
+func newSummarizer(opts SummarizeOptions) (index.Summarizer, error) {
+	chat, err := NewChatClient("ollama", opts.URL, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return chatSummarizer{chat: chat, model: opts.Model}, nil
+}
+
+// Summarize asks the model for a one paragraph summary of text, a chunk of
+// the file at path.
+func (s chatSummarizer) Summarize(ctx context.Context, path, text string) (string, error) {
+	summary, err := s.chat.Chat(ctx, []ChatMessage{
+		{Role: "user", Content: fmt.Sprintf(chunkSummaryPrompt, path, text)},
+	}, ChatOptions{Model: s.model, Temperature: 0.2})
+	if err != nil {
+		return "", fmt.Errorf("failed to summarize chunk: %w", err)
+	}
+
+	return strings.TrimSpace(summary), nil
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/karitham/cls/index"
+)
+
+// symbolLocation returns the local path:line of the definition s, or its
+// stored path when the root is unknown here.
+func symbolLocation(s Symbol) string {
+	path := s.Path
+	switch {
+	case strings.HasPrefix(s.Root, index.URLRootPrefix):
+		return index.PageURL(s.Root, s.Path)
+	case s.Root != "":
+		if local, ok := localPath(s.Root, s.Path); ok {
+			path = local
+		}
+	}
+
+	return fmt.Sprintf("%s:%d", path, s.Line)
+}
+
+// SymbolIndex holds the definitions of the files of a collection, kept next
+// to it in the state directory and updated as files are indexed.
+type SymbolIndex struct {
+	collection string
+	// Files maps root and relative path to the symbols of a file, and Calls
+	// to its call sites.
+	Files map[string][]Symbol `json:"files"`
+	Calls map[string][]Symbol `json:"calls,omitempty"`
+}
+
+func symbolsState(collection string) string {
+	return "symbols-" + collection + ".json"
+}
+
+func newSymbolIndex(collection string) *SymbolIndex {
+	return &SymbolIndex{collection: collection, Files: map[string][]Symbol{}, Calls: map[string][]Symbol{}}
+}
+
+// loadSymbols reads the symbol index of collection, empty when none exists.
+func loadSymbols(collection string) (*SymbolIndex, error) {
+	ix := newSymbolIndex(collection)
+	if err := readState(symbolsState(collection), ix); err != nil {
+		return nil, err
+	}
+	if ix.Files == nil {
+		ix.Files = map[string][]Symbol{}
+	}
+	if ix.Calls == nil {
+		ix.Calls = map[string][]Symbol{}
+	}
+
+	return ix, nil
+}
+
+// Save writes the index back to the state directory.
+func (ix *SymbolIndex) Save() error {
+	return writeState(symbolsState(ix.collection), ix)
+}
+
+// deleteSymbols removes the symbol index of collection.
+func deleteSymbols(collection string) error {
+	dir, err := stateDir()
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(filepath.Join(dir, symbolsState(collection)))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to delete symbol index: %w", err)
+	}
+
+	return nil
+}
+
+// Set replaces the symbols and call sites of the file stored under rel in
+// root.
+func (ix *SymbolIndex) Set(root, rel string, symbols, calls []Symbol) {
+	key := root + ":" + rel
+	delete(ix.Files, key)
+	delete(ix.Calls, key)
+	if len(symbols) > 0 {
+		ix.Files[key] = symbols
+	}
+	if len(calls) > 0 {
+		ix.Calls[key] = calls
+	}
+}
+
+// Lookup returns the symbols matching query, best first: exact names, then
+// names equal ignoring case, prefixes, substrings, and when fuzzy the names
+// holding the letters of query in order.
+func (ix *SymbolIndex) Lookup(query, kind string, fuzzy bool) []Symbol {
+	type match struct {
+		Symbol
+		rank int
+	}
+
+	var matches []match
+	for _, symbols := range ix.Files {
+		for _, s := range symbols {
+			if kind != "" && s.Kind != kind {
+				continue
+			}
+			if rank, ok := symbolRank(s.Name, query, fuzzy); ok {
+				matches = append(matches, match{s, rank})
+			}
+		}
+	}
+
+	slices.SortFunc(matches, func(a, b match) int {
+		return cmp.Or(
+			cmp.Compare(a.rank, b.rank),
+			cmp.Compare(len(a.Name), len(b.Name)),
+			strings.Compare(a.Path, b.Path),
+			cmp.Compare(a.Line, b.Line),
+		)
+	})
+
+	out := make([]Symbol, len(matches))
+	for i, m := range matches {
+		out[i] = m.Symbol
+	}
+
+	return out
+}
+
+func symbolRank(name, query string, fuzzy bool) (int, bool) {
+	lname, lquery := strings.ToLower(name), strings.ToLower(query)
+	switch {
+	case name == query:
+		return 0, true
+	case lname == lquery:
+		return 1, true
+	case !fuzzy:
+		return 0, false
+	case strings.HasPrefix(lname, lquery):
+		return 2, true
+	case strings.Contains(lname, lquery):
+		return 3, true
+	case isSubsequence(lquery, lname):
+		return 4, true
+	}
+
+	return 0, false
+}
+
+func isSubsequence(sub, s string) bool {
+	for _, r := range sub {
+		i := strings.IndexRune(s, r)
+		if i < 0 {
+			return false
+		}
+		s = s[i+len(string(r)):]
+	}
+
+	return true
+}
+
+// symbolsCommand prints the definitions of collection matching query.
+func symbolsCommand(collection, query, kind string, exact bool, n int, printer *Printer, logger *slog.Logger) {
+	ix, err := loadSymbols(collection)
+	if err != nil {
+		logger.Error("Failed to load symbol index", "error", err)
+		os.Exit(1)
+	}
+	if len(ix.Files) == 0 {
+		logger.Error("No symbols indexed for the collection, index it again to build them", "collection", collection)
+		os.Exit(1)
+	}
+
+	symbols := ix.Lookup(query, kind, !exact)
+	if n > 0 {
+		symbols = symbols[:min(n, len(symbols))]
+	}
+	if len(symbols) == 0 {
+		printer.Message("No symbols found")
+		os.Exit(exitNoMatch)
+	}
+
+	printer.Symbols(symbols)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// TreeLimits are the sizes above which indexing a tree asks for
+// confirmation first. Zero disables a limit.
+type TreeLimits struct {
+	Files int
+	Bytes int64
+}
+
+// TreeEstimate is the size of a tree, counted until a limit was exceeded.
+type TreeEstimate struct {
+	Files    int
+	Bytes    int64
+	Exceeded bool
+}
+
+var errTreeLimit = errors.New("tree limit exceeded")
+
+// estimateTree counts the files and bytes under root, skipping what the index
+// walk prunes. It stops as soon as a limit is exceeded, so estimating / or ~
+// stays quick.
+func estimateTree(root string, limits TreeLimits) (TreeEstimate, error) {
+	var est TreeEstimate
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// unreadable entries are reported by the index walk itself
+			return nil
+		}
+
+		if d.IsDir() {
+			if path != root && (strings.HasPrefix(d.Name(), ".") || d.Name() == "node_modules") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		est.Files++
+		if fi, err := d.Info(); err == nil {
+			est.Bytes += fi.Size()
+		}
+
+		if limits.Files > 0 && est.Files > limits.Files || limits.Bytes > 0 && est.Bytes > limits.Bytes {
+			est.Exceeded = true
+			return errTreeLimit
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errTreeLimit) {
+		return est, fmt.Errorf("failed to estimate tree size: %w", err)
+	}
+
+	return est, nil
+}
+
+// confirmTreeSize asks before indexing a tree larger than limits, returning
+// whether to go on. force skips the question.
+func confirmTreeSize(root string, limits TreeLimits, force bool, logger *slog.Logger) bool {
+	if force {
+		return true
+	}
+
+	est, err := estimateTree(root, limits)
+	if err != nil {
+		logger.Warn("Failed to estimate the size of the tree", "error", err)
+		return true
+	}
+	if !est.Exceeded {
+		return true
+	}
+
+	size := fmt.Sprintf("%d files", limits.Files)
+	if limits.Files == 0 || est.Files <= limits.Files {
+		size = formatBytes(limits.Bytes)
+	}
+
+	ok, err := confirm("%s holds more than %s, index it anyway?", root, size)
+	if err != nil {
+		logger.Error("Cannot confirm indexing a large tree", "error", err, "files", est.Files, "bytes", est.Bytes)
+		os.Exit(1)
+	}
+
+	return ok
+}
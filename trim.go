@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+)
+
+const summarizePrompt = `Summarize the following excerpt of %s in at most %d words, keeping only what helps answer the question: %s
+
+%s`
+
+// ContextTrim records how retrieved results were fit into the context window.
+type ContextTrim struct {
+	Kept       []QueryResult
+	Summarized []QueryResult
+	Dropped    []QueryResult
+}
+
+// trimContext fits results into budget tokens. Results packed by strategy
+// are kept verbatim. Of the rest, the better ranked half is summarized by chat
+// when summarize is set and the summaries fit, and everything else is dropped.
+func trimContext(ctx context.Context, chat ChatClient, chatOpts ChatOptions, question string, results []QueryResult, budget int, strategy string, summarize bool, logger *slog.Logger) (ContextTrim, error) {
+	var trim ContextTrim
+
+	budget -= estimateTokens(question)
+	kept, err := PackContext(results, budget, strategy)
+	if err != nil {
+		return trim, err
+	}
+	trim.Kept = kept
+	budget -= estimateTokens(formatContext(kept))
+
+	// kept results may be whole files standing for several chunks, so they
+	// are matched by path and lines rather than ID
+	rest := slices.DeleteFunc(slices.Clone(results), func(r QueryResult) bool {
+		return slices.ContainsFunc(kept, func(k QueryResult) bool {
+			return k.Path == r.Path && (k.StartLine == 0 || k.StartLine == r.StartLine)
+		})
+	})
+
+	mid := 0
+	if summarize {
+		mid = (len(rest) + 1) / 2
+	}
+
+	for i, r := range rest {
+		if i >= mid || budget <= 0 {
+			trim.Dropped = append(trim.Dropped, r)
+			continue
+		}
+
+		// leave room for the block header and the other summaries, in
+		// tokens, asking the model for about 3 words per 4 tokens
+		header := estimateTokens(contextBlock(len(trim.Kept)+len(trim.Summarized)+1, QueryResult{Path: r.Path, Language: "text"}))
+		tokens := budget/max(mid-i, 1) - header
+		words := tokens * 3 / 4
+		if words < 20 {
+			trim.Dropped = append(trim.Dropped, r)
+			continue
+		}
+
+		summary, err := chat.Chat(ctx, []ChatMessage{
+			{Role: "user", Content: fmt.Sprintf(summarizePrompt, r.Path, words, question, r.Content)},
+		}, chatOpts)
+		if err != nil {
+			logger.Warn("Failed to summarize result, dropping it", "path", r.Path, "error", err)
+			trim.Dropped = append(trim.Dropped, r)
+			continue
+		}
+
+		r.Content = "(summary) " + strings.TrimSpace(summary)
+		r.Language = "text"
+		cost := estimateTokens(contextBlock(len(trim.Kept)+len(trim.Summarized)+1, r))
+		if cost > budget {
+			trim.Dropped = append(trim.Dropped, r)
+			continue
+		}
+
+		budget -= cost
+		trim.Summarized = append(trim.Summarized, r)
+	}
+
+	return trim, nil
+}
+
+// Sources returns the results sent to the model, in block order.
+func (t ContextTrim) Sources() []QueryResult {
+	return slices.Concat(t.Kept, t.Summarized)
+}
+
+func (p *Printer) ContextTrim(t ContextTrim) {
+	if p.plain {
+		for _, r := range t.Summarized {
+			p.Message("summarized: %s", r.Path)
+		}
+		for _, r := range t.Dropped {
+			p.Message("dropped: %s", r.Path)
+		}
+		return
+	}
+
+	if len(t.Summarized) > 0 {
+		p.Message("Summarized to fit the context window:")
+		for _, r := range t.Summarized {
+			p.Message("  %s", r.Path)
+		}
+	}
+	if len(t.Dropped) > 0 {
+		p.Message("Dropped to fit the context window:")
+		for _, r := range t.Dropped {
+			p.Message("  %s (distance %.3f)", r.Path, r.Distance)
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/karitham/cls/store"
+	"github.com/karitham/cls/store/chromadb"
+)
+
+// serverStateFile records the ChromaDB container started by up, so down
+// finds it. Its URL is written to the user config file, as the default of
+// -url.
+const serverStateFile = "server.json"
+
+// upTimeout bounds the wait for a started ChromaDB to answer.
+const upTimeout = time.Minute
+
+// LocalServer is a ChromaDB container managed by up and down.
+type LocalServer struct {
+	URL       string `json:"url"`
+	Container string `json:"container"`
+	Image     string `json:"image"`
+	Volume    string `json:"volume"`
+}
+
+// UpOptions configures the container started by up.
+type UpOptions struct {
+	// Docker is the container CLI, docker or a compatible one like podman.
+	Docker    string
+	Image     string
+	Container string
+	// Volume persists the data of the container across down and up.
+	Volume string
+	Port   int
+	// PullModel pulls the embedding model into Ollama.
+	PullModel bool
+}
+
+// upCommand starts a ChromaDB container, or the one already created, waits
+// for it to answer and writes its URL to the user config file, as the
+// default of -url.
+func upCommand(opts UpOptions, printer *Printer, logger *slog.Logger) {
+	ctx := context.Background()
+
+	server := LocalServer{
+		URL:       "http://127.0.0.1:" + strconv.Itoa(opts.Port),
+		Container: opts.Container,
+		Image:     opts.Image,
+		Volume:    opts.Volume,
+	}
+
+	state, err := containerState(ctx, opts.Docker, opts.Container)
+	if err != nil {
+		logger.Error("Failed to run "+opts.Docker, "error", err)
+		os.Exit(1)
+	}
+	switch state {
+	case "":
+		logger.Info("Starting ChromaDB container", "image", opts.Image, "container", opts.Container, "port", opts.Port)
+		_, err = docker(ctx, opts.Docker, "run", "-d",
+			"--name", opts.Container,
+			"-p", fmt.Sprintf("127.0.0.1:%d:8000", opts.Port),
+			"-v", opts.Volume+":/data",
+			"--label", store.ManagedByKey+"=cls",
+			opts.Image)
+	case "running":
+		logger.Info("ChromaDB container already running", "container", opts.Container)
+	default:
+		logger.Info("Starting existing ChromaDB container", "container", opts.Container, "state", state)
+		_, err = docker(ctx, opts.Docker, "start", opts.Container)
+	}
+	if err != nil {
+		logger.Error("Failed to start ChromaDB container", "error", err)
+		os.Exit(1)
+	}
+	if state != "" {
+		// the port of an existing container is the one it was created with
+		if port, err := containerPort(ctx, opts.Docker, opts.Container); err == nil {
+			server.URL = "http://127.0.0.1:" + port
+		}
+	}
+
+	if err := waitForChroma(ctx, server.URL, logger); err != nil {
+		logger.Error("ChromaDB did not come up", "url", server.URL, "error", err, "logs", opts.Docker+" logs "+opts.Container)
+		os.Exit(1)
+	}
+	if err := writeState(serverStateFile, server); err != nil {
+		logger.Error("Failed to record server", "error", err)
+		os.Exit(1)
+	}
+	config, err := userConfigPath()
+	if err == nil {
+		err = writeConfigValue(config, "chroma", "url", server.URL)
+	}
+	if err != nil {
+		logger.Error("Failed to write config", "error", err)
+		os.Exit(1)
+	}
+
+	if opts.PullModel {
+		if err := pullModel(ctx, store.DefaultEmbedderURL, store.DefaultEmbedderModel, logger); err != nil {
+			logger.Error("Failed to pull embedding model", "model", store.DefaultEmbedderModel, "error", err)
+			os.Exit(1)
+		}
+	}
+
+	printer.Message("ChromaDB is up at %s, used by default from now on as set in %s", server.URL, config)
+}
+
+// downCommand stops the container started by up, removing it with remove
+// and its data too with purge, and removes its URL from the user config
+// file.
+func downCommand(dockerCLI string, remove, purge bool, printer *Printer, logger *slog.Logger) {
+	ctx := context.Background()
+
+	var server LocalServer
+	if err := readState(serverStateFile, &server); err != nil {
+		logger.Error("Failed to read server", "error", err)
+		os.Exit(1)
+	}
+	if server.Container == "" {
+		logger.Error("No ChromaDB container was started by cls up")
+		os.Exit(1)
+	}
+
+	if _, err := docker(ctx, dockerCLI, "stop", server.Container); err != nil {
+		logger.Error("Failed to stop ChromaDB container", "error", err)
+		os.Exit(1)
+	}
+	if remove || purge {
+		if _, err := docker(ctx, dockerCLI, "rm", server.Container); err != nil {
+			logger.Error("Failed to remove ChromaDB container", "error", err)
+			os.Exit(1)
+		}
+	}
+	if purge {
+		if _, err := docker(ctx, dockerCLI, "volume", "rm", server.Volume); err != nil {
+			logger.Error("Failed to remove ChromaDB volume", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	dir, err := stateDir()
+	if err == nil {
+		err = os.Remove(filepath.Join(dir, serverStateFile))
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		logger.Warn("Failed to forget server", "error", err)
+	}
+	// a URL set since then is left alone
+	config, err := userConfigPath()
+	if err == nil {
+		var url string
+		if url, err = readConfigValue(config, "chroma", "url"); err == nil && url == server.URL {
+			err = writeConfigValue(config, "chroma", "url", "")
+		}
+	}
+	if err != nil {
+		logger.Warn("Failed to remove server from config", "error", err)
+	}
+
+	printer.Message("ChromaDB container %s stopped", server.Container)
+}
+
+// docker runs the container CLI and returns its trimmed output, with its
+// error output in errors.
+func docker(ctx context.Context, cli string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, cli, args...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s %s: %w: %s", cli, args[0], err, msg)
+		}
+		return "", fmt.Errorf("%s %s: %w", cli, args[0], err)
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// containerState returns the state of container, such as running or
+// exited, or "" when it does not exist.
+func containerState(ctx context.Context, cli, container string) (string, error) {
+	out, err := docker(ctx, cli, "ps", "-a", "--filter", "name=^"+container+"$", "--format", "{{.State}}")
+	if err != nil {
+		return "", err
+	}
+
+	return out, nil
+}
+
+// containerPort returns the host port ChromaDB is published on.
+func containerPort(ctx context.Context, cli, container string) (string, error) {
+	out, err := docker(ctx, cli, "port", container, "8000/tcp")
+	if err != nil {
+		return "", err
+	}
+	line, _, _ := strings.Cut(out, "\n")
+	i := strings.LastIndex(line, ":")
+	if i < 0 {
+		return "", fmt.Errorf("unexpected port %q", line)
+	}
+
+	return line[i+1:], nil
+}
+
+// waitForChroma polls the ChromaDB at url until a client can be created,
+// which checks it answers.
+func waitForChroma(ctx context.Context, url string, logger *slog.Logger) error {
+	ctx, cancel := context.WithTimeout(ctx, upTimeout)
+	defer cancel()
+
+	for {
+		client, err := newStore(ChromaOptions{URL: url, Store: store.Default}, logger)
+		if err == nil {
+			return client.Close()
+		}
+		if !errors.Is(err, chromadb.ErrUnreachable) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
+
+// pullModel pulls model into the Ollama at baseURL unless it has it.
+func pullModel(ctx context.Context, baseURL, model string, logger *slog.Logger) error {
+	if _, err := ollamaModelDigest(ctx, baseURL, model); err == nil {
+		logger.Info("Embedding model already pulled", "model", model)
+		return nil
+	} else if !errors.Is(err, errModelNotPulled) {
+		return fmt.Errorf("%s is unreachable, is Ollama running? %w", baseURL, err)
+	}
+
+	logger.Info("Pulling embedding model", "model", model)
+	body, err := json.Marshal(map[string]any{"model": model, "stream": false})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, baseURL+"/api/pull", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var status struct {
+		Status string `json:"status"`
+		Error  string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	if status.Error != "" {
+		return errors.New(status.Error)
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return nil
+}
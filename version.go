@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/karitham/cls/buildinfo"
+)
+
+func (p *Printer) BuildInfo(info buildinfo.Info) {
+	var enabled, disabled []string
+	for name, on := range info.Features {
+		if on {
+			enabled = append(enabled, name)
+		} else {
+			disabled = append(disabled, name)
+		}
+	}
+	slices.Sort(enabled)
+	slices.Sort(disabled)
+
+	if p.plain {
+		p.Message("version %s", info.Version)
+		p.Message("commit %s", info.Commit)
+		p.Message("date %s", info.Date)
+		p.Message("go %s", info.GoVersion)
+		p.Message("platform %s", info.Platform)
+		for _, f := range enabled {
+			p.Message("feature %s enabled", f)
+		}
+		for _, f := range disabled {
+			p.Message("feature %s disabled", f)
+		}
+		return
+	}
+
+	p.Message("cls %s", info.Version)
+	p.Message("  commit:   %s", info.Commit)
+	p.Message("  built:    %s", info.Date)
+	p.Message("  go:       %s (%s)", info.GoVersion, info.Platform)
+	if len(enabled) > 0 {
+		p.Message("  features: %s", strings.Join(enabled, ", "))
+	}
+	if len(disabled) > 0 {
+		p.Message("  missing:  %s", strings.Join(disabled, ", "))
+	}
+}
+
+func showVersion(asJSON bool, printer *Printer) {
+	info := buildinfo.Get()
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(info)
+		return
+	}
+
+	printer.BuildInfo(info)
+}